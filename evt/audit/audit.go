@@ -0,0 +1,409 @@
+// Package audit keeps a persistent log of the events an [evtm.EventManager] dispatches, for
+// long-running emulation services whose operators must be able to reconstruct what the
+// simulator did during an incident long after it happened.
+//
+// The log is meant to be always on, so records are compact and binary, written through a
+// buffer that is flushed at least every FlushEvery, and spread over a series of files in one
+// directory, a new file being started when the current one grows past MaxBytes or has been
+// open for MaxAge, and the oldest removed once more than Keep files are held.  Files are
+// named by the instant they were started, so that their names sort in order of time.
+// ReadDir reads the records back across the files, in order, optionally restricted to a
+// window of real time.  Files may be compressed, as Options.Compressor selects; a reader
+// recognizes a compressed file for itself.
+package audit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iti/evt/compression"
+	"github.com/iti/evt/evtm"
+)
+
+// magic begins every audit file, identifying it and the version of its format
+const magic = "EVTAUDIT1\n"
+
+// suffix ends the name of every audit file
+const suffix = ".audit"
+
+// Entry records the dispatch of one event
+type Entry struct {
+	Wall    time.Time // real time of the dispatch
+	Ticks   int64     // tick count of the event's time
+	Pri     int64     // priority of the event's time
+	EventID int       // identifier of the event
+	Handler string    // name of the event handler
+	Class   string    // class of the event, if any
+}
+
+// String describes the entry
+func (ent Entry) String() string {
+	return fmt.Sprintf("%s t=%d pri=%d event=%d handler=%s class=%s",
+		ent.Wall.Format(time.RFC3339Nano), ent.Ticks, ent.Pri, ent.EventID, ent.Handler, ent.Class)
+}
+
+// Options configures a Log
+type Options struct {
+	Dir        string        // directory holding the files of the log
+	Prefix     string        // prefix of the names of the files; "audit" if empty
+	MaxBytes   int64         // size, before compression, past which a new file is started; 64 MiB if zero
+	MaxAge     time.Duration // age past which a new file is started; no limit if zero
+	Keep       int           // number of files retained; all if zero
+	FlushEvery time.Duration // longest time a record stays buffered; one second if zero
+
+	// Compressor, if not nil, compresses each file
+	Compressor compression.Compressor
+}
+
+// Log is an audit log being written
+type Log struct {
+	opts    Options
+	file    *os.File
+	cw      compression.Writer // compresses onto file
+	buf     *bufio.Writer      // buffers onto cw
+	size    int64              // bytes written to the current file, before compression
+	opened  time.Time          // when the current file was started
+	flushed time.Time          // when the buffer was last flushed
+	scratch []byte
+	err     error
+	remove  func()
+	mu      sync.Mutex
+}
+
+// Open starts a Log with a new file in opts.Dir, creating the directory if need be
+func Open(opts Options) (*Log, error) {
+	if opts.Prefix == "" {
+		opts.Prefix = "audit"
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 64 << 20
+	}
+	if opts.FlushEvery <= 0 {
+		opts.FlushEvery = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	lg := &Log{opts: opts}
+	if err := lg.rotate(); err != nil {
+		return nil, err
+	}
+	return lg, nil
+}
+
+// Attach starts logging every event mgr dispatches from now on.  The first error writing
+// the log stops the logging, and is returned by Err.
+func (lg *Log) Attach(mgr *evtm.EventManager) {
+	lg.Detach()
+	remove := mgr.AddInterceptor(func(mgr *evtm.EventManager, event *evtm.Event) {
+		lg.Append(Entry{Wall: time.Now(), Ticks: event.Time.Ticks(), Pri: event.Time.Pri(),
+			EventID: event.EventID, Handler: evtm.HandlerName(event.EventHandler), Class: event.Class})
+	})
+	lg.mu.Lock()
+	lg.remove = remove
+	lg.mu.Unlock()
+}
+
+// Detach stops logging the EventManager given to Attach
+func (lg *Log) Detach() {
+	lg.mu.Lock()
+	remove := lg.remove
+	lg.remove = nil
+	lg.mu.Unlock()
+	if remove != nil {
+		remove()
+	}
+}
+
+// Append adds an entry to the log, starting a new file first if the current one is due to
+// be rotated
+func (lg *Log) Append(ent Entry) error {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if lg.err != nil {
+		return lg.err
+	}
+	if lg.size >= lg.opts.MaxBytes || (lg.opts.MaxAge > 0 && ent.Wall.Sub(lg.opened) >= lg.opts.MaxAge) {
+		if lg.err = lg.rotate(); lg.err != nil {
+			return lg.err
+		}
+	}
+
+	rec := lg.scratch[:0]
+	rec = binary.AppendVarint(rec, ent.Wall.UnixNano())
+	rec = binary.AppendVarint(rec, ent.Ticks)
+	rec = binary.AppendVarint(rec, ent.Pri)
+	rec = binary.AppendVarint(rec, int64(ent.EventID))
+	rec = binary.AppendUvarint(rec, uint64(len(ent.Handler)))
+	rec = append(rec, ent.Handler...)
+	rec = binary.AppendUvarint(rec, uint64(len(ent.Class)))
+	rec = append(rec, ent.Class...)
+	lg.scratch = rec
+
+	var head [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(head[:], uint64(len(rec)))
+	if _, lg.err = lg.buf.Write(head[:n]); lg.err != nil {
+		return lg.err
+	}
+	if _, lg.err = lg.buf.Write(rec); lg.err != nil {
+		return lg.err
+	}
+	lg.size += int64(n + len(rec))
+	if now := time.Now(); now.Sub(lg.flushed) >= lg.opts.FlushEvery {
+		lg.flushed = now
+		lg.err = lg.flush()
+	}
+	return lg.err
+}
+
+// flush writes out the buffered records, through the compressor.  Called with lg.mu held.
+func (lg *Log) flush() error {
+	if err := lg.buf.Flush(); err != nil {
+		return err
+	}
+	return lg.cw.Flush()
+}
+
+// closeFile flushes the buffered records and closes the current file.  Called with lg.mu
+// held.
+func (lg *Log) closeFile() error {
+	err := lg.buf.Flush()
+	if cerr := lg.cw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := lg.file.Close(); err == nil {
+		err = cerr
+	}
+	lg.file = nil
+	return err
+}
+
+// Flush writes out the buffered records
+func (lg *Log) Flush() error {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if lg.err == nil {
+		lg.flushed = time.Now()
+		lg.err = lg.flush()
+	}
+	return lg.err
+}
+
+// Err returns the first error met writing the log
+func (lg *Log) Err() error {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	return lg.err
+}
+
+// Close detaches the log, flushes it, and closes its file
+func (lg *Log) Close() error {
+	lg.Detach()
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if lg.file == nil {
+		return lg.err
+	}
+	err := lg.closeFile()
+	if lg.err == nil {
+		lg.err = err
+	}
+	return err
+}
+
+// rotate closes the current file, if any, starts a new one, and removes the oldest files
+// beyond those to be kept.  Called with lg.mu held, or before the Log is shared.
+func (lg *Log) rotate() error {
+	if lg.file != nil {
+		if err := lg.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	name := filepath.Join(lg.opts.Dir, lg.opts.Prefix+"-"+now.UTC().Format("20060102T150405.000000000")+suffix)
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	cw, err := compression.NewWriter(file, lg.opts.Compressor)
+	if err == nil {
+		_, err = cw.Write([]byte(magic))
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	lg.file, lg.cw, lg.size, lg.opened, lg.flushed = file, cw, int64(len(magic)), now, now
+	if lg.buf == nil {
+		lg.buf = bufio.NewWriterSize(cw, 64<<10)
+	} else {
+		lg.buf.Reset(cw)
+	}
+
+	if lg.opts.Keep > 0 {
+		names, err := Files(lg.opts.Dir, lg.opts.Prefix)
+		if err != nil {
+			return err
+		}
+		for len(names) > lg.opts.Keep {
+			if err := os.Remove(names[0]); err != nil {
+				return err
+			}
+			names = names[1:]
+		}
+	}
+	return nil
+}
+
+// Files returns the paths of the files of the log in dir with the given prefix, oldest first
+func Files(dir, prefix string) ([]string, error) {
+	if prefix == "" {
+		prefix = "audit"
+	}
+	dirents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, dirent := range dirents {
+		name := dirent.Name()
+		if !dirent.IsDir() && strings.HasPrefix(name, prefix+"-") && strings.HasSuffix(name, suffix) {
+			names = append(names, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Reader reads the entries of one audit file
+type Reader struct {
+	r       *bufio.Reader
+	err     error // error recognizing the compression of the file
+	checked bool
+	scratch []byte
+}
+
+// NewReader creates a Reader of the audit file read from r, decompressing it if it was
+// compressed
+func NewReader(r io.Reader) *Reader {
+	rd, err := compression.NewReader(r)
+	if err != nil {
+		return &Reader{err: err}
+	}
+	return &Reader{r: bufio.NewReader(rd)}
+}
+
+// Next returns the next entry, or io.EOF once there are none.  A record cut short, as the
+// last one of a file may be if the process writing it died, is reported as io.ErrUnexpectedEOF.
+func (rd *Reader) Next() (Entry, error) {
+	var ent Entry
+	if rd.err != nil {
+		return ent, rd.err
+	}
+	if !rd.checked {
+		head := make([]byte, len(magic))
+		if _, err := io.ReadFull(rd.r, head); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return ent, err
+		}
+		if string(head) != magic {
+			return ent, errors.New("not an audit file")
+		}
+		rd.checked = true
+	}
+
+	length, err := binary.ReadUvarint(rd.r)
+	if err != nil {
+		return ent, err
+	}
+	if cap(rd.scratch) < int(length) {
+		rd.scratch = make([]byte, length)
+	}
+	rec := rd.scratch[:length]
+	if _, err := io.ReadFull(rd.r, rec); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return ent, err
+	}
+
+	var fields [4]int64
+	for i := range fields {
+		value, n := binary.Varint(rec)
+		if n <= 0 {
+			return ent, errors.New("corrupt audit record")
+		}
+		fields[i], rec = value, rec[n:]
+	}
+	var strs [2]string
+	for i := range strs {
+		size, n := binary.Uvarint(rec)
+		if n <= 0 || uint64(len(rec)-n) < size {
+			return ent, errors.New("corrupt audit record")
+		}
+		strs[i], rec = string(rec[n:n+int(size)]), rec[n+int(size):]
+	}
+	ent = Entry{Wall: time.Unix(0, fields[0]), Ticks: fields[1], Pri: fields[2], EventID: int(fields[3]),
+		Handler: strs[0], Class: strs[1]}
+	return ent, nil
+}
+
+// ReadDir calls fn with every entry of the log in dir with the given prefix whose real time
+// lies within [from, to], in order, until fn returns false.  A zero from or to leaves the
+// window open at that end.  A final record cut short is ignored.
+func ReadDir(dir, prefix string, from, to time.Time, fn func(Entry) bool) error {
+	names, err := Files(dir, prefix)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		more, err := readFile(name, from, to, fn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readFile is ReadDir for one file, returning false if fn asked to stop
+func readFile(name string, from, to time.Time, fn func(Entry) bool) (bool, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	rd := NewReader(file)
+	for {
+		ent, err := rd.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !from.IsZero() && ent.Wall.Before(from) {
+			continue
+		}
+		if !to.IsZero() && ent.Wall.After(to) {
+			return false, nil
+		}
+		if !fn(ent) {
+			return false, nil
+		}
+	}
+}
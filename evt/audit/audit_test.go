@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRotation checks that a log rotates its files by size, keeps only the newest, and reads
+// back in order the entries they hold, across files and within a window of real time
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	lg, err := Open(Options{Dir: dir, MaxBytes: 300, Keep: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for idx := 0; idx < 100; idx++ {
+		if err := lg.Append(Entry{Wall: base.Add(time.Duration(idx) * time.Second), Ticks: int64(idx),
+			EventID: idx, Handler: "main.step"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lg.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, err := Files(dir, "audit"); err != nil || len(files) != 3 {
+		t.Fatalf("log holds %d files (%v), want 3", len(files), err)
+	}
+	var ids []int
+	ReadDir(dir, "audit", time.Time{}, time.Time{}, func(ent Entry) bool { ids = append(ids, ent.EventID); return true })
+	if len(ids) == 0 || ids[len(ids)-1] != 99 || len(ids) == 100 {
+		t.Fatalf("read back %v, want the newest entries, not all", ids)
+	}
+	for idx := 1; idx < len(ids); idx++ {
+		if ids[idx] != ids[idx-1]+1 {
+			t.Fatalf("read back %v, want consecutive entries", ids)
+		}
+	}
+	var window []int
+	ReadDir(dir, "audit", base.Add(95*time.Second), base.Add(97*time.Second), func(ent Entry) bool {
+		window = append(window, ent.EventID)
+		return true
+	})
+	if len(window) != 3 || window[0] != 95 {
+		t.Errorf("window of 95s to 97s read %v, want [95 96 97]", window)
+	}
+}
+
+// TestAttach checks that an attached log records the events an EventManager dispatches
+func TestAttach(t *testing.T) {
+	dir := t.TempDir()
+	lg, err := Open(Options{Dir: dir, Prefix: "run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := evtm.New()
+	lg.Attach(mgr)
+	noop := func(*evtm.EventManager, any, any) any { return nil }
+	for idx := 1; idx <= 3; idx++ {
+		mgr.Schedule(nil, nil, noop, vrtime.CreateTime(int64(idx), 0))
+	}
+	mgr.Run(1)
+	if err := lg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var ticks []int64
+	ReadDir(dir, "run", time.Time{}, time.Time{}, func(ent Entry) bool { ticks = append(ticks, ent.Ticks); return true })
+	if len(ticks) != 3 || ticks[2] != 3 {
+		t.Errorf("logged events at %v, want 1, 2, 3", ticks)
+	}
+}
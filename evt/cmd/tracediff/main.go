@@ -0,0 +1,54 @@
+// tracediff compares two execution traces written by package etrace, or in the same
+// form by another implementation, and reports where they first diverge.
+//
+//	usage: tracediff [flags] expected.jsonl actual.jsonl
+//
+// The exit status is 0 if the traces match, 1 if they diverge, and 2 on error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iti/evt/etrace"
+)
+
+func main() {
+	var opts etrace.Options
+	flag.BoolVar(&opts.IgnorePriority, "nopri", false, "do not compare priorities")
+	flag.BoolVar(&opts.IgnoreEventID, "noid", false, "do not compare event identifiers")
+	flag.BoolVar(&opts.IgnoreHandler, "nohandler", false, "do not compare handler names")
+	flag.BoolVar(&opts.IgnoreDigest, "nodigest", false, "do not compare digests of event data")
+	flag.BoolVar(&opts.HandlerBase, "base", false, "compare handler names after the last '.' only")
+	flag.IntVar(&opts.Context, "context", 3, "number of matching records to show before a divergence")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: tracediff [flags] expected.jsonl actual.jsonl")
+		os.Exit(2)
+	}
+
+	expected := readTrace(flag.Arg(0))
+	actual := readTrace(flag.Arg(1))
+	if dv := etrace.Compare(expected, actual, opts); dv != nil {
+		fmt.Print(dv)
+		os.Exit(1)
+	}
+	fmt.Printf("traces match (%d records)\n", len(expected))
+}
+
+// readTrace reads the trace in the named file, exiting on error
+func readTrace(name string) []etrace.Record {
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer f.Close()
+	recs, err := etrace.Read(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+	return recs
+}
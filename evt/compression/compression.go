@@ -0,0 +1,187 @@
+// Package compression compresses the streams and blocks of bytes the evt packages store,
+// such as traces, audit logs, checkpoints, and events spilled to disk, in which the payloads
+// of events dominate the volume of a big run.
+//
+// A Compressor is named, and compressed data begins with a header naming the Compressor
+// that produced it, so that a reader negotiates the codec of each stream or block for
+// itself: NewReader and Decompress pick the Compressor by name, and pass data without a
+// header through unchanged, so that uncompressed files written before remain readable.
+// Gzip, Flate, and Zlib are provided from the standard library; others, e.g., snappy or
+// zstd, are added with Register by a program that has them.
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// magic begins the header of compressed data, which continues with the length of the name
+// of the Compressor, in a byte, and the name
+const magic = "EVTZ"
+
+// Writer is a compressing writer.  Flush writes out what has been compressed so far, so
+// that a reader can get at it before the stream is closed.
+type Writer interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compressor compresses and decompresses streams of bytes
+type Compressor interface {
+	// Name identifies the Compressor in the header of what it compresses; at most 255 bytes
+	Name() string
+
+	// NewWriter returns a Writer compressing what is written to it onto w
+	NewWriter(w io.Writer) (Writer, error)
+
+	// NewReader returns a reader decompressing r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// stdCompressor adapts a compressor of the standard library
+type stdCompressor struct {
+	name      string
+	newWriter func(w io.Writer) (Writer, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+func (sc stdCompressor) Name() string                                 { return sc.name }
+func (sc stdCompressor) NewWriter(w io.Writer) (Writer, error)        { return sc.newWriter(w) }
+func (sc stdCompressor) NewReader(r io.Reader) (io.ReadCloser, error) { return sc.newReader(r) }
+
+var (
+	// Gzip compresses with compress/gzip
+	Gzip Compressor = stdCompressor{
+		name:      "gzip",
+		newWriter: func(w io.Writer) (Writer, error) { return gzip.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	}
+
+	// Flate compresses with compress/flate, which has the least overhead per block
+	Flate Compressor = stdCompressor{
+		name:      "flate",
+		newWriter: func(w io.Writer) (Writer, error) { return flate.NewWriter(w, flate.DefaultCompression) },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	}
+
+	// Zlib compresses with compress/zlib
+	Zlib Compressor = stdCompressor{
+		name:      "zlib",
+		newWriter: func(w io.Writer) (Writer, error) { return zlib.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+	}
+)
+
+var (
+	registry   = map[string]Compressor{"gzip": Gzip, "flate": Flate, "zlib": Zlib}
+	registryMu sync.RWMutex
+)
+
+// Register makes a Compressor available to the readers of compressed data, under its name
+func Register(c Compressor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[c.Name()] = c
+}
+
+// Lookup returns the Compressor registered under name
+func Lookup(name string) (Compressor, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, found := registry[name]
+	return c, found
+}
+
+// header returns the header naming c
+func header(c Compressor) ([]byte, error) {
+	name := c.Name()
+	if len(name) == 0 || len(name) > 255 {
+		return nil, fmt.Errorf("compression: bad compressor name %q", name)
+	}
+	return append(append([]byte(magic), byte(len(name))), name...), nil
+}
+
+// passWriter is the Writer of an uncompressed stream
+type passWriter struct {
+	io.Writer
+}
+
+func (passWriter) Flush() error { return nil }
+func (passWriter) Close() error { return nil }
+
+// NewWriter returns a Writer compressing onto w with c, after a header naming c.  A nil c
+// writes w uncompressed, and without a header.  Closing the Writer does not close w.
+func NewWriter(w io.Writer, c Compressor) (Writer, error) {
+	if c == nil {
+		return passWriter{w}, nil
+	}
+	head, err := header(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(head); err != nil {
+		return nil, err
+	}
+	return c.NewWriter(w)
+}
+
+// NewReader returns a reader of r, decompressing it with the Compressor named in its header,
+// or passing it through unchanged if it has no header
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	peeked, err := br.Peek(len(magic) + 1)
+	if err != nil || string(peeked[:len(magic)]) != magic {
+		// too short, or not compressed
+		return io.NopCloser(br), nil
+	}
+	head := make([]byte, len(magic)+1+int(peeked[len(magic)]))
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("compression: reading header: %w", err)
+	}
+	name := string(head[len(magic)+1:])
+	c, found := Lookup(name)
+	if !found {
+		return nil, fmt.Errorf("compression: unknown compressor %q", name)
+	}
+	return c.NewReader(br)
+}
+
+// Compress compresses a block of data with c, after a header naming c.  A nil c returns
+// data unchanged.
+func Compress(c Compressor, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	cw, err := NewWriter(&buf, c)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses a block made by Compress, with the Compressor named in its
+// header, or returns data unchanged if it has no header
+func Decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return data, nil
+	}
+	rd, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
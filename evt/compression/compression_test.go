@@ -0,0 +1,47 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestRoundTrip checks that blocks and streams compressed by each Compressor decompress to
+// what went in, that uncompressed data passes through, and that an unknown codec is refused
+func TestRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("event 42 dispatched at 1.5\n", 200))
+	for _, c := range []Compressor{Gzip, Flate, Zlib, nil} {
+		block, err := Compress(c, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != nil && len(block) >= len(data)/4 {
+			t.Errorf("%s compressed %d bytes to %d", c.Name(), len(data), len(block))
+		}
+		if back, err := Decompress(block); err != nil || !bytes.Equal(back, data) {
+			t.Errorf("block through %v came back as %d bytes (%v)", c, len(back), err)
+		}
+
+		var buf bytes.Buffer
+		cw, err := NewWriter(&buf, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cw.Write(data[:100])
+		cw.Write(data[100:])
+		cw.Close()
+		rd, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back, err := io.ReadAll(rd); err != nil || !bytes.Equal(back, data) {
+			t.Errorf("stream through %v came back as %d bytes (%v)", c, len(back), err)
+		}
+	}
+
+	block := append([]byte(magic), 4, 'z', 's', 't', 'd')
+	if _, err := Decompress(block); err == nil {
+		t.Error("block of an unregistered compressor decompressed")
+	}
+}
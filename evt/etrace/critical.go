@@ -0,0 +1,131 @@
+package etrace
+
+import (
+	"sync"
+	"time"
+
+	"github.com/iti/evt/evtm"
+)
+
+// The events of a run can be executed in parallel only as far as their causes allow: an event
+// cannot start before the event that scheduled it has finished.  Weighting each event of a
+// trace with the real time its handler took, the longest chain of causes, the critical path,
+// bounds the time any parallel execution of the run can take, and the sum of all weights over
+// its length bounds the speedup.  Events assigned to the same logical process execute one
+// after another, so a partition of the model adds those orders to the chains of causes, and
+// comparing the critical paths of candidate partitions shows which loses least parallelism.
+
+// Timing records the trace of an EventManager in memory together with the real time each
+// event's handler takes, measured as the time from its dispatch to the next.  The measure
+// includes the work of the EventManager between events, and, in wallclock mode, the waits
+// for real time, so a run to be analyzed should not be paced.
+type Timing struct {
+	*Recording
+	costs  []time.Duration
+	last   time.Time
+	remove func()
+	mu     sync.Mutex
+}
+
+// CaptureTiming starts recording the trace of mgr with the real time taken by each event
+func CaptureTiming(mgr *evtm.EventManager) *Timing {
+	tm := &Timing{}
+	tm.remove = mgr.AddInterceptor(func(mgr *evtm.EventManager, event *evtm.Event) {
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+		now := time.Now()
+		if !tm.last.IsZero() {
+			tm.costs = append(tm.costs, now.Sub(tm.last))
+		}
+		tm.last = now
+	})
+	tm.Recording = Capture(mgr)
+	return tm
+}
+
+// Finish stops the recording, measuring the time taken by the last event to have been
+// dispatched.  It is called once the run is over.
+func (tm *Timing) Finish() {
+	tm.Detach()
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.remove != nil {
+		tm.remove()
+		tm.remove = nil
+		if !tm.last.IsZero() {
+			tm.costs = append(tm.costs, time.Since(tm.last))
+		}
+	}
+}
+
+// Costs returns the real time taken by the event of each record, in the order of the
+// records.  The last is missing until Finish has been called.
+func (tm *Timing) Costs() []time.Duration {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return append([]time.Duration(nil), tm.costs...)
+}
+
+// PathReport describes the critical path of a run
+type PathReport struct {
+	Path      []Record                 // the events on the critical path, in the order of dispatch
+	Length    time.Duration            // the real time taken by the events on the path
+	Total     time.Duration            // the real time taken by all the events
+	Speedup   float64                  // Total divided by Length, the bound on the speedup of a parallel run
+	ByHandler map[string]time.Duration // the real time taken by the events on the path, by handler
+}
+
+// CriticalPath finds the critical path of the run traced by recs, whose events took the real
+// times costs, one for each record.  An event follows the event that caused it (see
+// Record.Parent), if the trace holds it.  If partition is not nil it assigns each event to a
+// logical process, and an event also follows the event dispatched before it in the same
+// logical process.
+func CriticalPath(recs []Record, costs []time.Duration, partition func(Record) string) PathReport {
+	report := PathReport{ByHandler: make(map[string]time.Duration)}
+	if len(costs) < len(recs) {
+		recs = recs[:len(costs)]
+	}
+	finish := make([]time.Duration, len(recs)) // completion of each event on the longest chain to it
+	pred := make([]int, len(recs))             // predecessor of each event on that chain, -1 if none
+	byEvent := make(map[int]int, len(recs))
+	lastIn := make(map[string]int)
+	end := -1
+	for idx, rec := range recs {
+		pred[idx] = -1
+		if parent, found := byEvent[rec.Parent]; found && rec.Parent != 0 {
+			pred[idx] = parent
+		}
+		if partition != nil {
+			lp := partition(rec)
+			if prev, found := lastIn[lp]; found && (pred[idx] < 0 || finish[prev] > finish[pred[idx]]) {
+				pred[idx] = prev
+			}
+			lastIn[lp] = idx
+		}
+		finish[idx] = costs[idx]
+		if pred[idx] >= 0 {
+			finish[idx] += finish[pred[idx]]
+		}
+		byEvent[rec.EventID] = idx
+		report.Total += costs[idx]
+		if end < 0 || finish[idx] > finish[end] {
+			end = idx
+		}
+	}
+	if end < 0 {
+		return report
+	}
+
+	report.Length = finish[end]
+	for idx := end; idx >= 0; idx = pred[idx] {
+		report.Path = append(report.Path, recs[idx])
+		report.ByHandler[recs[idx].Handler] += costs[idx]
+	}
+	for i, j := 0, len(report.Path)-1; i < j; i, j = i+1, j-1 {
+		report.Path[i], report.Path[j] = report.Path[j], report.Path[i]
+	}
+	if report.Length > 0 {
+		report.Speedup = float64(report.Total) / float64(report.Length)
+	}
+	return report
+}
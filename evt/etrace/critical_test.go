@@ -0,0 +1,44 @@
+package etrace
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCriticalPath checks that the critical path follows the longest chain of causes, and
+// that a partition adds the order of the events within each logical process
+func TestCriticalPath(t *testing.T) {
+	// event 1 causes 2 and 3, and 2 causes 4
+	recs := []Record{
+		{EventID: 1, Handler: "a"},
+		{EventID: 2, Parent: 1, Handler: "b"},
+		{EventID: 3, Parent: 1, Handler: "c"},
+		{EventID: 4, Parent: 2, Handler: "b"},
+	}
+	costs := []time.Duration{1, 2, 10, 3}
+
+	report := CriticalPath(recs, costs, nil)
+	if len(report.Path) != 2 || report.Path[0].EventID != 1 || report.Path[1].EventID != 3 {
+		t.Fatalf("got path %v, want events 1 and 3", report.Path)
+	}
+	if report.Length != 11 || report.Total != 16 || report.ByHandler["c"] != 10 {
+		t.Errorf("got length %d of total %d, want 11 of 16", report.Length, report.Total)
+	}
+	if want := 16.0 / 11; report.Speedup != want {
+		t.Errorf("got speedup %f, want %f", report.Speedup, want)
+	}
+
+	// with 3 and 4 in the same logical process, 4 waits for 3 as well as 2
+	report = CriticalPath(recs, costs, func(rec Record) string {
+		if rec.EventID >= 3 {
+			return "lp1"
+		}
+		return "lp0"
+	})
+	if report.Length != 14 || len(report.Path) != 3 || report.Path[2].EventID != 4 {
+		t.Errorf("got path %v of length %d, want events 1, 3, and 4 of length 14", report.Path, report.Length)
+	}
+	if report := CriticalPath(nil, nil, nil); report.Length != 0 || report.Path != nil {
+		t.Errorf("got path %v of an empty trace, want none", report.Path)
+	}
+}
@@ -0,0 +1,303 @@
+// Package etrace records execution traces of an [evtm.EventManager], one record per event
+// dispatched, and compares two traces to find where they first diverge.
+//
+// A trace is written as JSON lines, one object per record with the fields of Record, so
+// that traces from other implementations, such as the Python port of this package, are
+// easily produced in the same form.  Comparing two traces reports the first record at which
+// they differ, with the records preceding it for context, rather than leaving a user to
+// find it by eye in two logs.  Where the order of events agrees but the model's state does
+// not, a StateProbe samples that state at chosen virtual times and CompareStates reports the
+// earliest time and field at which two runs differ.  Records name the event that caused each
+// one, so that Lineage and Descendants can follow chains of cause and effect through a trace.
+package etrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iti/evt/compression"
+	"github.com/iti/evt/evtm"
+)
+
+// Record describes the dispatch of one event
+type Record struct {
+	Index    int    `json:"index"`            // position of the event in the order of dispatch, from 0
+	Ticks    int64  `json:"ticks"`            // tick count of the event's time
+	Priority int64  `json:"pri"`              // priority of the event's time
+	EventID  int    `json:"event"`            // identifier of the event
+	Manager  uint32 `json:"mgr,omitempty"`    // identifier of the EventManager, if assigned (see evtm.GlobalID)
+	Parent   int    `json:"parent,omitempty"` // identifier of the event that caused this one, if any (see evtm.Event)
+	Handler  string `json:"handler"`          // name of the event handler
+	Digest   string `json:"digest"`           // digest of the event's data
+
+	// External is the external identifier of the event, if it has one (see
+	// evtm.EventManager.SetExternalIDs).  Being drawn independently by each run, it is not compared.
+	External string `json:"xid,omitempty"`
+
+	// Lamport and Vector are the logical clocks of the event, if they are on (see
+	// evtm.EventManager.SetLogicalClocks).  They are not compared.
+	Lamport uint64            `json:"lamport,omitempty"`
+	Vector  map[uint32]uint64 `json:"vc,omitempty"`
+
+	// Creator, Site, TraceID, and Tags are taken from the metadata of the event, if it has any
+	// (see evtm.EventManager.SetMetadata).  They are not compared.
+	Creator string            `json:"creator,omitempty"`
+	Site    string            `json:"site,omitempty"`
+	TraceID string            `json:"trace,omitempty"`
+	Tags    map[string]string `json:"tags,omitempty"`
+}
+
+// String describes the record
+func (rec Record) String() string {
+	event := strconv.Itoa(rec.EventID)
+	if rec.Manager != 0 {
+		event = evtm.GlobalID{Manager: rec.Manager, Event: rec.EventID}.String()
+	}
+	return fmt.Sprintf("#%d t=%d pri=%d event=%s handler=%s digest=%s",
+		rec.Index, rec.Ticks, rec.Priority, event, rec.Handler, rec.Digest)
+}
+
+// DigestData returns the default digest of an event's data: a hash of its printed form.
+// Data holding pointers, whose printed form varies from run to run, needs a digest of its own.
+func DigestData(data any) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%+v", data)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Writer writes the trace of an EventManager
+type Writer struct {
+	// Digest computes the digest of an event's data.  It is DigestData unless replaced.
+	Digest func(data any) string
+
+	emit   func(rec Record) error
+	close  func() error
+	index  int
+	err    error
+	mu     sync.Mutex
+	remove func()
+}
+
+// NewWriter creates a Writer of a trace to w
+func NewWriter(w io.Writer) *Writer {
+	enc := json.NewEncoder(w)
+	return &Writer{Digest: DigestData, emit: func(rec Record) error { return enc.Encode(rec) }}
+}
+
+// NewCompressedWriter creates a Writer of a trace to w, compressed by c, which Read
+// recognizes.  The trace is complete only once the Writer is closed.
+func NewCompressedWriter(w io.Writer, c compression.Compressor) (*Writer, error) {
+	cw, err := compression.NewWriter(w, c)
+	if err != nil {
+		return nil, err
+	}
+	tw := NewWriter(cw)
+	tw.close = cw.Close
+	return tw, nil
+}
+
+// Attach starts tracing mgr, writing a record for each event it dispatches from now on.
+// The record is written just before the handler is called, after any filters and interceptors
+// registered earlier have been applied.
+func (tw *Writer) Attach(mgr *evtm.EventManager) {
+	tw.Detach()
+	tw.remove = mgr.AddInterceptor(func(mgr *evtm.EventManager, event *evtm.Event) {
+		external, _ := mgr.ExternalID(event.EventID)
+		rec := Record{Ticks: event.Time.Ticks(), Priority: event.Time.Pri(), EventID: event.EventID,
+			Manager: mgr.ManagerID(), Parent: event.Parent, Handler: evtm.HandlerName(event.EventHandler), Digest: tw.Digest(event.Data),
+			External: external}
+		if clock, on := mgr.LogicalClock(); on {
+			rec.Lamport, rec.Vector = clock.Lamport, clock.Vector
+		}
+		if md := event.Meta; md != nil {
+			rec.Creator, rec.Site, rec.TraceID, rec.Tags = md.Creator, md.Site, md.TraceID, md.Tags
+		}
+		tw.Write(rec)
+	})
+}
+
+// Detach stops tracing the EventManager given to Attach
+func (tw *Writer) Detach() {
+	if tw.remove != nil {
+		tw.remove()
+		tw.remove = nil
+	}
+}
+
+// Write appends a record to the trace, numbering it
+func (tw *Writer) Write(rec Record) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	rec.Index = tw.index
+	tw.index += 1
+	if tw.err == nil {
+		tw.err = tw.emit(rec)
+	}
+}
+
+// Close detaches the Writer and ends the trace, flushing any compression.  It does not close
+// the underlying writer.
+func (tw *Writer) Close() error {
+	tw.Detach()
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.close != nil {
+		if err := tw.close(); tw.err == nil {
+			tw.err = err
+		}
+		tw.close = nil
+	}
+	return tw.err
+}
+
+// Err returns the first error met in writing the trace
+func (tw *Writer) Err() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.err
+}
+
+// Read reads a trace written as JSON lines, decompressing it if it was compressed.  Blank
+// lines are skipped.
+func Read(r io.Reader) ([]Record, error) {
+	rd, err := compression.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("etrace: %w", err)
+	}
+	defer rd.Close()
+	var recs []Record
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			return recs, fmt.Errorf("etrace: line %d: %w", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+// Options select what Compare compares
+type Options struct {
+	IgnorePriority bool // do not compare priorities
+	IgnoreEventID  bool // do not compare event identifiers, or those of their EventManagers
+	IgnoreHandler  bool // do not compare handler names
+	IgnoreDigest   bool // do not compare digests of data
+
+	// HandlerBase compares only the part of handler names after the last '.', so that
+	// "main.arrival" matches "arrival" as a handler of another implementation may be named.
+	HandlerBase bool
+
+	// Context is the number of matching records preceding a divergence to report with it
+	Context int
+}
+
+// Divergence describes the first difference between two traces
+type Divergence struct {
+	Index    int      // position in the traces of the first record that differs
+	Expected *Record  // that record in the expected trace, nil if the trace ended first
+	Actual   *Record  // that record in the actual trace, nil if the trace ended first
+	Fields   []string // the fields that differ, empty when one trace ended first
+	Context  []Record // the matching records preceding the divergence, from the expected trace
+}
+
+// String reports the divergence
+func (dv *Divergence) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "traces diverge at record %d", dv.Index)
+	if len(dv.Fields) > 0 {
+		fmt.Fprintf(&sb, " (%s)", strings.Join(dv.Fields, ", "))
+	}
+	sb.WriteString("\n")
+	for _, rec := range dv.Context {
+		fmt.Fprintf(&sb, "    %s\n", rec)
+	}
+	describe := func(label string, rec *Record) {
+		if rec == nil {
+			fmt.Fprintf(&sb, "  %s: <end of trace>\n", label)
+		} else {
+			fmt.Fprintf(&sb, "  %s: %s\n", label, rec)
+		}
+	}
+	describe("expected", dv.Expected)
+	describe("actual  ", dv.Actual)
+	return sb.String()
+}
+
+// handlerBase returns the part of a handler name after the last '.'
+func handlerBase(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// differences lists the fields in which two records differ
+func differences(exp, act Record, opts Options) []string {
+	var fields []string
+	if exp.Ticks != act.Ticks {
+		fields = append(fields, "time")
+	}
+	if !opts.IgnorePriority && exp.Priority != act.Priority {
+		fields = append(fields, "priority")
+	}
+	if !opts.IgnoreEventID && (exp.EventID != act.EventID || exp.Manager != act.Manager) {
+		fields = append(fields, "event")
+	}
+	if !opts.IgnoreHandler {
+		expHandler, actHandler := exp.Handler, act.Handler
+		if opts.HandlerBase {
+			expHandler, actHandler = handlerBase(expHandler), handlerBase(actHandler)
+		}
+		if expHandler != actHandler {
+			fields = append(fields, "handler")
+		}
+	}
+	if !opts.IgnoreDigest && exp.Digest != act.Digest {
+		fields = append(fields, "digest")
+	}
+	return fields
+}
+
+// Compare compares the records of two traces in order, and returns the first divergence,
+// or nil if the traces match.
+func Compare(expected, actual []Record, opts Options) *Divergence {
+	for idx := 0; idx < len(expected) || idx < len(actual); idx++ {
+		dv := &Divergence{Index: idx}
+		if idx < len(expected) {
+			dv.Expected = &expected[idx]
+		}
+		if idx < len(actual) {
+			dv.Actual = &actual[idx]
+		}
+		if dv.Expected != nil && dv.Actual != nil {
+			dv.Fields = differences(*dv.Expected, *dv.Actual, opts)
+			if len(dv.Fields) == 0 {
+				continue
+			}
+		}
+		start := idx - opts.Context
+		if start < 0 {
+			start = 0
+		}
+		if start < len(expected) {
+			end := idx
+			if end > len(expected) {
+				end = len(expected)
+			}
+			dv.Context = expected[start:end]
+		}
+		return dv
+	}
+	return nil
+}
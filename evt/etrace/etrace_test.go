@@ -0,0 +1,86 @@
+package etrace
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// traceRun writes the trace of a run of five events, the data of the event at index odd
+// being changed, and reads it back
+func traceRun(t *testing.T, odd int) []Record {
+	mgr := evtm.New()
+	var buf bytes.Buffer
+	tw := NewWriter(&buf)
+	tw.Attach(mgr)
+	noop := func(*evtm.EventManager, any, any) any { return nil }
+	for idx := 0; idx < 5; idx++ {
+		data := idx
+		if idx == odd {
+			data = -idx
+		}
+		mgr.Schedule(nil, data, noop, vrtime.CreateTime(int64(10*idx), 0))
+	}
+	mgr.Run(1)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
+
+// TestCompare checks that a trace read back matches another of the same run, and that the
+// first record whose data differs is reported with the records preceding it
+func TestCompare(t *testing.T) {
+	expected := traceRun(t, -1)
+	if len(expected) != 5 || expected[3].Ticks != 30 || expected[3].Index != 3 {
+		t.Fatalf("read back %v, want five records ten ticks apart", expected)
+	}
+	if dv := Compare(expected, traceRun(t, -1), Options{}); dv != nil {
+		t.Errorf("identical runs diverge:\n%s", dv)
+	}
+
+	dv := Compare(expected, traceRun(t, 3), Options{Context: 2})
+	if dv == nil || dv.Index != 3 || !reflect.DeepEqual(dv.Fields, []string{"digest"}) || len(dv.Context) != 2 {
+		t.Fatalf("divergence %v, want the digest of record 3 with two records of context", dv)
+	}
+	if dv := Compare(expected, traceRun(t, 3), Options{IgnoreDigest: true}); dv != nil {
+		t.Errorf("runs differing only in data diverge when digests are ignored:\n%s", dv)
+	}
+	if dv := Compare(expected, expected[:4], Options{}); dv == nil || dv.Index != 4 || dv.Actual != nil {
+		t.Errorf("divergence %v, want the end of the shorter trace at record 4", dv)
+	}
+}
+
+// TestSites checks that a trace records the file and line of the call that scheduled each
+// event while sites are captured, and records none otherwise
+func TestSites(t *testing.T) {
+	mgr := evtm.New()
+	rc := Capture(mgr)
+	noop := func(*evtm.EventManager, any, any) any { return nil }
+	mgr.Schedule(nil, nil, noop, vrtime.CreateTime(1, 0))
+	mgr.SetCaptureSites(true)
+	_, file, line, _ := runtime.Caller(0)
+	mgr.Schedule(nil, nil, noop, vrtime.CreateTime(2, 0))
+	mgr.Run(1)
+	rc.Detach()
+
+	recs := rc.Records()
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if recs[0].Site != "" {
+		t.Errorf("got site %q before capture began, want none", recs[0].Site)
+	}
+	if want := fmt.Sprintf("%s:%d", file, line+1); recs[1].Site != want {
+		t.Errorf("got site %q, want %q", recs[1].Site, want)
+	}
+}
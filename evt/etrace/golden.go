@@ -0,0 +1,111 @@
+package etrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/iti/evt/compression"
+	"github.com/iti/evt/evtm"
+)
+
+// UpdateEnv names the environment variable that, when set to a non-empty value, makes
+// AssertGolden write golden files rather than compare against them, e.g.,
+//
+//	ETRACE_UPDATE=1 go test ./...
+const UpdateEnv = "ETRACE_UPDATE"
+
+// TB is the part of [testing.TB] that AssertGolden uses
+type TB interface {
+	Helper()
+	Logf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// Recording holds in memory the trace of an EventManager
+type Recording struct {
+	*Writer
+	recs []Record
+}
+
+// Capture starts recording the trace of mgr in memory
+func Capture(mgr *evtm.EventManager) *Recording {
+	rc := &Recording{}
+	rc.Writer = &Writer{Digest: DigestData, emit: func(rec Record) error {
+		rc.recs = append(rc.recs, rec)
+		return nil
+	}}
+	rc.Attach(mgr)
+	return rc
+}
+
+// Records returns a copy of the records captured so far
+func (rc *Recording) Records() []Record {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Record(nil), rc.recs...)
+}
+
+// WriteFile writes a trace to the named file as JSON lines, creating its directory if need be
+func WriteFile(path string, recs []Record) error {
+	return WriteCompressedFile(path, recs, nil)
+}
+
+// WriteCompressedFile writes a trace to the named file as JSON lines compressed by c, which
+// ReadFile recognizes.  A nil c leaves the trace uncompressed.
+func WriteCompressedFile(path string, recs []Record, c compression.Compressor) error {
+	var buf bytes.Buffer
+	cw, err := compression.NewWriter(&buf, c)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(cw)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ReadFile reads the trace in the named file, decompressing it if it was compressed
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// AssertGolden fails the test unless the trace recs matches the golden trace in the
+// named file, reporting the first divergence.  When the environment variable named by
+// UpdateEnv is set, or the golden file does not yet exist, it writes recs as the golden
+// trace instead, so that a model's expected behavior is recorded by running its tests once.
+// Golden files conventionally live under the testdata directory of the package tested.
+func AssertGolden(t TB, path string, recs []Record, opts Options) {
+	t.Helper()
+	_, statErr := os.Stat(path)
+	if os.Getenv(UpdateEnv) != "" || os.IsNotExist(statErr) {
+		if err := WriteFile(path, recs); err != nil {
+			t.Fatalf("etrace: writing golden trace: %v", err)
+		}
+		t.Logf("etrace: wrote golden trace %s (%d records)", path, len(recs))
+		return
+	}
+	golden, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("etrace: reading golden trace: %v", err)
+	}
+	if dv := Compare(golden, recs, opts); dv != nil {
+		t.Fatalf("etrace: trace does not match golden trace %s (set %s=1 to update it)\n%s",
+			path, UpdateEnv, dv)
+	}
+}
@@ -0,0 +1,53 @@
+package etrace
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// fakeTB records what AssertGolden reports, so that a failure can be tested for
+type fakeTB struct {
+	fatal string
+}
+
+func (ft *fakeTB) Helper()                           {}
+func (ft *fakeTB) Logf(format string, args ...any)   {}
+func (ft *fakeTB) Fatalf(format string, args ...any) { ft.fatal = fmt.Sprintf(format, args...) }
+
+// captureRun captures the trace of a run of three events with the given data
+func captureRun(data ...int) []Record {
+	mgr := evtm.New()
+	rc := Capture(mgr)
+	noop := func(*evtm.EventManager, any, any) any { return nil }
+	for idx, datum := range data {
+		mgr.Schedule(nil, datum, noop, vrtime.CreateTime(int64(idx), 0))
+	}
+	mgr.Run(1)
+	rc.Close()
+	return rc.Records()
+}
+
+// TestAssertGolden checks that AssertGolden writes a missing golden trace, passes a run
+// that matches it, and fails one that does not, naming the divergence
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "run.trace")
+	AssertGolden(t, path, captureRun(1, 2, 3), Options{})
+	if recs, err := ReadFile(path); err != nil || len(recs) != 3 {
+		t.Fatalf("golden trace holds %d records (%v), want 3", len(recs), err)
+	}
+
+	ft := &fakeTB{}
+	AssertGolden(ft, path, captureRun(1, 2, 3), Options{})
+	if ft.fatal != "" {
+		t.Errorf("matching run failed: %s", ft.fatal)
+	}
+	AssertGolden(ft, path, captureRun(1, 5, 3), Options{})
+	if !strings.Contains(ft.fatal, "diverge at record 1 (digest)") {
+		t.Errorf("differing run reported as %q", ft.fatal)
+	}
+}
@@ -0,0 +1,49 @@
+package etrace
+
+import "github.com/iti/evt/evtm"
+
+// Lineage returns the chain of causes of the event with the identifier given, as recorded in
+// the trace recs: the record of the event, then that of the event whose handler scheduled it,
+// and so on back to an event scheduled outside any handler.  The chain stops early at a cause
+// the trace does not hold, e.g., one dispatched before tracing began.  It is empty if the
+// event is not in the trace.
+func Lineage(recs []Record, eventID int) []Record {
+	byEvent := make(map[int]int, len(recs))
+	for idx, rec := range recs {
+		byEvent[rec.EventID] = idx
+	}
+	var chain []Record
+	for eventID != 0 {
+		idx, found := byEvent[eventID]
+		if !found {
+			break
+		}
+		chain = append(chain, recs[idx])
+		eventID = recs[idx].Parent
+	}
+	return chain
+}
+
+// Descendants returns the records of the events in the trace recs that the event with the
+// identifier given caused, directly or through others, in the order of dispatch
+func Descendants(recs []Record, eventID int) []Record {
+	caused := map[int]bool{eventID: true}
+	var found []Record
+	for _, rec := range recs {
+		if rec.Parent != 0 && caused[rec.Parent] {
+			caused[rec.EventID] = true
+			found = append(found, rec)
+		}
+	}
+	return found
+}
+
+// HappensBefore reports whether the event of record a happened before that of record b, by
+// their vector clocks, which may come from the traces of different EventManagers.  It is
+// false if either record has no vector clock.
+func HappensBefore(a, b Record) bool {
+	if a.Vector == nil || b.Vector == nil {
+		return false
+	}
+	return evtm.LogicalClock{Vector: a.Vector}.HappensBefore(evtm.LogicalClock{Vector: b.Vector})
+}
@@ -0,0 +1,47 @@
+package etrace
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestLineage checks that a trace records the event that caused each one, so that Lineage
+// follows a chain back to its root and Descendants finds every event it caused
+func TestLineage(t *testing.T) {
+	mgr := evtm.New()
+	rc := Capture(mgr)
+	var spawn func(*evtm.EventManager, any, any) any
+	spawn = func(mgr *evtm.EventManager, context any, data any) any {
+		if depth := data.(int); depth > 0 {
+			mgr.Schedule(nil, depth-1, spawn, vrtime.CreateTime(1, 0))
+		}
+		return nil
+	}
+	rootID, _ := mgr.Schedule(nil, 3, spawn, vrtime.CreateTime(1, 0))
+	otherID, _ := mgr.Schedule(nil, 0, spawn, vrtime.CreateTime(2, 0))
+	mgr.Run(1)
+	rc.Detach()
+	recs := rc.Records()
+
+	last := recs[len(recs)-1]
+	chain := Lineage(recs, last.EventID)
+	if len(chain) != 4 || chain[0].EventID != last.EventID || chain[3].EventID != rootID || chain[3].Parent != 0 {
+		t.Fatalf("lineage of the last event %v, want four records back to event %d", chain, rootID)
+	}
+	for idx := 1; idx < len(chain); idx++ {
+		if chain[idx-1].Parent != chain[idx].EventID {
+			t.Errorf("record %v follows %v, which is not its parent", chain[idx-1], chain[idx])
+		}
+	}
+	if got := Descendants(recs, rootID); len(got) != 3 {
+		t.Errorf("got %d descendants of the root, want 3", len(got))
+	}
+	if got := Descendants(recs, otherID); len(got) != 0 {
+		t.Errorf("got %d descendants of an event that scheduled nothing, want 0", len(got))
+	}
+	if got := Lineage(recs, 1000); len(got) != 0 {
+		t.Errorf("got lineage %v of an event not in the trace, want none", got)
+	}
+}
@@ -0,0 +1,204 @@
+package etrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// StateSample holds the model state extracted at one virtual time
+type StateSample struct {
+	Ticks  int64             `json:"ticks"`  // virtual time of the sample, in ticks
+	Fields map[string]string `json:"fields"` // printed form of each field of state, by name
+}
+
+// StateProbe samples the state of a model at chosen virtual times, through extractors the
+// model registers, so that the states of two runs can be compared with CompareStates.  A
+// sample at time t is taken just before the first event later than t is dispatched, i.e., it
+// shows the state once every event at or before t has executed.  No events are scheduled
+// for the purpose, so probing a model does not change the course of its run.
+type StateProbe struct {
+	mgr        *evtm.EventManager
+	names      []string
+	extractors map[string]func() any
+	times      []int64 // times still to sample, in increasing order
+	samples    []StateSample
+	remove     func()
+	mu         sync.Mutex
+}
+
+// NewStateProbe creates a StateProbe of the model running on mgr
+func NewStateProbe(mgr *evtm.EventManager) *StateProbe {
+	sp := &StateProbe{mgr: mgr, extractors: make(map[string]func() any)}
+	sp.remove = mgr.AddInterceptor(func(mgr *evtm.EventManager, event *evtm.Event) {
+		sp.sampleBefore(event.Time.Ticks())
+	})
+	return sp
+}
+
+// Register adds a named extractor of model state.  An extractor returning a map[string]any
+// contributes one field per key, named name.key, so a divergence is pinned to the key.
+func (sp *StateProbe) Register(name string, extract func() any) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if _, present := sp.extractors[name]; !present {
+		sp.names = append(sp.names, name)
+	}
+	sp.extractors[name] = extract
+}
+
+// SampleAt adds virtual times, in seconds, at which to sample the state
+func (sp *StateProbe) SampleAt(times ...float64) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for _, t := range times {
+		sp.times = append(sp.times, vrtime.SecondsToTicks(t))
+	}
+	sort.Slice(sp.times, func(i, j int) bool { return sp.times[i] < sp.times[j] })
+}
+
+// SampleEvery adds samples every interval seconds of virtual time, from interval up to until
+func (sp *StateProbe) SampleEvery(interval, until float64) {
+	var times []float64
+	for idx := 1; float64(idx)*interval <= until; idx++ {
+		times = append(times, float64(idx)*interval)
+	}
+	sp.SampleAt(times...)
+}
+
+// sampleBefore takes the samples due at times before ticks
+func (sp *StateProbe) sampleBefore(ticks int64) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for len(sp.times) > 0 && sp.times[0] < ticks {
+		sp.samples = append(sp.samples, sp.extract(sp.times[0]))
+		sp.times = sp.times[1:]
+	}
+}
+
+// extract samples the state for time ticks.  Called with sp.mu held.
+func (sp *StateProbe) extract(ticks int64) StateSample {
+	sample := StateSample{Ticks: ticks, Fields: make(map[string]string)}
+	for _, name := range sp.names {
+		value := sp.extractors[name]()
+		if fields, isMap := value.(map[string]any); isMap {
+			for key, field := range fields {
+				sample.Fields[name+"."+key] = fmt.Sprintf("%+v", field)
+			}
+			continue
+		}
+		sample.Fields[name] = fmt.Sprintf("%+v", value)
+	}
+	return sample
+}
+
+// Finish takes the samples due at times up to the current time, for use once the run is
+// over, and stops the probe
+func (sp *StateProbe) Finish() {
+	sp.sampleBefore(sp.mgr.CurrentTicks() + 1)
+	sp.remove()
+}
+
+// Samples returns the samples taken, in order of time
+func (sp *StateProbe) Samples() []StateSample {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return append([]StateSample(nil), sp.samples...)
+}
+
+// StateDivergence describes the earliest difference between the states of two runs
+type StateDivergence struct {
+	Ticks    int64  // virtual time of the sample that differs
+	Field    string // name of the first field, in sorted order, that differs
+	Expected string // the field in the expected run, "<absent>" if it was not sampled there
+	Actual   string // the field in the actual run, "<absent>" if it was not sampled there
+}
+
+// String reports the divergence
+func (sd *StateDivergence) String() string {
+	return fmt.Sprintf("state diverges at %gs in field %s\n  expected: %s\n  actual  : %s",
+		vrtime.TicksToSeconds(sd.Ticks), sd.Field, sd.Expected, sd.Actual)
+}
+
+// absent stands for a field or sample that one run does not have
+const absent = "<absent>"
+
+// CompareStates compares the samples of two runs in order of time, and returns the
+// earliest divergence, or nil if the states match at every time both runs sampled
+// and neither sampled a time the other did not.
+func CompareStates(expected, actual []StateSample) *StateDivergence {
+	for idx := 0; idx < len(expected) || idx < len(actual); idx++ {
+		switch {
+		case idx >= len(expected):
+			return &StateDivergence{Ticks: actual[idx].Ticks, Field: "<sample>", Expected: absent, Actual: "sampled"}
+		case idx >= len(actual):
+			return &StateDivergence{Ticks: expected[idx].Ticks, Field: "<sample>", Expected: "sampled", Actual: absent}
+		}
+		exp, act := expected[idx], actual[idx]
+		if exp.Ticks != act.Ticks {
+			ticks := exp.Ticks
+			if act.Ticks < ticks {
+				ticks = act.Ticks
+			}
+			return &StateDivergence{Ticks: ticks, Field: "<sample>",
+				Expected: fmt.Sprintf("sampled at %d", exp.Ticks), Actual: fmt.Sprintf("sampled at %d", act.Ticks)}
+		}
+		if field := firstDifference(exp.Fields, act.Fields); field != "" {
+			sd := &StateDivergence{Ticks: exp.Ticks, Field: field, Expected: absent, Actual: absent}
+			if value, present := exp.Fields[field]; present {
+				sd.Expected = value
+			}
+			if value, present := act.Fields[field]; present {
+				sd.Actual = value
+			}
+			return sd
+		}
+	}
+	return nil
+}
+
+// firstDifference returns the first name, in sorted order, under which two sets of
+// fields differ, or "" if they are the same
+func firstDifference(exp, act map[string]string) string {
+	names := make([]string, 0, len(exp)+len(act))
+	for name := range exp {
+		names = append(names, name)
+	}
+	for name := range act {
+		if _, present := exp[name]; !present {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expValue, expPresent := exp[name]
+		actValue, actPresent := act[name]
+		if expPresent != actPresent || expValue != actValue {
+			return name
+		}
+	}
+	return ""
+}
+
+// describeFields lists fields in sorted order, for debugging output
+func describeFields(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for idx, name := range names {
+		parts[idx] = name + "=" + fields[name]
+	}
+	return strings.Join(parts, " ")
+}
+
+// String describes the sample
+func (ss StateSample) String() string {
+	return fmt.Sprintf("%gs: %s", vrtime.TicksToSeconds(ss.Ticks), describeFields(ss.Fields))
+}
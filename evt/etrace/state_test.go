@@ -0,0 +1,44 @@
+package etrace
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// probeRun samples a counter, incremented by an event each second and by an extra one
+// at bump seconds, every second of a six-second run
+func probeRun(bump int) []StateSample {
+	mgr := evtm.New()
+	count := 0
+	increment := func(*evtm.EventManager, any, any) any { count++; return nil }
+	for sec := 1; sec <= 6; sec++ {
+		mgr.Schedule(nil, nil, increment, vrtime.SecondsToTime(float64(sec)))
+	}
+	if bump > 0 {
+		mgr.Schedule(nil, nil, increment, vrtime.SecondsToTime(float64(bump)+0.5))
+	}
+	sp := NewStateProbe(mgr)
+	sp.Register("model", func() any { return map[string]any{"count": count, "odd": count%2 == 1} })
+	sp.SampleEvery(1, 6)
+	mgr.Run(10)
+	sp.Finish()
+	return sp.Samples()
+}
+
+// TestCompareStates checks that a sample shows the state once the events at its time have
+// executed, and that the earliest field to differ between two runs is found
+func TestCompareStates(t *testing.T) {
+	expected := probeRun(0)
+	if len(expected) != 6 || expected[1].Fields["model.count"] != "2" || expected[5].Fields["model.count"] != "6" {
+		t.Fatalf("sampled %v, want the counts 1 to 6", expected)
+	}
+	if sd := CompareStates(expected, probeRun(0)); sd != nil {
+		t.Errorf("identical runs diverge: %s", sd)
+	}
+	sd := CompareStates(expected, probeRun(3))
+	if sd == nil || sd.Ticks != vrtime.SecondsToTicks(4) || sd.Field != "model.count" || sd.Expected != "4" || sd.Actual != "5" {
+		t.Errorf("divergence %v, want model.count 4 against 5 at 4s", sd)
+	}
+}
@@ -0,0 +1,60 @@
+package evtm
+
+import (
+	"fmt"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// AbortError carries the error with which an event handler aborted a run,
+// and where in the run the abort occurred.
+type AbortError struct {
+	Err     error       // the cause given to Abort
+	Time    vrtime.Time // virtual time of the event whose handler aborted the run
+	EventID int         // identifier of that event
+}
+
+// Error describes the abort
+func (ae *AbortError) Error() string {
+	return fmt.Sprintf("evtm: run aborted at %g by event %d: %v", ae.Time.Seconds(), ae.EventID, ae.Err)
+}
+
+// Unwrap returns the cause of the abort
+func (ae *AbortError) Unwrap() error {
+	return ae.Err
+}
+
+// Abort stops a running EventManager because of err, in the way Stop does, but keeping
+// the cause.  RunE returns the error, wrapped in an AbortError that records the time and the
+// event being dispatched when Abort was called; after Run it is available from Err, and
+// RunFor returns StopAborted.  Only the first abort of a run is kept.
+func (evtmgr *EventManager) Abort(err error) {
+	evtmgr.mu.Lock()
+	if evtmgr.abortErr == nil {
+		evtmgr.abortErr = &AbortError{Err: err, Time: evtmgr.Time, EventID: evtmgr.EventID}
+	}
+	evtmgr.RunFlag = false
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Abort at %f: %v\n", evtmgr.Time.Seconds(), err)
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.interrupt()
+}
+
+// RunE is Run, returning the *AbortError with which an event handler aborted the run, or
+// nil if the run ended otherwise.
+func (evtmgr *EventManager) RunE(LimitTime float64) error {
+	evtmgr.Run(LimitTime)
+	return evtmgr.Err()
+}
+
+// Err returns the *AbortError with which the most recent run was aborted,
+// or nil if it was not.
+func (evtmgr *EventManager) Err() error {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.abortErr == nil {
+		return nil
+	}
+	return evtmgr.abortErr
+}
@@ -0,0 +1,37 @@
+package evtm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRunEReturnsAbort checks that RunE returns the AbortError of a handler calling Abort,
+// recording where the run stopped, and nil for a run that ends otherwise
+func TestRunEReturnsAbort(t *testing.T) {
+	evtmgr := New()
+	cause := errors.New("model inconsistent")
+	abortID, _ := evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		evtmgr.Abort(cause)
+		return nil
+	}, vrtime.CreateTime(30, 0))
+	later := false
+	evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any {
+		later = true
+		return nil
+	}, vrtime.CreateTime(40, 0))
+
+	err := evtmgr.RunE(1)
+	var ae *AbortError
+	if !errors.As(err, &ae) || !errors.Is(err, cause) {
+		t.Fatalf("RunE returned %v, want an AbortError wrapping the cause", err)
+	}
+	if ae.EventID != abortID || ae.Time.Ticks() != 30 || later {
+		t.Errorf("abort recorded at %d by event %d, later event dispatched %v", ae.Time.Ticks(), ae.EventID, later)
+	}
+
+	if err := evtmgr.RunE(1); err != nil || !later {
+		t.Errorf("second run returned %v and dispatched the later event %v", err, later)
+	}
+}
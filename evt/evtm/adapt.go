@@ -0,0 +1,188 @@
+package evtm
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// An EventManager in wallclock mode that cannot keep up with real time, because its handlers
+// take longer than the virtual time between events, falls ever further behind.  Under
+// adaptation it instead slows the wallclock scale, the number of virtual seconds advanced per
+// real second, whenever deadlines are persistently missed, and reports the change.  It speeds
+// up again, no further than the scale it started from, only after several windows of events
+// in a row would all have met their deadlines at the faster scale, so the scale does not flap
+// at the margin of overload.
+
+// Adaptation governs the adjustment of the wallclock scale under overload
+type Adaptation struct {
+	// Window is the number of events over which missed deadlines are counted; 100 if zero
+	Window int
+
+	// Overload is the fraction of the events of a window that must miss their deadlines for
+	// the scale to be reduced; 0.5 if zero
+	Overload float64
+
+	// Factor multiplies the scale when it is reduced, and divides it when it is restored;
+	// 0.8 if zero
+	Factor float64
+
+	// MinScale is the least the scale is reduced to; 0.01 if zero
+	MinScale float64
+
+	// Recover is the number of consecutive windows in which no deadline would have been
+	// missed at the increased scale after which the scale is increased; 3 if zero
+	Recover int
+
+	// Slack is the lateness tolerated before a deadline counts as missed
+	Slack time.Duration
+
+	// Report, if not nil, is called by the thread running the EventManager with each change of scale
+	Report func(evtmgr *EventManager, from, to float64)
+}
+
+// adapter holds the state of adaptation
+type adapter struct {
+	adapt    Adaptation
+	on       bool
+	nominal  float64 // scale before any adaptation, which it is not increased beyond
+	events   int     // events counted in the current window
+	missed   int     // deadlines missed in the current window
+	tight    int     // deadlines in the current window that would be missed at the increased scale
+	clean    int     // consecutive windows in which no deadline would be missed at the increased scale
+	from, to float64 // change of scale awaiting its report, when from != to
+}
+
+// SetAdaptation switches adaptation of the wallclock scale on, governed by adapt.  The
+// scale in force when it is called is the most the scale is restored to.
+func (evtmgr *EventManager) SetAdaptation(adapt Adaptation) {
+	if adapt.Window <= 0 {
+		adapt.Window = 100
+	}
+	if adapt.Overload <= 0 {
+		adapt.Overload = 0.5
+	}
+	if adapt.Factor <= 0 || adapt.Factor >= 1 {
+		adapt.Factor = 0.8
+	}
+	if adapt.MinScale <= 0 {
+		adapt.MinScale = 0.01
+	}
+	if adapt.Recover <= 0 {
+		adapt.Recover = 3
+	}
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.adapting = adapter{adapt: adapt, on: true, nominal: evtmgr.scale}
+}
+
+// ClearAdaptation switches adaptation off, restoring the scale in force when it was switched on
+func (evtmgr *EventManager) ClearAdaptation() {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.adapting.on {
+		evtmgr.scale = evtmgr.adapting.nominal
+	}
+	evtmgr.adapting = adapter{}
+}
+
+// SetWallclockScale sets the number of virtual seconds advanced per real second in wallclock
+// mode, e.g., 10 for an accelerated soak test or 0.1 for a slowed-down demonstration; the
+// default is 1.  Under adaptation factor becomes the scale restored to once the overload
+// passes.  It may be called while the EventManager runs, in which case the deadlines of
+// events are measured afresh from the current time.  An error is returned, and
+// nothing changed, if factor is not a positive, finite number.
+func (evtmgr *EventManager) SetWallclockScale(factor float64) error {
+	if !(factor > 0) || math.IsInf(factor, 1) {
+		return fmt.Errorf("wallclock scale must be positive and finite, not %g", factor)
+	}
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.scale = factor
+	if evtmgr.adapting.on {
+		evtmgr.adapting.nominal = factor
+	}
+	if evtmgr.RunFlag {
+		evtmgr.anchorAt(time.Now())
+	}
+	return nil
+}
+
+// WallclockScale returns the number of virtual seconds advanced per real second in
+// wallclock mode, 1 unless adaptation has reduced it or SetWallclockScale changed it
+func (evtmgr *EventManager) WallclockScale() float64 {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.scale
+}
+
+// adaptTo counts whether an event met its deadline, lateness being how late it is and gap
+// the real time allowed it, and adjusts the scale at the end of each window, returning true if
+// it changed the scale, which is then to be reported with reportAdaptation.  Called with
+// evtmgr.mu held.
+func (evtmgr *EventManager) adaptTo(lateness, gap time.Duration) bool {
+	ad := &evtmgr.adapting
+	if !ad.on {
+		return false
+	}
+	ad.events += 1
+	if lateness > ad.adapt.Slack {
+		ad.missed += 1
+	} else if lateness+time.Duration(float64(gap)*(1-ad.adapt.Factor)) > ad.adapt.Slack {
+		// increasing the scale shortens the gap by this much
+		ad.tight += 1
+	}
+	if ad.events < ad.adapt.Window {
+		return false
+	}
+
+	scale := evtmgr.scale
+	switch {
+	case float64(ad.missed) >= ad.adapt.Overload*float64(ad.events):
+		ad.clean = 0
+		scale *= ad.adapt.Factor
+		if scale < ad.adapt.MinScale {
+			scale = ad.adapt.MinScale
+		}
+	case ad.missed == 0 && ad.tight == 0:
+		ad.clean += 1
+		if ad.clean >= ad.adapt.Recover {
+			ad.clean = 0
+			scale /= ad.adapt.Factor
+			if scale > ad.nominal {
+				scale = ad.nominal
+			}
+		}
+	default:
+		ad.clean = 0
+	}
+	ad.events, ad.missed, ad.tight = 0, 0, 0
+	if scale == evtmgr.scale {
+		return false
+	}
+	ad.from, ad.to = evtmgr.scale, scale
+	evtmgr.scale = scale
+
+	// measure the drift of virtual time afresh at the new scale
+	evtmgr.anchorAt(time.Now())
+	return true
+}
+
+// reportAdaptation reports a change of scale made by adaptTo
+func (evtmgr *EventManager) reportAdaptation() {
+	evtmgr.mu.Lock()
+	ad := &evtmgr.adapting
+	from, to := ad.from, ad.to
+	ad.from, ad.to = 0, 0
+	report := ad.adapt.Report
+	evtmgr.mu.Unlock()
+	if from == to {
+		return
+	}
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("wallclock scale changed from %g to %g at %f\n", from, to, evtmgr.CurrentSeconds())
+	}
+	if report != nil {
+		report(evtmgr, from, to)
+	}
+}
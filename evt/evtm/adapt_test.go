@@ -0,0 +1,68 @@
+package evtm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestAdaptation checks that a window of missed deadlines reduces the wallclock scale, that
+// it is restored only after enough clean windows, never beyond where it started, and that
+// each change is reported
+func TestAdaptation(t *testing.T) {
+	evtmgr := New()
+	if err := evtmgr.SetWallclockScale(0); err == nil {
+		t.Error("scale of zero accepted")
+	}
+	evtmgr.SetWallclockScale(2)
+	var changes []string
+	evtmgr.SetAdaptation(Adaptation{Window: 4, Factor: 0.5, Recover: 2,
+		Report: func(evtmgr *EventManager, from, to float64) {
+			changes = append(changes, fmt.Sprintf("%g>%g", from, to))
+		}})
+
+	window := func(lateness time.Duration) {
+		for idx := 0; idx < 4; idx++ {
+			evtmgr.mu.Lock()
+			rescaled := evtmgr.adaptTo(lateness, time.Millisecond)
+			evtmgr.mu.Unlock()
+			if rescaled {
+				evtmgr.reportAdaptation()
+			}
+		}
+	}
+	window(time.Second)
+	window(time.Second)
+	if scale := evtmgr.WallclockScale(); scale != 0.5 {
+		t.Errorf("scale %g after two overloaded windows, want 0.5", scale)
+	}
+	for idx := 0; idx < 7; idx++ {
+		window(-time.Second)
+	}
+	if fmt.Sprint(changes) != "[2>1 1>0.5 0.5>1 1>2]" {
+		t.Errorf("scale changed %v, want down twice and back up twice, each after two clean windows", changes)
+	}
+}
+
+// TestWallclockScale checks that a scale of 10 paces a wallclock run at ten virtual seconds
+// per real second
+func TestWallclockScale(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	if err := evtmgr.SetWallclockScale(10); err != nil || evtmgr.WallclockScale() != 10 {
+		t.Fatalf("SetWallclockScale(10) returned %v with scale %g", err, evtmgr.WallclockScale())
+	}
+	var at time.Time
+	evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any {
+		at = time.Now()
+		return nil
+	}, vrtime.SecondsToTime(0.5))
+
+	start := time.Now()
+	evtmgr.Run(1)
+	if elapsed := at.Sub(start); elapsed < 45*time.Millisecond || elapsed > 250*time.Millisecond {
+		t.Errorf("event at 0.5s dispatched after %v, want about 50ms", elapsed)
+	}
+}
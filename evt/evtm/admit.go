@@ -0,0 +1,140 @@
+package evtm
+
+import (
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Events injected by external devices in wallclock mode arrive whenever the devices and the
+// scheduler of the host let them, so scheduling them as they come makes the virtual order of
+// events depend on accidents of real time.  A Device admits them instead under the
+// AdmissionPolicy of its EventManager: each event is stamped with the virtual time
+// corresponding to the real time of its arrival, optionally delayed by a dejitter interval and
+// quantized, kept monotone for the device, and ordered at its tick after the model's own
+// events, by device and then order of arrival.  Captured admissions replay with Replay to
+// exactly the same virtual order.
+
+// AdmittedPriority is the base of the band of priorities reserved for events admitted by
+// Devices.  It lies below EndOfTickPriority, so that admitted events execute after the
+// ordinary events of their tick but before those scheduled with ScheduleEndOfTick.
+const AdmittedPriority int64 = 1 << 61
+
+// admittedSeqBits is the number of low bits of an admitted event's priority that hold its
+// sequence number at its Device; the bits above hold the index of the Device
+const admittedSeqBits = 40
+
+// AdmissionPolicy governs how Devices timestamp the events they admit
+type AdmissionPolicy struct {
+	// Quantum, when more than a tick, rounds timestamps up to a multiple of Quantum, so that
+	// events arriving close together share a tick and are ordered by device rather than by
+	// the precise moment of their arrival.
+	Quantum vrtime.Time
+
+	// Dejitter delays each event by this much beyond its arrival, absorbing variation in
+	// the latency of the devices.
+	Dejitter time.Duration
+
+	// Capture, if not nil, is called with every admission, to record it for replay
+	Capture func(Admission)
+}
+
+// Admission records the admission of an event by a Device
+type Admission struct {
+	Device string      // name of the Device
+	Seq    int64       // number of the admission at the Device, counting from 1
+	Time   vrtime.Time // virtual time, with priority, at which the event executes
+	Data   any         // the data of the event
+}
+
+// Device admits the events injected by one external device into the virtual timeline of
+// an EventManager.  Each event is dispatched to the handler given to NewDevice, with the
+// Device as context.
+type Device struct {
+	evtmgr  *EventManager
+	name    string
+	index   int64
+	handler EventHandlerFunction
+	seq     int64 // admissions made, guarded by evtmgr.mu
+	last    int64 // tick of the latest admission, guarded by evtmgr.mu
+}
+
+// SetAdmissionPolicy selects how the EventManager's Devices timestamp the events they admit
+func (evtmgr *EventManager) SetAdmissionPolicy(policy AdmissionPolicy) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.admission = policy
+}
+
+// NewDevice creates a Device named name whose events are dispatched to handler.  Devices are
+// ordered at a tick in the order they were created, so a replay must create them in the same
+// order.
+func (evtmgr *EventManager) NewDevice(name string, handler EventHandlerFunction) *Device {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	dev := &Device{evtmgr: evtmgr, name: name, index: evtmgr.devices, handler: handler}
+	evtmgr.devices += 1
+	return dev
+}
+
+// Name returns the name of the Device
+func (dev *Device) Name() string {
+	return dev.name
+}
+
+// Admit schedules an event carrying data under the AdmissionPolicy.  It may be called from
+// any goroutine while the EventManager runs.  Admit returns the eventId of the event and the
+// virtual time at which it executes.
+func (dev *Device) Admit(data any) (int, vrtime.Time) {
+	evtmgr := dev.evtmgr
+	evtmgr.mu.Lock()
+	policy := evtmgr.admission
+
+	// the virtual time that corresponds to the present moment of real time
+	ticks := evtmgr.Time.Ticks()
+	if evtmgr.Wallclock && evtmgr.RunFlag && !evtmgr.lastDispatch.IsZero() {
+		if since := time.Since(evtmgr.lastDispatch) - evtmgr.held; since > 0 {
+			ticks += vrtime.SecondsToTicks(since.Seconds())
+		}
+	}
+	ticks += vrtime.SecondsToTicks(policy.Dejitter.Seconds())
+	if quantum := policy.Quantum.Ticks(); quantum > 1 {
+		ticks = (ticks + quantum - 1) / quantum * quantum
+	}
+	if ticks < dev.last {
+		ticks = dev.last
+	}
+	dev.last = ticks
+	dev.seq += 1
+
+	pri := AdmittedPriority + dev.index<<admittedSeqBits + dev.seq&(1<<admittedSeqBits-1)
+	at := vrtime.CreateTime(ticks, pri)
+	newEvent := evtmgr.placeAt(dev, data, dev.handler, at)
+	eventID := newEvent.EventID
+	admission := Admission{Device: dev.name, Seq: dev.seq, Time: at, Data: data}
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("Device %s admits event %d at %f\n", dev.name, eventID, at.Seconds())
+	}
+	var observed Event
+	observing := evtmgr.observing()
+	if observing {
+		observed = *newEvent
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.release()
+	if observing {
+		evtmgr.observe(OpSchedule, observed)
+	}
+
+	if policy.Capture != nil {
+		policy.Capture(admission)
+	}
+	return eventID, at
+}
+
+// Replay schedules an event captured from an earlier admission at its original time and
+// priority, reproducing the virtual order of that run.  An error is returned if the time has
+// already passed.
+func (dev *Device) Replay(admission Admission) (int, error) {
+	return dev.evtmgr.scheduleAt(dev, admission.Data, dev.handler, admission.Time, nil)
+}
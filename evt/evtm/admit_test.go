@@ -0,0 +1,52 @@
+package evtm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestAdmit checks that admitted events are quantized, follow the model's own events at
+// their tick, are ordered by device and then arrival, and replay in the same order
+func TestAdmit(t *testing.T) {
+	var captured []Admission
+	run := func(admit func(first, second *Device)) []string {
+		evtmgr := New()
+		evtmgr.SetAdmissionPolicy(AdmissionPolicy{Quantum: vrtime.CreateTime(10, 0),
+			Capture: func(adm Admission) { captured = append(captured, adm) }})
+		evtmgr.AdvanceTo(vrtime.CreateTime(101, 0))
+		var order []string
+		record := func(evtmgr *EventManager, context any, data any) any {
+			order = append(order, fmt.Sprintf("%v@%d", data, evtmgr.CurrentTicks()))
+			return nil
+		}
+		first, second := evtmgr.NewDevice("first", record), evtmgr.NewDevice("second", record)
+		admit(first, second)
+		evtmgr.Schedule(nil, "model", record, vrtime.CreateTime(9, 0))
+		evtmgr.AdvanceTo(vrtime.CreateTime(200, 0))
+		return order
+	}
+
+	live := run(func(first, second *Device) {
+		second.Admit("s1")
+		first.Admit("f1")
+		second.Admit("s2")
+	})
+	if fmt.Sprint(live) != "[model@110 f1@110 s1@110 s2@110]" {
+		t.Errorf("dispatched %v, want the model's event, then the first device's, then the second's", live)
+	}
+
+	admissions := captured
+	replayed := run(func(first, second *Device) {
+		for _, adm := range admissions {
+			dev := map[string]*Device{"first": first, "second": second}[adm.Device]
+			if _, err := dev.Replay(adm); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if fmt.Sprint(replayed) != fmt.Sprint(live) {
+		t.Errorf("replay dispatched %v, want %v", replayed, live)
+	}
+}
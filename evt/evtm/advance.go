@@ -0,0 +1,81 @@
+package evtm
+
+import (
+	"sync"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// A thread emulating a real component may need the EventManager to wait for it at some
+// virtual time, so that the events it injects are not overtaken by the simulation racing
+// ahead.  RequestTimeAdvance holds virtual time at or before a limit until the request is
+// released.  Requests are counted, so that any number of requesters may hold the same or
+// different limits; the EventManager advances no further than the least outstanding one.
+
+// RequestTimeAdvance asks that no event later than limit be dispatched until the function
+// returned is called, which releases the request and may be called more than once.  The
+// EventManager dispatches the events at or before limit, then waits, with its clock at the
+// time of the last event dispatched, until the request is released, an event is scheduled
+// within the limit, or it is stopped.  A limit earlier than the current time holds the
+// EventManager where it is.
+func (evtmgr *EventManager) RequestTimeAdvance(limit vrtime.Time) (release func()) {
+	ticks := limit.Ticks()
+	evtmgr.mu.Lock()
+	if evtmgr.advances == nil {
+		evtmgr.advances = make(map[int64]int)
+	}
+	evtmgr.advances[ticks] += 1
+	evtmgr.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { evtmgr.releaseTimeAdvance(ticks) })
+	}
+}
+
+// TimeAdvanceLimit returns the least limit of the outstanding requests made through
+// RequestTimeAdvance, and whether there are any
+func (evtmgr *EventManager) TimeAdvanceLimit() (vrtime.Time, bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	ticks, held := evtmgr.advanceLimit()
+	return vrtime.CreateTime(ticks, 0), held
+}
+
+// advanceLimit returns the least limit of the outstanding requests, in ticks.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) advanceLimit() (int64, bool) {
+	var least int64
+	held := false
+	for ticks := range evtmgr.advances {
+		if !held || ticks < least {
+			least, held = ticks, true
+		}
+	}
+	return least, held
+}
+
+// heldBound returns bound, lowered to the least limit of the outstanding requests
+func (evtmgr *EventManager) heldBound(bound int64) int64 {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if limit, held := evtmgr.advanceLimit(); held && limit < bound {
+		return limit
+	}
+	return bound
+}
+
+// releaseTimeAdvance withdraws one request holding virtual time to ticks, and wakes the
+// EventManager if it is waiting on it
+func (evtmgr *EventManager) releaseTimeAdvance(ticks int64) {
+	evtmgr.mu.Lock()
+	evtmgr.advances[ticks] -= 1
+	if evtmgr.advances[ticks] == 0 {
+		delete(evtmgr.advances, ticks)
+	}
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("time advance request at %f released\n", vrtime.TicksToSeconds(ticks))
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.release()
+}
@@ -0,0 +1,52 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRequestTimeAdvance checks that an outstanding request holds the EventManager at its
+// limit, with the clock at the last event dispatched, until it is released
+func TestRequestTimeAdvance(t *testing.T) {
+	evtmgr := New()
+	dispatched := make(chan int64, 2)
+	handler := func(evtmgr *EventManager, context any, data any) any {
+		dispatched <- evtmgr.CurrentTicks()
+		return nil
+	}
+	evtmgr.Schedule(nil, nil, handler, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, nil, handler, vrtime.CreateTime(30, 0))
+	release := evtmgr.RequestTimeAdvance(vrtime.CreateTime(20, 0))
+	if limit, held := evtmgr.TimeAdvanceLimit(); !held || limit.Ticks() != 20 {
+		t.Fatalf("got limit %d, %v, want 20, true", limit.Ticks(), held)
+	}
+	done := make(chan struct{})
+	go func() {
+		evtmgr.Run(1)
+		close(done)
+	}()
+
+	if ticks := <-dispatched; ticks != 10 {
+		t.Fatalf("first event dispatched at %d, want 10", ticks)
+	}
+	select {
+	case ticks := <-dispatched:
+		t.Fatalf("event at %d dispatched while time is held at 20", ticks)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if now := evtmgr.CurrentTicks(); now != 10 {
+		t.Errorf("held with the clock at %d, want 10", now)
+	}
+
+	release()
+	release()
+	if ticks := <-dispatched; ticks != 30 {
+		t.Errorf("second event dispatched at %d after the release, want 30", ticks)
+	}
+	<-done
+	if _, held := evtmgr.TimeAdvanceLimit(); held {
+		t.Error("request still outstanding after its release")
+	}
+}
@@ -0,0 +1,45 @@
+package evtm
+
+import (
+	"fmt"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// AssertionError reports a temporal assertion that did not hold
+type AssertionError struct {
+	Msg  string      // the message given to ScheduleAssertion
+	Time vrtime.Time // virtual time at which the assertion was checked
+}
+
+// Error describes the failed assertion
+func (ae *AssertionError) Error() string {
+	return fmt.Sprintf("assertion failed at %g: %s", ae.Time.Seconds(), ae.Msg)
+}
+
+// ScheduleAssertion checks predicate over the state of the model at virtual time at, once
+// every other event at that tick has executed, and aborts the run (see Abort) with an
+// *AssertionError carrying msg if it does not hold.  This expresses temporal checks, e.g.,
+// that a queue must be empty by t=100s, inside the simulation itself; errors.As on the error
+// RunE returns, or after Run on Err, finds the AssertionError.  If at is not after the current time the check is
+// made at the end of the current tick.  ScheduleAssertion returns the eventId of the event
+// that makes, or leads to, the check, which can be cancelled to withdraw the assertion.
+func (evtmgr *EventManager) ScheduleAssertion(at vrtime.Time, predicate func() bool, msg string) int {
+	check := func(evtmgr *EventManager, context any, data any) any {
+		if !predicate() {
+			evtmgr.Abort(&AssertionError{Msg: msg, Time: evtmgr.CurrentTime()})
+		}
+		return nil
+	}
+
+	now := evtmgr.CurrentTicks()
+	if at.Ticks() <= now {
+		eventID, _ := evtmgr.ScheduleEndOfTick(nil, nil, check)
+		return eventID
+	}
+	eventID, _ := evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		evtmgr.ScheduleEndOfTick(nil, nil, check)
+		return nil
+	}, vrtime.CreateTime(at.Ticks()-now, 0))
+	return eventID
+}
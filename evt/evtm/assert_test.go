@@ -0,0 +1,35 @@
+package evtm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestScheduleAssertion checks that an assertion is made after every other event at its time,
+// and that one that fails aborts the run with an AssertionError RunE returns
+func TestScheduleAssertion(t *testing.T) {
+	evtmgr := New()
+	pending := 2
+	drain := func(*EventManager, any, any) any {
+		pending -= 1
+		return nil
+	}
+	evtmgr.ScheduleAssertion(vrtime.CreateTime(100, 0), func() bool { return pending == 0 }, "queue drained")
+	evtmgr.Schedule(nil, nil, drain, vrtime.CreateTime(50, 0))
+	evtmgr.Schedule(nil, nil, drain, vrtime.CreateTime(100, 0))
+	if err := evtmgr.RunE(1); err != nil {
+		t.Fatalf("assertion that holds at the end of its tick failed: %v", err)
+	}
+
+	evtmgr.ScheduleAssertion(vrtime.CreateTime(10, 0), func() bool { return false }, "never holds")
+	err := evtmgr.RunE(2)
+	var ae *AssertionError
+	if !errors.As(err, &ae) || ae.Msg != "never holds" {
+		t.Fatalf("RunE returned %v, want the failed assertion", err)
+	}
+	if at := ae.Time.Ticks() - evtmgr.CurrentTicks(); at != 0 {
+		t.Errorf("run left the clock %d ticks from the failed assertion", at)
+	}
+}
@@ -0,0 +1,75 @@
+package evtm
+
+import (
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TimeAuthority owns the clock of an EventManager put in slave mode by SetTimeAuthority.
+// It is the inverse of wallclock mode: rather than advancing virtual time in step with real
+// time, the EventManager advances it only as far as the authority grants, as is needed when
+// another tool, e.g., a co-simulation master, owns the clock.
+type TimeAuthority interface {
+	// Grant is called by the thread running the EventManager when it has dispatched every event
+	// at or before the time last granted.  next is the time of its next pending event, or
+	// InfinityTime if there is none.  Grant blocks until the authority lets the EventManager
+	// advance, and returns the time up to which, inclusive, it may now dispatch events.  A false
+	// ok ends the run, as Stop does.
+	Grant(next vrtime.Time) (granted vrtime.Time, ok bool)
+}
+
+// SetTimeAuthority puts the EventManager in slave mode under auth from the next run on, or
+// takes it out of slave mode if auth is nil.  In slave mode a run dispatches only events at or
+// before the time granted; once they are done its clock is set to the granted time, and it asks
+// for another grant.  The run still ends at its own limit, and while the event list is empty it
+// asks for grants rather than returning or suspending.
+func (evtmgr *EventManager) SetTimeAuthority(auth TimeAuthority) {
+	evtmgr.mu.Lock()
+	evtmgr.authority = auth
+	evtmgr.mu.Unlock()
+}
+
+// TimeAuthorityFunc adapts a function to the TimeAuthority interface
+type TimeAuthorityFunc func(next vrtime.Time) (vrtime.Time, bool)
+
+// Grant calls the function
+func (fn TimeAuthorityFunc) Grant(next vrtime.Time) (vrtime.Time, bool) {
+	return fn(next)
+}
+
+// The EventManager can also be driven by a coordination layer, e.g., an HLA federate
+// ambassador, an FMI master, or a custom coupler, that negotiates each advance of time itself.
+// The layer asks the EventManager when it next needs to advance, negotiates a grant with
+// its peers, and has the EventManager advance to the grant, in lock step.
+
+// ProposeNextEventTime returns the time of the next pending event, the earliest time to which
+// the EventManager needs to advance, or InfinityTime if no event is pending
+func (evtmgr *EventManager) ProposeNextEventTime() vrtime.Time {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	next, pending := evtmgr.EventList.TryMinTime()
+	if !pending {
+		return vrtime.InfinityTime()
+	}
+	return next
+}
+
+// AdvanceTo dispatches every pending event at or before the time granted, including those
+// the dispatched handlers schedule within it, and leaves the clock at granted.  It is Run to
+// a limit given as a Time, except that Run ends after the first event at its limit while
+// AdvanceTo dispatches all of them.  It returns StopLimit unless a handler stopped or aborted
+// the run.
+// A grant earlier than the current time does nothing.  The EventManager should not be in
+// External mode, in which AdvanceTo suspends rather than returning when it runs out of events,
+// nor in slave mode.
+func (evtmgr *EventManager) AdvanceTo(granted vrtime.Time) StopReason {
+	if granted.Ticks() < evtmgr.CurrentTicks() {
+		return StopLimit
+	}
+	reason := evtmgr.run(granted.Ticks(), time.Time{}, ClockToLimit, true)
+	if reason == StopEmpty {
+		reason = StopLimit
+	}
+	return reason
+}
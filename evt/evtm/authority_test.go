@@ -0,0 +1,87 @@
+package evtm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestAdvanceToDrainsGrant checks that AdvanceTo dispatches every event at the time granted,
+// including one a handler schedules there, and none beyond it
+func TestAdvanceToDrainsGrant(t *testing.T) {
+	evtmgr := New()
+	var dispatched []int
+	record := func(evtmgr *EventManager, context any, data any) any {
+		dispatched = append(dispatched, data.(int))
+		if data.(int) == 1 {
+			evtmgr.Schedule(nil, 9, func(*EventManager, any, any) any {
+				dispatched = append(dispatched, 9)
+				return nil
+			}, vrtime.CreateTime(0, 0))
+		}
+		return nil
+	}
+	for idx := 1; idx <= 3; idx++ {
+		evtmgr.Schedule(nil, idx, record, vrtime.CreateTime(100, 0))
+	}
+	evtmgr.Schedule(nil, 4, record, vrtime.CreateTime(101, 0))
+
+	if reason := evtmgr.AdvanceTo(vrtime.CreateTime(100, 0)); reason != StopLimit {
+		t.Fatalf("AdvanceTo returned %v, want %v", reason, StopLimit)
+	}
+	if len(dispatched) != 4 {
+		t.Fatalf("AdvanceTo dispatched %v, want the 4 events at the grant", dispatched)
+	}
+	if pending := evtmgr.EventList.Len(); pending != 1 {
+		t.Errorf("%d events pending after AdvanceTo, want 1", pending)
+	}
+	if now := evtmgr.CurrentTicks(); now != 100 {
+		t.Errorf("clock at %d after AdvanceTo, want 100", now)
+	}
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(200, 0))
+	if len(dispatched) != 5 || evtmgr.CurrentTicks() != 200 {
+		t.Errorf("second AdvanceTo dispatched %v and left the clock at %d", dispatched, evtmgr.CurrentTicks())
+	}
+}
+
+// TestTimeAuthority checks that in slave mode a run dispatches only the events the authority
+// has granted, tells it the time of the next event each time it asks, moves the clock up to
+// each grant, and stops when the authority ends it
+func TestTimeAuthority(t *testing.T) {
+	evtmgr := New()
+	var dispatched []int64
+	record := func(evtmgr *EventManager, context any, data any) any {
+		dispatched = append(dispatched, evtmgr.CurrentTicks())
+		return nil
+	}
+	for _, at := range []int64{3, 7, 10, 20, 25} {
+		evtmgr.Schedule(nil, nil, record, vrtime.CreateTime(at, 0))
+	}
+
+	grants := []int64{5, 12, 30}
+	var asked []int64
+	var clocks []int64
+	evtmgr.SetTimeAuthority(TimeAuthorityFunc(func(next vrtime.Time) (vrtime.Time, bool) {
+		asked = append(asked, next.Ticks())
+		clocks = append(clocks, evtmgr.CurrentTicks())
+		if len(grants) == 0 {
+			return vrtime.Time{}, false
+		}
+		grant := grants[0]
+		grants = grants[1:]
+		return vrtime.CreateTime(grant, 0), true
+	}))
+
+	if reason := evtmgr.RunUntil(1); reason != StopStopped {
+		t.Errorf("run ended for %q, want %q", reason, StopStopped)
+	}
+	infinity := vrtime.InfinityTime().Ticks()
+	if fmt.Sprint(dispatched) != "[3 7 10 20 25]" || fmt.Sprint(asked) != fmt.Sprint([]int64{3, 7, 20, infinity}) {
+		t.Errorf("dispatched at %v after asking for %v", dispatched, asked)
+	}
+	if fmt.Sprint(clocks) != "[0 5 12 30]" {
+		t.Errorf("clock at %v when asking, want [0 5 12 30]", clocks)
+	}
+}
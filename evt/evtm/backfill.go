@@ -0,0 +1,67 @@
+package evtm
+
+import (
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// A digital twin typically starts from history: it replays the timestamped input recorded
+// since some instant in the past as fast as it can, until virtual time has caught up with
+// the present, and then carries on live, with virtual time aligned to the wallclock.
+
+// RunBackfill runs the EventManager to the virtual time LimitTime in two phases.  The clock
+// maps to the calendar through the epoch (see SetEpoch).  Until virtual time has caught up to
+// the present, events are dispatched as fast as possible, in passes that each run to the
+// present as it stands when the pass begins; a pass that finds nothing to dispatch, or an
+// event list that runs dry, ends the backfill, and the clock is brought to the present.  The
+// run then continues in wallclock mode to LimitTime, and the EventManager is left in
+// wallclock mode.  External mode, if set, takes effect only in the live phase.  With no epoch
+// set, virtual time zero is taken to be the moment RunBackfill is called, so there is nothing
+// to backfill.  RunBackfill returns why the run ended, as RunFor does.
+func (evtmgr *EventManager) RunBackfill(LimitTime float64) StopReason {
+	limit := vrtime.SecondsToTicks(LimitTime)
+	evtmgr.mu.Lock()
+	if !evtmgr.epochSet {
+		evtmgr.epoch, evtmgr.epochSet = time.Now().Add(-time.Duration(evtmgr.Time.Seconds()*1e9)), true
+	}
+	epoch := evtmgr.epoch
+	external := evtmgr.External
+	evtmgr.Wallclock, evtmgr.External = false, false
+	evtmgr.mu.Unlock()
+
+	present := func() int64 {
+		return vrtime.SecondsToTicks(time.Since(epoch).Seconds())
+	}
+
+	for {
+		upTo := present()
+		if upTo >= limit {
+			// the whole of the run lies in the past
+			reason := evtmgr.run(limit, time.Time{}, ClockToLimit, false)
+			evtmgr.SetExternal(external)
+			return reason
+		}
+		before := evtmgr.EventsDispatched()
+		reason := evtmgr.run(upTo, time.Time{}, ClockToLimit, false)
+		if reason == StopEmpty {
+			evtmgr.SetTime(vrtime.CreateTime(upTo, 0))
+			break
+		}
+		if reason != StopLimit {
+			evtmgr.SetExternal(external)
+			return reason
+		}
+		if evtmgr.EventsDispatched() == before {
+			break
+		}
+	}
+
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("backfill caught up at %f, going live\n", evtmgr.CurrentSeconds())
+	}
+	evtmgr.mu.Lock()
+	evtmgr.Wallclock, evtmgr.External = true, external
+	evtmgr.mu.Unlock()
+	return evtmgr.run(limit, time.Time{}, ClockToLimit, false)
+}
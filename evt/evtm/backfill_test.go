@@ -0,0 +1,37 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRunBackfill checks that events in the past of the epoch are dispatched at once, and
+// those after the present in step with real time
+func TestRunBackfill(t *testing.T) {
+	evtmgr := New()
+	start := time.Now()
+	evtmgr.SetEpoch(start.Add(-time.Second))
+	dispatched := make(map[float64]time.Duration)
+	record := func(evtmgr *EventManager, context any, data any) any {
+		dispatched[data.(float64)] = time.Since(start)
+		return nil
+	}
+	for _, at := range []float64{0.1, 0.5, 0.9, 1.1} {
+		evtmgr.Schedule(nil, at, record, vrtime.SecondsToTime(at))
+	}
+
+	if reason := evtmgr.RunBackfill(1.2); reason != StopLimit && reason != StopEmpty {
+		t.Errorf("run ended for %q", reason)
+	}
+	if len(dispatched) != 4 || !evtmgr.IsWallclock() {
+		t.Fatalf("dispatched %v, wallclock %v; want all four, live", dispatched, evtmgr.IsWallclock())
+	}
+	if history := dispatched[0.9]; history > 50*time.Millisecond {
+		t.Errorf("history replayed in %v, want no waiting", history)
+	}
+	if live := dispatched[1.1]; live < 100*time.Millisecond {
+		t.Errorf("live event dispatched %v after the start, want it 100ms in", live)
+	}
+}
@@ -0,0 +1,78 @@
+package evtm
+
+import (
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Strict wallclock pacing sleeps before every event until it is due, which is more than a
+// loosely coupled hardware integration needs and costs a sleep, with its lateness, per event.
+// Under a tolerance band virtual time is measured against real time from the anchor of the
+// run (see timeAnchor), and may run ahead of it, or fall behind, by the widths of the band before anything is
+// done: the EventManager sleeps only to bring virtual time back within the band ahead, and
+// raises an alarm when it falls further behind than the band allows.
+
+// ToleranceBand bounds the drift of virtual from real time allowed in wallclock mode
+type ToleranceBand struct {
+	// Ahead is how far virtual time may run ahead of real time before the EventManager sleeps
+	Ahead time.Duration
+
+	// Behind is how far virtual time may fall behind real time before Alarm is called
+	Behind time.Duration
+
+	// Alarm, if not nil, is called by the thread running the EventManager when virtual time
+	// falls more than Behind behind real time, with how far behind it is.  It is called once
+	// per excursion out of the band, and again only after virtual time has come back within it.
+	Alarm func(evtmgr *EventManager, lag time.Duration)
+}
+
+// bander holds the state of pacing under a tolerance band
+type bander struct {
+	band ToleranceBand
+	on   bool
+	late bool // true while virtual time is further behind than the band allows
+}
+
+// SetToleranceBand selects pacing under the tolerance band given, in place of strict pacing,
+// for wallclock mode.  The zero ToleranceBand restores strict pacing.
+func (evtmgr *EventManager) SetToleranceBand(band ToleranceBand) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.banding.band = band
+	evtmgr.banding.on = band.Ahead > 0 || band.Behind > 0 || band.Alarm != nil
+}
+
+// bandDelay sleeps, if need be, to keep the event due at tgt within the tolerance band, and
+// raises the alarm if it is too far behind.  It returns false if the sleep was interrupted.
+func (evtmgr *EventManager) bandDelay(tgt vrtime.Time) bool {
+	evtmgr.mu.Lock()
+	bd := &evtmgr.banding
+	ahead := time.Until(evtmgr.dueAt(tgt.Ticks()))
+	band := bd.band
+	alarm := false
+	if -ahead > band.Behind {
+		alarm = !bd.late
+		bd.late = true
+	} else {
+		bd.late = false
+	}
+	rescaled := evtmgr.adaptTo(-ahead-band.Behind, 0)
+	evtmgr.mu.Unlock()
+	if rescaled {
+		evtmgr.reportAdaptation()
+	}
+
+	if alarm {
+		if evtmgr.tracing(TraceInfo) {
+			evtmgr.tracef("virtual time %f behind real time by %v\n", tgt.Seconds(), -ahead)
+		}
+		if band.Alarm != nil {
+			band.Alarm(evtmgr, -ahead)
+		}
+	}
+	if ahead > band.Ahead {
+		return evtmgr.wait(ahead - band.Ahead)
+	}
+	return true
+}
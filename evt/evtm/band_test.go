@@ -0,0 +1,41 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestToleranceBand checks that virtual time may run ahead of real time within the band
+// without a sleep, and that falling further behind than the band allows raises the alarm
+// once per excursion
+func TestToleranceBand(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	var lags []time.Duration
+	evtmgr.SetToleranceBand(ToleranceBand{Ahead: time.Second, Behind: 10 * time.Millisecond,
+		Alarm: func(evtmgr *EventManager, lag time.Duration) { lags = append(lags, lag) }})
+	noop := func(*EventManager, any, any) any { return nil }
+	for idx := 1; idx <= 5; idx++ {
+		evtmgr.Schedule(nil, nil, noop, vrtime.SecondsToTime(0.04*float64(idx)))
+	}
+	start := time.Now()
+	evtmgr.Run(1)
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("run of 0.2s within a band of 1s ahead took %v", elapsed)
+	}
+
+	evtmgr = New()
+	evtmgr.SetWallclock(true)
+	evtmgr.SetToleranceBand(ToleranceBand{Behind: 10 * time.Millisecond,
+		Alarm: func(evtmgr *EventManager, lag time.Duration) { lags = append(lags, lag) }})
+	slow := func(*EventManager, any, any) any { time.Sleep(30 * time.Millisecond); return nil }
+	for idx := 0; idx < 3; idx++ {
+		evtmgr.Schedule(nil, nil, slow, vrtime.SecondsToTime(0.001*float64(idx)))
+	}
+	evtmgr.Run(1)
+	if len(lags) != 1 || lags[0] < 20*time.Millisecond {
+		t.Errorf("alarms at lags %v, want one of at least 20ms", lags)
+	}
+}
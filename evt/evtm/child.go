@@ -0,0 +1,189 @@
+package evtm
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// An EventManager may host child EventManagers, each the engine of an independently developed
+// sub-model with its own internal scheduling.  The parent drives a child with a single event of
+// its own, placed at the parent time at which the child's next event falls; when that event is
+// dispatched the child dispatches its events up to that time, and the driving event is placed
+// again.  The child is thus composed into the parent's stream of events without the parent
+// knowing anything of the child's events, and a child may host children of its own.
+
+// TimeMap maps the virtual time of a parent onto that of a child.  A child reads
+// (p - Origin) * Rate ticks when its parent reads p ticks.
+type TimeMap struct {
+	Origin vrtime.Time // parent time at which the child's clock reads zero
+	Rate   float64     // child ticks per parent tick; zero is taken to be one
+}
+
+// childTicks maps a parent tick count onto the child's clock, rounding down
+func (tm TimeMap) childTicks(parentTicks int64) int64 {
+	return int64(math.Floor(float64(parentTicks-tm.Origin.Ticks()) * tm.Rate))
+}
+
+// parentTicks maps a child tick count onto the parent's clock, rounding up, and returns
+// false if the result cannot be represented
+func (tm TimeMap) parentTicks(childTicks int64) (int64, bool) {
+	ticks := math.Ceil(float64(childTicks)/tm.Rate) + float64(tm.Origin.Ticks())
+	if ticks >= math.MaxInt64/2 {
+		return 0, false
+	}
+	return int64(ticks), true
+}
+
+// Child is an EventManager hosted by a parent EventManager
+type Child struct {
+	parent      *EventManager
+	mgr         *EventManager
+	tmap        TimeMap
+	driverID    int   // identifier of the parent event that drives the child, evtq.InvalidEventID if none
+	driverTicks int64 // parent time of that event, in ticks
+	driving     bool  // true while the child is dispatching its events
+	detached    bool  // true once Detach has been called
+	mu          sync.Mutex
+}
+
+// AddChild makes child a child of the EventManager, with its clock related to the parent's by
+// tmap.  Events the child has, and events later scheduled on it by any thread, are dispatched in
+// the course of the parent's runs, at the parent times onto which their times map; the child is
+// not to be run directly, nor put into external mode, while it is hosted.  If an event handler of
+// the child calls Abort the parent's run is aborted with the same error.
+func (evtmgr *EventManager) AddChild(child *EventManager, tmap TimeMap) *Child {
+	if tmap.Rate == 0 {
+		tmap.Rate = 1
+	}
+	ch := &Child{parent: evtmgr, mgr: child, tmap: tmap, driverID: evtq.InvalidEventID}
+
+	child.mu.Lock()
+	child.host = ch
+	child.mu.Unlock()
+
+	ch.mu.Lock()
+	ch.arm()
+	ch.mu.Unlock()
+	return ch
+}
+
+// Manager returns the child EventManager
+func (ch *Child) Manager() *EventManager {
+	return ch.mgr
+}
+
+// ChildTime returns the time on the child's clock corresponding to the given parent time
+func (ch *Child) ChildTime(parentTime vrtime.Time) vrtime.Time {
+	return vrtime.CreateTime(ch.tmap.childTicks(parentTime.Ticks()), 0)
+}
+
+// Detach ends the hosting of the child.  Its pending events stay in its event list, and it
+// may be run on its own again.
+func (ch *Child) Detach() {
+	ch.mu.Lock()
+	ch.detached = true
+	if ch.driverID != evtq.InvalidEventID {
+		ch.parent.RemoveEvent(ch.driverID)
+		ch.driverID = evtq.InvalidEventID
+	}
+	ch.mu.Unlock()
+
+	ch.mgr.mu.Lock()
+	if ch.mgr.host == ch {
+		ch.mgr.host = nil
+	}
+	ch.mgr.mu.Unlock()
+}
+
+// rearm places the driving event again, following a change to the child's event list
+// made other than by the child's own event handlers
+func (ch *Child) rearm() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.driving || ch.detached {
+		return
+	}
+	ch.arm()
+}
+
+// arm places the driving event at the parent time of the child's next event, if it is
+// not there already.  Called with ch.mu held.
+func (ch *Child) arm() {
+	ticks := int64(0)
+	next, due := ch.mgr.EventList.TryMinTime()
+	if due {
+		ticks, due = ch.tmap.parentTicks(next.Ticks())
+	}
+	if ch.driverID != evtq.InvalidEventID {
+		if due && ticks == ch.driverTicks {
+			return
+		}
+		ch.parent.RemoveEvent(ch.driverID)
+		ch.driverID = evtq.InvalidEventID
+	}
+	if !due {
+		return
+	}
+
+	// the child's next event may lie in the parent's past, if it was scheduled while the
+	// parent was between events; it is then dispatched at the parent's present time
+	offset := ticks - ch.parent.CurrentTicks()
+	if offset < 0 {
+		offset = 0
+	}
+	ch.driverID, _ = ch.parent.Schedule(ch, nil, ch.drive, vrtime.CreateTime(offset, 0))
+	ch.driverTicks = ticks
+}
+
+// Sync brings the child up to the parent's present time, dispatching the child's events
+// that are due.  The child's clock otherwise advances only as its events are dispatched, so
+// an event scheduled on it by a handler of the parent would be timed from the child's last
+// event; calling Sync first times it from the present.  Sync has no effect if the child is
+// detached or is already dispatching its events.
+func (ch *Child) Sync() {
+	ch.mu.Lock()
+	if ch.detached || ch.driving {
+		ch.mu.Unlock()
+		return
+	}
+	ch.driving = true
+	ch.mu.Unlock()
+	ch.advance(ch.parent)
+}
+
+// drive is the handler of the driving event
+func (ch *Child) drive(parent *EventManager, context any, data any) any {
+	ch.mu.Lock()
+	ch.driverID = evtq.InvalidEventID
+	if ch.detached || ch.driving {
+		ch.mu.Unlock()
+		return nil
+	}
+	ch.driving = true
+	ch.mu.Unlock()
+	ch.advance(parent)
+	return nil
+}
+
+// advance runs the child up to the parent's present time, dispatching every child event at
+// or before it, then places the driving event again.  Called with ch.driving set.
+func (ch *Child) advance(parent *EventManager) {
+
+	ch.mgr.run(ch.tmap.childTicks(parent.CurrentTicks()), time.Time{}, ClockToLimit, true)
+	err := ch.mgr.Err()
+
+	ch.mu.Lock()
+	ch.driving = false
+	if !ch.detached {
+		ch.arm()
+	}
+	ch.mu.Unlock()
+
+	if err != nil {
+		parent.Abort(err)
+	}
+}
@@ -0,0 +1,61 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestChildDrivenOnce checks that a single driving event dispatches every child event at
+// the parent time it falls on, and that the child's clock follows the time map
+func TestChildDrivenOnce(t *testing.T) {
+	parent := New()
+	child := New()
+	var at []int64
+	for idx := 0; idx < 3; idx++ {
+		child.Schedule(nil, nil, func(child *EventManager, context any, data any) any {
+			at = append(at, child.CurrentTicks())
+			return nil
+		}, vrtime.CreateTime(10, 0))
+	}
+	parent.AddChild(child, TimeMap{Origin: vrtime.CreateTime(5, 0), Rate: 2})
+
+	parent.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(at) != 3 || at[0] != 10 || at[2] != 10 {
+		t.Fatalf("child events dispatched at %v, want three at 10", at)
+	}
+	if driven := parent.EventsDispatched(); driven != 1 {
+		t.Errorf("parent dispatched %d driving events, want 1", driven)
+	}
+	if now := parent.CurrentTicks(); now != 100 {
+		t.Errorf("parent clock at %d, want 100", now)
+	}
+}
+
+// TestChildSync checks that Sync dispatches every child event that is due at the parent's
+// present time, not just the first
+func TestChildSync(t *testing.T) {
+	parent := New()
+	child := New()
+	dispatched := 0
+	synced := -1
+	var ch *Child
+	parent.Schedule(nil, nil, func(*EventManager, any, any) any {
+		dispatched = 0
+		ch.Sync()
+		synced = dispatched
+		return nil
+	}, vrtime.CreateTime(5, 0))
+	for idx := 0; idx < 3; idx++ {
+		child.Schedule(nil, nil, func(*EventManager, any, any) any {
+			dispatched += 1
+			return nil
+		}, vrtime.CreateTime(5, 0))
+	}
+	ch = parent.AddChild(child, TimeMap{})
+
+	parent.AdvanceTo(vrtime.CreateTime(10, 0))
+	if synced != 3 {
+		t.Errorf("Sync dispatched %d of the 3 child events due", synced)
+	}
+}
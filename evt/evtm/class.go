@@ -0,0 +1,116 @@
+package evtm
+
+import (
+	"github.com/iti/evt/vrtime"
+)
+
+// Events may be given a class, and the dispatch of every event of a class switched off
+// and on while the model runs.  This is handy for optional behaviour that is expensive,
+// e.g., detailed logging or background maintenance, that an experiment wants to toggle
+// part way through.  A class is identified by a name of the model's choosing.
+
+// classHold is the state of a class whose dispatch is switched off
+type classHold struct {
+	buffer bool     // keep the events withheld, rather than discarding them
+	held   []*Event // events withheld, in the order they came due
+}
+
+// SetEventClass gives the pending event with identifier eventID the named class.
+// It returns false if there is no such event.
+func (evtmgr *EventManager) SetEventClass(eventID int, class string) bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	item := evtmgr.EventList.GetValue(eventID)
+	if item == nil {
+		return false
+	}
+	item.(*Event).Class = class
+	return true
+}
+
+// DisableClass switches off the dispatch of events of the named class.  When such an event
+// comes due it is taken from the event list without its handler being called.  If buffer is
+// true the event is kept, and dispatched once the class is enabled again; otherwise it is
+// discarded, its outcome reported as cancelled, and the events scheduled with
+// ScheduleAfterEvent to follow it removed.  Events withheld are not counted by
+// EventsDispatched.
+func (evtmgr *EventManager) DisableClass(class string, buffer bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.disabled == nil {
+		evtmgr.disabled = make(map[string]*classHold)
+	}
+	if hold, present := evtmgr.disabled[class]; present {
+		hold.buffer = buffer
+		return
+	}
+	evtmgr.disabled[class] = &classHold{buffer: buffer}
+}
+
+// EnableClass switches the dispatch of events of the named class back on.  The events
+// buffered while it was off are returned to the event list at the current time, keeping
+// their priorities and lanes, and so are dispatched before virtual time advances.  They are
+// given new event identifiers, which callbacks registered with OnResult, and events scheduled
+// to follow them, follow.  EnableClass returns the number of events so returned.
+func (evtmgr *EventManager) EnableClass(class string) int {
+	evtmgr.mu.Lock()
+	hold, present := evtmgr.disabled[class]
+	if !present {
+		evtmgr.mu.Unlock()
+		return 0
+	}
+	delete(evtmgr.disabled, class)
+	for _, event := range hold.held {
+		event.Time = vrtime.CreateTime(evtmgr.Time.Ticks(), event.Time.Pri())
+		oldID := event.EventID
+		event.EventID = evtmgr.EventList.InsertInLane(event, event.Time, event.lane)
+		evtmgr.reroute(oldID, event.EventID)
+		if deps, present := evtmgr.after[oldID]; present {
+			delete(evtmgr.after, oldID)
+			evtmgr.after[event.EventID] = deps
+		}
+	}
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("EnableClass %s returns %d events\n", class, len(hold.held))
+	}
+	evtmgr.mu.Unlock()
+	if len(hold.held) > 0 {
+		evtmgr.release()
+	}
+	return len(hold.held)
+}
+
+// ClassEnabled returns false while the dispatch of events of the named class is switched off.
+func (evtmgr *EventManager) ClassEnabled(class string) bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	_, present := evtmgr.disabled[class]
+	return !present
+}
+
+// withheld reports whether an event that has come due belongs to a class that is switched off,
+// and whether it has been buffered, as the class calls for, rather than discarded.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) withheld(event *Event) (withheld bool, buffered bool) {
+	hold, present := evtmgr.disabled[event.Class]
+	if !present {
+		return false, false
+	}
+	if hold.buffer {
+		hold.held = append(hold.held, event)
+	}
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("withhold event %d of class %s\n", event.EventID, event.Class)
+	}
+	return true, hold.buffer
+}
+
+// buffered returns the number of events buffered by classes that are switched off.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) buffered() int {
+	count := 0
+	for _, hold := range evtmgr.disabled {
+		count += len(hold.held)
+	}
+	return count
+}
@@ -0,0 +1,75 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestClassBuffered checks that an event buffered by a disabled class is dispatched once the
+// class is enabled, in its lane, with its outcome and its followers waiting until then
+func TestClassBuffered(t *testing.T) {
+	evtmgr := New()
+	if err := evtmgr.SetLaneOrder(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, data.(string))
+		return evtmgr.CurrentTicks()
+	}
+	heldID, _ := evtmgr.ScheduleInLane(1, nil, "held", record, vrtime.CreateTime(10, 0))
+	evtmgr.SetEventClass(heldID, "optional")
+	evtmgr.ScheduleAfterEvent(heldID, nil, "after held", record, vrtime.CreateTime(5, 0))
+	var results []Result
+	evtmgr.OnResult(heldID, func(res Result) { results = append(results, res) })
+	evtmgr.DisableClass("optional", true)
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(50, 0))
+	if len(order) != 0 || len(results) != 0 {
+		t.Fatalf("buffered event dispatched %v with results %v before its class was enabled", order, results)
+	}
+
+	// the lane 0 event has the lesser priority, so only its lane puts the buffered one first
+	evtmgr.Schedule(nil, "lane0", record, vrtime.CreateTime(0, 0))
+	if returned := evtmgr.EnableClass("optional"); returned != 1 {
+		t.Fatalf("EnableClass returned %d events, want 1", returned)
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(order) != 3 || order[0] != "held" || order[2] != "after held" {
+		t.Errorf("events dispatched in the order %v, want held, lane0, after held", order)
+	}
+	if len(results) != 1 || results[0].Cancelled || results[0].Value != int64(50) {
+		t.Errorf("buffered event reported %v, want it dispatched at 50", results)
+	}
+}
+
+// TestClassDiscarded checks that an event discarded by a disabled class is reported as
+// cancelled, and takes its followers with it
+func TestClassDiscarded(t *testing.T) {
+	evtmgr := New()
+	dispatched := 0
+	count := func(*EventManager, any, any) any {
+		dispatched += 1
+		return nil
+	}
+	discardedID, _ := evtmgr.Schedule(nil, nil, count, vrtime.CreateTime(10, 0))
+	evtmgr.SetEventClass(discardedID, "optional")
+	afterID, _ := evtmgr.ScheduleAfterEvent(discardedID, nil, nil, count, vrtime.CreateTime(5, 0))
+	cancelled := make(map[int]bool)
+	for _, eventID := range []int{discardedID, afterID} {
+		evtmgr.OnResult(eventID, func(res Result) { cancelled[res.EventID] = res.Cancelled })
+	}
+	evtmgr.DisableClass("optional", false)
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if dispatched != 0 {
+		t.Errorf("%d events dispatched, want none", dispatched)
+	}
+	if !cancelled[discardedID] || !cancelled[afterID] {
+		t.Errorf("outcomes %v, want both events cancelled", cancelled)
+	}
+	if returned := evtmgr.EnableClass("optional"); returned != 0 || evtmgr.EventList.Len() != 0 {
+		t.Errorf("EnableClass returned %d events with %d pending, want none", returned, evtmgr.EventList.Len())
+	}
+}
@@ -0,0 +1,67 @@
+package evtm
+
+import (
+	"math"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Clock is a source of time for application code.  Code that reads the time and waits
+// through a Clock, rather than calling package [time] directly, runs unmodified against
+// the real clock (SystemClock) or in virtual time under an EventManager, which implements Clock.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After returns a channel on which the time is sent once d has elapsed
+	After(d time.Duration) <-chan time.Time
+
+	// Sleep blocks the calling goroutine until d has elapsed
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the Clock of package [time]
+var SystemClock Clock = systemClock{}
+
+// systemClock implements Clock with the real clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+// durationToTime converts a duration to an offset in virtual time, treating a negative
+// duration as zero
+func durationToTime(d time.Duration) vrtime.Time {
+	if d < 0 {
+		d = 0
+	}
+	return vrtime.SecondsToTime(d.Seconds())
+}
+
+// Now returns the calendar instant of the current virtual time: the epoch (see SetEpoch)
+// advanced by the virtual time elapsed, or, with no epoch set, the zero time.Time so advanced.
+func (evtmgr *EventManager) Now() time.Time {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.epoch.Add(time.Duration(math.Round(evtmgr.Time.Seconds() * 1e9)))
+}
+
+// After returns a channel on which Now is sent once the virtual time has advanced d
+// beyond the current time, in the manner of [time.After].  The value is sent by an event,
+// so the channel is buffered and the send never blocks the dispatch thread.
+func (evtmgr *EventManager) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	evtmgr.AfterFunc(durationToTime(d), func() {
+		ch <- evtmgr.Now()
+	})
+	return ch
+}
+
+// Sleep blocks the calling goroutine until the virtual time has advanced d beyond the current
+// time.  It is meant for goroutines other than the one running the EventManager, typically
+// in External mode (see SetExternal); called from an event handler it would block forever.
+func (evtmgr *EventManager) Sleep(d time.Duration) {
+	<-evtmgr.After(d)
+}
@@ -0,0 +1,44 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestClock checks that the EventManager, as a Clock, tells the time from its epoch, fires
+// After when virtual time reaches it, and wakes a goroutine in Sleep
+func TestClock(t *testing.T) {
+	var clock Clock = New()
+	evtmgr := clock.(*EventManager)
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	evtmgr.SetEpoch(epoch)
+
+	ch := clock.After(3 * time.Second)
+	evtmgr.AdvanceTo(vrtime.SecondsToTime(2))
+	select {
+	case at := <-ch:
+		t.Fatalf("After fired at %v, before its time", at)
+	default:
+	}
+	evtmgr.AdvanceTo(vrtime.SecondsToTime(5))
+	if at := <-ch; !at.Equal(epoch.Add(3 * time.Second)) {
+		t.Errorf("After fired at %v, want %v", at, epoch.Add(3*time.Second))
+	}
+	if now := clock.Now(); !now.Equal(epoch.Add(5 * time.Second)) {
+		t.Errorf("Now is %v, want %v", now, epoch.Add(5*time.Second))
+	}
+
+	evtmgr.SetExternal(true)
+	woke := make(chan time.Time, 1)
+	go func() {
+		clock.Sleep(time.Minute)
+		woke <- clock.Now()
+		evtmgr.Stop()
+	}()
+	evtmgr.Run(1e6)
+	if at := <-woke; !at.Equal(epoch.Add(65 * time.Second)) {
+		t.Errorf("Sleep woke at %v, want %v", at, epoch.Add(65*time.Second))
+	}
+}
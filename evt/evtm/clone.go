@@ -0,0 +1,97 @@
+package evtm
+
+import (
+	"math/rand"
+
+	"github.com/iti/evt/internal/slab"
+)
+
+// EventCopier returns the context and data to give the copy of an event in a clone of an
+// EventManager (see Clone).  A model whose events refer to its own state typically maps
+// the context to the corresponding entity of a copy of the model made alongside.
+type EventCopier func(event *Event) (context any, data any)
+
+// Clone returns an independent EventManager that continues from the state of this one: the
+// same clock, a copy of every pending event (with the same eventIds, times, and lanes),
+// events withheld by disabled classes, the dependencies of ScheduleAfterEvent, and random
+// number streams positioned where this EventManager's are, so that both go on to draw the
+// same numbers.  An optimization or planning loop can thereby branch a simulation at a
+// decision point and explore the alternatives.
+//
+// The context and data of each copied event are given by copier; a nil copier shares them
+// with the original, which is enough only when they are immutable.  Filters, interceptors, hooks,
+// observers, and WaitUntil conditions are not copied, as they are bound to this EventManager,
+// nor is the hosting of this EventManager as a child.  Nor is the manager identifier (see
+// SetManagerID): the clone numbers its events as the original does, so it must be given an
+// identifier of its own for the GlobalIDs of the two to differ.  The clone is not running.  Clone may be
+// called from an event handler, in which case the clone's clock is the time of the event
+// being dispatched.
+func (evtmgr *EventManager) Clone(copier EventCopier) *EventManager {
+	dup := func(event *Event) *Event {
+		copied := new(Event)
+		*copied = *event
+		if copier != nil {
+			copied.Context, copied.Data = copier(event)
+		}
+		return copied
+	}
+
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+
+	clone := &EventManager{
+		EventList:  evtmgr.EventList.Clone(func(value any) any { return dup(value.(*Event)) }),
+		Time:       evtmgr.Time,
+		NumEvts:    evtmgr.NumEvts,
+		Wallclock:  evtmgr.Wallclock,
+		External:   evtmgr.External,
+		suspChan:   make(chan bool, 1),
+		wake:       make(chan struct{}, 1),
+		autoPri:    evtmgr.autoPri,
+		nowPri:     evtmgr.nowPri,
+		endPri:     evtmgr.endPri,
+		after:      make(map[int][]afterDep, len(evtmgr.after)),
+		epoch:      evtmgr.epoch,
+		epochSet:   evtmgr.epochSet,
+		seed:       evtmgr.seed,
+		streams:    make(map[string]*rand.Rand, len(evtmgr.streams)),
+		sources:    make(map[string]*countingSource, len(evtmgr.sources)),
+		threadOpts: evtmgr.threadOpts,
+		lookahead:  evtmgr.lookahead,
+		metadata:   evtmgr.metadata,
+		sites:      evtmgr.sites,
+		scale:      evtmgr.scale,
+
+		suspendTimeout: evtmgr.suspendTimeout,
+	}
+	for eventID, deps := range evtmgr.after {
+		clone.after[eventID] = append([]afterDep(nil), deps...)
+	}
+	if evtmgr.logical != nil {
+		logical := evtmgr.logical.Copy()
+		clone.logical = &logical
+	}
+	if evtmgr.disabled != nil {
+		clone.disabled = make(map[string]*classHold, len(evtmgr.disabled))
+		for class, hold := range evtmgr.disabled {
+			copied := &classHold{buffer: hold.buffer}
+			for _, event := range hold.held {
+				copied.held = append(copied.held, dup(event))
+			}
+			clone.disabled[class] = copied
+		}
+	}
+	for name, cs := range evtmgr.sources {
+		copied := clone.newSource(name, cs.draws)
+		clone.sources[name] = copied
+		clone.streams[name] = rand.New(copied)
+	}
+	if evtmgr.slabs != nil {
+		clone.slabs = slab.New[Event](evtmgr.slabs.Size())
+	}
+	clone.traceLevel.Store(evtmgr.traceLevel.Load())
+	clone.traceLogger.Store(evtmgr.traceLogger.Load())
+	clone.profileLabels.Store(evtmgr.profileLabels.Load())
+	clone.recovery.Store(evtmgr.recovery.Load())
+	return clone
+}
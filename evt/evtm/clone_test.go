@@ -0,0 +1,43 @@
+package evtm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestClone checks that a clone continues as the original would, drawing the same random
+// numbers and dispatching the same events, dependents included, while changes to either
+// leave the other alone
+func TestClone(t *testing.T) {
+	var draws []string
+	var walk func(*EventManager, any, any) any
+	walk = func(evtmgr *EventManager, context any, data any) any {
+		step := evtmgr.RandStream("walk").Int63n(10) + 1
+		*context.(*[]string) = append(*context.(*[]string), fmt.Sprintf("%d:%d", evtmgr.CurrentTicks(), step))
+		if evtmgr.CurrentTicks() < 50 {
+			evtmgr.Schedule(context, nil, walk, vrtime.CreateTime(step, 0))
+		}
+		return nil
+	}
+	original := New()
+	original.SetSeed(11)
+	original.RandStream("walk").Int63()
+	headID, _ := original.Schedule(&draws, nil, walk, vrtime.CreateTime(3, 0))
+	original.ScheduleAfterEvent(headID, &draws, nil, walk, vrtime.CreateTime(1, 0))
+
+	var cloneDraws []string
+	clone := original.Clone(func(event *Event) (any, any) { return &cloneDraws, event.Data })
+	clone.Schedule(&cloneDraws, nil, func(*EventManager, any, any) any { return nil }, vrtime.CreateTime(1, 0))
+	original.Run(1)
+	clone.Run(1)
+
+	if len(draws) < 10 || fmt.Sprint(draws) != fmt.Sprint(cloneDraws) {
+		t.Errorf("original drew %v, clone %v; want the same walk", draws, cloneDraws)
+	}
+	if original.EventsDispatched() != clone.EventsDispatched()-1 {
+		t.Errorf("original dispatched %d events, clone %d; want one more in the clone",
+			original.EventsDispatched(), clone.EventsDispatched())
+	}
+}
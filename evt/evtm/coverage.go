@@ -0,0 +1,115 @@
+package evtm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Coverage counts the events dispatched by each handler over a run, so that a test
+// scenario can check it exercised the model paths it was meant to, e.g., that the timeout
+// branch of a protocol actually occurred.  Handlers the scenario is meant to exercise are
+// registered with Expect; Missed lists those that never fired.
+type Coverage struct {
+	expected []string         // names of the handlers expected to fire, in order of registration
+	counts   map[string]int64 // number of events dispatched, by handler name
+	remove   func()
+	mu       sync.Mutex
+}
+
+// NewCoverage starts counting the events the EventManager dispatches, by handler,
+// expecting each of handlers to fire
+func (evtmgr *EventManager) NewCoverage(handlers ...EventHandlerFunction) *Coverage {
+	cov := &Coverage{counts: make(map[string]int64)}
+	cov.Expect(handlers...)
+	cov.remove = evtmgr.AddInterceptor(func(evtmgr *EventManager, event *Event) {
+		name := HandlerName(event.EventHandler)
+		cov.mu.Lock()
+		cov.counts[name] += 1
+		cov.mu.Unlock()
+	})
+	return cov
+}
+
+// Expect registers handlers the run is expected to exercise
+func (cov *Coverage) Expect(handlers ...EventHandlerFunction) {
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+	for _, handler := range handlers {
+		cov.expected = append(cov.expected, HandlerName(handler))
+	}
+}
+
+// Stop ends the counting.  The counts already made remain available.
+func (cov *Coverage) Stop() {
+	cov.mu.Lock()
+	remove := cov.remove
+	cov.remove = nil
+	cov.mu.Unlock()
+	if remove != nil {
+		remove()
+	}
+}
+
+// Count returns the number of events dispatched to handler
+func (cov *Coverage) Count(handler EventHandlerFunction) int64 {
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+	return cov.counts[HandlerName(handler)]
+}
+
+// Missed returns the names of the expected handlers that never fired
+func (cov *Coverage) Missed() []string {
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+	var missed []string
+	for _, name := range cov.expected {
+		if cov.counts[name] == 0 {
+			missed = append(missed, name)
+		}
+	}
+	return missed
+}
+
+// Report describes the coverage: for each expected handler the number of events it
+// handled, then the handlers that fired without being expected, and a summary line
+func (cov *Coverage) Report() string {
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+
+	var sb strings.Builder
+	fired := 0
+	isExpected := make(map[string]bool)
+	for _, name := range cov.expected {
+		isExpected[name] = true
+		count := cov.counts[name]
+		if count > 0 {
+			fired += 1
+			fmt.Fprintf(&sb, "  %10d  %s\n", count, name)
+		} else {
+			fmt.Fprintf(&sb, "  %10s  %s\n", "NOT FIRED", name)
+		}
+	}
+
+	var others []string
+	for name := range cov.counts {
+		if !isExpected[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	if len(others) > 0 {
+		sb.WriteString("  not expected:\n")
+		for _, name := range others {
+			fmt.Fprintf(&sb, "  %10d  %s\n", cov.counts[name], name)
+		}
+	}
+
+	percent := 100.0
+	if len(cov.expected) > 0 {
+		percent = 100 * float64(fired) / float64(len(cov.expected))
+	}
+	fmt.Fprintf(&sb, "%d of %d expected handlers fired (%.1f%%)\n", fired, len(cov.expected), percent)
+	return sb.String()
+}
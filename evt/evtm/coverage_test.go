@@ -0,0 +1,41 @@
+package evtm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+func coverSend(*EventManager, any, any) any    { return nil }
+func coverTimeout(*EventManager, any, any) any { return nil }
+func coverAck(*EventManager, any, any) any     { return nil }
+
+// TestCoverage checks that Coverage counts the events of each handler until stopped, and
+// reports the expected handlers that never fired and those that fired unexpectedly
+func TestCoverage(t *testing.T) {
+	evtmgr := New()
+	cov := evtmgr.NewCoverage(coverSend, coverTimeout)
+	for i := 0; i < 3; i++ {
+		evtmgr.Schedule(nil, nil, coverSend, vrtime.CreateTime(int64(i), 0))
+	}
+	evtmgr.Schedule(nil, nil, coverAck, vrtime.CreateTime(5, 0))
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	cov.Stop()
+	evtmgr.Schedule(nil, nil, coverTimeout, vrtime.CreateTime(1, 0))
+	evtmgr.AdvanceTo(vrtime.CreateTime(20, 0))
+
+	if cov.Count(coverSend) != 3 || cov.Count(coverAck) != 1 || cov.Count(coverTimeout) != 0 {
+		t.Errorf("counted %d sends, %d acks, %d timeouts; want 3, 1, 0",
+			cov.Count(coverSend), cov.Count(coverAck), cov.Count(coverTimeout))
+	}
+	if missed := cov.Missed(); len(missed) != 1 || missed[0] != HandlerName(coverTimeout) {
+		t.Errorf("missed %v, want the timeout", missed)
+	}
+	report := cov.Report()
+	for _, want := range []string{"NOT FIRED  " + HandlerName(coverTimeout), "not expected:", "1 of 2 expected handlers fired (50.0%)"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
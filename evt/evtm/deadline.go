@@ -0,0 +1,103 @@
+package evtm
+
+import (
+	"math"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// In wallclock mode the real time at which an event is due is measured from an anchor, a
+// moment at which virtual and real time were paired: the start of the run, or the last change
+// of the scale.  An event is due at the real time of the anchor, advanced by the virtual time
+// between the anchor and the event, scaled, and by the time held since (see BeginHold).
+// Deriving every deadline from the anchor, rather than adding the gap before each event to
+// the time the one before it was dispatched, keeps the errors of sleeping from accumulating
+// into a drift of virtual from real time over a long run.
+
+// timeAnchor pairs a virtual time with a real time
+type timeAnchor struct {
+	wall  time.Time     // real time of the anchor
+	ticks int64         // virtual time of the anchor
+	held  time.Duration // totalHeld at the anchor
+}
+
+// anchorAt pairs the current virtual time with the real time wall.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) anchorAt(wall time.Time) {
+	evtmgr.anchor = timeAnchor{wall: wall, ticks: evtmgr.Time.Ticks(), held: evtmgr.totalHeld}
+}
+
+// dueAt returns the real time at which an event at virtual time ticks is due.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) dueAt(ticks int64) time.Time {
+	return evtmgr.anchor.wall.Add(evtmgr.scaled(ticks-evtmgr.anchor.ticks) +
+		evtmgr.totalHeld - evtmgr.anchor.held)
+}
+
+// scaled returns the real time in which ticks of virtual time pass at the wallclock scale.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) scaled(ticks int64) time.Duration {
+	return time.Duration(math.Round(vrtime.TicksToSeconds(ticks) / evtmgr.scale * 1e9))
+}
+
+// WallclockDrift returns how far behind real time virtual time was when the most recent event
+// was dispatched in wallclock mode: the real time by which it missed its deadline, measured
+// from the start of the run, or negative if it was early.  A drift that grows over a run
+// means the EventManager cannot keep up at its scale (see SetAdaptation).
+func (evtmgr *EventManager) WallclockDrift() time.Duration {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.drift
+}
+
+// lagAlarm holds the state of the alarm set by SetLagAlarm
+type lagAlarm struct {
+	threshold time.Duration
+	alarm     func(evtmgr *EventManager, lag time.Duration)
+	late      bool // true while the events dispatched are later than the threshold
+}
+
+// WallclockLag returns how far virtual time is behind real time in wallclock mode: the
+// real time since the next pending event fell due, or, if negative, how long remains until
+// it does.  It is zero unless the EventManager is running in wallclock mode with an event
+// pending.
+func (evtmgr *EventManager) WallclockLag() time.Duration {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if !evtmgr.Wallclock || !evtmgr.RunFlag {
+		return 0
+	}
+	next, pending := evtmgr.EventList.TryMinTime()
+	if !pending {
+		return 0
+	}
+	return time.Since(evtmgr.dueAt(next.Ticks()))
+}
+
+// SetLagAlarm has alarm called, in wallclock mode, when an event is dispatched later than
+// threshold after it fell due, with how late it is.  It is called by the thread running the
+// EventManager, without its lock held, once per excursion beyond the threshold, and again
+// only after an event has been dispatched within it.  A nil alarm switches the alarm off.
+func (evtmgr *EventManager) SetLagAlarm(threshold time.Duration, alarm func(evtmgr *EventManager, lag time.Duration)) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.lagging = lagAlarm{threshold: threshold, alarm: alarm}
+}
+
+// checkLag returns the alarm to be raised for an event dispatched with the lateness held in
+// drift, or nil if there is none.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) checkLag() func(*EventManager, time.Duration) {
+	la := &evtmgr.lagging
+	if la.alarm == nil {
+		return nil
+	}
+	if evtmgr.drift <= la.threshold {
+		la.late = false
+		return nil
+	}
+	if la.late {
+		return nil
+	}
+	la.late = true
+	return la.alarm
+}
@@ -0,0 +1,66 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestDeadlinesDoNotDrift checks that in wallclock mode the time handlers take within the gaps
+// between events does not accumulate into a drift of virtual from real time
+func TestDeadlinesDoNotDrift(t *testing.T) {
+	const events, gap = 20, 5 * time.Millisecond
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	var last time.Time
+	for idx := 1; idx <= events; idx++ {
+		evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any {
+			time.Sleep(time.Millisecond)
+			last = time.Now()
+			return nil
+		}, vrtime.SecondsToTime(float64(idx)*gap.Seconds()))
+	}
+
+	start := time.Now()
+	evtmgr.Run(float64(events) * gap.Seconds())
+	// a millisecond per event added to each gap would be 20ms late by the end
+	if late := last.Sub(start) - events*gap - time.Millisecond; late > 10*time.Millisecond {
+		t.Errorf("last event dispatched %v after its deadline", late)
+	}
+	if drift := evtmgr.WallclockDrift(); drift > 5*time.Millisecond {
+		t.Errorf("got drift %v, want at most 5ms", drift)
+	}
+}
+
+// TestLagAlarm checks that WallclockLag shows the next event overdue while a handler overruns,
+// and that the lag alarm is raised once for a run of late events
+func TestLagAlarm(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	var lag time.Duration
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		time.Sleep(40 * time.Millisecond)
+		lag = evtmgr.WallclockLag()
+		return nil
+	}, vrtime.CreateTime(0, 0))
+	noop := func(*EventManager, any, any) any { return nil }
+	for _, seconds := range []float64{0.01, 0.02, 0.1} {
+		evtmgr.Schedule(nil, nil, noop, vrtime.SecondsToTime(seconds))
+	}
+	var alarms []time.Duration
+	evtmgr.SetLagAlarm(10*time.Millisecond, func(evtmgr *EventManager, lag time.Duration) {
+		alarms = append(alarms, lag)
+	})
+
+	evtmgr.Run(0.1)
+	if lag < 20*time.Millisecond {
+		t.Errorf("got lag %v behind the event due at 10ms, want about 30ms", lag)
+	}
+	if len(alarms) != 1 || alarms[0] < 20*time.Millisecond {
+		t.Errorf("got alarms %v, want one of about 30ms", alarms)
+	}
+	if lag := evtmgr.WallclockLag(); lag != 0 {
+		t.Errorf("got lag %v once the run is over, want 0", lag)
+	}
+}
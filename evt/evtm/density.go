@@ -0,0 +1,121 @@
+package evtm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Density profiles the load of a model over virtual time, counting the events scheduled and
+// dispatched in each bucket of virtual time of a fixed width, and, when the profile is taken,
+// those still pending, so that a user can see where in simulated time events concentrate,
+// e.g., to choose the bucket width of a spilling event list (see SetSpill).
+type Density struct {
+	evtmgr     *EventManager
+	width      int64           // ticks per bucket
+	scheduled  map[int64]int64 // events scheduled, by bucket of the time they were scheduled for
+	dispatched map[int64]int64 // events dispatched, by bucket
+	remove     []func()
+	mu         sync.Mutex
+}
+
+// DensityBucket is the profile of one bucket of virtual time
+type DensityBucket struct {
+	Start      vrtime.Time // beginning of the bucket
+	Scheduled  int64       // events scheduled to occur in the bucket since profiling began
+	Dispatched int64       // events dispatched in the bucket since profiling began
+	Pending    int64       // events pending in the bucket when the profile was taken
+}
+
+// NewDensity starts profiling the events of the EventManager in buckets width wide
+func (evtmgr *EventManager) NewDensity(width vrtime.Time) *Density {
+	ds := &Density{evtmgr: evtmgr, width: width.Ticks(), scheduled: make(map[int64]int64),
+		dispatched: make(map[int64]int64)}
+	if ds.width <= 0 {
+		ds.width = 1
+	}
+	ds.remove = append(ds.remove, evtmgr.AddInterceptor(func(evtmgr *EventManager, event *Event) {
+		ds.mu.Lock()
+		ds.dispatched[ds.bucketOf(event.Time.Ticks())] += 1
+		ds.mu.Unlock()
+	}))
+	ds.remove = append(ds.remove, evtmgr.AddScheduleObserver(func(evtmgr *EventManager, op ScheduleOp, event Event) {
+		// an event scheduled to follow another has no time yet
+		if op != OpSchedule || event.Time.Ticks() == vrtime.InfinityTime().Ticks() {
+			return
+		}
+		ds.mu.Lock()
+		ds.scheduled[ds.bucketOf(event.Time.Ticks())] += 1
+		ds.mu.Unlock()
+	}))
+	return ds
+}
+
+// bucketOf returns the bucket holding the tick count ticks
+func (ds *Density) bucketOf(ticks int64) int64 {
+	bucket := ticks / ds.width
+	if ticks < 0 && ticks%ds.width != 0 {
+		bucket -= 1
+	}
+	return bucket
+}
+
+// Stop ends the profiling.  The counts already made remain available.
+func (ds *Density) Stop() {
+	ds.mu.Lock()
+	remove := ds.remove
+	ds.remove = nil
+	ds.mu.Unlock()
+	for _, rm := range remove {
+		rm()
+	}
+}
+
+// Profile returns the buckets in which any event has been scheduled, dispatched, or is
+// pending, in order of time
+func (ds *Density) Profile() []DensityBucket {
+	pending := ds.evtmgr.EventList.Histogram(ds.width)
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	byBucket := make(map[int64]*DensityBucket)
+	get := func(bucket int64) *DensityBucket {
+		db, present := byBucket[bucket]
+		if !present {
+			db = &DensityBucket{Start: vrtime.CreateTime(bucket*ds.width, 0)}
+			byBucket[bucket] = db
+		}
+		return db
+	}
+	for bucket, count := range ds.scheduled {
+		get(bucket).Scheduled = count
+	}
+	for bucket, count := range ds.dispatched {
+		get(bucket).Dispatched = count
+	}
+	for bucket, count := range pending {
+		get(bucket).Pending = int64(count)
+	}
+	profile := make([]DensityBucket, 0, len(byBucket))
+	for _, db := range byBucket {
+		profile = append(profile, *db)
+	}
+	sort.Slice(profile, func(i, j int) bool { return profile[i].Start.Ticks() < profile[j].Start.Ticks() })
+	return profile
+}
+
+// WriteCSV writes the profile as comma-separated values, with a header line, one line per
+// bucket giving its start in seconds and its counts
+func (ds *Density) WriteCSV(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "start,scheduled,dispatched,pending"); err != nil {
+		return err
+	}
+	for _, db := range ds.Profile() {
+		if _, err := fmt.Fprintf(w, "%g,%d,%d,%d\n", db.Start.Seconds(), db.Scheduled, db.Dispatched, db.Pending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package evtm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestDensity checks the counts of events scheduled, dispatched, and pending in each bucket,
+// and that events scheduled after Stop are counted only while pending
+func TestDensity(t *testing.T) {
+	evtmgr := New()
+	ds := evtmgr.NewDensity(vrtime.CreateTime(10, 0))
+	noop := func(*EventManager, any, any) any { return nil }
+	for _, ticks := range []int64{1, 5, 12, 25, 27} {
+		evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(ticks, 0))
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(15, 0))
+	ds.Stop()
+	evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(5, 0)) // at 20
+
+	var got [][4]int64
+	for _, db := range ds.Profile() {
+		got = append(got, [4]int64{db.Start.Ticks(), db.Scheduled, db.Dispatched, db.Pending})
+	}
+	want := [][4]int64{{0, 2, 2, 0}, {10, 1, 1, 0}, {20, 2, 0, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got buckets %v, want %v", got, want)
+	}
+
+	var csv strings.Builder
+	if err := ds.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(csv.String()), "\n"); len(lines) != 4 || lines[0] != "start,scheduled,dispatched,pending" {
+		t.Errorf("got CSV %q, want a header and three buckets", csv.String())
+	}
+}
@@ -0,0 +1,181 @@
+package evtm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Diagnostics selects the checks an EventManager makes for events being held up by others.
+// Findings are reported through the trace subsystem at TraceInfo, naming the offender.
+type Diagnostics struct {
+	// InversionDelay, when positive, reports an event dispatched in wallclock mode more than
+	// InversionDelay of real time after the first event of its tick, i.e., one held up by the
+	// handlers of events ahead of it at the tick.  The offender is the handler among those
+	// that took the longest.
+	InversionDelay time.Duration
+
+	// StarvationCount, when positive, reports a tag whose events have been cancelled or
+	// removed StarvationCount times since one of them was last dispatched, as happens when a
+	// timer is always reset before it expires.  The tag of an event is its Class, or the name
+	// of its handler if it has none.  The offender is the site of the latest cancellation.
+	StarvationCount int
+}
+
+// Finding describes one report made under Diagnostics
+type Finding struct {
+	Kind     string        // "inversion" or "starvation"
+	Victim   string        // handler of the delayed event, or tag of the starved events
+	Offender string        // handler that held the event up, or the site of the latest cancellation
+	Delay    time.Duration // real time the event was held up, for an inversion
+	Count    int           // number of cancellations, for starvation
+}
+
+// diagnoser holds the state of the checks selected by Diagnostics
+type diagnoser struct {
+	diag     Diagnostics
+	remove   []func()
+	tick     int64         // tick of the events being dispatched
+	first    time.Time     // real time the first event of the tick was dispatched
+	prev     string        // handler of the previous event dispatched at the tick
+	prevAt   time.Time     // real time it was dispatched
+	longest  string        // handler of the event at the tick that has run longest so far
+	longDur  time.Duration // how long it ran
+	postpone map[string]int
+	findings []Finding
+	mu       sync.Mutex
+}
+
+// SetDiagnostics selects the checks the EventManager makes for events held up by others,
+// replacing any selected before.  The zero Diagnostics switches them off.
+func (evtmgr *EventManager) SetDiagnostics(diag Diagnostics) {
+	evtmgr.mu.Lock()
+	old := evtmgr.diagnoser
+	evtmgr.diagnoser = nil
+	evtmgr.mu.Unlock()
+	if old != nil {
+		for _, remove := range old.remove {
+			remove()
+		}
+	}
+	if diag.InversionDelay <= 0 && diag.StarvationCount <= 0 {
+		return
+	}
+
+	dg := &diagnoser{diag: diag, tick: -1, postpone: make(map[string]int)}
+	dg.remove = append(dg.remove, evtmgr.AddInterceptor(dg.dispatched))
+	if diag.StarvationCount > 0 {
+		dg.remove = append(dg.remove, evtmgr.AddScheduleObserver(dg.observed))
+	}
+	evtmgr.mu.Lock()
+	evtmgr.diagnoser = dg
+	evtmgr.mu.Unlock()
+}
+
+// Findings returns the reports made under the Diagnostics selected, in the order they were made
+func (evtmgr *EventManager) Findings() []Finding {
+	evtmgr.mu.Lock()
+	dg := evtmgr.diagnoser
+	evtmgr.mu.Unlock()
+	if dg == nil {
+		return nil
+	}
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+	return append([]Finding(nil), dg.findings...)
+}
+
+// OffenderCount is the number of findings naming an offender
+type OffenderCount struct {
+	Offender string
+	Count    int
+}
+
+// Offenders counts the findings by offender, most frequent first, to separate the
+// systematic from the occasional
+func (evtmgr *EventManager) Offenders() []OffenderCount {
+	counts := make(map[string]int)
+	for _, finding := range evtmgr.Findings() {
+		counts[finding.Offender] += 1
+	}
+	offenders := make([]OffenderCount, 0, len(counts))
+	for name, count := range counts {
+		offenders = append(offenders, OffenderCount{Offender: name, Count: count})
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Count != offenders[j].Count {
+			return offenders[i].Count > offenders[j].Count
+		}
+		return offenders[i].Offender < offenders[j].Offender
+	})
+	return offenders
+}
+
+// tag returns the tag of an event, under which its starvation is tracked
+func tag(event *Event) string {
+	if event.Class != "" {
+		return event.Class
+	}
+	return HandlerName(event.EventHandler)
+}
+
+// dispatched is the interceptor through which the diagnoser watches dispatches
+func (dg *diagnoser) dispatched(evtmgr *EventManager, event *Event) {
+	var finding *Finding
+	var longest time.Duration
+	dg.mu.Lock()
+	if dg.diag.StarvationCount > 0 {
+		delete(dg.postpone, tag(event))
+	}
+	if dg.diag.InversionDelay > 0 && evtmgr.IsWallclock() {
+		now := time.Now()
+		handler := HandlerName(event.EventHandler)
+		if ticks := event.Time.Ticks(); ticks != dg.tick {
+			dg.tick, dg.first, dg.longest, dg.longDur = ticks, now, "", 0
+		} else {
+			// the previous event of the tick ran from prevAt until now
+			if ran := now.Sub(dg.prevAt); ran > dg.longDur {
+				dg.longest, dg.longDur = dg.prev, ran
+			}
+			if delay := now.Sub(dg.first); delay > dg.diag.InversionDelay {
+				finding = &Finding{Kind: "inversion", Victim: handler, Offender: dg.longest, Delay: delay}
+				longest = dg.longDur
+			}
+		}
+		dg.prev, dg.prevAt = handler, now
+	}
+	if finding != nil {
+		dg.findings = append(dg.findings, *finding)
+	}
+	dg.mu.Unlock()
+
+	if finding != nil && evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Diagnostics: event %d (%s, pri %d) held up %v at tick %d; longest ahead of it %s ran %v\n",
+			event.EventID, finding.Victim, event.Time.Pri(), finding.Delay, event.Time.Ticks(), finding.Offender, longest)
+	}
+}
+
+// observed is the schedule observer through which the diagnoser watches cancellations
+func (dg *diagnoser) observed(evtmgr *EventManager, op ScheduleOp, event Event) {
+	if op == OpSchedule {
+		return
+	}
+	name := tag(&event)
+	var finding *Finding
+	dg.mu.Lock()
+	dg.postpone[name] += 1
+	if count := dg.postpone[name]; count >= dg.diag.StarvationCount {
+		caller, file, line := callSite()
+		finding = &Finding{Kind: "starvation", Victim: name, Count: count,
+			Offender: fmt.Sprintf("%s (%s:%d)", caller, file, line)}
+		dg.findings = append(dg.findings, *finding)
+		delete(dg.postpone, name)
+	}
+	dg.mu.Unlock()
+
+	if finding != nil && evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Diagnostics: events of %s cancelled %d times without one dispatched; latest by %s\n",
+			finding.Victim, finding.Count, finding.Offender)
+	}
+}
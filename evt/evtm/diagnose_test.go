@@ -0,0 +1,53 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+func diagSlow(*EventManager, any, any) any    { time.Sleep(20 * time.Millisecond); return nil }
+func diagVictim(*EventManager, any, any) any  { return nil }
+func diagTimeout(*EventManager, any, any) any { return nil }
+
+// TestDiagnostics checks that an event held up at its tick by a slow handler ahead of it
+// is reported with that handler as the offender, and that a timer always reset before it
+// expires is reported as starved
+func TestDiagnostics(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	evtmgr.SetDiagnostics(Diagnostics{InversionDelay: 10 * time.Millisecond, StarvationCount: 3})
+	evtmgr.Schedule(nil, nil, diagSlow, vrtime.CreateTime(0, 0))
+	evtmgr.Schedule(nil, nil, diagVictim, vrtime.CreateTime(0, 0))
+
+	timeoutID, _ := evtmgr.Schedule(nil, nil, diagTimeout, vrtime.CreateTime(100, 0))
+	var poll func(*EventManager, any, any) any
+	poll = func(evtmgr *EventManager, context any, data any) any {
+		evtmgr.RemoveEvent(timeoutID)
+		timeoutID, _ = evtmgr.Schedule(nil, nil, diagTimeout, vrtime.CreateTime(100, 0))
+		if evtmgr.CurrentTicks() < 7 {
+			evtmgr.Schedule(nil, nil, poll, vrtime.CreateTime(1, 0))
+		}
+		return nil
+	}
+	evtmgr.Schedule(nil, nil, poll, vrtime.CreateTime(1, 0))
+	evtmgr.Run(1e-6)
+
+	var inversions, starvations []Finding
+	for _, finding := range evtmgr.Findings() {
+		switch finding.Kind {
+		case "inversion":
+			inversions = append(inversions, finding)
+		case "starvation":
+			starvations = append(starvations, finding)
+		}
+	}
+	if len(inversions) != 1 || inversions[0].Victim != HandlerName(diagVictim) ||
+		inversions[0].Offender != HandlerName(diagSlow) || inversions[0].Delay < 20*time.Millisecond {
+		t.Errorf("inversions %+v, want the victim held up 20ms by the slow handler", inversions)
+	}
+	if len(starvations) != 2 || starvations[0].Victim != HandlerName(diagTimeout) || starvations[0].Count != 3 {
+		t.Errorf("starvations %+v, want two of the timeout, each after 3 removals", starvations)
+	}
+}
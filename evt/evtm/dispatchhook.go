@@ -0,0 +1,55 @@
+package evtm
+
+// Dispatch hooks observe every event the EventManager dispatches, for instrumentation
+// such as tracing, metrics, and the checking of invariants, without wrapping each handler.
+// They are called by the thread running the EventManager without its lock held, with the
+// clock at the time of the event, so a hook may call the EventManager's methods, e.g.,
+// CurrentTime, or schedule events.  A hook must not modify the event; an EventInterceptor
+// does that.  Under slab allocation the event is recycled once ReleaseSlabs is called (see
+// SetSlabSize), so a hook that keeps it beyond that must keep a copy.
+
+// preDispatchEntry wraps a registered pre-dispatch hook, giving it an identity for removal
+type preDispatchEntry struct {
+	hook func(*Event)
+}
+
+// postDispatchEntry wraps a registered post-dispatch hook, giving it an identity for removal
+type postDispatchEntry struct {
+	hook func(*Event, any)
+}
+
+// RegisterPreDispatchHook registers hook to be called with each event just before its
+// handler, after the filters and interceptors.  Hooks are called in the order they were
+// registered.  RegisterPreDispatchHook returns a function that removes the hook.
+func (evtmgr *EventManager) RegisterPreDispatchHook(hook func(*Event)) (remove func()) {
+	return register(&evtmgr.mu, &evtmgr.preHooks, &preDispatchEntry{hook: hook})
+}
+
+// RegisterPostDispatchHook registers hook to be called with each event just after its
+// handler returns, and the value the handler returned.  Hooks are called in the order they
+// were registered.  RegisterPostDispatchHook returns a function that removes the hook.
+func (evtmgr *EventManager) RegisterPostDispatchHook(hook func(*Event, any)) (remove func()) {
+	return register(&evtmgr.mu, &evtmgr.postHooks, &postDispatchEntry{hook: hook})
+}
+
+// beforeDispatch calls the pre-dispatch hooks on an event
+func (evtmgr *EventManager) beforeDispatch(event *Event) {
+	hooks := evtmgr.preHooks.Load()
+	if hooks == nil {
+		return
+	}
+	for _, entry := range *hooks {
+		entry.hook(event)
+	}
+}
+
+// afterDispatch calls the post-dispatch hooks on an event and the value its handler returned
+func (evtmgr *EventManager) afterDispatch(event *Event, value any) {
+	hooks := evtmgr.postHooks.Load()
+	if hooks == nil {
+		return
+	}
+	for _, entry := range *hooks {
+		entry.hook(event, value)
+	}
+}
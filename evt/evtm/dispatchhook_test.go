@@ -0,0 +1,37 @@
+package evtm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestDispatchHooks checks that hooks are called around each handler in the order registered,
+// the post-dispatch hooks with the value returned, and that a removed hook is no longer called
+func TestDispatchHooks(t *testing.T) {
+	evtmgr := New()
+	var calls []string
+	evtmgr.RegisterPreDispatchHook(func(event *Event) {
+		calls = append(calls, fmt.Sprintf("pre1@%d", evtmgr.CurrentTicks()))
+	})
+	removePre := evtmgr.RegisterPreDispatchHook(func(event *Event) { calls = append(calls, "pre2") })
+	evtmgr.RegisterPostDispatchHook(func(event *Event, value any) {
+		calls = append(calls, fmt.Sprintf("post=%v", value))
+	})
+	handler := func(evtmgr *EventManager, context any, data any) any {
+		calls = append(calls, "handler")
+		return data
+	}
+	evtmgr.Schedule(nil, 1, handler, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, 2, handler, vrtime.CreateTime(20, 0))
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	removePre()
+	evtmgr.AdvanceTo(vrtime.CreateTime(20, 0))
+	want := []string{"pre1@10", "pre2", "handler", "post=1", "pre1@20", "handler", "post=2"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+}
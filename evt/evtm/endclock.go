@@ -0,0 +1,67 @@
+package evtm
+
+import (
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// ClockPolicy selects where a run that reaches its limit, or runs out of events, leaves the
+// clock of the EventManager.  Windowed synchronization schemes differ in what they need:
+// one that hands out windows in lock step wants every member's clock at the end of the
+// window, one that negotiates the next window from each member's clock wants it left at the
+// last event, or moved up to the next.  A run that is stopped, aborted, or out of budget
+// always leaves the clock at the time of the last event dispatched.
+type ClockPolicy int
+
+const (
+	// ClockToLimit sets the clock to the limit of the run.  It is the policy of Run.
+	ClockToLimit ClockPolicy = iota
+
+	// ClockAtLastEvent leaves the clock at the time of the last event dispatched.
+	ClockAtLastEvent
+
+	// ClockAtNextEvent sets the clock to the time of the next pending event, which lies
+	// beyond the limit, or to the limit if no event is pending.
+	ClockAtNextEvent
+)
+
+// String names the policy
+func (policy ClockPolicy) String() string {
+	switch policy {
+	case ClockToLimit:
+		return "to limit"
+	case ClockAtLastEvent:
+		return "at last event"
+	case ClockAtNextEvent:
+		return "at next event"
+	}
+	return "unknown"
+}
+
+// RunWithClock is Run, leaving the clock where policy says once the run ends, and returning
+// the reason it ended
+func (evtmgr *EventManager) RunWithClock(LimitTime float64, policy ClockPolicy) StopReason {
+	return evtmgr.run(vrtime.SecondsToTicks(LimitTime), time.Time{}, policy, false)
+}
+
+// settleClock sets the clock as policy says at the end of a run that ended for reason.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) settleClock(reason StopReason, limitTicks int64, policy ClockPolicy) {
+	if reason != StopLimit && reason != StopEmpty {
+		return
+	}
+	if evtmgr.Time.Ticks() >= limitTicks {
+		return
+	}
+	switch policy {
+	case ClockToLimit:
+		evtmgr.Time = vrtime.CreateTime(limitTicks, 0)
+	case ClockAtNextEvent:
+		ticks := limitTicks
+		if next, pending := evtmgr.EventList.TryMinTime(); pending {
+			ticks = next.Ticks()
+		}
+		evtmgr.Time = vrtime.CreateTime(ticks, 0)
+	}
+}
@@ -0,0 +1,37 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestClockPolicy checks where each policy leaves the clock when a run reaches its limit with
+// an event pending beyond it, and when it runs out of events
+func TestClockPolicy(t *testing.T) {
+	limit, later := vrtime.SecondsToTicks(1), vrtime.SecondsToTicks(2)
+	noop := func(*EventManager, any, any) any { return nil }
+	for _, tc := range []struct {
+		policy        ClockPolicy
+		pending, none int64 // clock with an event pending beyond the limit, and with none
+	}{
+		{ClockToLimit, limit, limit},
+		{ClockAtLastEvent, 10, 10},
+		{ClockAtNextEvent, later, limit},
+	} {
+		for _, beyond := range []bool{true, false} {
+			evtmgr := New()
+			evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(10, 0))
+			want, stop := tc.none, StopEmpty
+			if beyond {
+				evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(later, 0))
+				want, stop = tc.pending, StopLimit
+			}
+			reason := evtmgr.RunWithClock(1, tc.policy)
+			if now := evtmgr.CurrentTicks(); reason != stop || now != want {
+				t.Errorf("%v, event beyond %v: run ended for %q with the clock at %d, want %q at %d",
+					tc.policy, beyond, reason, now, stop, want)
+			}
+		}
+	}
+}
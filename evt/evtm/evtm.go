@@ -6,12 +6,15 @@
 package evtm
 
 import (
-	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/internal/slab"
 	"github.com/iti/evt/vrtime"
 )
 
@@ -54,10 +57,18 @@ import (
 // This design decision makes it possible for one to run the simulation, window of simulation time by window of simulation time,
 // after a window completes loading the event list with events to execute in the next window without needing to dither around with
 // the EventManager clock value.  Certain parallel simulation time management
-// protocols work this way, and the design is meant to support that.
+// protocols work this way, and the design is meant to support that; the Coordinator of
+// package fed runs a set of EventManagers window by window in just this way.
 //
-// evtMgrTrace is a flag used while debugging to selectively print/log information
-var evtMgrTrace = false
+// NowPriority is the base of the band of priorities reserved for events scheduled
+// through ScheduleNow.  Model code should not choose priorities this small, so at any
+// tick the events in the band are dispatched ahead of all others.
+const NowPriority int64 = math.MinInt64
+
+// EndOfTickPriority is the base of the band of priorities reserved for events scheduled
+// through ScheduleEndOfTick.  Model code should not choose priorities this large, so at
+// any tick the events in the band are dispatched after all others.
+const EndOfTickPriority int64 = 1 << 62
 
 // EventHandlerFunction is invoked when the corresponding event fires
 type EventHandlerFunction func(*EventManager, any, any) any
@@ -93,6 +104,27 @@ type Event struct {
 	EventID int
 
 	Cancel bool
+
+	// Class, when not empty, names the class of the event, by which its dispatch
+	// may be switched off and on (see DisableClass).
+	Class string
+
+	// Parent is the eventId of the event whose handler scheduled this one, its cause, or
+	// evtq.InvalidEventID if it was scheduled outside the handlers of the EventManager, e.g.,
+	// before the run or by another EventManager.  Following Parent from event to event traces
+	// a chain of causes.  An event scheduled by another goroutine while a handler is running
+	// is attributed to that handler's event.
+	Parent int
+
+	// Clock holds the logical clocks of the sender of an event sent by another EventManager,
+	// when logical clocks are on (see SetLogicalClocks), and is nil otherwise.
+	Clock *LogicalClock
+
+	// Meta is information about the event kept for tooling, nil unless metadata is
+	// switched on (see SetMetadata).
+	Meta *Metadata
+
+	lane int // lane of the event list holding the event (see ScheduleInLane)
 }
 
 // An EventManager structure holds information needed
@@ -103,30 +135,133 @@ type Event struct {
 // flag also which, if changed to false when processing an event, will
 // inhibit the dispatch of further events until the event manager
 // is told to run again.
+//
+// The EventManager reads and writes its state under its own lock, so its methods
+// may be called from any goroutine.  The exported fields other than EventList are
+// retained for compatibility; reading or writing them directly races with a running
+// EventManager, and each names the methods that access it safely.
 type EventManager struct {
 	EventList *evtq.EventQueue // order events
-	Time      vrtime.Time      // time of last event pulled off the EventList (but not necessarily yet executed completely)
-	EventID   int              // identifier needed if we aim to remove events from EventList
-	NumEvts   int              // number of events executed by the event manager
-	RunFlag   bool             // indicate whether the EventManager is actively in use right now
-	Wallclock bool             // scale virtual time advance to wallclock time, approximately
-	StartTime time.Time        // wallclock time at time of first event
-	External  bool             // if true we don't close up when the event list is empy
-	mu        sync.Mutex       // needed for thread safety
-	suspended bool             // true when the thread running the EventManager is waiting for a signal sent when an event is scheduled
-	suspChan  chan bool        //
-	autoPri   int64            // use when time on event being scheduled has a priority of int64(0)
+
+	// Time is the time of last event pulled off the EventList (but not necessarily yet executed completely).
+	//
+	// Deprecated: use CurrentTime and SetTime.
+	Time vrtime.Time
+
+	// EventID identifies the event being dispatched, needed if we aim to remove events from EventList.
+	//
+	// Deprecated: use CurrentEventID.
+	EventID int
+
+	// NumEvts is the number of events dispatched by the event manager.
+	//
+	// Deprecated: use EventsDispatched.
+	NumEvts int
+
+	// RunFlag indicates whether the EventManager is actively in use right now.
+	//
+	// Deprecated: use IsRunning and Stop.
+	RunFlag bool
+
+	// Wallclock scales virtual time advance to wallclock time, approximately.
+	//
+	// Deprecated: use IsWallclock and SetWallclock.
+	Wallclock bool
+
+	// StartTime is the wallclock time at which the most recent call to Run began.
+	//
+	// Deprecated: use RunStartTime.
+	StartTime time.Time
+
+	// External, if true, means we don't close up when the event list is empty.
+	//
+	// Deprecated: use IsExternal and SetExternal.
+	External bool
+
+	mu        sync.Mutex            // guards the fields above, and those below that are not atomic
+	suspended bool                  // true when the thread running the EventManager is waiting for a signal sent when an event is scheduled
+	suspChan  chan bool             //
+	wake      chan struct{}         // interrupts the sleep of the thread running the EventManager in wallclock mode
+	quiet     *sync.Cond            // broadcast when the EventManager may have become idle, created by WaitIdle
+	autoPri   int64                 // use when time on event being scheduled has a priority of int64(0)
+	nowPri    int64                 // next offset into the NowPriority band, used by ScheduleNow
+	endPri    int64                 // next offset into the EndOfTickPriority band, used by ScheduleEndOfTick
+	after     map[int][]afterDep    // events waiting, through ScheduleAfterEvent, on the dispatch of the keyed event
+	disabled  map[string]*classHold // classes of events whose dispatch is switched off
+
+	lastDispatch time.Time     // wallclock time when the most recent event was dispatched
+	holds        int           // number of open HoldVirtualTime / BeginHold calls
+	holdStart    time.Time     // wallclock time when the outermost open hold began
+	held         time.Duration // wallclock time spent in holds since lastDispatch
+	totalHeld    time.Duration // wallclock time spent in holds since the EventManager was created
+
+	epoch    time.Time // calendar instant at virtual time zero, when epochSet
+	epochSet bool      // true once SetEpoch has been called
+
+	seed    int64                      // seed from which the random number streams are derived
+	streams map[string]*rand.Rand      // random number streams, by name
+	sources map[string]*countingSource // sources of the random number streams, by name
+
+	slabs *slab.Slab[Event] // source of Events when slab allocation is selected, otherwise nil
+
+	stats    runStats    // progress of the current run, see RunStats
+	abortErr *AbortError // cause of the abort of the current run, nil unless Abort has been called
+	host     *Child      // hosting of the EventManager by a parent, nil if it is not a child
+
+	diagnoser  *diagnoser    // state of the checks selected by SetDiagnostics, nil when there are none
+	threadOpts ThreadOptions // binding of the dispatch loop to an OS thread, see SetThreadOptions
+	pacing     pacer         // state of precise pacing in wallclock mode, see SetPacingPrecision
+	jitter     jitter        // pacing errors in wallclock mode, see JitterStats
+	banding    bander        // pacing under a tolerance band, see SetToleranceBand
+	anchor     timeAnchor    // pairing of virtual and real time from which events are paced
+	drift      time.Duration // lateness of the last event dispatched in wallclock mode, see WallclockDrift
+	lagging    lagAlarm      // alarm raised when events are dispatched late, see SetLagAlarm
+	scale      float64       // virtual seconds advanced per real second in wallclock mode
+	adapting   adapter       // adaptation of the scale under overload, see SetAdaptation
+	authority  TimeAuthority // owner of the clock in slave mode, nil otherwise
+	lookahead  vrtime.Time   // minimum offset of events sent by ScheduleRemote, see SetLookahead
+	managerID  uint32        // identifier within a federation, see SetManagerID
+	external   *externalIDs  // external identifiers of events, nil unless selected by SetExternalIDs
+	metadata   bool          // give events Metadata, see SetMetadata
+	sites      bool          // capture the sites of calls scheduling events, see SetCaptureSites
+	current    *Event        // event being dispatched, nil when the EventManager is not running
+	logical    *LogicalClock // logical clocks, nil unless selected by SetLogicalClocks
+	advances   map[int64]int // outstanding RequestTimeAdvance calls, by limit in ticks
+	paused     bool          // true between calls to Pause and Resume
+	waiters    []*Waiter     // WaitUntil conditions checked on Signal
+
+	suspendTimeout time.Duration // longest wait for an event in External mode, zero for no limit
+
+	admission AdmissionPolicy // timestamping of events admitted by Devices
+	devices   int64           // number of Devices created
+
+	traceLevel    atomic.Int32                         // TraceLevel at which the EventManager reports its operation
+	traceLogger   atomic.Pointer[log.Logger]           // destination of trace statements, nil for the standard logger
+	scheduleCalls atomic.Int64                         // number of traced calls to Schedule
+	profileLabels atomic.Bool                          // attach pprof labels to handler calls
+	recovery      atomic.Int32                         // RecoveryPolicy applied to panics in handlers
+	filters       atomic.Pointer[[]*filterEntry]       // filters consulted before dispatch, nil when there are none
+	interceptors  atomic.Pointer[[]*interceptorEntry]  // interceptors applied before dispatch, nil when there are none
+	observers     atomic.Pointer[[]*observerEntry]     // observers of scheduling calls, nil when there are none
+	preHooks      atomic.Pointer[[]*preDispatchEntry]  // hooks called before each handler, nil when there are none
+	postHooks     atomic.Pointer[[]*postDispatchEntry] // hooks called after each handler, nil when there are none
+	results       atomic.Pointer[resultRouter]         // destinations of the values handlers return, nil until one is set
+}
+
+// afterDep records an event scheduled by ScheduleAfterEvent, to be given
+// a time offset from that of the event it depends on once that time is known
+type afterDep struct {
+	eventID int
+	offset  vrtime.Time
 }
 
 // New creates an empty event queue,
 // sets the virtual time to zero, initializes
-// the 'running' flag to false.
-func New() *EventManager {
-	if evtMgrTrace {
-		fmt.Println("Creating new EvtMgr")
-		log.Println("Creating new EvtMgr")
-	}
-	newEq := evtq.New()
+// the 'running' flag to false.  An optional size hint
+// is passed through to [evtq.New] as the number of events
+// the event queue is expected to hold.
+func New(sizeHint ...int) *EventManager {
+	newEq := evtq.New(sizeHint...)
 	newEm := &EventManager{
 		EventList: newEq,
 		Time:      vrtime.ZeroTime(),
@@ -134,7 +269,12 @@ func New() *EventManager {
 		External:  false,
 		suspended: false,
 		suspChan:  make(chan bool, 1),
+		wake:      make(chan struct{}, 1),
 		autoPri:   int64(1),
+		after:     make(map[int][]afterDep),
+		streams:   make(map[string]*rand.Rand),
+		sources:   make(map[string]*countingSource),
+		scale:     1,
 		Wallclock: false}
 	return newEm
 }
@@ -144,13 +284,103 @@ func New() *EventManager {
 // empties before reaching the end simulation time, the thread running the EventManager suspends
 // until the scheduling (by a different thread) of an event on the EventManager releases it.
 func (evtmgr *EventManager) SetExternal(external bool) {
+	evtmgr.mu.Lock()
 	evtmgr.External = external
+	evtmgr.mu.Unlock()
+}
+
+// SetSuspendTimeout bounds the real time the EventManager waits, suspended in External mode,
+// for another thread to schedule an event.  If none is scheduled within timeout the run ends
+// with StopSuspendTimeout, the clock left at the time of the last event executed.  A timeout
+// of zero, the default, waits indefinitely.
+func (evtmgr *EventManager) SetSuspendTimeout(timeout time.Duration) {
+	evtmgr.mu.Lock()
+	evtmgr.suspendTimeout = timeout
+	evtmgr.mu.Unlock()
+}
+
+// IsExternal returns true if the EventManager suspends, rather than returning from Run,
+// when its event list empties.
+func (evtmgr *EventManager) IsExternal() bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.External
 }
 
 // SetWallclock assigns a value to the flag which when true puts the EventManager
 // into a model where it runs in tandem with wallclock time
 func (evtmgr *EventManager) SetWallclock(wallclock bool) {
+	evtmgr.mu.Lock()
 	evtmgr.Wallclock = wallclock
+	evtmgr.mu.Unlock()
+}
+
+// IsWallclock returns true if the EventManager runs in tandem with wallclock time.
+func (evtmgr *EventManager) IsWallclock() bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.Wallclock
+}
+
+// IsRunning returns true while the EventManager is in its dispatch loop and has not been stopped.
+func (evtmgr *EventManager) IsRunning() bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.RunFlag
+}
+
+// Idle returns true if the EventManager has no work: its event list is empty and no
+// event handler is executing, either because it is not running or because it is suspended
+// waiting for another thread to schedule an event.
+func (evtmgr *EventManager) Idle() bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.EventList.Len() == 0 && (evtmgr.suspended || !evtmgr.RunFlag)
+}
+
+// WaitIdle blocks until the EventManager is Idle.  A goroutine that schedules work on an
+// EventManager running in External mode can call it to wait for that work, and everything
+// it leads to, to be done before going on, where it would otherwise have to sleep and hope.
+func (evtmgr *EventManager) WaitIdle() {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.quiet == nil {
+		evtmgr.quiet = sync.NewCond(&evtmgr.mu)
+	}
+	for !(evtmgr.EventList.Len() == 0 && (evtmgr.suspended || !evtmgr.RunFlag)) {
+		evtmgr.quiet.Wait()
+	}
+}
+
+// quieten wakes the goroutines in WaitIdle to check whether the EventManager is idle.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) quieten() {
+	if evtmgr.quiet != nil {
+		evtmgr.quiet.Broadcast()
+	}
+}
+
+// CurrentEventID returns the identifier of the event being dispatched,
+// or evtq.InvalidEventID when the EventManager is not running.
+func (evtmgr *EventManager) CurrentEventID() int {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.EventID
+}
+
+// EventsDispatched returns the number of events the EventManager has dispatched,
+// counting the one being dispatched now.  Cancelled events are not counted.
+func (evtmgr *EventManager) EventsDispatched() int {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.NumEvts
+}
+
+// RunStartTime returns the wallclock time at which the most recent call to Run began.
+func (evtmgr *EventManager) RunStartTime() time.Time {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.StartTime
 }
 
 // CurrentTime returns a copy of the simulation's current time.
@@ -184,56 +414,222 @@ func (evtmgr *EventManager) CurrentTicks() int64 {
 	return ct
 }
 
-// realTimeDelay computes how long the EventManager should sleep now if running wallclock time,
-// and causes it to sleep that long
-func (evtmgr *EventManager) realTimeDelay(current, tgt vrtime.Time) {
-	if !evtmgr.Wallclock {
-		return
+// BeginHold declares that the caller is about to perform a blocking real-world operation
+// (e.g., a round-trip to a device) during which virtual time should not advance.  In wallclock
+// mode the EventManager excludes the real time that passes before the matching EndHold from
+// the pacing of the next event, so the operation does not silently consume simulated time.
+// Holds may be nested, and may be opened and closed by threads other than the one running
+// the EventManager.  Outside of wallclock mode holds have no effect.
+func (evtmgr *EventManager) BeginHold() {
+	evtmgr.mu.Lock()
+	if evtmgr.holds == 0 {
+		evtmgr.holdStart = time.Now()
 	}
+	evtmgr.holds += 1
+	evtmgr.mu.Unlock()
+}
 
-	// represent next event time in terms of ticks only
+// EndHold closes a hold opened by BeginHold.
+func (evtmgr *EventManager) EndHold() {
 	evtmgr.mu.Lock()
-	currentTimeInTicks := current.Ticks()
-	tgtTimeInTicks := tgt.Ticks()
+	if evtmgr.holds > 0 {
+		evtmgr.holds -= 1
+		if evtmgr.holds == 0 {
+			gap := time.Since(evtmgr.holdStart)
+			evtmgr.held += gap
+			evtmgr.totalHeld += gap
+		}
+	}
+	evtmgr.mu.Unlock()
+}
+
+// HoldVirtualTime calls op with virtual time held, as if bracketed by BeginHold and EndHold.
+func (evtmgr *EventManager) HoldVirtualTime(op func()) {
+	evtmgr.BeginHold()
+	defer evtmgr.EndHold()
+	op()
+}
+
+// HeldDuration returns the total wallclock time spent with virtual time held.
+func (evtmgr *EventManager) HeldDuration() time.Duration {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.totalHeld
+}
 
-	// compute how long the thread running EventManager should sleep,
-	// in the units of nanoseconds
-	gapInTicks := tgtTimeInTicks - currentTimeInTicks
-	gapInNanoseconds := gapInTicks * vrtime.NanoSecPerTick
-	gapInDuration := time.Duration(gapInNanoseconds)
+// paceTo delays the thread running the EventManager in wallclock mode until the real time
+// at which the next event is due, provided that event falls within the limit of the run.
+// It returns false if the delay was interrupted (see interrupt).
+func (evtmgr *EventManager) paceTo(limitTicks int64) bool {
+	nxtEvtTime, pending := evtmgr.EventList.TryMinTime()
+	if !pending {
+		return true
+	}
+	if evtmgr.tracing(TraceDebug) {
+		evtmgr.tracef("1. evt len %d, nxtTime %f\n", evtmgr.EventList.Len(), nxtEvtTime.Seconds())
+	}
+	if limitTicks < nxtEvtTime.Ticks() {
+		return true
+	}
+	evtmgr.mu.Lock()
+	banded := evtmgr.banding.on
 	evtmgr.mu.Unlock()
+	if banded {
+		return evtmgr.bandDelay(nxtEvtTime)
+	}
+	return evtmgr.realTimeDelay(evtmgr.CurrentTime(), nxtEvtTime)
+}
 
-	// fmt.Printf("For a vt gap of %f seconds, suspend %f seconds\n",
-	//	vrtime.TicksToSeconds(gapInTicks), gapInDuration.Seconds())
+// realTimeDelay causes the EventManager, running in wallclock mode, to sleep until the real
+// time at which the event at tgt is due, measured from the anchor of the run, so that the
+// real time the last event took, apart from time spent in holds, counts against the sleep.
+// It returns false if the sleep was interrupted.
+func (evtmgr *EventManager) realTimeDelay(current, tgt vrtime.Time) bool {
+	evtmgr.mu.Lock()
+	gap := evtmgr.scaled(tgt.Ticks() - current.Ticks())
+	due := evtmgr.dueAt(tgt.Ticks())
+	rescaled := evtmgr.adaptTo(time.Since(due), gap)
+	if rescaled {
+		// the scale has changed, and with it the anchor
+		due = evtmgr.dueAt(tgt.Ticks())
+	}
+	evtmgr.jitter.due = due
+	evtmgr.mu.Unlock()
+	if rescaled {
+		evtmgr.reportAdaptation()
+	}
 
-	time.Sleep(gapInDuration)
+	delay := time.Until(due)
+	if delay <= 0 {
+		return true
+	}
+	return evtmgr.wait(delay)
 }
 
 // function Run(LimitTime) starts the event dispatch loop for an EventManager
 // that has been inactive.  It will stay in this processing loop
 // until (a) there are events in queue, but none with timestamps greater than LimitTime,
 // (b) there are no events in queue, or (c) the last event executed set the Event Manager's
-// RunFlag to false.  In case (a) the clock of the Event Manager is set to LimitTime,
-// in cases (b) and (c) the clock is left at the time of the last event executed.
+// RunFlag to false.  In cases (a) and (b) the clock of the Event Manager is set to LimitTime,
+// in case (c) the clock is left at the time of the last event executed.  RunWithClock
+// selects a different policy for the clock.
+// If the run ended because an event handler called Abort, Err returns the cause; RunE
+// returns it to the caller directly.
 func (evtmgr *EventManager) Run(LimitTime float64) {
-
 	// input argument is in seconds, so transform to ticks
-	LimitTimeInTicks := vrtime.SecondsToTicks(LimitTime)
+	evtmgr.run(vrtime.SecondsToTicks(LimitTime), time.Time{}, ClockToLimit, false)
+}
+
+// RunUntil is Run, returning the reason the run ended, so that the caller need not work
+// it out from the clock and the length of the event list
+func (evtmgr *EventManager) RunUntil(LimitTime float64) StopReason {
+	return evtmgr.run(vrtime.SecondsToTicks(LimitTime), time.Time{}, ClockToLimit, false)
+}
+
+// StopReason tells why a run of the EventManager returned
+type StopReason int
+
+const (
+	// StopLimit means the next event lies beyond the limit of the run,
+	// and the clock has been advanced to the limit, unless RunWithClock placed it otherwise.
+	StopLimit StopReason = iota
+
+	// StopEmpty means the event list was exhausted.
+	StopEmpty
+
+	// StopStopped means Stop was called.
+	StopStopped
+
+	// StopBudget means the real time budget of the run was exhausted
+	// before the limit was reached.
+	StopBudget
+
+	// StopAborted means an event handler called Abort.  The cause is available from Err.
+	StopAborted
+
+	// StopSuspendTimeout means the EventManager, suspended in External mode, waited longer
+	// than the timeout set by SetSuspendTimeout for an event to be scheduled.
+	StopSuspendTimeout
+)
+
+// String describes the reason
+func (reason StopReason) String() string {
+	switch reason {
+	case StopLimit:
+		return "limit reached"
+	case StopEmpty:
+		return "event list empty"
+	case StopStopped:
+		return "stopped"
+	case StopBudget:
+		return "real time budget exhausted"
+	case StopAborted:
+		return "aborted"
+	case StopSuspendTimeout:
+		return "suspension timed out"
+	}
+	return "unknown"
+}
+
+// RunFor is Run, additionally bounded by a budget of real time.  Once realBudget has elapsed
+// no further event is dispatched, and RunFor returns StopBudget with the clock left at the
+// time of the last event executed; a later call takes up where it left off.  This lets an
+// interactive tool or a service time-slice simulation work cooperatively.  The handler of
+// an event is not interrupted, and in wallclock mode the wait for an event is not cut short,
+// so the budget may be overrun by that much.  RunFor returns the reason it stopped.
+func (evtmgr *EventManager) RunFor(simLimit float64, realBudget time.Duration) StopReason {
+	return evtmgr.run(vrtime.SecondsToTicks(simLimit), time.Now().Add(realBudget), ClockToLimit, false)
+}
+
+// run is the event dispatch loop behind Run and RunFor.  No event is dispatched after the
+// wallclock deadline, unless it is the zero time.Time.  The run leaves the clock as policy says.
+// Ordinarily the run ends once it has dispatched an event at or past the limit; with drain it
+// goes on to dispatch every event at the limit, ending only when none at or before it remain.
+func (evtmgr *EventManager) run(LimitTimeInTicks int64, deadline time.Time, policy ClockPolicy, drain bool) StopReason {
+	budgeted := !deadline.IsZero()
+	var reason StopReason
 
 	// as long as RunFlag is true the EventManager will stay in a loop
 	// the next event is pulled from the EventQueue and dispatched
+	evtmgr.mu.Lock()
 	evtmgr.RunFlag = true
+	evtmgr.abortErr = nil
 
 	// remember the wallclock time when events started executing
 	evtmgr.StartTime = time.Now()
+	evtmgr.lastDispatch = evtmgr.StartTime
+	evtmgr.held = 0
+	evtmgr.anchorAt(evtmgr.StartTime)
+	evtmgr.banding.late = false
+	evtmgr.beginStats(LimitTimeInTicks)
+	wallclock := evtmgr.Wallclock
+	threadOpts := evtmgr.threadOpts
+	authority := evtmgr.authority
+	evtmgr.mu.Unlock()
+
+	// in slave mode, the time up to which the authority has let the run dispatch events
+	var granted int64 = -1
 
+	// an interruption left over from before the run is not meant for it
+	select {
+	case <-evtmgr.wake:
+	default:
+	}
+
+	if threadOpts.Lock {
+		defer evtmgr.bindThread(threadOpts)()
+	}
+
+	// keep working if the RunFlag is true and there are events to dispatch.
+	// Each pass through the loop acquires the EventManager's lock once, and under it the
+	// EventQueue's lock once, both to decide whether to continue and to take the next event.
+	// The flags the loop consults are read under that lock as well, the wallclock flag
+	// being carried over from the previous pass.
 	var entry bool = true
-	// keep working if the RunFlag is true and there are events to dispatch
-	for evtmgr.RunFlag == (entry || (evtmgr.EventList.Len() > 0 && evtmgr.CurrentTicks() < LimitTimeInTicks)) {
+	for {
 
-		entry = false
-		// nxtEvt pulls off the package associated with the event with least
-		// time-stamp and unpacks it into
+		// The next event pulled off is the package associated with the event with least
+		// time-stamp, and holds
 		//   a) context is information the event handler may need about where and what
 		//      it is executing.  The code that schedules the event and the code that
 		//      handles the event have to be using the formatting, as the representation
@@ -247,100 +643,250 @@ func (evtmgr *EventManager) Run(LimitTime float64) {
 		//      The boolean return flags whether the event was dispatched without error
 		//   d) Events are given unique integer id numbers when scheduled, and evt_id
 		//      returns that of the event being dispatched
-		var nxtEvtTime vrtime.Time
-
-		if evtmgr.EventList.Len() > 0 {
-			// "wake up Clyde, we got something to do" (with apologies to JJ Cale)
-
-			// virtual time when me and Cldye do it
-			nxtEvtTime = evtmgr.EventList.MinTime()
 
-			if evtMgrTrace {
-				fmt.Printf("1. evt len %d, nxtTime %f\n", evtmgr.EventList.Len(), nxtEvtTime.Seconds())
-				log.Printf("1. evt len %d, nxtTime %f\n", evtmgr.EventList.Len(), nxtEvtTime.Seconds())
-			}
+		// in slave mode no event beyond the time granted may be dispatched
+		bound := LimitTimeInTicks
+		if authority != nil && granted < bound {
+			bound = granted
+		}
 
-			// if the minimum next event falls beyond the termination time set the
-			// event manager's time to the termination time and exit
-			if LimitTimeInTicks < nxtEvtTime.Ticks() {
-				evtmgr.Time = vrtime.CreateTime(LimitTimeInTicks, 0)
-				break
-			}
+		// if so configured, hold back this thread to align with the wallclock
+		paced := true
+		if wallclock {
+			paced = evtmgr.paceTo(evtmgr.heldBound(bound))
+		}
+		if budgeted && !time.Now().Before(deadline) {
+			reason = StopBudget
+			break
+		}
 
-			// if so configured, hold back this thread to align with the wallclock
-			if evtmgr.Wallclock {
-				evtmgr.realTimeDelay(evtmgr.CurrentTime(), nxtEvtTime)
+		evtmgr.mu.Lock()
+		if !evtmgr.RunFlag {
+			reason = StopStopped
+			if evtmgr.abortErr != nil {
+				reason = StopAborted
 			}
-
-			// get the next event, and call its handling function
-			evtmgr.mu.Lock()
-			event := nxtEvt(evtmgr.EventList) // safely extract the next event
 			evtmgr.mu.Unlock()
+			break
+		}
+		if evtmgr.paused {
+			// wait for Resume, or for Stop, then look again
+			evtmgr.park()
+			evtmgr.mu.Unlock()
+			continue
+		}
+		if !paced {
+			// the wait for the next event was cut short, so wait again
+			evtmgr.mu.Unlock()
+			continue
+		}
+		if !entry && !drain && evtmgr.Time.Ticks() >= LimitTimeInTicks {
+			evtmgr.mu.Unlock()
+			reason = StopLimit
+			break
+		}
+		entry = false
 
-			evtmgr.Time = event.Time       // update the EventManager's clock to be that of the next event
-			evtmgr.EventID = event.EventID // remember the eventId while we can, before the event disappears
+		// no event beyond the least limit of the requests to hold virtual time is dispatched
+		held := bound
+		if limit, holding := evtmgr.advanceLimit(); holding && limit < held {
+			held = limit
+		}
 
-			// dispatch the event using the information carried along by the event
-			if !event.Cancel {
-				event.EventHandler(evtmgr, event.Context, event.Data)
-				evtmgr.NumEvts += 1
+		// "wake up Clyde, we got something to do" (with apologies to JJ Cale)
+		value, _, found := evtmgr.EventList.PopUpTo(held)
+		if !found && held < bound {
+			// every event within the limit has been dispatched, so wait for the request
+			// to be released or for an event to be scheduled within it
+			evtmgr.suspended = true
+			evtmgr.quieten()
+			if evtmgr.tracing(TraceInfo) {
+				evtmgr.tracef("Holding evtmgr at time advance limit %f\n", vrtime.TicksToSeconds(held))
 			}
+			evtmgr.mu.Unlock()
+			_ = <-evtmgr.suspChan
+			continue
 		}
-
-		if evtmgr.External {
-			if evtMgrTrace {
-				fmt.Printf("Checking suspension %d, %t, lock %v\n", evtmgr.EventList.Len(), evtmgr.suspended, evtmgr.mu)
-				log.Printf("Checking suspension %d, %t, lock %v\n", evtmgr.EventList.Len(), evtmgr.suspended, evtmgr.mu)
+		if !found && bound < LimitTimeInTicks {
+			// every event granted has been dispatched, so catch the clock up to the
+			// grant and ask the authority for more
+			if granted > evtmgr.Time.Ticks() {
+				evtmgr.Time = vrtime.CreateTime(granted, 0)
+			}
+			next, pending := evtmgr.EventList.TryMinTime()
+			if !pending {
+				next = vrtime.InfinityTime()
 			}
-			evtmgr.mu.Lock()
-			if evtmgr.EventList.Len() == 0 {
-				evtmgr.suspended = true
-				if evtMgrTrace {
-					fmt.Println("Suspending evtmgr")
-					log.Println("Suspending evtmgr")
-				}
+			evtmgr.mu.Unlock()
+			grant, ok := authority.Grant(next)
+			if !ok {
+				evtmgr.mu.Lock()
+				evtmgr.RunFlag = false
+				evtmgr.mu.Unlock()
+				reason = StopStopped
+				break
+			}
+			if grant.Ticks() > granted {
+				granted = grant.Ticks()
+			}
+			if evtmgr.tracing(TraceInfo) {
+				evtmgr.tracef("granted advance to %f\n", vrtime.TicksToSeconds(granted))
+			}
+			continue
+		}
+		if !found {
+			if evtmgr.EventList.Len() > 0 {
+				// the minimum next event falls beyond the termination time, so exit
 				evtmgr.mu.Unlock()
-				// block on release message
-				_ = <-evtmgr.suspChan
-				evtmgr.suspended = false
-			} else {
+				reason = StopLimit
+				break
+			}
+			if !evtmgr.External {
 				evtmgr.mu.Unlock()
+				reason = StopEmpty
+				break
 			}
+
+			// the event list is empty, so block until another thread schedules an event
+			evtmgr.suspended = true
+			evtmgr.quieten()
+			if evtmgr.tracing(TraceInfo) {
+				evtmgr.tracef("Suspending evtmgr\n")
+			}
+			timeout := evtmgr.suspendTimeout
+			evtmgr.mu.Unlock()
+			if !evtmgr.awaitSchedule(timeout) {
+				reason = StopSuspendTimeout
+				break
+			}
+			if evtmgr.tracing(TraceInfo) {
+				evtmgr.tracef("Resuming evtmgr\n")
+			}
+			continue
 		}
 
-		// in order to see if we're done yet we need to get the time of next event
-		// so that it can be compared with a termination time
-		evtmgr.mu.Lock()
-		if evtmgr.EventList.Len() > 0 {
-			nxtEvtTime = evtmgr.EventList.MinTime()
+		event := value.(*Event)
+		evtmgr.Time = event.Time       // update the EventManager's clock to be that of the next event
+		evtmgr.EventID = event.EventID // remember the eventId while we can, before the event disappears
+		evtmgr.dispatching(event.EventID)
+		evtmgr.current = event
+		evtmgr.tickLogical(event)
+		cancelled := event.Cancel
+		buffered := false // withheld, to be dispatched once its class is enabled
+		if !cancelled && event.Class != "" {
+			cancelled, buffered = evtmgr.withheld(event)
 		}
+		var dropped []int // events that were to follow one cancelled or discarded
+		if cancelled && !buffered {
+			dropped = evtmgr.dropAfter(event.EventID, nil)
+		}
+		_, following := evtmgr.after[event.EventID] // events to be placed once this one is dispatched
+		if !cancelled {
+			evtmgr.NumEvts += 1
+			if evtmgr.NumEvts%statsInterval == 0 {
+				evtmgr.sampleStats()
+			}
+		}
+		wallclock = evtmgr.Wallclock
+		var raise func(*EventManager, time.Duration) // lag alarm to be raised, if any
+		if wallclock {
+			evtmgr.lastDispatch = time.Now()
+			evtmgr.drift = evtmgr.lastDispatch.Sub(evtmgr.dueAt(event.Time.Ticks()))
+			raise = evtmgr.checkLag()
+			if !evtmgr.jitter.due.IsZero() {
+				evtmgr.jitter.record(evtmgr.lastDispatch.Sub(evtmgr.jitter.due))
+				evtmgr.jitter.due = time.Time{}
+			}
+			evtmgr.held = 0
+		}
+		lag := evtmgr.drift
 		evtmgr.mu.Unlock()
+		if raise != nil {
+			if evtmgr.tracing(TraceInfo) {
+				evtmgr.tracef("virtual time %f behind real time by %v\n", event.Time.Seconds(), lag)
+			}
+			raise(evtmgr, lag)
+		}
 
+		// dispatch the event using the information carried along by the event
+		eventID := event.EventID
+		if !cancelled && evtmgr.admit(event) {
+			if following {
+				evtmgr.mu.Lock()
+				evtmgr.resolveAfter(event)
+				evtmgr.mu.Unlock()
+			}
+			evtmgr.rewrite(event)
+			if evtmgr.tracing(TraceEvents) {
+				evtmgr.tracef("dispatch event %d at %f\n", event.EventID, event.Time.Seconds())
+			}
+			evtmgr.beforeDispatch(event)
+			value := evtmgr.dispatch(event)
+			evtmgr.afterDispatch(event, value)
+			evtmgr.route(Result{EventID: eventID, Time: event.Time, Value: value})
+		} else if !cancelled && event.EventID != eventID {
+			// deferred by a filter, under a new identifier
+			evtmgr.reroute(eventID, event.EventID)
+		} else if !buffered {
+			evtmgr.route(Result{EventID: eventID, Time: event.Time, Cancelled: true})
+		}
+		evtmgr.routeDropped(dropped)
 	}
 	// if we fell out of the loop because evtmgr.RunFlag was set to false by an event,
 	// leave the clock of the event manager at the time of the last event executed.
-	//   Likewise, if the loop ends because there are no further events, leave
-	// the event manager time at the time of the last event executed.  This means
-	// we do nothing here.
-	if evtmgr.RunFlag && evtmgr.CurrentTime().Ticks() < LimitTimeInTicks {
-		// Either the queue is exhausted, or the next item in the queue
-		// starts beyond the termination time. In either event,
-		// we soak up the remaining time.
-		evtmgr.Time = vrtime.CreateTime(LimitTimeInTicks, 0)
+	// Nor do we move it if the real time budget ran out, as events within the limit
+	// remain to be dispatched.  Otherwise the queue is exhausted, or the next item in
+	// the queue starts beyond the termination time, and the policy places the clock.
+	evtmgr.mu.Lock()
+	if evtmgr.RunFlag {
+		evtmgr.settleClock(reason, LimitTimeInTicks, policy)
 	}
+	evtmgr.mu.Unlock()
+
+	// the items of this run are done with; its Events are left to ReleaseSlabs, as the model
+	// or its hooks may still hold some
+	evtmgr.EventList.ReleaseSlabs()
 
 	// falling out of the displatch loop we know the EventManager isn't running anymore
+	evtmgr.mu.Lock()
 	evtmgr.EventID = evtq.InvalidEventID
+	evtmgr.dispatching(evtq.InvalidEventID)
+	evtmgr.current = nil
 	evtmgr.RunFlag = false
+	evtmgr.stats.end = time.Now()
+	evtmgr.quieten()
+	evtmgr.mu.Unlock()
+	return reason
+}
+
+// dispatch calls the handler of an event taken from the event list, returning what the handler returns
+func (evtmgr *EventManager) dispatch(event *Event) any {
+	if policy := RecoveryPolicy(evtmgr.recovery.Load()); policy != RecoverNone {
+		return evtmgr.dispatchRecovering(event, policy)
+	}
+	return evtmgr.invoke(event)
+}
+
+// invoke calls the handler of an event, with profiling labels if they are selected
+func (evtmgr *EventManager) invoke(event *Event) any {
+	if evtmgr.profileLabels.Load() {
+		return evtmgr.dispatchLabeled(event)
+	}
+	return event.EventHandler(evtmgr, event.Context, event.Data)
 }
 
 // Stop stops the event dispatch loop of the EventManager.
+// It may be called from an event handler or from another goroutine.  A thread
+// suspended waiting for an event (see SetExternal), or sleeping in wallclock mode until
+// the next event is due, is woken, and Run returns.
 func (evtmgr *EventManager) Stop() {
+	evtmgr.mu.Lock()
 	evtmgr.RunFlag = false
+	evtmgr.mu.Unlock()
+	evtmgr.interrupt()
+	evtmgr.release()
 }
 
-var entryNum int = 1
-
 // Schedule creates a new event and puts it on the EventManager's event queue.
 // The call to Schedule passes all the parameters needed to create that event
 //   - event handler function
@@ -352,13 +898,58 @@ var entryNum int = 1
 // and the virtual time when the execution will occur.
 func (evtmgr *EventManager) Schedule(context any, data any,
 	handler func(*EventManager, any, any) any, offset vrtime.Time) (int, vrtime.Time) {
+	return evtmgr.ScheduleInLane(0, context, data, handler, offset)
+}
+
+// ScheduleSeconds is Schedule with the offset given in seconds and the priority given apart,
+// for model code that works in seconds.  A priority of 0 is replaced as it is by Schedule.
+func (evtmgr *EventManager) ScheduleSeconds(context any, data any,
+	handler func(*EventManager, any, any) any, offsetSeconds float64, pri int64) (int, vrtime.Time) {
 
-	// entryNum and eid are used in print statements during debugging
-	eid := entryNum
-	entryNum += 1
-	if evtMgrTrace {
-		fmt.Printf("enter Schedule entry %d with mutex %v, event time %f\n", eid, evtmgr.mu, evtmgr.Time.Plus(offset).Seconds())
-		log.Printf("enter Schedule entry %d with mutex %v, event time %f\n", eid, evtmgr.mu, evtmgr.Time.Plus(offset).Seconds())
+	offset := vrtime.SecondsToTime(offsetSeconds)
+	offset.SetPri(pri)
+	return evtmgr.Schedule(context, data, handler, offset)
+}
+
+// SetLaneOrder divides the event list into len(order) lanes, which at any tick are drained in
+// the sequence given by order, whatever the priorities of the events within them (see
+// [evtq.EventQueue.SetLaneOrder]).  Model events are scheduled into lane 0; a framework can
+// place its own events, e.g., window boundaries or the flushing of statistics, in another lane
+// with ScheduleInLane, and so order them ahead of or behind the model's events at a tick
+// without reserving priorities.
+func (evtmgr *EventManager) SetLaneOrder(order ...int) error {
+	return evtmgr.EventList.SetLaneOrder(order...)
+}
+
+// ScheduleInLane is Schedule, placing the new event in the given lane of the event list.
+// evtq.InvalidEventID is returned, with a zero Time, and nothing scheduled, if there is no
+// such lane.
+func (evtmgr *EventManager) ScheduleInLane(lane int, context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) (int, vrtime.Time) {
+	return evtmgr.scheduleInLane(lane, context, data, handler, offset, nil)
+}
+
+// scheduleInLane schedules an event in lane, with onResult, if not nil, as the callback for
+// its outcome, registered before the event can be dispatched
+func (evtmgr *EventManager) scheduleInLane(lane int, context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time, onResult func(Result)) (int, vrtime.Time) {
+
+	// eid numbers the calls to Schedule, to match up their trace statements
+	var eid int64
+	if evtmgr.tracing(TraceDebug) {
+		eid = evtmgr.scheduleCalls.Add(1)
+		evtmgr.tracef("enter Schedule entry %d, event time %f\n", eid, evtmgr.CurrentTime().Plus(offset).Seconds())
+	}
+
+	evtmgr.mu.Lock()
+
+	// refuse a lane the event list does not have before anything is committed to the event
+	if lane != 0 && (lane < 0 || lane >= evtmgr.EventList.Lanes()) {
+		evtmgr.mu.Unlock()
+		if evtmgr.tracing(TraceDebug) {
+			evtmgr.tracef("Schedule entry %d refuses lane %d\n", eid, lane)
+		}
+		return evtq.InvalidEventID, vrtime.Time{}
 	}
 
 	// change offset priority if it has a priority of 0
@@ -367,7 +958,6 @@ func (evtmgr *EventManager) Schedule(context any, data any,
 		evtmgr.autoPri += 1
 	}
 
-	evtmgr.mu.Lock()
 	// time of the last event to be pulled from the EventQueue
 	currentTime := evtmgr.Time
 
@@ -378,67 +968,383 @@ func (evtmgr *EventManager) Schedule(context any, data any,
 	newTime.SetPri(offset.Pri())
 
 	// bundle together the information needed for event dispatch
-	newEvent := Event{Context: context, EventHandler: handler, Data: data, Time: newTime}
+	newEvent := evtmgr.newEvent(context, data, handler, newTime)
+	newEvent.lane = lane
 
 	// put the event bundle into the EventQueue with priority equal to the
 	// scheduled time, and get in return the unique event id
 
-	eventID := evtmgr.EventList.Insert(&newEvent, newTime)
+	eventID := evtmgr.EventList.InsertInLane(newEvent, newTime, lane)
 
 	// newEvent just got placed into the EventQueue but we can still get
 	// at it and put in the identify of the event that carries it
 	newEvent.EventID = eventID
-	if evtMgrTrace {
-		fmt.Printf("Schedule entry %d schedules event %d at %f\n", eid, eventID, newTime.Seconds())
-		log.Printf("Schedule entry %d schedules event %d at %f\n", eid, eventID, newTime.Seconds())
+	if onResult != nil {
+		evtmgr.router().expect(eventID, onResult)
+	}
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("Schedule entry %d schedules event %d at %f\n", eid, eventID, newTime.Seconds())
+	}
+	var observed Event
+	observing := evtmgr.observing()
+	if observing {
+		observed = *newEvent
 	}
 	evtmgr.mu.Unlock()
-	if evtmgr.External {
-		// we block the thread managing the EventManger if the event list becomes empty, or unblock
-		// the thread when it is blocked and this scheduling transitions the event list from being
-		// empty to non-empty
-		evtmgr.mu.Lock()
-		if evtmgr.suspended && evtmgr.EventList.Len() == 1 {
-			// trigger for the unblock
-			if evtMgrTrace {
-				fmt.Println("Schedule unsuspends EventManager")
-				log.Println("Schedule unsuspends EventManager")
-			}
-
-			// the thread is blocked on channel suspChan, so we unblock with sending a message down the channel
-			evtmgr.suspChan <- true
-		}
-		evtmgr.mu.Unlock()
+	evtmgr.release()
+	if observing {
+		evtmgr.observe(OpSchedule, observed)
 	}
-	if evtMgrTrace {
-		fmt.Printf("Schedule entry %d returns\n", eid)
-		log.Printf("Schedule entry %d returns\n", eid)
+
+	if evtmgr.tracing(TraceDebug) {
+		evtmgr.tracef("Schedule entry %d returns\n", eid)
 	}
 
 	// return the eventId gotten from EventQueue, and the time of the scheduled event
 	return eventID, newTime
 }
 
-// nxtEvt pulls off the minimum time event from an EventQueue and
-// debundles the information it contains, returning the
-// unbundled fields
-func nxtEvt(queue *evtq.EventQueue) *Event {
-	event := queue.Pop().(*Event)
+// ScheduleNow creates an event that executes at the current virtual time, ahead of
+// every other event pending at that tick.  Its priority is drawn from the band reserved
+// at NowPriority, and events scheduled this way execute in the order they were scheduled.
+// Since such an event is usually the very next one dispatched, it is given to the
+// EventQueue's fast path rather than inserted into the heap.
+//
+// ScheduleNow returns the eventId of the new event and the virtual time when the execution will occur.
+func (evtmgr *EventManager) ScheduleNow(context any, data any,
+	handler func(*EventManager, any, any) any) (int, vrtime.Time) {
+
+	evtmgr.mu.Lock()
+	newTime := vrtime.CreateTime(evtmgr.Time.Ticks(), NowPriority+evtmgr.nowPri)
+	evtmgr.nowPri += 1
+
+	newEvent := evtmgr.newEvent(context, data, handler, newTime)
+	eventID := evtmgr.EventList.InsertFront(newEvent, newTime)
+	newEvent.EventID = eventID
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("ScheduleNow schedules event %d at %f\n", eventID, newTime.Seconds())
+	}
+	var observed Event
+	observing := evtmgr.observing()
+	if observing {
+		observed = *newEvent
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.release()
+	if observing {
+		evtmgr.observe(OpSchedule, observed)
+	}
+
+	return eventID, newTime
+}
+
+// ScheduleEndOfTick creates an event that executes at the current virtual time, after
+// every other event at that tick has executed and before the clock advances.  This supports
+// a two-phase update where the events of a tick compute new values and the end-of-tick
+// events commit them.  Its priority is drawn from the band reserved at EndOfTickPriority,
+// so an event scheduled at the present tick by an ordinary Schedule call (including one made
+// from an end-of-tick handler) still executes first.  End-of-tick events execute in the order
+// they were scheduled.
+//
+// ScheduleEndOfTick returns the eventId of the new event and the virtual time when the execution will occur.
+func (evtmgr *EventManager) ScheduleEndOfTick(context any, data any,
+	handler func(*EventManager, any, any) any) (int, vrtime.Time) {
+
+	evtmgr.mu.Lock()
+	newTime := vrtime.CreateTime(evtmgr.Time.Ticks(), EndOfTickPriority+evtmgr.endPri)
+	evtmgr.endPri += 1
+
+	newEvent := evtmgr.newEvent(context, data, handler, newTime)
+	eventID := evtmgr.EventList.Insert(newEvent, newTime)
+	newEvent.EventID = eventID
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("ScheduleEndOfTick schedules event %d at %f\n", eventID, newTime.Seconds())
+	}
+	var observed Event
+	observing := evtmgr.observing()
+	if observing {
+		observed = *newEvent
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.release()
+	if observing {
+		evtmgr.observe(OpSchedule, observed)
+	}
+
+	return eventID, newTime
+}
+
+// ScheduleAfterEvent creates a new event whose time is given relative to the time at which
+// the event with identifier eventID executes, rather than relative to the current time.
+// That time need not be known yet; the referenced event may itself have been scheduled
+// with ScheduleAfterEvent, or have its time changed before it executes.  Until the referenced
+// event is dispatched the new event is held in the event list at InfinityTime, so it has an
+// eventId that can be used with CancelEvent and RemoveEvent, and that can be referenced by
+// a further call to ScheduleAfterEvent to build up a chain of dependent activities.
+// If the referenced event is cancelled or removed, the new event is removed as well.
+//
+// ScheduleAfterEvent returns the eventId of the new event, and false (with evtq.InvalidEventID)
+// if there is no pending event with identifier eventID.
+func (evtmgr *EventManager) ScheduleAfterEvent(eventID int, context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) (int, bool) {
+
+	evtmgr.mu.Lock()
+	if evtmgr.EventList.GetValue(eventID) == nil {
+		evtmgr.mu.Unlock()
+		return evtq.InvalidEventID, false
+	}
+
+	// change offset priority if it has a priority of 0
+	if offset.Pri() == int64(0) {
+		offset.SetPri(evtmgr.autoPri)
+		evtmgr.autoPri += 1
+	}
+
+	newTime := vrtime.InfinityTime()
+	newEvent := evtmgr.newEvent(context, data, handler, newTime)
+	newID := evtmgr.EventList.Insert(newEvent, newTime)
+	newEvent.EventID = newID
+	evtmgr.after[eventID] = append(evtmgr.after[eventID], afterDep{eventID: newID, offset: offset})
+
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("ScheduleAfterEvent holds event %d until event %d executes\n", newID, eventID)
+	}
+	observed := *newEvent
+	evtmgr.mu.Unlock()
+	evtmgr.observe(OpSchedule, observed)
+	return newID, true
+}
+
+// resolveAfter gives the events that were scheduled to follow the event being dispatched
+// their times, now that the time of that event is known.  It is called only once filters
+// have let the event through; events following one that is cancelled or dropped are
+// removed with dropAfter instead.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) resolveAfter(event *Event) {
+	deps, present := evtmgr.after[event.EventID]
+	if !present {
+		return
+	}
+	delete(evtmgr.after, event.EventID)
+	for _, dep := range deps {
+		item := evtmgr.EventList.GetValue(dep.eventID)
+		if item == nil {
+			continue
+		}
+		newTime := event.Time.Plus(dep.offset)
+		newTime.SetPri(dep.offset.Pri())
+		item.(*Event).Time = newTime
+		evtmgr.EventList.UpdateTime(dep.eventID, newTime)
+	}
+}
+
+// dropAfter removes from the event list every event waiting, directly or through
+// a chain of other waiting events, on the event with identifier eventID.  It appends
+// the eventIds of those removed to dropped and returns it, for their outcomes to be
+// routed as cancelled once evtmgr.mu is released.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) dropAfter(eventID int, dropped []int) []int {
+	deps := evtmgr.after[eventID]
+	delete(evtmgr.after, eventID)
+	for _, dep := range deps {
+		dropped = evtmgr.dropAfter(dep.eventID, dropped)
+		if evtmgr.EventList.Remove(dep.eventID) {
+			dropped = append(dropped, dep.eventID)
+		}
+	}
+	return dropped
+}
+
+// routeDropped reports the outcomes of the events removed by dropAfter as cancelled
+func (evtmgr *EventManager) routeDropped(dropped []int) {
+	for _, eventID := range dropped {
+		evtmgr.route(Result{EventID: eventID, Cancelled: true})
+	}
+}
+
+// newEvent creates an Event, carving it from the slabs when slab allocation
+// is selected.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) newEvent(context any, data any,
+	handler func(*EventManager, any, any) any, time vrtime.Time) *Event {
+
+	// times already computed would be reinterpreted by a change to the time base
+	if !vrtime.TimeBaseFrozen() {
+		vrtime.FreezeTimeBase()
+	}
+
+	var event *Event
+	if evtmgr.slabs != nil {
+		event = evtmgr.slabs.Get()
+	} else {
+		event = new(Event)
+	}
+	event.Context = context
+	event.Data = data
+	event.EventHandler = handler
+	event.Time = time
+	event.Meta = evtmgr.metadataFor()
+	event.Clock = nil
+	event.lane = 0
+	event.Parent = evtq.InvalidEventID
+	if evtmgr.current != nil {
+		event.Parent = evtmgr.current.EventID
+	}
 	return event
 }
 
+// SetSlabSize selects slab allocation for the Events the EventManager creates and for
+// the items of its event list (see [evtq.EventQueue.SetSlabSize]).  When size is positive they
+// are carved, size at a time, out of preallocated slabs that are released wholesale,
+// drastically cutting the work of the garbage collector in short-lived, high-volume
+// simulations.  The event list releases the slabs of its items at the end of each call to
+// Run.  The slabs of Events are released only by ReleaseSlabs; until a model calls it, between
+// runs or windows of virtual time, they keep the memory of every Event allocated since it was
+// last called, however long the run.  A size of zero (the default) allocates each Event
+// individually.
+func (evtmgr *EventManager) SetSlabSize(size int) {
+	evtmgr.mu.Lock()
+	if size <= 0 {
+		evtmgr.slabs = nil
+	} else {
+		evtmgr.slabs = slab.New[Event](size)
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.EventList.SetSlabSize(size)
+}
+
+// ReleaseSlabs frees the slabs of Events wholesale, and those of the event list's items.  It is
+// to be called between runs.  If no event is pending the slabs of Events are zeroed and carved
+// again from the start, so every *Event handed out before, to the model, to dispatch hooks,
+// or to interceptors, must no longer be referenced; otherwise they are dropped, their memory
+// being reclaimed by the collector once the Events within them are gone.
+func (evtmgr *EventManager) ReleaseSlabs() {
+	evtmgr.mu.Lock()
+	if evtmgr.slabs != nil {
+		evtmgr.slabs.Release(evtmgr.EventList.Len() == 0 && evtmgr.buffered() == 0)
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.EventList.ReleaseSlabs()
+}
+
+// awaitSchedule blocks the suspended thread running the EventManager until it is released,
+// returning false if timeout, when positive, passes first
+func (evtmgr *EventManager) awaitSchedule(timeout time.Duration) bool {
+	if timeout <= 0 {
+		_ = <-evtmgr.suspChan
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-evtmgr.suspChan:
+		return true
+	case <-timer.C:
+	}
+
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if !evtmgr.suspended {
+		// released just as the timeout passed, so take the signal sent
+		_ = <-evtmgr.suspChan
+		return true
+	}
+	evtmgr.suspended = false
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Suspension of evtmgr timed out\n")
+	}
+	return false
+}
+
+// release unblocks the thread running the EventManager when it is suspended
+// waiting for an event, and the scheduling just done has transitioned the event
+// list from being empty to non-empty, or waiting on a request to hold virtual time.
+// It also lets a parent hosting the EventManager know that its event list has changed.
+func (evtmgr *EventManager) release() {
+	evtmgr.mu.Lock()
+	if evtmgr.suspended {
+		// trigger for the unblock, which is sent only once
+		evtmgr.suspended = false
+		if evtmgr.tracing(TraceInfo) {
+			evtmgr.tracef("Schedule unsuspends EventManager\n")
+		}
+
+		// the thread is blocked on channel suspChan, so we unblock with sending a message down the channel
+		evtmgr.suspChan <- true
+	}
+	host := evtmgr.host
+	evtmgr.mu.Unlock()
+
+	// a parent hosting the EventManager may need to drive it sooner
+	if host != nil {
+		host.rearm()
+	}
+}
+
 // CancelEvent cancels the indicated event from the event list
 func (evtmgr *EventManager) CancelEvent(eventID int) bool {
+	evtmgr.mu.Lock()
 	item := evtmgr.EventList.GetValue(eventID)
+	var observed Event
 	if item != nil {
 		evt := item.(*Event)
 		evt.Cancel = true
+		observed = *evt
+	}
+	evtmgr.mu.Unlock()
+	if item != nil {
+		evtmgr.observe(OpCancel, observed)
 	}
 	return item != nil
 }
 
+// CancelWhere cancels every pending event on the event list for which match returns true,
+// e.g., all those addressed to some entity of the model, and returns the number cancelled,
+// not counting those already cancelled.
+// match is called by the calling goroutine without the EventManager's lock held, and must
+// not modify the events.  Events spilled to disk (see SetSpill) are read back to be examined.
+func (evtmgr *EventManager) CancelWhere(match func(*Event) bool) int {
+	var matched []int
+	for _, pending := range evtmgr.EventList.Smallest(evtmgr.EventList.Len()) {
+		if match(pending.Value.(*Event)) {
+			matched = append(matched, pending.ItemID)
+		}
+	}
+
+	var observed []Event
+	evtmgr.mu.Lock()
+	for _, eventID := range matched {
+		// the event may have been dispatched, removed, or cancelled in the meantime
+		if item := evtmgr.EventList.GetValue(eventID); item != nil && !item.(*Event).Cancel {
+			evt := item.(*Event)
+			evt.Cancel = true
+			observed = append(observed, *evt)
+		}
+	}
+	evtmgr.mu.Unlock()
+	for _, evt := range observed {
+		evtmgr.observe(OpCancel, evt)
+	}
+	return len(observed)
+}
+
 // RemoveEvent removes the indicated event from the event list,
 // and returns a flag indicating whether the event was found and removed
 func (evtmgr *EventManager) RemoveEvent(eventID int) bool {
-	return evtmgr.EventList.Remove(eventID)
+	var observed *Event
+	evtmgr.mu.Lock()
+	dropped := evtmgr.dropAfter(eventID, nil)
+	if evtmgr.observing() {
+		if item := evtmgr.EventList.GetValue(eventID); item != nil {
+			copied := *item.(*Event)
+			observed = &copied
+		}
+	}
+	evtmgr.mu.Unlock()
+	removed := evtmgr.EventList.Remove(eventID)
+	if removed && observed != nil {
+		evtmgr.observe(OpRemove, *observed)
+	}
+	if removed {
+		evtmgr.route(Result{EventID: eventID, Cancelled: true})
+	}
+	evtmgr.routeDropped(dropped)
+	return removed
 }
@@ -0,0 +1,295 @@
+package evtm
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// BenchmarkRunHold measures the dispatch loop under the hold model: 1000 events pending, each
+// handler scheduling one more a random time ahead, and the run stopping after b.N dispatches.
+// It uses only the API of the original EventManager, so it can be run against it for
+// comparison.
+func BenchmarkRunHold(b *testing.B) {
+	evtmgr := New()
+	rng := rand.New(rand.NewSource(1))
+	dispatched := 0
+	var hold func(*EventManager, any, any) any
+	hold = func(evtmgr *EventManager, context any, data any) any {
+		dispatched += 1
+		if dispatched >= b.N {
+			evtmgr.Stop()
+			return nil
+		}
+		evtmgr.Schedule(nil, nil, hold, vrtime.CreateTime(1+rng.Int63n(1000), 0))
+		return nil
+	}
+	for idx := 0; idx < 1000; idx++ {
+		evtmgr.Schedule(nil, nil, hold, vrtime.CreateTime(1+rng.Int63n(1000), 0))
+	}
+	b.ResetTimer()
+	evtmgr.Run(1e6)
+}
+
+// TestScheduleNow checks that events scheduled with ScheduleNow run at the current time,
+// ahead of the events already pending at that tick and in the order they were scheduled
+func TestScheduleNow(t *testing.T) {
+	evtmgr := New()
+	var order []string
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, data.(string))
+		return nil
+	}
+	evtmgr.Schedule(nil, "first", func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, "first")
+		evtmgr.ScheduleNow(nil, "now 1", record)
+		if _, at := evtmgr.ScheduleNow(nil, "now 2", record); at.Ticks() != 10 {
+			t.Errorf("ScheduleNow placed an event at %d, want 10", at.Ticks())
+		}
+		return nil
+	}, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, "pending", record, vrtime.CreateTime(10, 0))
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(20, 0))
+	if want := []string{"first", "now 1", "now 2", "pending"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("events dispatched in the order %v, want %v", order, want)
+	}
+}
+
+// TestScheduleEndOfTick checks that an end-of-tick event runs after every other event at its
+// tick, including one scheduled there by an end-of-tick handler, and before the clock advances
+func TestScheduleEndOfTick(t *testing.T) {
+	evtmgr := New()
+	var order []string
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, data.(string))
+		return nil
+	}
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		evtmgr.ScheduleEndOfTick(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+			order = append(order, "commit")
+			evtmgr.Schedule(nil, "late", record, vrtime.CreateTime(0, 0))
+			evtmgr.ScheduleEndOfTick(nil, "commit again", record)
+			return nil
+		})
+		order = append(order, "compute")
+		return nil
+	}, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, "compute other", record, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, "next tick", record, vrtime.CreateTime(11, 0))
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(20, 0))
+	want := []string{"compute", "compute other", "commit", "late", "commit again", "next tick"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("events dispatched in the order %v, want %v", order, want)
+	}
+}
+
+// TestScheduleAfterEvent checks that a chain of events scheduled after one another is placed
+// relative to the times its links actually execute
+func TestScheduleAfterEvent(t *testing.T) {
+	evtmgr := New()
+	at := make(map[string]int64)
+	record := func(evtmgr *EventManager, context any, data any) any {
+		at[data.(string)] = evtmgr.CurrentTicks()
+		return nil
+	}
+	headID, _ := evtmgr.Schedule(nil, "head", record, vrtime.CreateTime(10, 0))
+	nextID, ok := evtmgr.ScheduleAfterEvent(headID, nil, "next", record, vrtime.CreateTime(5, 0))
+	if !ok {
+		t.Fatal("ScheduleAfterEvent refused a pending event")
+	}
+	evtmgr.ScheduleAfterEvent(nextID, nil, "last", record, vrtime.CreateTime(3, 0))
+	if _, ok := evtmgr.ScheduleAfterEvent(12345, nil, nil, record, vrtime.CreateTime(1, 0)); ok {
+		t.Error("ScheduleAfterEvent accepted an event that does not exist")
+	}
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if want := map[string]int64{"head": 10, "next": 15, "last": 18}; !reflect.DeepEqual(at, want) {
+		t.Errorf("events dispatched at %v, want %v", at, want)
+	}
+}
+
+// TestHoldVirtualTime checks that in wallclock mode the real time spent in a hold does not
+// count against the pacing of the next event
+func TestHoldVirtualTime(t *testing.T) {
+	const hold = 30 * time.Millisecond
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	var start, next time.Time
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		start = time.Now()
+		evtmgr.HoldVirtualTime(func() { time.Sleep(hold) })
+		return nil
+	}, vrtime.CreateTime(0, 0))
+	evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any {
+		next = time.Now()
+		return nil
+	}, vrtime.SecondsToTime(0.05))
+
+	evtmgr.Run(1)
+	if held := evtmgr.HeldDuration(); held < hold {
+		t.Errorf("HeldDuration is %v, want at least %v", held, hold)
+	}
+	if gap := next.Sub(start); gap < 50*time.Millisecond+hold-5*time.Millisecond {
+		t.Errorf("next event dispatched %v after the holding one, want the hold on top of 50ms", gap)
+	}
+}
+
+// TestConcurrentAccess checks that another goroutine can feed, observe, and stop a running
+// EventManager through its methods.  Run it with -race.
+func TestConcurrentAccess(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetExternal(true)
+	done := make(chan struct{})
+	go func() {
+		evtmgr.Run(1e6)
+		close(done)
+	}()
+
+	var dispatched atomic.Int64
+	handler := func(*EventManager, any, any) any { dispatched.Add(1); return nil }
+	for i := 0; i < 100; i++ {
+		evtmgr.Schedule(nil, nil, handler, vrtime.CreateTime(int64(i), 0))
+		evtmgr.CurrentTime()
+		evtmgr.IsRunning()
+		evtmgr.CurrentEventID()
+	}
+	for dispatched.Load() < 100 {
+		time.Sleep(time.Millisecond)
+	}
+	if !evtmgr.IsRunning() || !evtmgr.IsExternal() {
+		t.Error("suspended EventManager reported as not running in External mode")
+	}
+	evtmgr.Stop()
+	<-done
+	if evtmgr.IsRunning() || evtmgr.EventsDispatched() != 100 {
+		t.Errorf("stopped after %d events, running %v; want 100, false", evtmgr.EventsDispatched(), evtmgr.IsRunning())
+	}
+}
+
+// TestRunForBudget checks that RunFor returns StopBudget once its real time budget is spent,
+// leaving the clock at the last event executed, and that a later call takes up from there
+func TestRunForBudget(t *testing.T) {
+	evtmgr := New()
+	var dispatched int64
+	var step func(*EventManager, any, any) any
+	step = func(evtmgr *EventManager, context any, data any) any {
+		dispatched++
+		time.Sleep(time.Millisecond)
+		evtmgr.Schedule(nil, nil, step, vrtime.CreateTime(1, 0))
+		return nil
+	}
+	evtmgr.Schedule(nil, nil, step, vrtime.CreateTime(1, 0))
+
+	for slice := 1; slice <= 2; slice++ {
+		before := dispatched
+		if reason := evtmgr.RunFor(1, 20*time.Millisecond); reason != StopBudget {
+			t.Fatalf("slice %d stopped for %q, want %q", slice, reason, StopBudget)
+		}
+		if dispatched == before || dispatched > before+25 {
+			t.Errorf("slice %d dispatched %d events in 20ms of 1ms handlers", slice, dispatched-before)
+		}
+		if now := evtmgr.CurrentTime().Ticks(); now != dispatched {
+			t.Errorf("slice %d left the clock at %d, want the last event at %d", slice, now, dispatched)
+		}
+	}
+}
+
+// TestTimeBaseFrozen checks that scheduling an event freezes the time base, after which a
+// change to the tick rate is refused, and that a rate that is not positive is always refused
+func TestTimeBaseFrozen(t *testing.T) {
+	if err := vrtime.ConfigureTicksPerSecond(0); err == nil || errors.Is(err, vrtime.ErrTimeBaseFrozen) {
+		t.Errorf("zero ticks per second gave error %v, want one for the rate", err)
+	}
+	New().Schedule(nil, nil, func(*EventManager, any, any) any { return nil }, vrtime.CreateTime(1, 0))
+	if !vrtime.TimeBaseFrozen() {
+		t.Fatal("time base not frozen once an event is scheduled")
+	}
+	tps := vrtime.TicksPerSecond
+	if err := vrtime.ConfigureTicksPerSecond(tps); !errors.Is(err, vrtime.ErrTimeBaseFrozen) {
+		t.Errorf("change of a frozen time base gave error %v, want ErrTimeBaseFrozen", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("SetTicksPerSecond did not panic on a frozen time base")
+		}
+	}()
+	vrtime.SetTicksPerSecond(tps)
+}
+
+// TestRunUntil checks the reasons RunUntil gives for a run reaching its limit, running out of
+// events, and waiting in External mode longer than the suspension timeout
+func TestRunUntil(t *testing.T) {
+	noop := func(*EventManager, any, any) any { return nil }
+	evtmgr := New()
+	evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, nil, noop, vrtime.SecondsToTime(2))
+	if reason := evtmgr.RunUntil(1); reason != StopLimit {
+		t.Errorf("run with an event beyond its limit ended for %q, want %q", reason, StopLimit)
+	}
+	if reason := evtmgr.RunUntil(3); reason != StopEmpty {
+		t.Errorf("run out of events ended for %q, want %q", reason, StopEmpty)
+	}
+
+	evtmgr = New()
+	evtmgr.SetExternal(true)
+	evtmgr.SetSuspendTimeout(10 * time.Millisecond)
+	evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(10, 0))
+	if reason := evtmgr.RunUntil(1); reason != StopSuspendTimeout || evtmgr.CurrentTicks() != 10 {
+		t.Errorf("suspended run ended for %q with the clock at %d, want %q at 10",
+			reason, evtmgr.CurrentTicks(), StopSuspendTimeout)
+	}
+}
+
+// TestScheduleSeconds checks that ScheduleSeconds places an event the number of seconds
+// given ahead with the priority given, which orders the events at the same time
+func TestScheduleSeconds(t *testing.T) {
+	evtmgr := New()
+	evtmgr.AdvanceTo(vrtime.SecondsToTime(1))
+	var order []string
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, data.(string))
+		return nil
+	}
+	_, at := evtmgr.ScheduleSeconds(nil, "low", record, 0.5, 7)
+	if at.Ticks() != vrtime.SecondsToTicks(1.5) || at.Pri() != 7 {
+		t.Errorf("event placed at %s, want 1.5s with priority 7", at.TimeStr())
+	}
+	evtmgr.ScheduleSeconds(nil, "high", record, 0.5, 3)
+	evtmgr.ScheduleSeconds(nil, "last", record, 0.5, 9)
+	evtmgr.AdvanceTo(vrtime.SecondsToTime(2))
+	if want := []string{"high", "low", "last"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("events dispatched in order %v, want %v", order, want)
+	}
+}
+
+// TestCancelWhere checks that CancelWhere cancels the pending events that match, counting
+// none twice, and that the events cancelled are not dispatched
+func TestCancelWhere(t *testing.T) {
+	evtmgr := New()
+	var dispatched []int
+	handler := func(evtmgr *EventManager, context any, data any) any {
+		dispatched = append(dispatched, data.(int))
+		return nil
+	}
+	for idx := 1; idx <= 6; idx++ {
+		evtmgr.Schedule(nil, idx, handler, vrtime.CreateTime(int64(idx), 0))
+	}
+	even := func(event *Event) bool { return event.Data.(int)%2 == 0 }
+	if n := evtmgr.CancelWhere(even); n != 3 {
+		t.Errorf("cancelled %d events, want 3", n)
+	}
+	if n := evtmgr.CancelWhere(even); n != 0 {
+		t.Errorf("cancelled %d events again, want 0", n)
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	if want := []int{1, 3, 5}; !reflect.DeepEqual(dispatched, want) {
+		t.Errorf("dispatched %v, want %v", dispatched, want)
+	}
+}
@@ -0,0 +1,223 @@
+// Package evtmtest provides a test double for unit-testing event handlers.
+//
+// A Mock wraps a real [evtm.EventManager] whose dispatch loop is never left running: a test
+// calls a handler directly with Invoke, inspects the Schedule, CancelEvent, and RemoveEvent
+// calls the handler made, advances the clock by hand, and asserts what it expects, e.g.,
+//
+//	mock := evtmtest.New()
+//	mock.Invoke(sendFrame, link, frame)
+//	mock.ExpectScheduled(t, retransmit, vrtime.SecondsToTime(0.2))
+//	mock.Advance(vrtime.SecondsToTime(0.2))	// dispatches the retransmission
+package evtmtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// TB is the part of [testing.TB] that the assertions of a Mock use
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Call records a scheduling call made on the EventManager of a Mock
+type Call struct {
+	Op      evtm.ScheduleOp // what was done
+	EventID int             // identifier of the event concerned
+	Handler string          // name of the event's handler (see evtm.HandlerName)
+	Context any             // context of the event
+	Data    any             // data of the event
+	At      vrtime.Time     // virtual time at which the call was made
+	Time    vrtime.Time     // time at which the event is to execute
+}
+
+// Offset returns how far after the call the event is to execute, ignoring priority
+func (call Call) Offset() vrtime.Time {
+	return vrtime.CreateTime(call.Time.Ticks()-call.At.Ticks(), 0)
+}
+
+// String describes the call
+func (call Call) String() string {
+	return fmt.Sprintf("%s event %d handler %s at %s offset %gs", call.Op, call.EventID,
+		call.Handler, call.At.TimeStr(), call.Offset().Seconds())
+}
+
+// Mock is a test double of an EventManager
+type Mock struct {
+	mgr     *evtm.EventManager
+	mu      sync.Mutex
+	calls   []Call
+	pending map[int]Call
+}
+
+// New creates a Mock with an EventManager at virtual time zero and no pending events
+func New() *Mock {
+	mock := &Mock{mgr: evtm.New(), pending: make(map[int]Call)}
+	mock.mgr.AddScheduleObserver(mock.record)
+	return mock
+}
+
+// record is the ScheduleObserver through which the Mock learns of scheduling calls
+func (mock *Mock) record(mgr *evtm.EventManager, op evtm.ScheduleOp, event evtm.Event) {
+	call := Call{Op: op, EventID: event.EventID, Handler: evtm.HandlerName(event.EventHandler),
+		Context: event.Context, Data: event.Data, At: mgr.CurrentTime(), Time: event.Time}
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.calls = append(mock.calls, call)
+	if op == evtm.OpSchedule {
+		mock.pending[call.EventID] = call
+	} else {
+		delete(mock.pending, call.EventID)
+	}
+}
+
+// Manager returns the EventManager the Mock wraps, to be given to code under test
+func (mock *Mock) Manager() *evtm.EventManager {
+	return mock.mgr
+}
+
+// Invoke calls handler with context and data, as the EventManager would dispatch it at
+// the current virtual time, and returns what the handler returns
+func (mock *Mock) Invoke(handler evtm.EventHandlerFunction, context any, data any) any {
+	return handler(mock.mgr, context, data)
+}
+
+// Now returns the current virtual time
+func (mock *Mock) Now() vrtime.Time {
+	return mock.mgr.CurrentTime()
+}
+
+// SetTime sets the virtual time, dispatching nothing
+func (mock *Mock) SetTime(t vrtime.Time) {
+	mock.mgr.SetTime(t)
+}
+
+// Advance advances the virtual time by offset, dispatching in order the pending events
+// whose times are reached, and returns the number dispatched
+func (mock *Mock) Advance(offset vrtime.Time) int {
+	return mock.AdvanceTo(mock.mgr.CurrentTime().Plus(offset))
+}
+
+// AdvanceTo advances the virtual time to t, dispatching in order the pending events whose
+// times are reached, and returns the number dispatched.  Events the dispatched handlers
+// schedule are dispatched too, if their times are reached.  Nothing is done if t is
+// before the current time.
+func (mock *Mock) AdvanceTo(t vrtime.Time) int {
+	if t.Ticks() < mock.mgr.CurrentTicks() {
+		return 0
+	}
+	before := mock.mgr.EventsDispatched()
+	mock.mgr.Run(t.Seconds())
+	mock.mgr.SetTime(vrtime.CreateTime(t.Ticks(), 0))
+	mock.mu.Lock()
+	for id := range mock.pending {
+		if mock.mgr.EventList.GetValue(id) == nil {
+			delete(mock.pending, id)
+		}
+	}
+	mock.mu.Unlock()
+	return mock.mgr.EventsDispatched() - before
+}
+
+// Calls returns the scheduling calls recorded, in the order they were made
+func (mock *Mock) Calls() []Call {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return append([]Call(nil), mock.calls...)
+}
+
+// Scheduled returns the recorded calls that scheduled an event with the named handler,
+// in the order they were made
+func (mock *Mock) Scheduled(handler evtm.EventHandlerFunction) []Call {
+	name := evtm.HandlerName(handler)
+	var found []Call
+	for _, call := range mock.Calls() {
+		if call.Op == evtm.OpSchedule && call.Handler == name {
+			found = append(found, call)
+		}
+	}
+	return found
+}
+
+// Pending returns the calls that scheduled the events still waiting to be dispatched,
+// ordered by eventID
+func (mock *Mock) Pending() []Call {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	found := make([]Call, 0, len(mock.pending))
+	for _, call := range mock.pending {
+		found = append(found, call)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].EventID < found[j].EventID })
+	return found
+}
+
+// Reset forgets the calls recorded so far.  Pending events stay pending.
+func (mock *Mock) Reset() {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.calls = nil
+}
+
+// describe lists the recorded calls, for the message of a failed assertion
+func (mock *Mock) describe() string {
+	calls := mock.Calls()
+	if len(calls) == 0 {
+		return "no calls were recorded"
+	}
+	var sb strings.Builder
+	sb.WriteString("recorded calls:")
+	for _, call := range calls {
+		fmt.Fprintf(&sb, "\n  %s", call)
+	}
+	return sb.String()
+}
+
+// ExpectScheduled reports an error unless an event with the named handler was scheduled
+// offset (ignoring priority) after the time of the call.  It returns the first such call.
+func (mock *Mock) ExpectScheduled(t TB, handler evtm.EventHandlerFunction, offset vrtime.Time) Call {
+	t.Helper()
+	for _, call := range mock.Scheduled(handler) {
+		if call.Offset().Ticks() == offset.Ticks() {
+			return call
+		}
+	}
+	t.Errorf("evtmtest: expected %s to be scheduled %gs out; %s",
+		evtm.HandlerName(handler), offset.Seconds(), mock.describe())
+	return Call{EventID: evtq.InvalidEventID}
+}
+
+// ExpectNotScheduled reports an error if an event with the named handler was scheduled
+func (mock *Mock) ExpectNotScheduled(t TB, handler evtm.EventHandlerFunction) {
+	t.Helper()
+	if calls := mock.Scheduled(handler); len(calls) > 0 {
+		t.Errorf("evtmtest: expected %s not to be scheduled; %s", evtm.HandlerName(handler), mock.describe())
+	}
+}
+
+// ExpectCancelled reports an error unless the event with the given identifier was
+// cancelled or removed
+func (mock *Mock) ExpectCancelled(t TB, eventID int) {
+	t.Helper()
+	for _, call := range mock.Calls() {
+		if call.EventID == eventID && call.Op != evtm.OpSchedule {
+			return
+		}
+	}
+	t.Errorf("evtmtest: expected event %d to be cancelled or removed; %s", eventID, mock.describe())
+}
+
+// ExpectPending reports an error unless exactly n events are waiting to be dispatched
+func (mock *Mock) ExpectPending(t TB, n int) {
+	t.Helper()
+	if pending := mock.Pending(); len(pending) != n {
+		t.Errorf("evtmtest: expected %d pending events, found %d; %s", n, len(pending), mock.describe())
+	}
+}
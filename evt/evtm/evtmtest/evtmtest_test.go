@@ -0,0 +1,61 @@
+package evtmtest
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// sender is a model under test: it transmits a frame, retransmitting it until acknowledged
+type sender struct {
+	sent    int
+	timeout int
+}
+
+func transmit(mgr *evtm.EventManager, context any, data any) any {
+	snd := context.(*sender)
+	snd.sent++
+	snd.timeout, _ = mgr.Schedule(snd, data, transmit, vrtime.SecondsToTime(0.2))
+	return nil
+}
+
+func acknowledge(mgr *evtm.EventManager, context any, data any) any {
+	mgr.RemoveEvent(context.(*sender).timeout)
+	return nil
+}
+
+// failures counts the assertions of a Mock that fail
+type failures int
+
+func (fl *failures) Helper()                           {}
+func (fl *failures) Errorf(format string, args ...any) { *fl++ }
+
+// TestMock checks that a Mock records what a handler schedules and removes, dispatches
+// pending events as its clock is advanced, and fails assertions that do not hold
+func TestMock(t *testing.T) {
+	mock := New()
+	snd := &sender{}
+	mock.Invoke(transmit, snd, "frame")
+	call := mock.ExpectScheduled(t, transmit, vrtime.SecondsToTime(0.2))
+	mock.ExpectPending(t, 1)
+
+	if n := mock.Advance(vrtime.SecondsToTime(0.5)); n != 2 || snd.sent != 3 {
+		t.Errorf("advancing 0.5s dispatched %d events and sent %d frames, want 2 and 3", n, snd.sent)
+	}
+	mock.Invoke(acknowledge, snd, nil)
+	mock.ExpectCancelled(t, snd.timeout)
+	mock.ExpectPending(t, 0)
+	if scheduled := mock.Scheduled(transmit); len(scheduled) != 3 || scheduled[0] != call {
+		t.Errorf("recorded %v, want three transmissions starting with %v", scheduled, call)
+	}
+
+	var fl failures
+	mock.ExpectScheduled(&fl, transmit, vrtime.SecondsToTime(0.3))
+	mock.ExpectNotScheduled(&fl, transmit)
+	mock.ExpectCancelled(&fl, call.EventID)
+	mock.ExpectPending(&fl, 1)
+	if fl != 4 {
+		t.Errorf("%d of 4 false assertions failed", fl)
+	}
+}
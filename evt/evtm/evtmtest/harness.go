@@ -0,0 +1,129 @@
+package evtmtest
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Harness coordinates an EventManager running in External mode with the goroutines that
+// feed it, so that their interleaving is scripted rather than left to the scheduler.  Each
+// producer runs on a goroutine of its own, but only when the script hands it a step, and
+// every step ends only once the EventManager has dispatched everything the step led to and
+// suspended again.  The suspend/resume logic of the EventManager and the glue code of a
+// model are thereby tested without sleeps, and a failing interleaving is reproduced every
+// time.  A Harness records a transcript of the steps taken and the events dispatched.
+type Harness struct {
+	mgr        *evtm.EventManager
+	done       chan struct{}
+	started    bool
+	stopped    bool
+	transcript []string
+	remove     func()
+	mu         sync.Mutex
+}
+
+// Producer is a goroutine fed by a Harness
+type Producer struct {
+	harness *Harness
+	name    string
+	steps   chan func(*evtm.EventManager)
+	stepped chan struct{}
+}
+
+// NewHarness creates a Harness around mgr, putting mgr into External mode
+func NewHarness(mgr *evtm.EventManager) *Harness {
+	mgr.SetExternal(true)
+	h := &Harness{mgr: mgr, done: make(chan struct{})}
+	h.remove = mgr.AddInterceptor(func(mgr *evtm.EventManager, event *evtm.Event) {
+		h.note("dispatch %s at %s", evtm.HandlerName(event.EventHandler), event.Time.TimeStr())
+	})
+	return h
+}
+
+// note appends a line to the transcript
+func (h *Harness) note(format string, args ...any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transcript = append(h.transcript, fmt.Sprintf(format, args...))
+}
+
+// Manager returns the EventManager the Harness coordinates
+func (h *Harness) Manager() *evtm.EventManager {
+	return h.mgr
+}
+
+// Start runs the EventManager on a goroutine of its own, with no limit on virtual time,
+// and waits for it to dispatch the events already scheduled and suspend
+func (h *Harness) Start() {
+	h.mu.Lock()
+	if h.started {
+		h.mu.Unlock()
+		return
+	}
+	h.started = true
+	h.mu.Unlock()
+
+	go func() {
+		h.mgr.Run(vrtime.TicksToSeconds(math.MaxInt64 / 2))
+		close(h.done)
+	}()
+	h.Settle()
+}
+
+// Settle waits until the EventManager has dispatched every pending event and suspended
+func (h *Harness) Settle() {
+	h.mgr.WaitIdle()
+}
+
+// Producer creates a producer with the given name, for the transcript
+func (h *Harness) Producer(name string) *Producer {
+	p := &Producer{harness: h, name: name,
+		steps: make(chan func(*evtm.EventManager)), stepped: make(chan struct{})}
+	go func() {
+		for step := range p.steps {
+			step(h.mgr)
+			p.stepped <- struct{}{}
+		}
+	}()
+	return p
+}
+
+// Step runs fn on the producer's goroutine, waits for it to return, and then waits for the
+// EventManager to dispatch everything fn scheduled, and everything that led to, and suspend.
+// label describes the step in the transcript.
+func (p *Producer) Step(label string, fn func(mgr *evtm.EventManager)) {
+	p.harness.note("step %s: %s", p.name, label)
+	p.steps <- fn
+	<-p.stepped
+	p.harness.Settle()
+}
+
+// Close ends the producer's goroutine
+func (p *Producer) Close() {
+	close(p.steps)
+}
+
+// Stop stops the EventManager, waits for its Run to return, and returns the transcript
+func (h *Harness) Stop() []string {
+	h.mu.Lock()
+	started, stopped := h.started, h.stopped
+	h.stopped = true
+	h.mu.Unlock()
+	if started && !stopped {
+		h.mgr.Stop()
+		<-h.done
+		h.remove()
+	}
+	return h.Transcript()
+}
+
+// Transcript returns the steps taken and the events dispatched so far, in order
+func (h *Harness) Transcript() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.transcript...)
+}
@@ -0,0 +1,45 @@
+package evtmtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+func pong(*evtm.EventManager, any, any) any { return nil }
+
+func ping(mgr *evtm.EventManager, context any, data any) any {
+	mgr.Schedule(nil, nil, pong, vrtime.CreateTime(1, 0))
+	return nil
+}
+
+// scriptedRun feeds an EventManager from two producers by a fixed script
+func scriptedRun() []string {
+	h := NewHarness(evtm.New())
+	h.Start()
+	first, second := h.Producer("first"), h.Producer("second")
+	defer first.Close()
+	defer second.Close()
+	first.Step("ping", func(mgr *evtm.EventManager) { mgr.Schedule(nil, nil, ping, vrtime.CreateTime(5, 0)) })
+	second.Step("pong", func(mgr *evtm.EventManager) { mgr.Schedule(nil, nil, pong, vrtime.CreateTime(2, 0)) })
+	first.Step("ping again", func(mgr *evtm.EventManager) { mgr.Schedule(nil, nil, ping, vrtime.CreateTime(0, 0)) })
+	return h.Stop()
+}
+
+// TestHarness checks that each step of a script is dispatched to completion before the next,
+// so that the transcript of the run, the order of scheduling included, is the same every time
+func TestHarness(t *testing.T) {
+	pingName, pongName := evtm.HandlerName(ping), evtm.HandlerName(pong)
+	want := fmt.Sprint([]string{
+		"step first: ping", "dispatch " + pingName + " at (5,1)", "dispatch " + pongName + " at (6,2)",
+		"step second: pong", "dispatch " + pongName + " at (8,3)",
+		"step first: ping again", "dispatch " + pingName + " at (8,4)", "dispatch " + pongName + " at (9,5)",
+	})
+	for run := 0; run < 20; run++ {
+		if got := fmt.Sprint(scriptedRun()); got != want {
+			t.Fatalf("run %d transcript %s, want %s", run, got, want)
+		}
+	}
+}
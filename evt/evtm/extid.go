@@ -0,0 +1,142 @@
+package evtm
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Internally an event is identified by its compact integer eventId, drawn from a counter of
+// its EventManager.  Systems outside the simulator that refer to events, through an API or
+// in a trace, may instead be given external identifiers, strings such as UUIDs that need no
+// coordination of counters to be unique.  Once SetExternalIDs has switched them on, the
+// EventManager maps each pending event's eventId to its external identifier and back, until
+// the event has been dispatched or removed.
+
+// externalIDs holds the mapping between eventIds and external identifiers.  It is guarded
+// by the lock of the EventManager.
+type externalIDs struct {
+	gen     func() string  // source of the identifiers of events scheduled, nil if assigned only explicitly
+	byEvent map[int]string // external identifier of each event, by eventId
+	byExt   map[string]int // eventId of each event, by external identifier
+	current int            // eventId of the event dispatched last, whose identifier is kept while it runs
+	remove  func()         // removes the ScheduleObserver that keeps the mapping
+}
+
+// NewUUID returns a random (version 4) UUID in its canonical textual form
+func NewUUID() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(fmt.Sprintf("evtm: reading random bytes for a UUID: %v", err))
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+// SetExternalIDs switches on external identifiers, giving every event scheduled from now on
+// the identifier gen returns, e.g., NewUUID.  With a nil gen, events have external
+// identifiers only as AssignExternalID gives them.  A Clone of the EventManager does not
+// inherit the mapping.
+func (evtmgr *EventManager) SetExternalIDs(gen func() string) {
+	evtmgr.ClearExternalIDs()
+	ext := &externalIDs{gen: gen, byEvent: make(map[int]string), byExt: make(map[string]int)}
+	ext.remove = evtmgr.AddScheduleObserver(func(evtmgr *EventManager, op ScheduleOp, event Event) {
+		var extID string
+		if op == OpSchedule && ext.gen != nil {
+			extID = ext.gen()
+		}
+		evtmgr.mu.Lock()
+		defer evtmgr.mu.Unlock()
+		switch op {
+		case OpSchedule:
+			if extID != "" {
+				ext.assign(event.EventID, extID)
+			}
+		case OpRemove:
+			ext.retire(event.EventID)
+		}
+	})
+	evtmgr.mu.Lock()
+	evtmgr.external = ext
+	evtmgr.mu.Unlock()
+}
+
+// ClearExternalIDs switches external identifiers off, forgetting those assigned
+func (evtmgr *EventManager) ClearExternalIDs() {
+	evtmgr.mu.Lock()
+	ext := evtmgr.external
+	evtmgr.external = nil
+	evtmgr.mu.Unlock()
+	if ext != nil {
+		ext.remove()
+	}
+}
+
+// AssignExternalID gives the pending event with the eventId given the external identifier
+// extID, in place of any it has.  It is an error if external identifiers are off, if there
+// is no such event, or if another event has the identifier.
+func (evtmgr *EventManager) AssignExternalID(eventID int, extID string) error {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	ext := evtmgr.external
+	if ext == nil {
+		return errors.New("external event identifiers are not switched on")
+	}
+	if evtmgr.EventList.GetValue(eventID) == nil && eventID != ext.current {
+		return fmt.Errorf("event %d is not pending", eventID)
+	}
+	if other, taken := ext.byExt[extID]; taken && other != eventID {
+		return fmt.Errorf("external identifier %q is already that of event %d", extID, other)
+	}
+	ext.retire(eventID)
+	ext.assign(eventID, extID)
+	return nil
+}
+
+// ExternalID returns the external identifier of the event with the eventId given, while
+// it is pending or being dispatched, and false if it has none
+func (evtmgr *EventManager) ExternalID(eventID int) (string, bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.external == nil {
+		return "", false
+	}
+	extID, found := evtmgr.external.byEvent[eventID]
+	return extID, found
+}
+
+// LookupExternalID returns the eventId of the event with the external identifier given,
+// e.g., to cancel it, and false if no event pending or being dispatched has it
+func (evtmgr *EventManager) LookupExternalID(extID string) (int, bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.external == nil {
+		return 0, false
+	}
+	eventID, found := evtmgr.external.byExt[extID]
+	return eventID, found
+}
+
+// assign records the external identifier of an event
+func (ext *externalIDs) assign(eventID int, extID string) {
+	ext.byEvent[eventID] = extID
+	ext.byExt[extID] = eventID
+}
+
+// retire forgets the external identifier of an event
+func (ext *externalIDs) retire(eventID int) {
+	if extID, found := ext.byEvent[eventID]; found {
+		delete(ext.byEvent, eventID)
+		delete(ext.byExt, extID)
+	}
+}
+
+// dispatching notes the event taken from the event list for dispatch, whose identifier is
+// kept while it runs, and forgets that of the event before it.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) dispatching(eventID int) {
+	if ext := evtmgr.external; ext != nil {
+		ext.retire(ext.current)
+		ext.current = eventID
+	}
+}
@@ -0,0 +1,53 @@
+package evtm
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestExternalIDs checks that every event scheduled gets a UUID that maps back to it while
+// it is pending or being dispatched, and is forgotten once it is removed or has run
+func TestExternalIDs(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetExternalIDs(NewUUID)
+	var during string
+	var duringFound bool
+	handler := func(evtmgr *EventManager, context any, data any) any {
+		during, duringFound = evtmgr.ExternalID(evtmgr.CurrentEventID())
+		return nil
+	}
+	keptID, _ := evtmgr.Schedule(nil, nil, handler, vrtime.CreateTime(1, 0))
+	removedID, _ := evtmgr.Schedule(nil, nil, handler, vrtime.CreateTime(2, 0))
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	kept, found := evtmgr.ExternalID(keptID)
+	if !found || !uuid.MatchString(kept) {
+		t.Fatalf("event given external identifier %q, want a UUID", kept)
+	}
+	if eventID, found := evtmgr.LookupExternalID(kept); !found || eventID != keptID {
+		t.Errorf("identifier maps back to event %d, want %d", eventID, keptID)
+	}
+	removed, _ := evtmgr.ExternalID(removedID)
+	if removed == kept {
+		t.Error("two events share an external identifier")
+	}
+	evtmgr.RemoveEvent(removedID)
+	if _, found := evtmgr.LookupExternalID(removed); found {
+		t.Error("identifier of a removed event still maps")
+	}
+	if err := evtmgr.AssignExternalID(removedID, "other"); err == nil {
+		t.Error("identifier assigned to an event no longer pending")
+	}
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(5, 0))
+	if !duringFound || during != kept {
+		t.Errorf("handler saw identifier %q, want %q", during, kept)
+	}
+	evtmgr.Schedule(nil, nil, handler, vrtime.CreateTime(1, 0))
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	if _, found := evtmgr.LookupExternalID(kept); found {
+		t.Error("identifier of an event long dispatched still maps")
+	}
+}
@@ -0,0 +1,92 @@
+package evtm
+
+import (
+	"github.com/iti/evt/vrtime"
+)
+
+// FilterVerdict is the decision of an EventFilter about an event that has come due.
+type FilterVerdict int
+
+const (
+	// FilterDispatch lets the event be dispatched.
+	FilterDispatch FilterVerdict = iota
+
+	// FilterDrop discards the event without calling its handler.
+	FilterDrop
+
+	// FilterDefer returns the event to the event list, in its lane, to come due again the
+	// offset returned with the verdict after the current time.  An offset of less than a tick
+	// is taken as one tick, as the event would otherwise come due again at once, and forever.
+	FilterDefer
+)
+
+// EventFilter is consulted just before an event is dispatched.  It returns a verdict on the
+// event, and with FilterDefer the offset by which to defer it.  A filter applies a policy
+// centrally, e.g., dropping all traffic for a node undergoing a simulated blackout, rather
+// than it being guarded for inside every handler.  It is called by the thread running the
+// EventManager, without the EventManager's lock held, and should not modify the event;
+// that is the business of an EventInterceptor.
+type EventFilter func(evtmgr *EventManager, event *Event) (FilterVerdict, vrtime.Time)
+
+// filterEntry wraps a registered EventFilter, giving it an identity for removal
+type filterEntry struct {
+	filter EventFilter
+}
+
+// AddFilter registers a filter to be consulted before each event is dispatched.  Filters are
+// consulted in the order they were added, and the first verdict other than FilterDispatch
+// decides the fate of the event.  A deferred event is given a new event identifier, and
+// keeps its priority.  Events dropped or deferred are not counted by EventsDispatched.
+// Events scheduled with ScheduleAfterEvent to follow a dropped event are removed, and those
+// following a deferred one wait for it to be dispatched at its new time.
+// AddFilter returns a function that removes the filter.
+func (evtmgr *EventManager) AddFilter(filter EventFilter) (remove func()) {
+	return register(&evtmgr.mu, &evtmgr.filters, &filterEntry{filter: filter})
+}
+
+// admit consults the registered filters about an event that has come due, carrying out a
+// verdict to drop or defer it, and returns true if the event should be dispatched.
+func (evtmgr *EventManager) admit(event *Event) bool {
+	filters := evtmgr.filters.Load()
+	if filters == nil {
+		return true
+	}
+	for _, entry := range *filters {
+		verdict, offset := entry.filter(evtmgr, event)
+		if verdict == FilterDispatch {
+			continue
+		}
+
+		evtmgr.mu.Lock()
+		evtmgr.NumEvts -= 1
+		var dropped []int
+		if verdict == FilterDefer {
+			if offset.Ticks() < 1 {
+				offset = vrtime.CreateTime(1, 0)
+			}
+			newTime := evtmgr.Time.Plus(offset)
+			newTime.SetPri(event.Time.Pri())
+			event.Time = newTime
+			oldID := event.EventID
+			event.EventID = evtmgr.EventList.InsertInLane(event, newTime, event.lane)
+
+			// events scheduled to follow the event now follow it at its new time
+			if deps, present := evtmgr.after[oldID]; present {
+				delete(evtmgr.after, oldID)
+				evtmgr.after[event.EventID] = deps
+			}
+		} else {
+			dropped = evtmgr.dropAfter(event.EventID, nil)
+		}
+		if evtmgr.tracing(TraceEvents) {
+			evtmgr.tracef("filter %s event at %f\n", verdictName[verdict], event.Time.Seconds())
+		}
+		evtmgr.mu.Unlock()
+		evtmgr.routeDropped(dropped)
+		return false
+	}
+	return true
+}
+
+// verdictName describes each FilterVerdict, for trace statements
+var verdictName = map[FilterVerdict]string{FilterDispatch: "dispatches", FilterDrop: "drops", FilterDefer: "defers"}
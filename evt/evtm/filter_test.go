@@ -0,0 +1,96 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestFilterDeferKeepsLane checks that a deferred event returns to its lane, and so keeps
+// the place the lane order gives it among the events of its new tick
+func TestFilterDeferKeepsLane(t *testing.T) {
+	evtmgr := New()
+	if err := evtmgr.SetLaneOrder(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, data.(string))
+		return nil
+	}
+
+	// the lane 0 event has the lesser priority, so only its lane puts the deferred one first
+	evtmgr.Schedule(nil, "lane0", record, vrtime.CreateTime(10, 0))
+	deferredID, _ := evtmgr.ScheduleInLane(1, nil, "lane1", record, vrtime.CreateTime(5, 0))
+	evtmgr.AddFilter(func(evtmgr *EventManager, event *Event) (FilterVerdict, vrtime.Time) {
+		if event.EventID == deferredID {
+			return FilterDefer, vrtime.CreateTime(5, 0)
+		}
+		return FilterDispatch, vrtime.Time{}
+	})
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(order) != 2 || order[0] != "lane1" {
+		t.Errorf("events dispatched in the order %v, want the deferred lane 1 event first", order)
+	}
+}
+
+// TestFilterDeferZeroOffset checks that an event deferred by no time comes due a tick later,
+// rather than at once and forever
+func TestFilterDeferZeroOffset(t *testing.T) {
+	evtmgr := New()
+	var at []int64
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		at = append(at, evtmgr.CurrentTicks())
+		return nil
+	}, vrtime.CreateTime(0, 0))
+	deferrals := 0
+	evtmgr.AddFilter(func(*EventManager, *Event) (FilterVerdict, vrtime.Time) {
+		if deferrals < 3 {
+			deferrals += 1
+			return FilterDefer, vrtime.CreateTime(0, 0)
+		}
+		return FilterDispatch, vrtime.Time{}
+	})
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(at) != 1 || at[0] != 3 {
+		t.Errorf("event deferred three times by no time dispatched at %v, want at 3", at)
+	}
+}
+
+// TestFilterDependents checks that the events following a dropped event are removed with it,
+// and that those following a deferred event are placed relative to the time it is dispatched
+func TestFilterDependents(t *testing.T) {
+	evtmgr := New()
+	at := make(map[string]int64)
+	record := func(evtmgr *EventManager, context any, data any) any {
+		at[data.(string)] = evtmgr.CurrentTicks()
+		return nil
+	}
+	droppedID, _ := evtmgr.Schedule(nil, "dropped", record, vrtime.CreateTime(10, 0))
+	evtmgr.ScheduleAfterEvent(droppedID, nil, "after dropped", record, vrtime.CreateTime(5, 0))
+	deferredID, _ := evtmgr.Schedule(nil, "deferred", record, vrtime.CreateTime(20, 0))
+	evtmgr.ScheduleAfterEvent(deferredID, nil, "after deferred", record, vrtime.CreateTime(5, 0))
+	evtmgr.AddFilter(func(evtmgr *EventManager, event *Event) (FilterVerdict, vrtime.Time) {
+		switch event.EventID {
+		case droppedID:
+			return FilterDrop, vrtime.Time{}
+		case deferredID:
+			return FilterDefer, vrtime.CreateTime(30, 0)
+		}
+		return FilterDispatch, vrtime.Time{}
+	})
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if _, ran := at["after dropped"]; ran {
+		t.Errorf("event following a dropped one dispatched at %d", at["after dropped"])
+	}
+	if at["deferred"] != 50 || at["after deferred"] != 55 {
+		t.Errorf("deferred event dispatched at %d and its follower at %d, want 50 and 55",
+			at["deferred"], at["after deferred"])
+	}
+	if pending := evtmgr.EventList.Len(); pending != 0 {
+		t.Errorf("%d events pending, want none", pending)
+	}
+}
@@ -0,0 +1,95 @@
+package evtm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Future is the eventual outcome of an event scheduled by ScheduleWithResult: the value its
+// handler returns once it executes, or its cancellation.
+type Future struct {
+	eventID int
+	done    chan struct{} // closed once the outcome is known
+	result  Result
+	then    []func(Result)
+	mu      sync.Mutex
+}
+
+// ScheduleWithResult schedules an event as Schedule does, returning a Future of the value its
+// handler returns.  A goroutine other than the one running the EventManager, e.g., emulation
+// code coupled with a simulated computation, may block on the Future with Await or wait on
+// Done; any code may attach a completion callback with Then.
+func (evtmgr *EventManager) ScheduleWithResult(context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) *Future {
+
+	ft := &Future{done: make(chan struct{})}
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.eventID, _ = evtmgr.scheduleInLane(0, context, data, handler, offset, ft.settle)
+	return ft
+}
+
+// settle records the outcome of the event and calls the callbacks attached with Then
+func (ft *Future) settle(res Result) {
+	ft.mu.Lock()
+	ft.result = res
+	then := ft.then
+	ft.then = nil
+	close(ft.done)
+	ft.mu.Unlock()
+
+	for _, fn := range then {
+		fn(res)
+	}
+}
+
+// EventID returns the eventId of the event
+func (ft *Future) EventID() int {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return ft.eventID
+}
+
+// Done returns a channel closed once the event has executed or been cancelled
+func (ft *Future) Done() <-chan struct{} {
+	return ft.done
+}
+
+// Await blocks until the event has executed, returning the value its handler returned, or an
+// error if the event was cancelled or removed rather than executed.  It blocks for as long as
+// the event stays pending, so a handler of the EventManager must never call it.
+func (ft *Future) Await() (any, error) {
+	<-ft.done
+	if ft.result.Cancelled {
+		return nil, fmt.Errorf("event %d was cancelled before it executed", ft.result.EventID)
+	}
+	return ft.result.Value, nil
+}
+
+// Result returns the outcome of the event without blocking, and false if it is not yet known
+func (ft *Future) Result() (Result, bool) {
+	select {
+	case <-ft.done:
+		return ft.result, true
+	default:
+		return Result{}, false
+	}
+}
+
+// Then attaches fn to be called with the outcome of the event: by the thread running the
+// EventManager as the event completes, without the EventManager's lock held, or at once by
+// the caller if the outcome is already known.  Callbacks are called in the order attached.
+func (ft *Future) Then(fn func(Result)) {
+	ft.mu.Lock()
+	select {
+	case <-ft.done:
+		ft.mu.Unlock()
+		fn(ft.result)
+		return
+	default:
+	}
+	ft.then = append(ft.then, fn)
+	ft.mu.Unlock()
+}
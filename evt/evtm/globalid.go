@@ -0,0 +1,62 @@
+package evtm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An eventId identifies an event on the EventManager that scheduled it, and on no other:
+// every event list numbers its events from 1, unless it stamps them with a generation (see
+// [evtq.EventQueue.SetGenerationStamp]), and a clone (see Clone) goes on numbering its
+// events as the original does.  A GlobalID adds to the eventId the identifier of its
+// EventManager, assigned with SetManagerID uniquely across a federation, clones included,
+// so that traces, mailboxes, and messages can name an event wherever it was scheduled.
+
+// GlobalID identifies an event across every EventManager of a federation
+type GlobalID struct {
+	Manager uint32 // identifier of the EventManager, see SetManagerID
+	Event   int    // eventId of the event on that EventManager
+}
+
+// String returns the GlobalID as "manager/event", the form ParseGlobalID reads
+func (gid GlobalID) String() string {
+	return fmt.Sprintf("%d/%d", gid.Manager, gid.Event)
+}
+
+// ParseGlobalID reads a GlobalID in the form written by String
+func ParseGlobalID(text string) (GlobalID, error) {
+	mgrText, evtText, found := strings.Cut(text, "/")
+	if !found {
+		return GlobalID{}, fmt.Errorf("global event id %q is not of the form manager/event", text)
+	}
+	mgr, err := strconv.ParseUint(mgrText, 10, 32)
+	if err != nil {
+		return GlobalID{}, fmt.Errorf("global event id %q: %w", text, err)
+	}
+	event, err := strconv.Atoi(evtText)
+	if err != nil {
+		return GlobalID{}, fmt.Errorf("global event id %q: %w", text, err)
+	}
+	return GlobalID{Manager: uint32(mgr), Event: event}, nil
+}
+
+// SetManagerID sets the identifier of the EventManager within its federation, which every
+// GlobalID of its events carries.  The identifier is 0, unassigned, until it is set.
+func (evtmgr *EventManager) SetManagerID(id uint32) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.managerID = id
+}
+
+// ManagerID returns the identifier of the EventManager set by SetManagerID
+func (evtmgr *EventManager) ManagerID() uint32 {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.managerID
+}
+
+// GlobalID returns the GlobalID of the event with the eventId given
+func (evtmgr *EventManager) GlobalID(eventID int) GlobalID {
+	return GlobalID{Manager: evtmgr.ManagerID(), Event: eventID}
+}
@@ -0,0 +1,33 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestGlobalIDOfClone checks that a clone does not take the manager identifier of its
+// original, whose eventIds it shares, and that a GlobalID survives a round trip as text
+func TestGlobalIDOfClone(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetManagerID(7)
+	eventID, _ := evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any { return nil }, vrtime.CreateTime(5, 0))
+
+	clone := evtmgr.Clone(nil)
+	if clone.ManagerID() != 0 {
+		t.Fatalf("clone took the manager identifier %d of its original", clone.ManagerID())
+	}
+	clone.SetManagerID(8)
+	if evtmgr.GlobalID(eventID) == clone.GlobalID(eventID) {
+		t.Errorf("original and clone name event %d alike", eventID)
+	}
+
+	gid := evtmgr.GlobalID(eventID)
+	parsed, err := ParseGlobalID(gid.String())
+	if err != nil || parsed != gid {
+		t.Errorf("%v read back as %v, %v", gid, parsed, err)
+	}
+	if _, err := ParseGlobalID("7-1"); err == nil {
+		t.Error("malformed global id accepted")
+	}
+}
@@ -0,0 +1,42 @@
+package evtm
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Filters and interceptors are kept in lists that are replaced, never modified, once
+// published, so that the thread running the EventManager reads them with a single atomic load.
+
+// register appends entry to the list published through list, and returns a function that
+// removes it again.  Updates to the list are serialized by mu.
+func register[T any](mu *sync.Mutex, list *atomic.Pointer[[]*T], entry *T) (remove func()) {
+	mu.Lock()
+	entries := []*T{}
+	if current := list.Load(); current != nil {
+		entries = append(entries, *current...)
+	}
+	entries = append(entries, entry)
+	list.Store(&entries)
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		current := list.Load()
+		if current == nil {
+			return
+		}
+		entries := []*T{}
+		for _, other := range *current {
+			if other != entry {
+				entries = append(entries, other)
+			}
+		}
+		if len(entries) == 0 {
+			list.Store(nil)
+		} else {
+			list.Store(&entries)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package evtm
+
+// EventInterceptor is called just before an event is dispatched, after any filters have
+// admitted it, and may rewrite the event: replace its Data or Context, or substitute its
+// EventHandler, e.g., with one that wraps the original.  This supports fault injection,
+// the migration of data formats during long runs, and transparent monitoring.  It is
+// called by the thread running the EventManager, without the EventManager's lock held.
+// Under slab allocation the event is recycled once ReleaseSlabs is called (see SetSlabSize).
+type EventInterceptor func(evtmgr *EventManager, event *Event)
+
+// interceptorEntry wraps a registered EventInterceptor, giving it an identity for removal
+type interceptorEntry struct {
+	intercept EventInterceptor
+}
+
+// AddInterceptor registers an interceptor to be applied to each event before it is
+// dispatched.  Interceptors are applied in the order they were added, each seeing the event
+// as rewritten by those before it.  AddInterceptor returns a function that removes the interceptor.
+func (evtmgr *EventManager) AddInterceptor(intercept EventInterceptor) (remove func()) {
+	return register(&evtmgr.mu, &evtmgr.interceptors, &interceptorEntry{intercept: intercept})
+}
+
+// rewrite applies the registered interceptors to an event about to be dispatched
+func (evtmgr *EventManager) rewrite(event *Event) {
+	interceptors := evtmgr.interceptors.Load()
+	if interceptors == nil {
+		return
+	}
+	for _, entry := range *interceptors {
+		entry.intercept(evtmgr, event)
+	}
+}
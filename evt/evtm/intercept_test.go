@@ -0,0 +1,35 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestInterceptor checks that interceptors rewrite an event's Data and handler in the order
+// they were added, and that a removed one no longer applies
+func TestInterceptor(t *testing.T) {
+	evtmgr := New()
+	var got []any
+	record := func(evtmgr *EventManager, context any, data any) any { got = append(got, data); return nil }
+	evtmgr.Schedule(nil, 1, record, vrtime.CreateTime(1, 0))
+	evtmgr.Schedule(nil, 2, record, vrtime.CreateTime(2, 0))
+	evtmgr.Schedule(nil, 3, record, vrtime.CreateTime(3, 0))
+
+	removeDouble := evtmgr.AddInterceptor(func(evtmgr *EventManager, event *Event) {
+		event.Data = 2 * event.Data.(int)
+	})
+	evtmgr.AddInterceptor(func(evtmgr *EventManager, event *Event) {
+		original := event.EventHandler
+		event.EventHandler = func(evtmgr *EventManager, context any, data any) any {
+			return original(evtmgr, context, data.(int)+100)
+		}
+	})
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(2, 0))
+	removeDouble()
+	evtmgr.AdvanceTo(vrtime.CreateTime(3, 0))
+	if len(got) != 3 || got[0] != 102 || got[1] != 104 || got[2] != 103 {
+		t.Errorf("handlers saw %v, want [102 104 103]", got)
+	}
+}
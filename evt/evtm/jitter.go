@@ -0,0 +1,134 @@
+package evtm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// In wallclock mode each event is intended to be dispatched at a particular real time: the
+// real time of the anchor of the run, advanced by the virtual time between the two and by any
+// time held (see BeginHold).  The difference between the real time at which
+// it is actually dispatched and that intended is its pacing error, positive when it is late.
+// The errors are summarized to show how faithfully the run tracked real time.
+
+// jitterBuckets is the number of buckets of a JitterHistogram.  Bucket 0 counts events
+// dispatched early or less than a microsecond late, bucket k (k>0) those between 2^(k-1) and
+// 2^k microseconds late, and the last bucket everything later.
+const jitterBuckets = 24
+
+// JitterHistogram counts pacing errors by bucket (see JitterBucketBound)
+type JitterHistogram [jitterBuckets]int64
+
+// JitterBucketBound returns the upper bound of the errors counted in bucket k of a JitterHistogram
+func JitterBucketBound(k int) time.Duration {
+	if k >= jitterBuckets-1 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(1<<k) * time.Microsecond
+}
+
+// JitterStats summarizes the pacing errors of events dispatched in wallclock mode
+type JitterStats struct {
+	Count     int64           // number of events paced
+	Mean      time.Duration   // mean error
+	StdDev    time.Duration   // standard deviation of the error
+	Min       time.Duration   // least error, negative if an event was early
+	Max       time.Duration   // greatest error
+	Histogram JitterHistogram // errors by bucket
+}
+
+// Percentile returns an upper bound on the error of the fraction p (between 0 and 1) of
+// events with the smallest errors, as resolved by the histogram
+func (js JitterStats) Percentile(p float64) time.Duration {
+	if js.Count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(p * float64(js.Count)))
+	var seen int64
+	for k, count := range js.Histogram {
+		seen += count
+		if seen >= target {
+			if bound := JitterBucketBound(k); bound < js.Max {
+				return bound
+			}
+			return js.Max
+		}
+	}
+	return js.Max
+}
+
+// String summarizes the statistics on one line
+func (js JitterStats) String() string {
+	return fmt.Sprintf("%d events paced: mean %v sd %v min %v max %v p50 %v p99 %v",
+		js.Count, js.Mean, js.StdDev, js.Min, js.Max, js.Percentile(0.5), js.Percentile(0.99))
+}
+
+// Table describes the non-empty buckets of the histogram, one per line
+func (js JitterStats) Table() string {
+	var sb strings.Builder
+	for k, count := range js.Histogram {
+		if count == 0 {
+			continue
+		}
+		bound := "inf"
+		if k < jitterBuckets-1 {
+			bound = JitterBucketBound(k).String()
+		}
+		fmt.Fprintf(&sb, "  <= %-10s %10d\n", bound, count)
+	}
+	return sb.String()
+}
+
+// jitter accumulates pacing errors
+type jitter struct {
+	due   time.Time // real time the next event is intended to be dispatched, zero when not paced
+	count int64
+	mean  float64 // running mean of the error, in nanoseconds
+	m2    float64 // running sum of squared deviations from the mean, in nanoseconds squared
+	min   time.Duration
+	max   time.Duration
+	hist  JitterHistogram
+}
+
+// record folds the pacing error of one event into the statistics
+func (jt *jitter) record(err time.Duration) {
+	if jt.count == 0 || err < jt.min {
+		jt.min = err
+	}
+	if jt.count == 0 || err > jt.max {
+		jt.max = err
+	}
+	jt.count += 1
+	delta := float64(err) - jt.mean
+	jt.mean += delta / float64(jt.count)
+	jt.m2 += delta * (float64(err) - jt.mean)
+
+	k := 0
+	for k < jitterBuckets-1 && err > JitterBucketBound(k) {
+		k += 1
+	}
+	jt.hist[k] += 1
+}
+
+// JitterStats returns the statistics of the pacing errors of events dispatched in wallclock
+// mode since the EventManager was created or ResetJitter was last called
+func (evtmgr *EventManager) JitterStats() JitterStats {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	jt := &evtmgr.jitter
+	js := JitterStats{Count: jt.count, Min: jt.min, Max: jt.max, Histogram: jt.hist,
+		Mean: time.Duration(math.Round(jt.mean))}
+	if jt.count > 1 {
+		js.StdDev = time.Duration(math.Round(math.Sqrt(jt.m2 / float64(jt.count-1))))
+	}
+	return js
+}
+
+// ResetJitter discards the pacing errors recorded so far
+func (evtmgr *EventManager) ResetJitter() {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.jitter = jitter{}
+}
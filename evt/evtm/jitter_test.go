@@ -0,0 +1,41 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestJitterStats checks the summary of known pacing errors, and that a wallclock run
+// records an error for each event it dispatches
+func TestJitterStats(t *testing.T) {
+	var jt jitter
+	for _, err := range []time.Duration{-time.Microsecond, 3 * time.Microsecond, 3 * time.Microsecond, 100 * time.Microsecond} {
+		jt.record(err)
+	}
+	evtmgr := New()
+	evtmgr.jitter = jt
+	js := evtmgr.JitterStats()
+	if js.Count != 4 || js.Mean != 26250*time.Nanosecond || js.Min != -time.Microsecond || js.Max != 100*time.Microsecond {
+		t.Errorf("stats %s, want 4 errors of mean 26.25µs from -1µs to 100µs", js)
+	}
+	// -1µs lies in bucket 0, 3µs in bucket 2 (2-4µs), 100µs in bucket 7 (64-128µs)
+	if js.Histogram[0] != 1 || js.Histogram[2] != 2 || js.Histogram[7] != 1 {
+		t.Errorf("histogram\n%s", js.Table())
+	}
+	if p50, p99 := js.Percentile(0.5), js.Percentile(0.99); p50 != 4*time.Microsecond || p99 != 100*time.Microsecond {
+		t.Errorf("p50 %v p99 %v, want 4µs and 100µs", p50, p99)
+	}
+
+	evtmgr.ResetJitter()
+	evtmgr.SetWallclock(true)
+	noop := func(*EventManager, any, any) any { return nil }
+	for i := 1; i <= 5; i++ {
+		evtmgr.Schedule(nil, nil, noop, vrtime.SecondsToTime(0.001*float64(i)))
+	}
+	evtmgr.Run(1)
+	if js := evtmgr.JitterStats(); js.Count != 5 {
+		t.Errorf("wallclock run paced %s, want 5 events", js)
+	}
+}
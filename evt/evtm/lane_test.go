@@ -0,0 +1,44 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestScheduleInLane checks that the lanes of the event list are drained in the order set at
+// a tick, and that a lane the event list does not have is refused without side effects
+func TestScheduleInLane(t *testing.T) {
+	evtmgr := New()
+	if err := evtmgr.SetLaneOrder(2, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	var order []int
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, data.(int))
+		return nil
+	}
+	observed := 0
+	evtmgr.AddScheduleObserver(func(evtmgr *EventManager, op ScheduleOp, event Event) {
+		if op == OpSchedule {
+			observed += 1
+		}
+	})
+
+	for _, lane := range []int{3, -1} {
+		if eventID, _ := evtmgr.ScheduleInLane(lane, nil, lane, record, vrtime.CreateTime(5, 0)); eventID != 0 {
+			t.Errorf("lane %d accepted as event %d", lane, eventID)
+		}
+	}
+	if observed != 0 || evtmgr.EventList.Len() != 0 {
+		t.Fatalf("refused lanes left %d events pending and %d observed", evtmgr.EventList.Len(), observed)
+	}
+
+	for _, lane := range []int{0, 1, 2} {
+		evtmgr.ScheduleInLane(lane, nil, lane, record, vrtime.CreateTime(5, 0))
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(5, 0))
+	if len(order) != 3 || order[0] != 2 || order[1] != 0 || order[2] != 1 {
+		t.Errorf("lanes drained in the order %v, want [2 0 1]", order)
+	}
+}
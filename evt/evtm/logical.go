@@ -0,0 +1,142 @@
+package evtm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The virtual timestamps of events exchanged between EventManagers say when events take
+// effect, not which could have influenced which, and a bug in synchronization shows itself
+// precisely as an event acting before something that caused it.  Logical clocks, switched on
+// by SetLogicalClocks, record causality independently of virtual time.  Every event
+// dispatched advances the Lamport clock and this EventManager's entry in the vector clock of
+// its EventManager.  An event sent by one EventManager to another carries the clocks of the
+// sender at the time it was sent, and its dispatch merges them into the receiver's, so that
+// the clocks of two events show whether one happened before the other or they are
+// concurrent.  The entries of vector clocks are keyed by manager identifier, so every
+// EventManager of the run must be given a distinct one with SetManagerID.
+
+// LogicalClock holds the logical clocks of an event
+type LogicalClock struct {
+	Lamport uint64            // Lamport clock
+	Vector  map[uint32]uint64 // vector clock, by manager identifier
+}
+
+// Copy returns a copy of the clock that shares nothing with it
+func (lc LogicalClock) Copy() LogicalClock {
+	copied := LogicalClock{Lamport: lc.Lamport, Vector: make(map[uint32]uint64, len(lc.Vector))}
+	for mgr, count := range lc.Vector {
+		copied.Vector[mgr] = count
+	}
+	return copied
+}
+
+// HappensBefore reports whether the event stamped lc happened before the event stamped
+// other, by their vector clocks
+func (lc LogicalClock) HappensBefore(other LogicalClock) bool {
+	strictly := false
+	for mgr, count := range lc.Vector {
+		if count > other.Vector[mgr] {
+			return false
+		}
+		if count < other.Vector[mgr] {
+			strictly = true
+		}
+	}
+	for mgr, count := range other.Vector {
+		if _, present := lc.Vector[mgr]; !present && count > 0 {
+			strictly = true
+		}
+	}
+	return strictly
+}
+
+// Concurrent reports whether neither of the events stamped lc and other happened before the
+// other
+func (lc LogicalClock) Concurrent(other LogicalClock) bool {
+	return !lc.HappensBefore(other) && !other.HappensBefore(lc)
+}
+
+// String describes the clock as "L<lamport> [mgr:count ...]"
+func (lc LogicalClock) String() string {
+	mgrs := make([]uint32, 0, len(lc.Vector))
+	for mgr := range lc.Vector {
+		mgrs = append(mgrs, mgr)
+	}
+	sort.Slice(mgrs, func(i, j int) bool { return mgrs[i] < mgrs[j] })
+	entries := make([]string, len(mgrs))
+	for idx, mgr := range mgrs {
+		entries[idx] = fmt.Sprintf("%d:%d", mgr, lc.Vector[mgr])
+	}
+	return fmt.Sprintf("L%d [%s]", lc.Lamport, strings.Join(entries, " "))
+}
+
+// SetLogicalClocks switches the logical clocks of the EventManager on or off.  Switching them
+// on starts them from zero.
+func (evtmgr *EventManager) SetLogicalClocks(on bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.logical = nil
+	if on {
+		evtmgr.logical = &LogicalClock{Vector: make(map[uint32]uint64)}
+	}
+}
+
+// LogicalClock returns a copy of the logical clocks of the EventManager, which while a
+// handler runs are those of the event being dispatched, and false if they are off
+func (evtmgr *EventManager) LogicalClock() (LogicalClock, bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.logical == nil {
+		return LogicalClock{}, false
+	}
+	return evtmgr.logical.Copy(), true
+}
+
+// StampEvent gives the pending event with the eventId given the clocks of the sender of the
+// message it delivers, taken from the sender with LogicalClock, for events that carry messages
+// between EventManagers by a route other than ScheduleOn or ScheduleRemote.  It returns false
+// if there is no such event.
+func (evtmgr *EventManager) StampEvent(eventID int, sent LogicalClock) bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	item := evtmgr.EventList.GetValue(eventID)
+	if item == nil {
+		return false
+	}
+	stamp := sent.Copy()
+	item.(*Event).Clock = &stamp
+	return true
+}
+
+// sendStamp returns the clocks to send with an event for another EventManager, nil if they
+// are off
+func (evtmgr *EventManager) sendStamp() *LogicalClock {
+	stamp, on := evtmgr.LogicalClock()
+	if !on {
+		return nil
+	}
+	return &stamp
+}
+
+// tickLogical advances the logical clocks for the dispatch of an event, merging the clocks
+// the event carries from its sender.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) tickLogical(event *Event) {
+	lc := evtmgr.logical
+	if lc == nil {
+		return
+	}
+	if sent := event.Clock; sent != nil {
+		if sent.Lamport > lc.Lamport {
+			lc.Lamport = sent.Lamport
+		}
+		for mgr, count := range sent.Vector {
+			if count > lc.Vector[mgr] {
+				lc.Vector[mgr] = count
+			}
+		}
+	}
+	lc.Lamport += 1
+	lc.Vector[evtmgr.managerID] += 1
+}
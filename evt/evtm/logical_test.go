@@ -0,0 +1,56 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestLogicalClocks checks that an event sent to another EventManager happens before its
+// dispatch there, while events the two dispatch independently are concurrent
+func TestLogicalClocks(t *testing.T) {
+	sender, receiver := New(), New()
+	sender.SetManagerID(1)
+	receiver.SetManagerID(2)
+	sender.SetLogicalClocks(true)
+	receiver.SetLogicalClocks(true)
+	clocks := make(map[string]LogicalClock)
+	stamp := func(name string) func(*EventManager, any, any) any {
+		return func(evtmgr *EventManager, context any, data any) any {
+			clocks[name], _ = evtmgr.LogicalClock()
+			return nil
+		}
+	}
+	sender.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		clocks["send"], _ = evtmgr.LogicalClock()
+		if _, _, err := evtmgr.ScheduleRemote(receiver, nil, nil, stamp("receive"), vrtime.CreateTime(5, 0)); err != nil {
+			t.Error(err)
+		}
+		return nil
+	}, vrtime.CreateTime(10, 0))
+	receiver.Schedule(nil, nil, stamp("local"), vrtime.CreateTime(1, 0))
+
+	sender.AdvanceTo(vrtime.CreateTime(10, 0))
+	receiver.AdvanceTo(vrtime.CreateTime(20, 0))
+	if len(clocks) != 3 {
+		t.Fatalf("got clocks %v, want three events stamped", clocks)
+	}
+	send, receive, local := clocks["send"], clocks["receive"], clocks["local"]
+	if !send.HappensBefore(receive) || receive.HappensBefore(send) {
+		t.Errorf("send %s does not happen before receive %s", send, receive)
+	}
+	if !send.Concurrent(local) || !local.HappensBefore(receive) {
+		t.Errorf("local %s, want concurrent with send %s and before receive %s", local, send, receive)
+	}
+	if receive.Lamport <= send.Lamport {
+		t.Errorf("got Lamport clock %d at receive, want more than %d at send", receive.Lamport, send.Lamport)
+	}
+	if got := receive.String(); got != "L2 [1:1 2:2]" {
+		t.Errorf("got %s, want L2 [1:1 2:2]", got)
+	}
+
+	receiver.SetLogicalClocks(false)
+	if _, on := receiver.LogicalClock(); on {
+		t.Error("logical clocks reported on after being switched off")
+	}
+}
@@ -0,0 +1,101 @@
+package evtm
+
+import (
+	"fmt"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Tooling that cuts across a model, such as tracing, auditing, and correlation with external
+// systems, needs somewhere to keep its data on each event without disturbing the Context and
+// Data the model's handlers agree on.  An Event's Meta is that place.  It is nil, costing
+// nothing, unless SetMetadata switches it on, in which case each event scheduled is given
+// Metadata recording when and by which handler it was scheduled, and inheriting the TraceID
+// and Tags of the event whose handler scheduled it, so that correlation data set on one
+// event follows the chain of events it causes.  For debugging, SetCaptureSites adds the
+// source position of the call that scheduled each event, at the cost of walking the stack
+// on every call.
+
+// Metadata is information about an event kept for tooling
+type Metadata struct {
+	Created vrtime.Time       // virtual time at which the event was scheduled
+	Creator string            // name of the handler that scheduled the event, empty if it was scheduled outside a handler
+	Site    string            // file:line of the call that scheduled the event, if captured (see SetCaptureSites)
+	TraceID string            // identifier of a trace, e.g., of a distributed tracing system, the event belongs to
+	Tags    map[string]string // further data by key, created by the first call to Tag
+}
+
+// Tag sets the value of a tag
+func (md *Metadata) Tag(key, value string) {
+	if md.Tags == nil {
+		md.Tags = make(map[string]string)
+	}
+	md.Tags[key] = value
+}
+
+// SetMetadata switches the metadata of events scheduled from now on on or off
+func (evtmgr *EventManager) SetMetadata(on bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.metadata = on
+}
+
+// SetCaptureSites switches on or off the capture of the file and line of each call that
+// schedules an event, in the Site of its metadata.  Events are given metadata while sites are
+// captured, whether or not SetMetadata has switched it on.
+func (evtmgr *EventManager) SetCaptureSites(on bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.sites = on
+}
+
+// CurrentMetadata returns the metadata of the event being dispatched, or nil if it has none.
+// A handler may set the TraceID and Tags of the metadata returned, for the events it goes
+// on to schedule to inherit.
+func (evtmgr *EventManager) CurrentMetadata() *Metadata {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if evtmgr.current == nil {
+		return nil
+	}
+	return evtmgr.current.Meta
+}
+
+// SetEventMetadata replaces the metadata of the pending event with the eventId given, and
+// returns false if there is no such event
+func (evtmgr *EventManager) SetEventMetadata(eventID int, md *Metadata) bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	item := evtmgr.EventList.GetValue(eventID)
+	if item == nil {
+		return false
+	}
+	item.(*Event).Meta = md
+	return true
+}
+
+// metadataFor returns the metadata of an event being scheduled, or nil if metadata is off.
+// Called with evtmgr.mu held.
+func (evtmgr *EventManager) metadataFor() *Metadata {
+	if !evtmgr.metadata && !evtmgr.sites {
+		return nil
+	}
+	md := &Metadata{Created: evtmgr.Time}
+	if evtmgr.sites {
+		_, file, line := callSite()
+		md.Site = fmt.Sprintf("%s:%d", file, line)
+	}
+	if cur := evtmgr.current; cur != nil {
+		md.Creator = HandlerName(cur.EventHandler)
+		if parent := cur.Meta; parent != nil {
+			md.TraceID = parent.TraceID
+			if len(parent.Tags) > 0 {
+				md.Tags = make(map[string]string, len(parent.Tags))
+				for key, value := range parent.Tags {
+					md.Tags[key] = value
+				}
+			}
+		}
+	}
+	return md
+}
@@ -0,0 +1,59 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+func metaChild(evtmgr *EventManager, context any, data any) any {
+	return nil
+}
+
+func metaParent(evtmgr *EventManager, context any, data any) any {
+	md := evtmgr.CurrentMetadata()
+	md.TraceID = "trace-1"
+	md.Tag("tenant", "a")
+	eventID, _ := evtmgr.Schedule(nil, nil, metaChild, vrtime.CreateTime(3, 0))
+	*(context.(*int)) = eventID
+	md.Tag("tenant", "b")
+	return nil
+}
+
+// TestMetadata checks that events carry no metadata until it is switched on, and that an
+// event scheduled by a handler then records when and by whom, inheriting a copy of the
+// TraceID and Tags of its creator
+func TestMetadata(t *testing.T) {
+	evtmgr := New()
+	offID, _ := evtmgr.Schedule(nil, nil, metaChild, vrtime.CreateTime(1, 0))
+	if evtmgr.EventList.GetValue(offID).(*Event).Meta != nil {
+		t.Error("event given metadata while it is off")
+	}
+
+	evtmgr.SetMetadata(true)
+	var childID int
+	rootID, _ := evtmgr.Schedule(&childID, nil, metaParent, vrtime.CreateTime(2, 0))
+	if md := evtmgr.EventList.GetValue(rootID).(*Event).Meta; md == nil || md.Creator != "" {
+		t.Fatalf("event scheduled outside a handler given metadata %+v, want no creator", md)
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(2, 0))
+
+	md := evtmgr.EventList.GetValue(childID).(*Event).Meta
+	if md == nil {
+		t.Fatal("event scheduled by a handler has no metadata")
+	}
+	if md.Created.Ticks() != 2 || md.Creator != HandlerName(metaParent) || md.Site != "" {
+		t.Errorf("got created %d by %q at %q, want created 2 by %q without a site",
+			md.Created.Ticks(), md.Creator, md.Site, HandlerName(metaParent))
+	}
+	if md.TraceID != "trace-1" || md.Tags["tenant"] != "a" {
+		t.Errorf("got trace %q and tags %v, want trace-1 and the tag as it was when scheduled", md.TraceID, md.Tags)
+	}
+	if !evtmgr.SetEventMetadata(childID, &Metadata{TraceID: "other"}) ||
+		evtmgr.EventList.GetValue(childID).(*Event).Meta.TraceID != "other" {
+		t.Error("metadata of a pending event not replaced")
+	}
+	if evtmgr.SetEventMetadata(rootID, &Metadata{}) {
+		t.Error("SetEventMetadata accepted an event already dispatched")
+	}
+}
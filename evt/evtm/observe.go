@@ -0,0 +1,63 @@
+package evtm
+
+// ScheduleOp identifies the change to the pending events reported to a ScheduleObserver
+type ScheduleOp int
+
+const (
+	// OpSchedule reports an event scheduled by one of the Schedule methods
+	OpSchedule ScheduleOp = iota
+
+	// OpCancel reports a pending event cancelled by CancelEvent
+	OpCancel
+
+	// OpRemove reports a pending event removed by RemoveEvent
+	OpRemove
+)
+
+// String names the operation
+func (op ScheduleOp) String() string {
+	switch op {
+	case OpSchedule:
+		return "schedule"
+	case OpCancel:
+		return "cancel"
+	case OpRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// ScheduleObserver is told of each event scheduled, cancelled, or removed through the
+// EventManager's methods, so that test code and monitors can see what a model asks of the
+// event list without wrapping every call.  It is called by the goroutine that made the
+// call, after the change has been made and without the EventManager's lock held.  The
+// event is a copy; for an event scheduled with ScheduleAfterEvent its Time is InfinityTime.
+type ScheduleObserver func(evtmgr *EventManager, op ScheduleOp, event Event)
+
+// observerEntry wraps a registered ScheduleObserver, giving it an identity for removal
+type observerEntry struct {
+	observe ScheduleObserver
+}
+
+// AddScheduleObserver registers an observer of the scheduling, cancellation, and removal of
+// events.  Observers are called in the order they were added.  AddScheduleObserver returns a
+// function that removes the observer.
+func (evtmgr *EventManager) AddScheduleObserver(observe ScheduleObserver) (remove func()) {
+	return register(&evtmgr.mu, &evtmgr.observers, &observerEntry{observe: observe})
+}
+
+// observing returns true if any ScheduleObserver is registered
+func (evtmgr *EventManager) observing() bool {
+	return evtmgr.observers.Load() != nil
+}
+
+// observe reports a change to the registered observers
+func (evtmgr *EventManager) observe(op ScheduleOp, event Event) {
+	observers := evtmgr.observers.Load()
+	if observers == nil {
+		return
+	}
+	for _, entry := range *observers {
+		entry.observe(evtmgr, op, event)
+	}
+}
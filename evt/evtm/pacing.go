@@ -0,0 +1,110 @@
+package evtm
+
+import (
+	"runtime"
+	"time"
+)
+
+// Sleeping on a timer wakes up late by an amount that varies from tens of microseconds to a
+// millisecond or more, depending on the system and its load, which ruins the alignment of
+// virtual with real time below a millisecond.  With a precision target set, the EventManager
+// sleeps only for the coarse part of a wait and spins through the final stretch, whose length
+// it adapts to the lateness of its own sleeps.
+
+// initialSpin is the final stretch of a wait spun through before any sleep has been measured
+const initialSpin = time.Millisecond
+
+// pacer holds the state of precise pacing
+type pacer struct {
+	precision time.Duration // target precision, zero to sleep through every wait
+	lateness  time.Duration // smoothed lateness of coarse sleeps
+	measured  bool          // true once lateness holds a measurement
+}
+
+// SetPacingPrecision selects how closely, in wallclock mode, the dispatch of each event is
+// aligned with the real time it is due.  With a precision of zero (the default) the
+// EventManager simply sleeps until the event is due.  A positive precision makes it spin
+// through the end of each wait, trading CPU time for alignment to within about that precision.
+func (evtmgr *EventManager) SetPacingPrecision(precision time.Duration) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if precision < 0 {
+		precision = 0
+	}
+	evtmgr.pacing.precision = precision
+}
+
+// spinWindow returns the final stretch of a wait to spin through.  Called with evtmgr.mu held.
+func (pc *pacer) spinWindow() time.Duration {
+	if !pc.measured {
+		return initialSpin
+	}
+	window := 2 * pc.lateness
+	if window < pc.precision {
+		window = pc.precision
+	}
+	return window
+}
+
+// record folds the lateness of a coarse sleep into the smoothed measurement.  Called with evtmgr.mu held.
+func (pc *pacer) record(late time.Duration) {
+	if late < 0 {
+		late = 0
+	}
+	if !pc.measured {
+		pc.lateness, pc.measured = late, true
+		return
+	}
+	pc.lateness += (late - pc.lateness) / 8
+}
+
+// wait blocks the thread running the EventManager for the duration d, in the way selected
+// by SetPacingPrecision.  It returns false if the wait was interrupted.
+func (evtmgr *EventManager) wait(d time.Duration) bool {
+	evtmgr.mu.Lock()
+	precise := evtmgr.pacing.precision > 0
+	window := evtmgr.pacing.spinWindow()
+	evtmgr.mu.Unlock()
+
+	if !precise {
+		return evtmgr.sleep(d)
+	}
+
+	deadline := time.Now().Add(d)
+	if coarse := d - window; coarse > 0 {
+		if !evtmgr.sleep(coarse) {
+			return false
+		}
+		late := time.Since(deadline.Add(-window))
+		evtmgr.mu.Lock()
+		evtmgr.pacing.record(late)
+		evtmgr.mu.Unlock()
+	}
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+	return true
+}
+
+// sleep blocks the thread running the EventManager for the duration d, returning false if
+// it is woken sooner by interrupt
+func (evtmgr *EventManager) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-evtmgr.wake:
+		return false
+	}
+}
+
+// interrupt cuts short the sleep of the thread running the EventManager in wallclock mode,
+// so that it looks again at whether it is to go on, as when it is stopped or paused.  An
+// interruption made while it is not sleeping cuts short its next sleep.
+func (evtmgr *EventManager) interrupt() {
+	select {
+	case evtmgr.wake <- struct{}{}:
+	default:
+	}
+}
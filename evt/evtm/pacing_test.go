@@ -0,0 +1,67 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestPacingPrecision checks that with a precision target no event is dispatched before the
+// real time it is due, and that the lateness of the coarse sleeps is measured
+func TestPacingPrecision(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetWallclock(true)
+	evtmgr.SetPacingPrecision(50 * time.Microsecond)
+	start := time.Now()
+	var early []time.Duration
+	check := func(evtmgr *EventManager, context any, data any) any {
+		due := time.Duration(evtmgr.CurrentTime().Seconds() * float64(time.Second))
+		if ahead := due - time.Since(start); ahead > 0 {
+			early = append(early, ahead)
+		}
+		return nil
+	}
+	for i := 1; i <= 10; i++ {
+		evtmgr.Schedule(nil, nil, check, vrtime.SecondsToTime(0.002*float64(i)))
+	}
+	evtmgr.Run(1)
+
+	if len(early) > 0 {
+		t.Errorf("events dispatched early by %v", early)
+	}
+	if !evtmgr.pacing.measured {
+		t.Error("no coarse sleep measured over 2ms waits")
+	}
+}
+
+// TestStopInterruptsSleep checks that Stop ends a wallclock run at once rather than after the
+// sleep until the next event, for each way of pacing
+func TestStopInterruptsSleep(t *testing.T) {
+	for _, precision := range []time.Duration{0, 50 * time.Microsecond} {
+		evtmgr := New()
+		evtmgr.SetWallclock(true)
+		evtmgr.SetPacingPrecision(precision)
+		evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any { return nil }, vrtime.SecondsToTime(10))
+		done := make(chan struct{})
+		go func() {
+			evtmgr.Run(20)
+			close(done)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		start := time.Now()
+		evtmgr.Stop()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("precision %v: run still sleeping a second after Stop", precision)
+		}
+		if n := evtmgr.EventsDispatched(); n != 0 {
+			t.Errorf("precision %v: %d events dispatched after Stop, want 0", precision, n)
+		}
+		if waited := time.Since(start); waited > 100*time.Millisecond {
+			t.Errorf("precision %v: run ended %v after Stop", precision, waited)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package evtm
+
+import (
+	"time"
+)
+
+// Pause asks the EventManager to stop dispatching events, without ending its run, so that
+// an interactive tool can inspect or change the model.  It may be called from an event
+// handler or from another goroutine, and returns at once; the event being dispatched, if
+// any, runs to completion, and the thread running the EventManager then waits, keeping the
+// event list and the clock as they are, until Resume or Stop is called.  Pausing an
+// EventManager that is not running makes its next run begin paused.  In wallclock mode the
+// real time spent paused is not counted against the pacing of the next event.
+func (evtmgr *EventManager) Pause() {
+	evtmgr.mu.Lock()
+	evtmgr.paused = true
+	evtmgr.mu.Unlock()
+	evtmgr.interrupt()
+}
+
+// Resume lets a paused EventManager carry on dispatching events from where it stopped
+func (evtmgr *EventManager) Resume() {
+	evtmgr.mu.Lock()
+	evtmgr.paused = false
+	evtmgr.mu.Unlock()
+	evtmgr.release()
+}
+
+// IsPaused returns true if Pause has been called and Resume has not
+func (evtmgr *EventManager) IsPaused() bool {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.paused
+}
+
+// park blocks the thread running the EventManager while it is paused.  Called with
+// evtmgr.mu held, which is released while waiting.
+func (evtmgr *EventManager) park() {
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Pausing evtmgr at %f\n", evtmgr.Time.Seconds())
+	}
+	start := time.Now()
+	for evtmgr.paused && evtmgr.RunFlag {
+		evtmgr.suspended = true
+		evtmgr.quieten()
+		evtmgr.mu.Unlock()
+		_ = <-evtmgr.suspChan
+		evtmgr.mu.Lock()
+	}
+	paused := time.Since(start)
+	evtmgr.held += paused
+	evtmgr.anchor.wall = evtmgr.anchor.wall.Add(paused)
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Resuming evtmgr at %f\n", evtmgr.Time.Seconds())
+	}
+}
@@ -0,0 +1,59 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestPause checks that a handler calling Pause holds the run after its event until Resume,
+// and that an EventManager paused before its run begins paused and can still be stopped
+func TestPause(t *testing.T) {
+	evtmgr := New()
+	dispatched := make(chan int64, 2)
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		evtmgr.Pause()
+		dispatched <- evtmgr.CurrentTicks()
+		return nil
+	}, vrtime.CreateTime(10, 0))
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		dispatched <- evtmgr.CurrentTicks()
+		return nil
+	}, vrtime.CreateTime(20, 0))
+	done := make(chan struct{})
+	go func() {
+		evtmgr.Run(1)
+		close(done)
+	}()
+
+	<-dispatched
+	select {
+	case ticks := <-dispatched:
+		t.Fatalf("event at %d dispatched while paused", ticks)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if !evtmgr.IsPaused() || evtmgr.CurrentTicks() != 10 {
+		t.Errorf("paused %v with the clock at %d, want true at 10", evtmgr.IsPaused(), evtmgr.CurrentTicks())
+	}
+	evtmgr.Resume()
+	if ticks := <-dispatched; ticks != 20 {
+		t.Errorf("event dispatched at %d after Resume, want 20", ticks)
+	}
+	<-done
+
+	idle := New()
+	idle.Schedule(nil, nil, func(*EventManager, any, any) any { return nil }, vrtime.CreateTime(1, 0))
+	idle.Pause()
+	done = make(chan struct{})
+	go func() {
+		idle.Run(1)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	idle.Stop()
+	<-done
+	if n := idle.EventsDispatched(); n != 0 {
+		t.Errorf("run begun paused dispatched %d events, want 0", n)
+	}
+}
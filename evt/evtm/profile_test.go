@@ -0,0 +1,35 @@
+package evtm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// profiledHandler is an event handler with a name of its own, for HandlerName to find
+func profiledHandler(evtmgr *EventManager, context any, data any) any {
+	return data.(int) + 1
+}
+
+// TestProfileLabels checks that handlers are dispatched as usual with profiling labels on,
+// and that HandlerName names them
+func TestProfileLabels(t *testing.T) {
+	if name := HandlerName(profiledHandler); name != "github.com/iti/evt/evtm.profiledHandler" {
+		t.Errorf("HandlerName gave %q", name)
+	}
+	if name := HandlerName(func(*EventManager, any, any) any { return nil }); !strings.Contains(name, "TestProfileLabels.func") {
+		t.Errorf("HandlerName of a closure gave %q", name)
+	}
+	if name := HandlerName(nil); name != "<nil>" {
+		t.Errorf("HandlerName of nil gave %q", name)
+	}
+
+	evtmgr := New()
+	evtmgr.SetProfileLabels(true)
+	fut := evtmgr.ScheduleWithResult(nil, 41, profiledHandler, vrtime.CreateTime(5, 0))
+	evtmgr.Run(1)
+	if value, err := fut.Await(); value != 42 || err != nil {
+		t.Errorf("labelled handler returned %v, %v, want 42", value, err)
+	}
+}
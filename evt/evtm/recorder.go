@@ -0,0 +1,154 @@
+package evtm
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// ScheduleRecord describes one scheduling call captured by a Recorder
+type ScheduleRecord struct {
+	Op       ScheduleOp  // what was done
+	EventID  int         // identifier of the event concerned
+	Handler  string      // name of the event's handler
+	Caller   string      // name of the function that made the call, e.g., the handler of the event being dispatched
+	File     string      // source file of the call
+	Line     int         // source line of the call
+	At       vrtime.Time // virtual time at which the call was made
+	Time     vrtime.Time // time at which the event is to execute
+	Priority int64       // priority of the event
+	Summary  string      // summary of the event's data
+}
+
+// Offset returns how far after the call the event is to execute, in ticks
+func (rec ScheduleRecord) Offset() int64 {
+	return rec.Time.Ticks() - rec.At.Ticks()
+}
+
+// OffsetSeconds returns how far after the call the event is to execute, in seconds
+func (rec ScheduleRecord) OffsetSeconds() float64 {
+	return vrtime.TicksToSeconds(rec.Offset())
+}
+
+// String describes the record
+func (rec ScheduleRecord) String() string {
+	return fmt.Sprintf("%s event %d handler %s by %s (%s:%d) at %s offset %gs pri %d data %s",
+		rec.Op, rec.EventID, rec.Handler, rec.Caller, rec.File, rec.Line, rec.At.TimeStr(),
+		rec.OffsetSeconds(), rec.Priority, rec.Summary)
+}
+
+// summaryLength bounds the length of the default summary of an event's data
+const summaryLength = 80
+
+// SummarizeData returns the default summary of an event's data: its type and its
+// printed form, truncated
+func SummarizeData(data any) string {
+	if data == nil {
+		return "<nil>"
+	}
+	text := fmt.Sprintf("%T %+v", data, data)
+	if len(text) > summaryLength {
+		text = text[:summaryLength] + "..."
+	}
+	return text
+}
+
+// Recorder captures the scheduling calls made on an EventManager, with the site of each
+// call, so that a test can assert directly, e.g., that a handler scheduled a retransmission
+// 200ms out.  Capturing the call site walks the stack, so a Recorder is meant for tests
+// and debugging rather than production runs.
+type Recorder struct {
+	// Summarize produces the summary of an event's data.  It is SummarizeData unless replaced.
+	Summarize func(data any) string
+
+	records []ScheduleRecord
+	remove  func()
+	mu      sync.Mutex
+}
+
+// NewRecorder starts capturing the scheduling calls made on the EventManager
+func (evtmgr *EventManager) NewRecorder() *Recorder {
+	rec := &Recorder{Summarize: SummarizeData}
+	rec.remove = evtmgr.AddScheduleObserver(rec.record)
+	return rec
+}
+
+// Stop ends the capture.  The records already captured remain available.
+func (rec *Recorder) Stop() {
+	rec.mu.Lock()
+	remove := rec.remove
+	rec.remove = nil
+	rec.mu.Unlock()
+	if remove != nil {
+		remove()
+	}
+}
+
+// record is the ScheduleObserver through which the Recorder captures calls
+func (rec *Recorder) record(evtmgr *EventManager, op ScheduleOp, event Event) {
+	entry := ScheduleRecord{Op: op, EventID: event.EventID, Handler: HandlerName(event.EventHandler),
+		At: evtmgr.CurrentTime(), Time: event.Time, Priority: event.Time.Pri()}
+	entry.Caller, entry.File, entry.Line = callSite()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	entry.Summary = rec.Summarize(event.Data)
+	rec.records = append(rec.records, entry)
+}
+
+// evtmPrefix is the prefix of the names of the functions of this package
+const evtmPrefix = "github.com/iti/evt/evtm."
+
+// callSite finds the first frame on the stack outside this package, which is the site of
+// the scheduling call being observed
+func callSite() (caller string, file string, line int) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, evtmPrefix) {
+			return frame.Function, frame.File, frame.Line
+		}
+		if !more {
+			return "<unknown>", "", 0
+		}
+	}
+}
+
+// Records returns the records captured, in the order the calls were made
+func (rec *Recorder) Records() []ScheduleRecord {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]ScheduleRecord(nil), rec.records...)
+}
+
+// Where returns the records satisfying match, in the order the calls were made
+func (rec *Recorder) Where(match func(ScheduleRecord) bool) []ScheduleRecord {
+	var found []ScheduleRecord
+	for _, entry := range rec.Records() {
+		if match(entry) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// ScheduledBy returns the records of events that the function caller scheduled with the
+// given handler.  caller is typically the handler of the event that was being dispatched.
+func (rec *Recorder) ScheduledBy(caller, handler EventHandlerFunction) []ScheduleRecord {
+	callerName, handlerName := HandlerName(caller), HandlerName(handler)
+	return rec.Where(func(entry ScheduleRecord) bool {
+		return entry.Op == OpSchedule && entry.Caller == callerName && entry.Handler == handlerName
+	})
+}
+
+// Reset discards the records captured so far
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.records = nil
+}
@@ -0,0 +1,45 @@
+package evtm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// The Recorder takes the first caller outside package evtm for the call site, so its test
+// lies outside the package.
+
+func retransmit(*evtm.EventManager, any, any) any { return nil }
+
+func sendFrame(mgr *evtm.EventManager, context any, data any) any {
+	mgr.Schedule(context, data, retransmit, vrtime.SecondsToTime(0.2))
+	return nil
+}
+
+// TestRecorder checks that a Recorder captures the handler that made a scheduling call,
+// where it was made, and the offset and data of the event, until it is stopped
+func TestRecorder(t *testing.T) {
+	mgr := evtm.New()
+	rec := mgr.NewRecorder()
+	mgr.Schedule(nil, "frame", sendFrame, vrtime.SecondsToTime(1))
+	mgr.Run(10)
+	rec.Stop()
+	mgr.Schedule(nil, "late", sendFrame, vrtime.SecondsToTime(1))
+
+	if all := rec.Records(); len(all) != 2 {
+		t.Fatalf("captured %v, want the two calls made before Stop", all)
+	}
+	found := rec.ScheduledBy(sendFrame, retransmit)
+	if len(found) != 1 {
+		t.Fatalf("captured %v, want one retransmission scheduled by sendFrame", rec.Records())
+	}
+	entry := found[0]
+	if !strings.HasSuffix(entry.File, "recorder_test.go") || entry.Line == 0 {
+		t.Errorf("call site %s:%d, want a line of recorder_test.go", entry.File, entry.Line)
+	}
+	if entry.OffsetSeconds() != 0.2 || entry.At.Seconds() != 1 || entry.Summary != "string frame" {
+		t.Errorf("record %s, want data frame 0.2s out at 1s", entry)
+	}
+}
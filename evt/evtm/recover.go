@@ -0,0 +1,80 @@
+package evtm
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// RecoveryPolicy selects what the EventManager does when an event handler panics
+type RecoveryPolicy int32
+
+const (
+	// RecoverNone lets the panic unwind the dispatch loop and the goroutine running it, as
+	// though the EventManager were not there.  It is the default.
+	RecoverNone RecoveryPolicy = iota
+
+	// RecoverContinue logs the panic, as a *PanicError, to the destination of trace
+	// statements (see SetTraceLogger), and carries on with the next event.
+	RecoverContinue
+
+	// RecoverStop aborts the run, as Abort does, with a *PanicError as the cause, which
+	// holds a copy of the failing event.
+	RecoverStop
+
+	// RecoverRepanic panics again with a *PanicError, so that the panic identifies the
+	// event whose handler raised it.
+	RecoverRepanic
+)
+
+// PanicError describes a panic raised by an event handler and recovered by the EventManager
+type PanicError struct {
+	Value   any         // the value passed to panic
+	EventID int         // identifier of the event being dispatched
+	Time    vrtime.Time // virtual time of the event
+	Handler string      // name of the handler, see HandlerName
+	Event   Event       // copy of the event
+	Stack   []byte      // stack of the goroutine at the panic
+}
+
+// Error describes the panic
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("evtm: handler %s of event %d at %g panicked: %v",
+		pe.Handler, pe.EventID, pe.Time.Seconds(), pe.Value)
+}
+
+// Unwrap returns the value passed to panic, if it is an error
+func (pe *PanicError) Unwrap() error {
+	if err, isErr := pe.Value.(error); isErr {
+		return err
+	}
+	return nil
+}
+
+// SetRecovery selects what the EventManager does when an event handler panics
+func (evtmgr *EventManager) SetRecovery(policy RecoveryPolicy) {
+	evtmgr.recovery.Store(int32(policy))
+}
+
+// dispatchRecovering calls the handler of an event, applying the recovery policy to any panic
+func (evtmgr *EventManager) dispatchRecovering(event *Event, policy RecoveryPolicy) (rtn any) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+		pe := &PanicError{Value: value, EventID: event.EventID, Time: event.Time,
+			Handler: HandlerName(event.EventHandler), Event: *event, Stack: debug.Stack()}
+		switch policy {
+		case RecoverContinue:
+			evtmgr.tracef("%v\n%s", pe, pe.Stack)
+		case RecoverStop:
+			evtmgr.Abort(pe)
+		default:
+			panic(pe)
+		}
+		rtn = nil
+	}()
+	return evtmgr.invoke(event)
+}
@@ -0,0 +1,56 @@
+package evtm
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRecovery checks each recovery policy on a handler that panics: RecoverContinue logs
+// the panic and dispatches the next event, RecoverStop aborts the run with a PanicError, and
+// RecoverRepanic panics again with one
+func TestRecovery(t *testing.T) {
+	fault := errors.New("fault")
+	setup := func(policy RecoveryPolicy) (*EventManager, int, *bool) {
+		evtmgr := New()
+		evtmgr.SetRecovery(policy)
+		failID, _ := evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any { panic(fault) },
+			vrtime.CreateTime(10, 0))
+		later := new(bool)
+		evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any { *later = true; return nil },
+			vrtime.CreateTime(20, 0))
+		return evtmgr, failID, later
+	}
+
+	evtmgr, failID, later := setup(RecoverContinue)
+	var logged bytes.Buffer
+	evtmgr.SetTraceLogger(log.New(&logged, "", 0))
+	if err := evtmgr.RunE(1); err != nil || !*later {
+		t.Errorf("RecoverContinue: run returned %v, later event dispatched %v; want nil, true", err, *later)
+	}
+	if !strings.Contains(logged.String(), "panicked: fault") {
+		t.Errorf("RecoverContinue logged %q, want the panic", logged.String())
+	}
+
+	evtmgr, failID, later = setup(RecoverStop)
+	err := evtmgr.RunE(1)
+	var pe *PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, fault) || pe.EventID != failID || pe.Time.Ticks() != 10 || *later {
+		t.Errorf("RecoverStop: run returned %v, later event dispatched %v; want a PanicError for event %d",
+			err, *later, failID)
+	}
+
+	evtmgr, failID, _ = setup(RecoverRepanic)
+	defer func() {
+		pe, isPE := recover().(*PanicError)
+		if !isPE || pe.EventID != failID || pe.Value != fault {
+			t.Errorf("RecoverRepanic: panicked with %v, want a PanicError for event %d", pe, failID)
+		}
+	}()
+	evtmgr.Run(1)
+	t.Error("RecoverRepanic: run returned")
+}
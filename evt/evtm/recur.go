@@ -0,0 +1,135 @@
+package evtm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Recurrence is a rule for a recurring schedule expressed in calendar terms,
+// e.g., "every 15 minutes" or "daily at second 300 of the day".  Occurrences fall at
+// Offset past the start of each cycle of length Period.  When the EventManager has an
+// epoch (see SetEpoch) cycles are aligned to midnight of the epoch's day, in the epoch's
+// location, so that "every 15m" fires on the quarter hour and "daily at 300s" five minutes
+// past midnight.  Without an epoch cycles are aligned to virtual time zero.
+type Recurrence struct {
+	Period time.Duration // length of a cycle
+	Offset time.Duration // position of the occurrence within each cycle
+}
+
+// ParseRecurrence converts a recurrence expression into a Recurrence.  The forms accepted are
+//
+//	every <duration> [at <duration>]
+//	hourly [at <duration>]
+//	daily [at <duration>]
+//
+// where each <duration> is in the format accepted by [time.ParseDuration], e.g., "15m" or "300s".
+func ParseRecurrence(expr string) (Recurrence, error) {
+	var rule Recurrence
+	words := strings.Fields(strings.ToLower(expr))
+	if len(words) == 0 {
+		return rule, fmt.Errorf("empty recurrence expression")
+	}
+
+	var err error
+	switch words[0] {
+	case "every":
+		if len(words) < 2 {
+			return rule, fmt.Errorf("recurrence %q has no period", expr)
+		}
+		if rule.Period, err = time.ParseDuration(words[1]); err != nil {
+			return rule, fmt.Errorf("recurrence %q: %w", expr, err)
+		}
+		words = words[2:]
+	case "hourly":
+		rule.Period = time.Hour
+		words = words[1:]
+	case "daily":
+		rule.Period = 24 * time.Hour
+		words = words[1:]
+	default:
+		return rule, fmt.Errorf("recurrence %q does not start with every, hourly, or daily", expr)
+	}
+
+	if len(words) > 0 {
+		if len(words) != 2 || words[0] != "at" {
+			return rule, fmt.Errorf("recurrence %q has unexpected text %q", expr, strings.Join(words, " "))
+		}
+		if rule.Offset, err = time.ParseDuration(words[1]); err != nil {
+			return rule, fmt.Errorf("recurrence %q: %w", expr, err)
+		}
+	}
+	return rule, rule.validate()
+}
+
+// validate checks that the rule describes a schedule that can be followed
+func (rule Recurrence) validate() error {
+	if vrtime.SecondsToTicks(rule.Period.Seconds()) <= 0 {
+		return fmt.Errorf("recurrence period %v is shorter than a tick", rule.Period)
+	}
+	if rule.Offset < 0 || rule.Offset >= rule.Period {
+		return fmt.Errorf("recurrence offset %v is not within the period %v", rule.Offset, rule.Period)
+	}
+	return nil
+}
+
+// SetEpoch establishes the calendar instant that virtual time zero represents.
+func (evtmgr *EventManager) SetEpoch(epoch time.Time) {
+	evtmgr.mu.Lock()
+	evtmgr.epoch = epoch
+	evtmgr.epochSet = true
+	evtmgr.mu.Unlock()
+}
+
+// Epoch returns the calendar instant that virtual time zero represents,
+// and false if no epoch has been set.
+func (evtmgr *EventManager) Epoch() (time.Time, bool) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.epoch, evtmgr.epochSet
+}
+
+// CalendarTime returns the calendar instant the current virtual time represents.
+// The zero time.Time is returned if no epoch has been set.
+func (evtmgr *EventManager) CalendarTime() time.Time {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	if !evtmgr.epochSet {
+		return time.Time{}
+	}
+	return evtmgr.epoch.Add(time.Duration(math.Round(evtmgr.Time.Seconds() * 1e9)))
+}
+
+// ScheduleRecurring creates a series of events following the recurrence rule, calling
+// handler with context and data at each occurrence.  The first occurrence is the earliest one
+// at or after the current time.  The series is otherwise managed as one created by
+// SchedulePeriodic, and opts has the same meaning there.
+func (evtmgr *EventManager) ScheduleRecurring(context any, data any,
+	handler func(*EventManager, any, any) any, rule Recurrence, opts RepeatOptions) (*Repeating, error) {
+
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	periodTicks := vrtime.SecondsToTicks(rule.Period.Seconds())
+
+	// the virtual time, in ticks, at which the first cycle starts
+	var cycleTicks int64
+	if epoch, present := evtmgr.Epoch(); present {
+		y, m, d := epoch.Date()
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, epoch.Location())
+		cycleTicks = -vrtime.SecondsToTicks(epoch.Sub(midnight).Seconds())
+	}
+	firstTicks := cycleTicks + vrtime.SecondsToTicks(rule.Offset.Seconds())
+
+	// advance to the first occurrence not in the past
+	now := evtmgr.CurrentTicks()
+	if firstTicks < now {
+		firstTicks += ((now - firstTicks + periodTicks - 1) / periodTicks) * periodTicks
+	}
+
+	interval := vrtime.CreateTime(periodTicks, 0)
+	return evtmgr.startRepeating(context, data, handler, interval, opts, firstTicks), nil
+}
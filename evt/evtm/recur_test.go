@@ -0,0 +1,58 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseRecurrence checks the forms of recurrence expression accepted, and some refused
+func TestParseRecurrence(t *testing.T) {
+	for expr, want := range map[string]Recurrence{
+		"every 15m":        {Period: 15 * time.Minute},
+		"Every 90s at 30s": {Period: 90 * time.Second, Offset: 30 * time.Second},
+		"hourly at 10m":    {Period: time.Hour, Offset: 10 * time.Minute},
+		"daily at 300s":    {Period: 24 * time.Hour, Offset: 300 * time.Second},
+		"daily":            {Period: 24 * time.Hour},
+	} {
+		rule, err := ParseRecurrence(expr)
+		if err != nil || rule != want {
+			t.Errorf("%q parsed as %v, %v, want %v", expr, rule, err, want)
+		}
+	}
+	for _, expr := range []string{"", "weekly", "every", "every 10m at 20m", "daily at -1s", "hourly 5m", "every 0s"} {
+		if _, err := ParseRecurrence(expr); err == nil {
+			t.Errorf("%q accepted", expr)
+		}
+	}
+}
+
+// TestScheduleRecurringEpoch checks that with an epoch set the occurrences of a recurring
+// schedule fall on the calendar instants the rule describes
+func TestScheduleRecurringEpoch(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetEpoch(time.Date(2024, 3, 1, 10, 7, 0, 0, time.UTC))
+	rule, _ := ParseRecurrence("every 15m")
+	var at []time.Time
+	_, err := evtmgr.ScheduleRecurring(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		at = append(at, evtmgr.CalendarTime())
+		return nil
+	}, rule, RepeatOptions{Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evtmgr.Run(24 * 3600)
+	want := []time.Time{
+		time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 10, 45, 0, 0, time.UTC),
+	}
+	if len(at) != len(want) {
+		t.Fatalf("occurrences at %v, want %v", at, want)
+	}
+	for idx := range want {
+		if !at[idx].Equal(want[idx]) {
+			t.Errorf("occurrence %d at %v, want %v", idx, at[idx], want[idx])
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package evtm
+
+import (
+	"fmt"
+
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// Conservative synchronization of EventManagers that exchange events rests on each one
+// promising a lookahead: a minimum delay between its current time and the time of any event
+// it schedules on another.  A receiver can then safely advance to the sender's time plus its
+// lookahead without fear of an event arriving in its past.  An EventManager declares its
+// lookahead with SetLookahead, and ScheduleRemote refuses an event that breaks the promise.
+
+// LookaheadError reports an event sent by ScheduleRemote sooner than the sender's lookahead allows
+type LookaheadError struct {
+	Offset    vrtime.Time // offset at which the event was to be sent
+	Lookahead vrtime.Time // lookahead declared by the sender
+	Time      vrtime.Time // virtual time of the sender
+}
+
+// Error describes the violation
+func (le *LookaheadError) Error() string {
+	return fmt.Sprintf("remote event at offset %g from %g violates lookahead %g",
+		le.Offset.Seconds(), le.Time.Seconds(), le.Lookahead.Seconds())
+}
+
+// SetLookahead declares the minimum offset of the events this EventManager sends to other
+// EventManagers through ScheduleRemote.  The default is zero, which enforces nothing.
+func (evtmgr *EventManager) SetLookahead(lookahead vrtime.Time) {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	evtmgr.lookahead = vrtime.CreateTime(lookahead.Ticks(), 0)
+}
+
+// Lookahead returns the lookahead declared by SetLookahead
+func (evtmgr *EventManager) Lookahead() vrtime.Time {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	return evtmgr.lookahead
+}
+
+// ScheduleRemote schedules an event on the EventManager dest to execute offset after the
+// current time of this one.  It returns a *LookaheadError, and schedules nothing, if offset is
+// less than the lookahead declared with SetLookahead, and an error if the time of the event
+// has already passed at dest.  Otherwise it returns the eventId of the event at dest and the
+// virtual time at which it executes there.
+func (evtmgr *EventManager) ScheduleRemote(dest *EventManager, context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) (int, vrtime.Time, error) {
+
+	evtmgr.mu.Lock()
+	now := evtmgr.Time
+	lookahead := evtmgr.lookahead
+	evtmgr.mu.Unlock()
+
+	if offset.Ticks() < lookahead.Ticks() {
+		return evtq.InvalidEventID, vrtime.ZeroTime(),
+			&LookaheadError{Offset: offset, Lookahead: lookahead, Time: now}
+	}
+	return evtmgr.scheduleOn(dest, context, data, handler, now, offset)
+}
+
+// ScheduleOn schedules an event on the EventManager target to execute offset after the
+// current time of this one, for EventManagers that exchange events without the discipline of
+// a lookahead.  It is safe whether or not target is running, on this thread or another, and
+// wakes target if it is suspended in External mode waiting for an event.  An error is returned,
+// and nothing scheduled, if the time of the event has already passed at target.  Otherwise
+// ScheduleOn returns the eventId of the event at target and the virtual time at which it
+// executes there.
+func (evtmgr *EventManager) ScheduleOn(target *EventManager, context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) (int, vrtime.Time, error) {
+
+	return evtmgr.scheduleOn(target, context, data, handler, evtmgr.CurrentTime(), offset)
+}
+
+// scheduleOn schedules an event on target at offset from now
+func (evtmgr *EventManager) scheduleOn(target *EventManager, context any, data any,
+	handler func(*EventManager, any, any) any, now, offset vrtime.Time) (int, vrtime.Time, error) {
+
+	at := vrtime.CreateTime(now.Ticks()+offset.Ticks(), offset.Pri())
+	eventID, err := target.scheduleAt(context, data, handler, at, evtmgr.sendStamp())
+	if err != nil {
+		return evtq.InvalidEventID, vrtime.ZeroTime(), err
+	}
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("event %d sent to execute at %f on another EventManager\n", eventID, at.Seconds())
+	}
+	return eventID, at, nil
+}
+
+// scheduleAt schedules an event at the absolute time at, which must not have passed, carrying
+// the logical clocks stamp of its sender, if any
+func (evtmgr *EventManager) scheduleAt(context any, data any,
+	handler func(*EventManager, any, any) any, at vrtime.Time, stamp *LogicalClock) (int, error) {
+
+	evtmgr.mu.Lock()
+	if at.Ticks() < evtmgr.Time.Ticks() {
+		current := evtmgr.Time
+		evtmgr.mu.Unlock()
+		return evtq.InvalidEventID, fmt.Errorf("event at %g is in the past of its target, at %g",
+			at.Seconds(), current.Seconds())
+	}
+	newEvent := evtmgr.placeAt(context, data, handler, at)
+	newEvent.Parent = evtq.InvalidEventID // the cause lies outside this EventManager
+	newEvent.Clock = stamp
+	eventID := newEvent.EventID
+	if evtmgr.tracing(TraceEvents) {
+		evtmgr.tracef("event %d scheduled at %f by another EventManager\n", eventID, at.Seconds())
+	}
+	var observed Event
+	observing := evtmgr.observing()
+	if observing {
+		observed = *newEvent
+	}
+	evtmgr.mu.Unlock()
+	evtmgr.release()
+	if observing {
+		evtmgr.observe(OpSchedule, observed)
+	}
+	return eventID, nil
+}
+
+// placeAt inserts an event at the absolute time at into the event list.  A zero priority is
+// replaced as Schedule replaces it.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) placeAt(context any, data any,
+	handler func(*EventManager, any, any) any, at vrtime.Time) *Event {
+
+	if at.Pri() == int64(0) {
+		at.SetPri(evtmgr.autoPri)
+		evtmgr.autoPri += 1
+	}
+	newEvent := evtmgr.newEvent(context, data, handler, at)
+	newEvent.EventID = evtmgr.EventList.Insert(newEvent, at)
+	return newEvent
+}
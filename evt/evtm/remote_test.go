@@ -0,0 +1,60 @@
+package evtm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestScheduleRemote checks that ScheduleRemote refuses an event sooner than the sender's
+// lookahead, and places one that keeps it at the sender's time plus the offset
+func TestScheduleRemote(t *testing.T) {
+	sender, receiver := New(), New()
+	sender.SetLookahead(vrtime.CreateTime(10, 0))
+	sender.AdvanceTo(vrtime.CreateTime(50, 0))
+	noop := func(*EventManager, any, any) any { return nil }
+
+	eventID, _, err := sender.ScheduleRemote(receiver, nil, nil, noop, vrtime.CreateTime(9, 0))
+	var le *LookaheadError
+	if !errors.As(err, &le) || le.Lookahead.Ticks() != 10 || eventID != evtq.InvalidEventID || receiver.EventList.Len() != 0 {
+		t.Fatalf("offset 9 under lookahead 10 gave event %d and error %v", eventID, err)
+	}
+	eventID, at, err := sender.ScheduleRemote(receiver, nil, nil, noop, vrtime.CreateTime(10, 0))
+	if err != nil || at.Ticks() != 60 || receiver.EventList.Len() != 1 {
+		t.Fatalf("offset 10 under lookahead 10 gave event %d at %d and error %v, want it at 60", eventID, at.Ticks(), err)
+	}
+
+	receiver.AdvanceTo(vrtime.CreateTime(100, 0))
+	if _, _, err := sender.ScheduleRemote(receiver, nil, nil, noop, vrtime.CreateTime(10, 0)); err == nil {
+		t.Error("event in the past of the receiver accepted")
+	}
+}
+
+// TestScheduleOn checks that ScheduleOn from another goroutine wakes a target suspended in
+// External mode, which then dispatches the event at the sender's time plus the offset
+func TestScheduleOn(t *testing.T) {
+	sender, target := New(), New()
+	sender.AdvanceTo(vrtime.CreateTime(20, 0))
+	target.SetExternal(true)
+	dispatched := make(chan int64, 1)
+	done := make(chan struct{})
+	go func() {
+		target.Run(1)
+		close(done)
+	}()
+
+	_, at, err := sender.ScheduleOn(target, nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		dispatched <- evtmgr.CurrentTicks()
+		return nil
+	}, vrtime.CreateTime(5, 0))
+	if err != nil || at.Ticks() != 25 {
+		t.Fatalf("ScheduleOn placed the event at %d with error %v, want 25", at.Ticks(), err)
+	}
+	if ticks := <-dispatched; ticks != 25 {
+		t.Errorf("target dispatched the event at %d, want 25", ticks)
+	}
+	target.Stop()
+	<-done
+}
@@ -0,0 +1,179 @@
+package evtm
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// RepeatOptions modify the series of events created by SchedulePeriodic.
+type RepeatOptions struct {
+	// Count limits the number of times the handler is called; zero means no limit.
+	Count int
+
+	// Jitter is the largest displacement added to an occurrence.  Each occurrence
+	// is moved later than its nominal time by a number of ticks drawn uniformly from
+	// [0, Jitter.Ticks()].  Nominal times stay on the grid defined by the interval,
+	// so jitter does not accumulate from one occurrence to the next.
+	Jitter vrtime.Time
+
+	// Stream is the source of the jitter.  When nil the EventManager's
+	// random number stream named "repeat" is used.
+	Stream *rand.Rand
+
+	// Stop is evaluated before each occurrence is dispatched, with the context and
+	// data of the series.  When it returns true the series ends without calling the handler.
+	Stop func(*EventManager, any, any) bool
+}
+
+// Repeating is a handle on a series of events created by SchedulePeriodic.
+// It may be used to cancel the remainder of the series.
+type Repeating struct {
+	evtmgr   *EventManager
+	context  any
+	data     any
+	handler  EventHandlerFunction
+	interval vrtime.Time
+	opts     RepeatOptions
+	nominal  vrtime.Time // nominal time of the pending occurrence
+	eventID  int         // identifier of the pending occurrence
+	fired    int         // number of times the handler has been called
+	done     bool        // true once the series has ended
+	mu       sync.Mutex
+}
+
+// SchedulePeriodic creates a series of events that call handler with the given
+// context and data every interval of virtual time, starting interval after the current time,
+// until the series is cancelled, the count in opts is reached, or its stop predicate holds.
+// A priority of 0 in interval is replaced, occurrence by occurrence, as it is by Schedule.
+// SchedulePeriodic returns an error, scheduling nothing, if interval is not positive, as the
+// series would otherwise recur forever at the current time, or if the count in opts is negative.
+func (evtmgr *EventManager) SchedulePeriodic(context any, data any,
+	handler func(*EventManager, any, any) any, interval vrtime.Time, opts RepeatOptions) (*Repeating, error) {
+
+	if interval.Ticks() <= 0 {
+		return nil, fmt.Errorf("repeating interval %g must be positive", interval.Seconds())
+	}
+	if opts.Count < 0 {
+		return nil, fmt.Errorf("repeating count %d must not be negative", opts.Count)
+	}
+	return evtmgr.startRepeating(context, data, handler, interval, opts,
+		evtmgr.CurrentTicks()+interval.Ticks()), nil
+}
+
+// ScheduleRepeating is SchedulePeriodic without jitter or a stop predicate: the handler is
+// called every interval, starting interval after the current time, count times, or until the
+// series is cancelled through the handle returned if count is zero.  It returns an error,
+// scheduling nothing, if interval is not positive or count is negative.
+func (evtmgr *EventManager) ScheduleRepeating(context any, data any,
+	handler func(*EventManager, any, any) any, interval vrtime.Time, count int) (*Repeating, error) {
+
+	return evtmgr.SchedulePeriodic(context, data, handler, interval, RepeatOptions{Count: count})
+}
+
+// startRepeating creates a series of events whose first occurrence has the nominal time firstTicks.
+func (evtmgr *EventManager) startRepeating(context any, data any,
+	handler func(*EventManager, any, any) any, interval vrtime.Time, opts RepeatOptions, firstTicks int64) *Repeating {
+
+	rpt := &Repeating{evtmgr: evtmgr, context: context, data: data,
+		handler: handler, interval: interval, opts: opts}
+	if rpt.opts.Stream == nil && rpt.opts.Jitter.Ticks() > 0 {
+		rpt.opts.Stream = evtmgr.RandStream("repeat")
+	}
+
+	rpt.mu.Lock()
+	rpt.nominal = vrtime.CreateTime(firstTicks-interval.Ticks(), 0)
+	rpt.scheduleNext()
+	rpt.mu.Unlock()
+	return rpt
+}
+
+// scheduleNext schedules the occurrence of the series following the one
+// whose nominal time is in rpt.nominal.  Called with rpt.mu held.
+func (rpt *Repeating) scheduleNext() {
+	rpt.nominal = vrtime.CreateTime(rpt.nominal.Ticks()+rpt.interval.Ticks(), 0)
+	offsetTicks := rpt.nominal.Ticks() - rpt.evtmgr.CurrentTicks()
+	if rpt.opts.Jitter.Ticks() > 0 {
+		offsetTicks += rpt.opts.Stream.Int63n(rpt.opts.Jitter.Ticks() + 1)
+	}
+
+	// jitter larger than the interval may have put the last occurrence past this one's nominal time
+	if offsetTicks < 0 {
+		offsetTicks = 0
+	}
+	offset := vrtime.CreateTime(offsetTicks, rpt.interval.Pri())
+	rpt.eventID, _ = rpt.evtmgr.Schedule(rpt.context, rpt.data, rpt.fire, offset)
+}
+
+// fire is the event handler of every occurrence in the series
+func (rpt *Repeating) fire(evtmgr *EventManager, context any, data any) any {
+	rpt.mu.Lock()
+	if rpt.done {
+		rpt.mu.Unlock()
+		return nil
+	}
+	rpt.eventID = evtq.InvalidEventID
+	stop := rpt.opts.Stop
+	rpt.mu.Unlock()
+
+	if stop != nil && stop(evtmgr, context, data) {
+		rpt.mu.Lock()
+		rpt.done = true
+		rpt.mu.Unlock()
+		return nil
+	}
+
+	rtn := rpt.handler(evtmgr, context, data)
+
+	// the handler may have cancelled the series
+	rpt.mu.Lock()
+	defer rpt.mu.Unlock()
+	rpt.fired += 1
+	if rpt.done || (rpt.opts.Count > 0 && rpt.fired >= rpt.opts.Count) {
+		rpt.done = true
+		return rtn
+	}
+	rpt.scheduleNext()
+	return rtn
+}
+
+// Cancel ends the series, removing its pending occurrence from the event list.
+// It returns false if the series had already ended.
+func (rpt *Repeating) Cancel() bool {
+	rpt.mu.Lock()
+	defer rpt.mu.Unlock()
+	if rpt.done {
+		return false
+	}
+	rpt.done = true
+	if rpt.eventID != evtq.InvalidEventID {
+		rpt.evtmgr.RemoveEvent(rpt.eventID)
+		rpt.eventID = evtq.InvalidEventID
+	}
+	return true
+}
+
+// Active returns true if the series has not ended.
+func (rpt *Repeating) Active() bool {
+	rpt.mu.Lock()
+	defer rpt.mu.Unlock()
+	return !rpt.done
+}
+
+// Fired returns the number of times the handler of the series has been called.
+func (rpt *Repeating) Fired() int {
+	rpt.mu.Lock()
+	defer rpt.mu.Unlock()
+	return rpt.fired
+}
+
+// EventID returns the identifier of the pending occurrence of the series,
+// or evtq.InvalidEventID if there is none.
+func (rpt *Repeating) EventID() int {
+	rpt.mu.Lock()
+	defer rpt.mu.Unlock()
+	return rpt.eventID
+}
@@ -0,0 +1,76 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestScheduleRepeatingArguments checks that ScheduleRepeating refuses an interval that is
+// not positive and a negative count, scheduling nothing
+func TestScheduleRepeatingArguments(t *testing.T) {
+	evtmgr := New()
+	noop := func(*EventManager, any, any) any { return nil }
+	for _, bad := range []struct {
+		interval vrtime.Time
+		count    int
+	}{
+		{vrtime.CreateTime(0, 0), 0},
+		{vrtime.CreateTime(-5, 0), 3},
+		{vrtime.CreateTime(10, 0), -1},
+	} {
+		if rpt, err := evtmgr.ScheduleRepeating(nil, nil, noop, bad.interval, bad.count); err == nil || rpt != nil {
+			t.Errorf("interval %d and count %d accepted", bad.interval.Ticks(), bad.count)
+		}
+	}
+	if pending := evtmgr.EventList.Len(); pending != 0 {
+		t.Errorf("%d events scheduled by refused series", pending)
+	}
+
+	fired := 0
+	rpt, err := evtmgr.ScheduleRepeating(nil, nil, func(*EventManager, any, any) any {
+		fired += 1
+		return nil
+	}, vrtime.CreateTime(10, 0), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if fired != 3 || rpt.Active() {
+		t.Errorf("series fired %d times and is active %v, want 3 and false", fired, rpt.Active())
+	}
+}
+
+// TestSchedulePeriodicInterval checks that SchedulePeriodic refuses an interval that is not
+// positive, rather than recurring forever at the current time, and that a stop predicate and
+// jitter keep occurrences on their nominal grid
+func TestSchedulePeriodicInterval(t *testing.T) {
+	evtmgr := New()
+	noop := func(*EventManager, any, any) any { return nil }
+	for _, ticks := range []int64{0, -3} {
+		if rpt, err := evtmgr.SchedulePeriodic(nil, nil, noop, vrtime.CreateTime(ticks, 0), RepeatOptions{}); err == nil || rpt != nil {
+			t.Errorf("interval %d accepted", ticks)
+		}
+	}
+
+	var at []int64
+	_, err := evtmgr.SchedulePeriodic(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		at = append(at, evtmgr.CurrentTicks())
+		return nil
+	}, vrtime.CreateTime(10, 0), RepeatOptions{
+		Jitter: vrtime.CreateTime(3, 0),
+		Stop:   func(evtmgr *EventManager, context any, data any) bool { return evtmgr.CurrentTicks() >= 60 },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(at) != 5 {
+		t.Fatalf("series fired at %v, want 5 times before the stop predicate held", at)
+	}
+	for idx, ticks := range at {
+		if nominal := int64(10 * (idx + 1)); ticks < nominal || ticks > nominal+3 {
+			t.Errorf("occurrence %d at %d, want within jitter of %d", idx, ticks, nominal)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package evtm
+
+import (
+	"sync"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// A handler returns a value, which the EventManager discards unless the code that scheduled
+// the event asked for it: OnResult registers a callback for the outcome of one event, and
+// SetResultChannel sends the outcome of every event dispatched on a channel.
+
+// Result is the outcome of an event.  An event a filter defers, or a disabled class buffers,
+// keeps its callback under its new identifier; one a filter drops, or a class discards, is
+// reported as cancelled.
+type Result struct {
+	EventID   int         // identifier of the event
+	Time      vrtime.Time // time of the event
+	Value     any         // value returned by the event's handler
+	Cancelled bool        // true if the event was cancelled, dropped, or discarded rather than dispatched, or removed
+}
+
+// resultRouter holds the destinations of the results of events
+type resultRouter struct {
+	callbacks map[int]func(Result) // callbacks for the outcomes of single events, by eventId
+	ch        chan<- Result        // destination of every result, nil if none
+	mu        sync.Mutex
+}
+
+// OnResult calls fn with the outcome of the pending event with the eventId given, once it has
+// been dispatched, cancelled, or removed.  fn is called by the thread running the
+// EventManager after the handler returns, or by the one removing the event.  OnResult returns
+// false, registering nothing, if there is no such event.  A later call replaces fn.
+func (evtmgr *EventManager) OnResult(eventID int, fn func(Result)) bool {
+	if evtmgr.EventList.GetValue(eventID) == nil {
+		return false
+	}
+	evtmgr.router().expect(eventID, fn)
+	return true
+}
+
+// SetResultChannel sends the outcome of every event dispatched from now on to ch, nil to stop.
+// Sending blocks while the channel is full, holding up the EventManager.  Cancelled events
+// are not sent.
+func (evtmgr *EventManager) SetResultChannel(ch chan<- Result) {
+	rr := evtmgr.router()
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.ch = ch
+}
+
+// router returns the resultRouter of the EventManager, creating it if need be
+func (evtmgr *EventManager) router() *resultRouter {
+	if rr := evtmgr.results.Load(); rr != nil {
+		return rr
+	}
+	evtmgr.results.CompareAndSwap(nil, &resultRouter{callbacks: make(map[int]func(Result))})
+	return evtmgr.results.Load()
+}
+
+// expect registers fn as the callback for the outcome of the event with eventId
+func (rr *resultRouter) expect(eventID int, fn func(Result)) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.callbacks[eventID] = fn
+}
+
+// route delivers the outcome of an event to those that asked for it
+func (evtmgr *EventManager) route(res Result) {
+	rr := evtmgr.results.Load()
+	if rr == nil {
+		return
+	}
+	rr.mu.Lock()
+	fn := rr.callbacks[res.EventID]
+	delete(rr.callbacks, res.EventID)
+	ch := rr.ch
+	rr.mu.Unlock()
+	if fn != nil {
+		fn(res)
+	}
+	if ch != nil && !res.Cancelled {
+		ch <- res
+	}
+}
+
+// reroute moves the callback for the event with eventId from to the identifier to, the event
+// having been put back on the event list
+func (evtmgr *EventManager) reroute(from, to int) {
+	rr := evtmgr.results.Load()
+	if rr == nil {
+		return
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if fn, found := rr.callbacks[from]; found {
+		delete(rr.callbacks, from)
+		rr.callbacks[to] = fn
+	}
+}
+
+// Call schedules an event as Schedule does and blocks until it has executed, returning the
+// value its handler returns, for a goroutine other than the one running the EventManager,
+// e.g., the emulation thread driving an EventManager in External mode.  It returns an error
+// if the event is cancelled or removed rather than executed, and blocks for as long as the
+// event stays pending, so a handler of the EventManager must never call it.
+func (evtmgr *EventManager) Call(context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) (any, error) {
+
+	return evtmgr.ScheduleWithResult(context, data, handler, offset).Await()
+}
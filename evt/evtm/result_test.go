@@ -0,0 +1,134 @@
+package evtm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestOnResult checks that a callback gets the value the handler returns, and that a
+// channel set with SetResultChannel gets it too
+func TestOnResult(t *testing.T) {
+	evtmgr := New()
+	ch := make(chan Result, 4)
+	evtmgr.SetResultChannel(ch)
+	eventID, _ := evtmgr.Schedule(nil, 21, func(evtmgr *EventManager, context any, data any) any {
+		return 2 * data.(int)
+	}, vrtime.CreateTime(5, 0))
+	var got []Result
+	if !evtmgr.OnResult(eventID, func(res Result) { got = append(got, res) }) {
+		t.Fatal("OnResult refused a pending event")
+	}
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	if len(got) != 1 || got[0].Value != 42 || got[0].Cancelled || got[0].Time.Ticks() != 5 {
+		t.Fatalf("callback got %v, want the value 42 at 5", got)
+	}
+	if len(ch) != 1 || (<-ch).Value != 42 {
+		t.Error("result not sent on the channel")
+	}
+	if evtmgr.OnResult(eventID, func(Result) {}) {
+		t.Error("OnResult accepted an event already dispatched")
+	}
+}
+
+// TestResultOfDroppedDependents checks that the events following a removed or cancelled one,
+// directly or through a chain, have their outcomes reported as cancelled
+func TestResultOfDroppedDependents(t *testing.T) {
+	noop := func(*EventManager, any, any) any { return nil }
+	for _, remove := range []bool{true, false} {
+		evtmgr := New()
+		headID, _ := evtmgr.Schedule(nil, nil, noop, vrtime.CreateTime(10, 0))
+		nextID, _ := evtmgr.ScheduleAfterEvent(headID, nil, nil, noop, vrtime.CreateTime(5, 0))
+		lastID, _ := evtmgr.ScheduleAfterEvent(nextID, nil, nil, noop, vrtime.CreateTime(5, 0))
+		cancelled := make(map[int]bool)
+		for _, eventID := range []int{nextID, lastID} {
+			evtmgr.OnResult(eventID, func(res Result) { cancelled[res.EventID] = res.Cancelled })
+		}
+
+		if remove {
+			evtmgr.RemoveEvent(headID)
+		} else {
+			evtmgr.CancelEvent(headID)
+			evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+		}
+		if len(cancelled) != 2 || !cancelled[nextID] || !cancelled[lastID] {
+			t.Errorf("remove %v: dependents reported %v, want both cancelled", remove, cancelled)
+		}
+		if pending := evtmgr.EventList.Len(); pending != 0 {
+			t.Errorf("remove %v: %d events pending, want the dependents gone", remove, pending)
+		}
+	}
+}
+
+// TestCall checks that Call returns the value of the handler once the event has executed,
+// and an error if the event is removed instead
+func TestCall(t *testing.T) {
+	evtmgr := New()
+	type outcome struct {
+		value any
+		err   error
+	}
+	call := func(data int) chan outcome {
+		done := make(chan outcome, 1)
+		go func() {
+			value, err := evtmgr.Call(nil, data, func(evtmgr *EventManager, context any, data any) any {
+				return 2 * data.(int)
+			}, vrtime.CreateTime(5, 0))
+			done <- outcome{value, err}
+		}()
+		for evtmgr.EventList.Len() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return done
+	}
+
+	done := call(21)
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	if got := <-done; got.err != nil || got.value != 42 {
+		t.Errorf("got %v, %v, want 42", got.value, got.err)
+	}
+
+	done = call(1)
+	evtmgr.RemoveEvent(evtmgr.EventList.Smallest(1)[0].ItemID)
+	if got := <-done; got.err == nil {
+		t.Errorf("got %v for a removed event, want an error", got.value)
+	}
+}
+
+// TestFuture checks that a Future reports nothing until its event executes, then gives the
+// value the handler returned to Await, Result, and callbacks attached before and after, and
+// that the Future of a removed event reports its cancellation
+func TestFuture(t *testing.T) {
+	evtmgr := New()
+	ft := evtmgr.ScheduleWithResult(nil, 21, func(evtmgr *EventManager, context any, data any) any {
+		return 2 * data.(int)
+	}, vrtime.CreateTime(5, 0))
+	if _, known := ft.Result(); known {
+		t.Fatal("outcome known before the event executed")
+	}
+	var calls []any
+	ft.Then(func(res Result) { calls = append(calls, res.Value) })
+	awaited := make(chan any, 1)
+	go func() {
+		value, _ := ft.Await()
+		awaited <- value
+	}()
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	if value := <-awaited; value != 42 {
+		t.Errorf("Await returned %v, want 42", value)
+	}
+	ft.Then(func(res Result) { calls = append(calls, res.Value) })
+	if res, known := ft.Result(); !known || res.Value != 42 || res.EventID != ft.EventID() || len(calls) != 2 {
+		t.Errorf("got result %+v, %v and callbacks given %v, want 42 twice", res, known, calls)
+	}
+
+	dropped := evtmgr.ScheduleWithResult(nil, nil, func(*EventManager, any, any) any { return nil },
+		vrtime.CreateTime(5, 0))
+	evtmgr.RemoveEvent(dropped.EventID())
+	if _, err := dropped.Await(); err == nil {
+		t.Error("Await of a removed event returned no error")
+	}
+}
@@ -0,0 +1,70 @@
+package evtm
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Random number streams give each stochastic element of a model its own
+// sequence of random numbers.  A stream is identified by a name, and its sequence
+// is determined by that name and the seed of the EventManager, so that
+// adding, removing, or reordering the use of one stream does not disturb
+// the numbers drawn from any other.
+
+// SetSeed sets the seed from which the EventManager's random number streams are derived,
+// and discards any streams already created so that they restart from the new seed.
+func (evtmgr *EventManager) SetSeed(seed int64) {
+	evtmgr.mu.Lock()
+	evtmgr.seed = seed
+	evtmgr.streams = make(map[string]*rand.Rand)
+	evtmgr.sources = make(map[string]*countingSource)
+	evtmgr.mu.Unlock()
+}
+
+// countingSource is the source of a random number stream.  It counts the values drawn, so
+// that a stream can be duplicated (see Clone) by drawing as many from a fresh source.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func (cs *countingSource) Int63() int64 {
+	cs.draws += 1
+	return cs.src.Int63()
+}
+
+func (cs *countingSource) Uint64() uint64 {
+	cs.draws += 1
+	return cs.src.Uint64()
+}
+
+func (cs *countingSource) Seed(seed int64) {
+	cs.draws = 0
+	cs.src.Seed(seed)
+}
+
+// newSource creates the source of the stream with the given name, advanced past draws values
+func (evtmgr *EventManager) newSource(name string, draws uint64) *countingSource {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	cs := &countingSource{src: rand.NewSource(evtmgr.seed ^ int64(h.Sum64())).(rand.Source64)}
+	for cs.draws < draws {
+		cs.Uint64()
+	}
+	return cs
+}
+
+// RandStream returns the random number stream with the given name, creating it
+// on first use.  The returned generator is not safe for concurrent use.
+func (evtmgr *EventManager) RandStream(name string) *rand.Rand {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+	stream, present := evtmgr.streams[name]
+	if !present {
+		cs := evtmgr.newSource(name, 0)
+		stream = rand.New(cs)
+		evtmgr.streams[name] = stream
+		evtmgr.sources[name] = cs
+	}
+	return stream
+}
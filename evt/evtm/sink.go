@@ -0,0 +1,119 @@
+package evtm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// EventSink receives the events an EventManager dispatches, to export the output stream of
+// a simulation without wrapping every handler.  A sink added with AddSink is given a copy of
+// each event selected, just before it is dispatched, by the thread running the EventManager.
+type EventSink interface {
+	// Put receives an event.  An error aborts the run (see Abort).
+	Put(event Event) error
+}
+
+// SinkFunc adapts a function to the EventSink interface
+type SinkFunc func(event Event) error
+
+// Put calls f
+func (f SinkFunc) Put(event Event) error {
+	return f(event)
+}
+
+// AddSink gives sink every dispatched event for which selected returns true, or every
+// dispatched event if selected is nil.  It returns a function that detaches the sink.
+func (evtmgr *EventManager) AddSink(sink EventSink, selected func(*Event) bool) (remove func()) {
+	return evtmgr.AddInterceptor(func(evtmgr *EventManager, event *Event) {
+		if selected != nil && !selected(event) {
+			return
+		}
+		if err := sink.Put(*event); err != nil {
+			evtmgr.Abort(fmt.Errorf("event sink: %w", err))
+		}
+	})
+}
+
+// FormatEvent formats an event as a line for a WriterSink
+type FormatEvent func(event *Event) string
+
+// FormatEventLine is the default FormatEvent: the time of the event in seconds, its eventId,
+// the name of its handler, and its data
+func FormatEventLine(event *Event) string {
+	return fmt.Sprintf("%.9f %d %s %v\n", event.Time.Seconds(), event.EventID, HandlerName(event.EventHandler), event.Data)
+}
+
+// WriterSink is an EventSink writing a line per event to an io.Writer, e.g., a file or a
+// network connection.  Output is buffered; Flush or Close writes out what remains.
+type WriterSink struct {
+	w      *bufio.Writer
+	format FormatEvent
+	closer io.Closer
+}
+
+// NewWriterSink creates a WriterSink writing to w, formatting each event with format, or with
+// FormatEventLine if format is nil
+func NewWriterSink(w io.Writer, format FormatEvent) *WriterSink {
+	if format == nil {
+		format = FormatEventLine
+	}
+	return &WriterSink{w: bufio.NewWriter(w), format: format}
+}
+
+// CreateFileSink creates a WriterSink writing to a new file at path
+func CreateFileSink(path string, format FormatEvent) (*WriterSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	sink := NewWriterSink(file, format)
+	sink.closer = file
+	return sink, nil
+}
+
+// DialSink creates a WriterSink writing to a network connection to address (see net.Dial)
+func DialSink(network, address string, format FormatEvent) (*WriterSink, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	sink := NewWriterSink(conn, format)
+	sink.closer = conn
+	return sink, nil
+}
+
+// Put writes a line for the event
+func (ws *WriterSink) Put(event Event) error {
+	_, err := ws.w.WriteString(ws.format(&event))
+	return err
+}
+
+// Flush writes out any buffered output
+func (ws *WriterSink) Flush() error {
+	return ws.w.Flush()
+}
+
+// Close flushes the sink and closes the file or connection it opened, if any
+func (ws *WriterSink) Close() error {
+	err := ws.w.Flush()
+	if ws.closer != nil {
+		if cerr := ws.closer.Close(); err == nil {
+			err = cerr
+		}
+		ws.closer = nil
+	}
+	return err
+}
+
+// ChanSink is an EventSink sending each event on a channel, to be consumed by another
+// goroutine.  Put blocks while the channel is full, holding up the EventManager.
+type ChanSink chan<- Event
+
+// Put sends the event on the channel
+func (cs ChanSink) Put(event Event) error {
+	cs <- event
+	return nil
+}
@@ -0,0 +1,44 @@
+package evtm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestSinks checks that a sink receives the selected events as they are dispatched, until
+// it is detached, and that a failing sink aborts the run
+func TestSinks(t *testing.T) {
+	evtmgr := New()
+	var buf bytes.Buffer
+	ws := NewWriterSink(&buf, func(event *Event) string { return event.Data.(string) + "\n" })
+	removeWriter := evtmgr.AddSink(ws, func(event *Event) bool { return event.Data != "skip" })
+	ch := make(chan Event, 8)
+	evtmgr.AddSink(ChanSink(ch), nil)
+
+	noop := func(*EventManager, any, any) any { return nil }
+	for idx, data := range []string{"a", "skip", "b"} {
+		evtmgr.Schedule(nil, data, noop, vrtime.CreateTime(int64(idx), 0))
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	removeWriter()
+	evtmgr.Schedule(nil, "c", noop, vrtime.CreateTime(1, 0))
+	evtmgr.AdvanceTo(vrtime.CreateTime(20, 0))
+
+	if err := ws.Close(); err != nil || buf.String() != "a\nb\n" {
+		t.Errorf("writer sink wrote %q (%v), want the two selected events", buf.String(), err)
+	}
+	if len(ch) != 4 {
+		t.Errorf("channel sink received %d events, want 4", len(ch))
+	}
+
+	evtmgr = New()
+	evtmgr.AddSink(SinkFunc(func(Event) error { return errors.New("disk full") }), nil)
+	evtmgr.Schedule(nil, "a", noop, vrtime.CreateTime(1, 0))
+	if err := evtmgr.RunE(1); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("run with a failing sink ended with %v, want the sink's error", err)
+	}
+}
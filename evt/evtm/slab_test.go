@@ -0,0 +1,40 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestSlabEventsKeptPastRun checks that under slab allocation the Events a dispatch hook
+// keeps are intact after the run that dispatched them, and that the slabs are reused only
+// once ReleaseSlabs is called
+func TestSlabEventsKeptPastRun(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetSlabSize(4)
+	var kept []*Event
+	evtmgr.RegisterPreDispatchHook(func(event *Event) { kept = append(kept, event) })
+	noop := func(*EventManager, any, any) any { return nil }
+	for idx := 0; idx < 10; idx++ {
+		evtmgr.Schedule(nil, idx, noop, vrtime.CreateTime(int64(idx), 0))
+	}
+
+	evtmgr.Run(100)
+	if len(kept) != 10 {
+		t.Fatalf("hook saw %d events, want 10", len(kept))
+	}
+	for idx, event := range kept {
+		if event.Data != idx || event.EventHandler == nil {
+			t.Fatalf("event %d kept by the hook holds %v once the run ended", idx, event.Data)
+		}
+	}
+
+	evtmgr.ReleaseSlabs()
+	if kept[0].Data != nil {
+		t.Errorf("ReleaseSlabs left the first Event holding %v, want it zeroed for reuse", kept[0].Data)
+	}
+	evtmgr.Schedule(nil, "reused", noop, vrtime.CreateTime(1, 0))
+	if kept[0].Data != "reused" {
+		t.Errorf("Event scheduled after ReleaseSlabs not carved from the start of the slabs")
+	}
+}
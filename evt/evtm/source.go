@@ -0,0 +1,167 @@
+package evtm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// EventSource supplies events to an EventManager from outside the model: the replay of a
+// trace, a generator, or a live feed.  The EventManager pulls from each source added with
+// AddSource one event at a time, scheduling it at its time and pulling the next when it has
+// been dispatched, so that the events of every source merge in time order with those of the
+// model, and a source is read no further ahead than the run has reached.
+type EventSource interface {
+	// Next returns the data of the next event and the virtual time, not earlier than that of
+	// the event before, at which it occurs.  ok is false once the source is exhausted.
+	// A source that failed, rather than ran out, reports why through an Err method.
+	Next() (data any, at vrtime.Time, ok bool)
+}
+
+// SourceFunc adapts a function to the EventSource interface, e.g., to make a generator a source
+type SourceFunc func() (data any, at vrtime.Time, ok bool)
+
+// Next calls f
+func (f SourceFunc) Next() (any, vrtime.Time, bool) {
+	return f()
+}
+
+// sourced is an EventSource being pulled from by an EventManager
+type sourced struct {
+	src     EventSource
+	handler EventHandlerFunction
+}
+
+// AddSource pulls events from src, dispatching each to handler with src as context and the
+// data of the event as data.  An error is returned if the first event of the source is in the
+// past.  Should a later event lie in the past, or the source fail, the run is aborted (see Abort).
+func (evtmgr *EventManager) AddSource(src EventSource, handler EventHandlerFunction) error {
+	return evtmgr.pull(&sourced{src: src, handler: handler})
+}
+
+// pull schedules the next event of a source
+func (evtmgr *EventManager) pull(sd *sourced) error {
+	data, at, ok := sd.src.Next()
+	if !ok {
+		if failing, can := sd.src.(interface{ Err() error }); can {
+			if err := failing.Err(); err != nil {
+				return fmt.Errorf("event source: %w", err)
+			}
+		}
+		return nil
+	}
+	_, err := evtmgr.scheduleAt(sd.src, data, sd.dispatch, at, nil)
+	return err
+}
+
+// dispatch is the handler of the events of a source, which hands the event to the handler
+// given to AddSource and then pulls the next
+func (sd *sourced) dispatch(evtmgr *EventManager, context any, data any) any {
+	rtn := sd.handler(evtmgr, context, data)
+	if err := evtmgr.pull(sd); err != nil {
+		evtmgr.Abort(err)
+	}
+	return rtn
+}
+
+// ParseRecord parses one line of a textual event source into the data and time of an event
+type ParseRecord func(line string) (data any, at vrtime.Time, err error)
+
+// ParseSecondsLine is the default ParseRecord: a line holds the time of the event in seconds,
+// then whitespace, then the data of the event, which is the rest of the line as a string
+func ParseSecondsLine(line string) (any, vrtime.Time, error) {
+	field, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	secs, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return nil, vrtime.ZeroTime(), fmt.Errorf("bad time in %q: %w", line, err)
+	}
+	return strings.TrimSpace(rest), vrtime.SecondsToTime(secs), nil
+}
+
+// ReaderSource is an EventSource reading a record per line from an io.Reader, e.g., a file
+// or a network connection.  Blank lines and lines starting with '#' are skipped.  Reading
+// blocks until a line arrives, so a live feed read this way holds up the EventManager until
+// its next event is known; Device admission suits a feed that must not.
+type ReaderSource struct {
+	scanner *bufio.Scanner
+	parse   ParseRecord
+	closer  io.Closer
+	line    int
+	err     error
+}
+
+// NewReaderSource creates a ReaderSource reading r, parsing each line with parse, or with
+// ParseSecondsLine if parse is nil
+func NewReaderSource(r io.Reader, parse ParseRecord) *ReaderSource {
+	if parse == nil {
+		parse = ParseSecondsLine
+	}
+	return &ReaderSource{scanner: bufio.NewScanner(r), parse: parse}
+}
+
+// OpenFileSource creates a ReaderSource reading the file at path, which is closed when the
+// source is exhausted or by Close
+func OpenFileSource(path string, parse ParseRecord) (*ReaderSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	src := NewReaderSource(file, parse)
+	src.closer = file
+	return src, nil
+}
+
+// DialSource creates a ReaderSource reading the records sent over a network connection
+// to address (see net.Dial), which is closed when the source is exhausted or by Close
+func DialSource(network, address string, parse ParseRecord) (*ReaderSource, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	src := NewReaderSource(conn, parse)
+	src.closer = conn
+	return src, nil
+}
+
+// Next reads and parses the next record
+func (rs *ReaderSource) Next() (any, vrtime.Time, bool) {
+	for rs.err == nil && rs.scanner.Scan() {
+		rs.line += 1
+		line := rs.scanner.Text()
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		data, at, err := rs.parse(line)
+		if err != nil {
+			rs.err = fmt.Errorf("line %d: %w", rs.line, err)
+			break
+		}
+		return data, at, true
+	}
+	if rs.err == nil {
+		rs.err = rs.scanner.Err()
+	}
+	rs.Close()
+	return nil, vrtime.ZeroTime(), false
+}
+
+// Err returns the error that ended reading, nil if the source simply ran out
+func (rs *ReaderSource) Err() error {
+	return rs.err
+}
+
+// Close closes the file or connection the source reads, if it opened one
+func (rs *ReaderSource) Close() error {
+	if rs.closer == nil {
+		return nil
+	}
+	closer := rs.closer
+	rs.closer = nil
+	return closer.Close()
+}
@@ -0,0 +1,37 @@
+package evtm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestReaderSource checks that the events of a source merge in time order with the model's
+// own, comments and blank lines skipped, and that a bad record aborts the run
+func TestReaderSource(t *testing.T) {
+	evtmgr := New()
+	var order []string
+	record := func(evtmgr *EventManager, context any, data any) any {
+		order = append(order, fmt.Sprintf("%v@%g", data, evtmgr.CurrentTime().Seconds()))
+		return nil
+	}
+	src := NewReaderSource(strings.NewReader("0.5 a\n# a comment\n\n1.5 c\n"), nil)
+	if err := evtmgr.AddSource(src, record); err != nil {
+		t.Fatal(err)
+	}
+	evtmgr.Schedule(nil, "b", record, vrtime.SecondsToTime(1))
+	if err := evtmgr.RunE(10); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(order) != "[a@0.5 b@1 c@1.5]" {
+		t.Errorf("dispatched %v, want [a@0.5 b@1 c@1.5]", order)
+	}
+
+	evtmgr = New()
+	evtmgr.AddSource(NewReaderSource(strings.NewReader("2 x\nbogus y\n"), nil), record)
+	if err := evtmgr.RunE(10); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("run over a bad record ended with %v, want an error at line 2", err)
+	}
+}
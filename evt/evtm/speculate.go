@@ -0,0 +1,79 @@
+package evtm
+
+// Speculation is a point in the execution of an EventManager to which it can be rolled back.
+// Between the mark and its resolution the EventManager executes forward as usual; Commit then
+// keeps what was done, and Rollback returns the EventManager to the mark.  This supports
+// interactive steering, where a user tries a change and may undo it, and optimistic coupling
+// with an external component whose input may invalidate work done ahead of it.
+type Speculation struct {
+	evtmgr   *EventManager
+	saved    *EventManager // the state at the mark, nil once resolved
+	restores []func()      // callbacks restoring model state, in order of registration
+}
+
+// Speculate marks the current state of the EventManager as a speculation point.  Pending
+// events are copied as Clone copies them, with copier giving their context and data.  The
+// model's own state is not copied; the model registers callbacks with OnRollback to restore it.
+func (evtmgr *EventManager) Speculate(copier EventCopier) *Speculation {
+	return &Speculation{evtmgr: evtmgr, saved: evtmgr.Clone(copier)}
+}
+
+// OnRollback registers a callback that restores part of the model's state to the mark.
+// Callbacks are called by Rollback in the reverse order of registration.
+func (sp *Speculation) OnRollback(restore func()) {
+	sp.restores = append(sp.restores, restore)
+}
+
+// Active returns true if the speculation has been neither committed nor rolled back
+func (sp *Speculation) Active() bool {
+	return sp.saved != nil
+}
+
+// Commit keeps the execution since the mark, and ends the speculation
+func (sp *Speculation) Commit() {
+	sp.saved = nil
+	sp.restores = nil
+}
+
+// Rollback returns the EventManager to the mark: its clock, pending events, eventId
+// numbering, count of events dispatched, and random number streams are those it had then.
+// The callbacks registered with OnRollback are then called, and the speculation ends.
+// It returns false if the speculation had already ended.  Called from an event handler,
+// Rollback lets that handler finish, after which the run continues from the mark.
+func (sp *Speculation) Rollback() bool {
+	saved := sp.saved
+	if saved == nil {
+		return false
+	}
+	sp.saved = nil
+	evtmgr := sp.evtmgr
+
+	evtmgr.mu.Lock()
+	evtmgr.EventList.Restore(saved.EventList)
+	evtmgr.Time = saved.Time
+	evtmgr.NumEvts = saved.NumEvts
+	evtmgr.autoPri = saved.autoPri
+	evtmgr.nowPri = saved.nowPri
+	evtmgr.endPri = saved.endPri
+	evtmgr.after = saved.after
+	evtmgr.disabled = saved.disabled
+	evtmgr.seed = saved.seed
+	for name, cs := range evtmgr.sources {
+		// the streams are reset in place, as the model may hold on to them
+		var draws uint64
+		if prior, present := saved.sources[name]; present {
+			draws = prior.draws
+		}
+		*cs = *evtmgr.newSource(name, draws)
+	}
+	evtmgr.mu.Unlock()
+
+	for idx := len(sp.restores) - 1; idx >= 0; idx-- {
+		sp.restores[idx]()
+	}
+	sp.restores = nil
+	if evtmgr.tracing(TraceInfo) {
+		evtmgr.tracef("Rollback to %f\n", saved.Time.Seconds())
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRollback checks that rolling back restores the clock, the pending events, the random
+// number streams, and, through its callbacks, the model, and that a committed speculation
+// cannot be rolled back
+func TestRollback(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetSeed(5)
+	count := 0
+	increment := func(*EventManager, any, any) any { count++; return nil }
+	for i := 1; i <= 10; i++ {
+		evtmgr.Schedule(nil, nil, increment, vrtime.CreateTime(int64(i), 0))
+	}
+	stream := evtmgr.RandStream("choice")
+	evtmgr.AdvanceTo(vrtime.CreateTime(4, 0))
+
+	sp := evtmgr.Speculate(nil)
+	saved := count
+	sp.OnRollback(func() { count = saved })
+	first := stream.Int63()
+	evtmgr.AdvanceTo(vrtime.CreateTime(8, 0))
+	if !sp.Rollback() || sp.Active() {
+		t.Fatal("Rollback of an active speculation failed")
+	}
+	if count != 4 || evtmgr.CurrentTicks() != 4 || evtmgr.EventList.Len() != 6 || evtmgr.EventsDispatched() != 4 {
+		t.Errorf("rolled back to count %d at %d with %d pending after %d dispatched, want 4 at 4 with 6 after 4",
+			count, evtmgr.CurrentTicks(), evtmgr.EventList.Len(), evtmgr.EventsDispatched())
+	}
+	if again := stream.Int63(); again != first {
+		t.Errorf("stream drew %d after the rollback, want %d as at the mark", again, first)
+	}
+
+	sp = evtmgr.Speculate(nil)
+	evtmgr.AdvanceTo(vrtime.CreateTime(20, 0))
+	sp.Commit()
+	if sp.Rollback() || count != 10 {
+		t.Errorf("committed speculation rolled back, or count %d, want 10", count)
+	}
+}
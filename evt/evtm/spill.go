@@ -0,0 +1,98 @@
+package evtm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/iti/evt/compression"
+	"github.com/iti/evt/vrtime"
+)
+
+// SpillCodec encodes the events of an EventManager whose event list spills far-future events
+// to disk (see SetSpill).  A handler cannot be written to disk, so it is written by name, and
+// every handler of an event that may be spilled must be registered with the codec.  Context
+// and Data are written with encoding/gob, so the concrete types they hold must be registered
+// with gob.Register.  An event the codec cannot encode stays in memory.
+type SpillCodec struct {
+	// Compressor, if not nil, compresses each event written, which pays when events carry
+	// bulky Data.  Events are read back whatever compressed them.
+	Compressor compression.Compressor
+
+	handlers map[string]EventHandlerFunction
+}
+
+// spilledEvent is the form in which an Event is written to disk.  Its eventId is that of
+// the element of the event list holding it.
+type spilledEvent struct {
+	Handler string
+	Context any
+	Data    any
+	Ticks   int64
+	Pri     int64
+	Cancel  bool
+	Class   string
+	Parent  int
+	Clock   *LogicalClock
+	Meta    *Metadata
+}
+
+// NewSpillCodec creates a SpillCodec for events dispatched to the handlers given
+func NewSpillCodec(handlers ...EventHandlerFunction) *SpillCodec {
+	sc := &SpillCodec{handlers: make(map[string]EventHandlerFunction)}
+	sc.Register(handlers...)
+	return sc
+}
+
+// Register adds handlers to those whose events the codec can encode
+func (sc *SpillCodec) Register(handlers ...EventHandlerFunction) {
+	for _, handler := range handlers {
+		sc.handlers[HandlerName(handler)] = handler
+	}
+}
+
+// Encode writes an *Event
+func (sc *SpillCodec) Encode(v any) ([]byte, error) {
+	event := v.(*Event)
+	name := HandlerName(event.EventHandler)
+	if _, registered := sc.handlers[name]; !registered {
+		return nil, fmt.Errorf("handler %s is not registered", name)
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(spilledEvent{Handler: name, Context: event.Context, Data: event.Data,
+		Ticks: event.Time.Ticks(), Pri: event.Time.Pri(), Cancel: event.Cancel, Class: event.Class, Parent: event.Parent, Clock: event.Clock, Meta: event.Meta})
+	if err != nil {
+		return nil, err
+	}
+	return compression.Compress(sc.Compressor, buf.Bytes())
+}
+
+// Decode reads an *Event written by Encode
+func (sc *SpillCodec) Decode(eventID int, data []byte) (any, error) {
+	data, err := compression.Decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	var se spilledEvent
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&se); err != nil {
+		return nil, err
+	}
+	handler, registered := sc.handlers[se.Handler]
+	if !registered {
+		return nil, fmt.Errorf("handler %s is not registered", se.Handler)
+	}
+	return &Event{Context: se.Context, Data: se.Data, Time: vrtime.CreateTime(se.Ticks, se.Pri),
+		EventHandler: handler, EventID: eventID, Cancel: se.Cancel, Class: se.Class, Parent: se.Parent, Clock: se.Clock, Meta: se.Meta}, nil
+}
+
+// SetSpill has the event list keep in memory only events within horizon of the earliest,
+// roughly, and spill those further in the future to files in dir, encoded by codec, so that a
+// model may hold more pending events than fit in memory (see [evtq.EventQueue.SetSpill]).  An
+// event is read back into memory as its time approaches, or when it is looked up by eventId,
+// e.g., to cancel it.  A zero horizon or a nil codec switches spilling off.
+func (evtmgr *EventManager) SetSpill(dir string, horizon vrtime.Time, codec *SpillCodec) error {
+	if codec == nil {
+		return evtmgr.EventList.SetSpill(dir, 0, nil)
+	}
+	return evtmgr.EventList.SetSpill(dir, horizon.Ticks(), codec)
+}
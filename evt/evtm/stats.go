@@ -0,0 +1,97 @@
+package evtm
+
+import (
+	"time"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// statsInterval is the number of events dispatched between samples of the progress of a run.
+// It is a power of two, so the test for a sample is a mask.
+const statsInterval = 1024
+
+// RunStats reports the progress of the current run of an EventManager, or of the last
+// one if it is not running.
+type RunStats struct {
+	Events      int           // events dispatched, over all runs
+	RunEvents   int           // events dispatched in this run
+	Elapsed     time.Duration // wallclock time since this run began, or that it took
+	AverageRate float64       // events per second of wallclock time over this run
+	CurrentRate float64       // events per second of wallclock time over the most recent sample interval
+	VirtualTime float64       // current virtual time, in seconds
+	LimitTime   float64       // virtual time, in seconds, at which this run ends
+	ETA         time.Duration // estimated wallclock time until virtual time reaches LimitTime, -1 if unknown
+	Running     bool          // true if the run is in progress
+}
+
+// runStats is the state from which RunStats is computed.  It is updated under the EventManager's lock.
+type runStats struct {
+	limitTicks   int64     // limit of the run, in ticks
+	startEvents  int       // value of NumEvts when the run began
+	startTicks   int64     // virtual time, in ticks, when the run began
+	end          time.Time // wallclock time when the run returned, zero while it is in progress
+	sampleAt     time.Time // wallclock time of the most recent sample
+	sampleEvents int       // value of NumEvts at that sample
+	sampleTicks  int64     // virtual time, in ticks, at that sample
+	eventRate    float64   // events per second between the last two samples
+	tickRate     float64   // ticks of virtual time per second between the last two samples
+}
+
+// beginStats records the start of a run.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) beginStats(limitTicks int64) {
+	evtmgr.stats = runStats{limitTicks: limitTicks, startEvents: evtmgr.NumEvts,
+		startTicks: evtmgr.Time.Ticks(), sampleAt: evtmgr.StartTime,
+		sampleEvents: evtmgr.NumEvts, sampleTicks: evtmgr.Time.Ticks()}
+}
+
+// sampleStats updates the rates of the run.  Called with evtmgr.mu held.
+func (evtmgr *EventManager) sampleStats() {
+	now := time.Now()
+	span := now.Sub(evtmgr.stats.sampleAt).Seconds()
+	if span <= 0 {
+		return
+	}
+	evtmgr.stats.eventRate = float64(evtmgr.NumEvts-evtmgr.stats.sampleEvents) / span
+	evtmgr.stats.tickRate = float64(evtmgr.Time.Ticks()-evtmgr.stats.sampleTicks) / span
+	evtmgr.stats.sampleAt = now
+	evtmgr.stats.sampleEvents = evtmgr.NumEvts
+	evtmgr.stats.sampleTicks = evtmgr.Time.Ticks()
+}
+
+// RunStats returns the throughput of the current run, and an estimate of how long
+// it will take to reach its limit.  The rates and the estimate are computed from samples
+// taken every statsInterval events, so they cost the dispatch loop little.  The estimate
+// projects the rate at which virtual time advanced over the most recent sample interval,
+// or over the run as a whole before the first sample is taken.
+func (evtmgr *EventManager) RunStats() RunStats {
+	evtmgr.mu.Lock()
+	defer evtmgr.mu.Unlock()
+
+	st := evtmgr.stats
+	end := st.end
+	if evtmgr.RunFlag || end.IsZero() {
+		end = time.Now()
+	}
+	rs := RunStats{Events: evtmgr.NumEvts, RunEvents: evtmgr.NumEvts - st.startEvents,
+		VirtualTime: evtmgr.Time.Seconds(), LimitTime: vrtime.TicksToSeconds(st.limitTicks),
+		CurrentRate: st.eventRate, ETA: -1, Running: evtmgr.RunFlag}
+	if !evtmgr.StartTime.IsZero() {
+		rs.Elapsed = end.Sub(evtmgr.StartTime)
+	}
+	if rs.Elapsed > 0 {
+		rs.AverageRate = float64(rs.RunEvents) / rs.Elapsed.Seconds()
+	}
+
+	remaining := st.limitTicks - evtmgr.Time.Ticks()
+	tickRate := st.tickRate
+	if tickRate <= 0 && rs.Elapsed > 0 {
+		tickRate = float64(evtmgr.Time.Ticks()-st.startTicks) / rs.Elapsed.Seconds()
+	}
+	switch {
+	case remaining <= 0 || !rs.Running:
+		rs.ETA = 0
+	case tickRate > 0:
+		rs.ETA = time.Duration(float64(remaining) / tickRate * float64(time.Second))
+	}
+	return rs
+}
@@ -0,0 +1,43 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestRunStats checks the statistics reported in the middle of a run and after it, and that
+// the counts of a run start afresh while the cumulative count does not
+func TestRunStats(t *testing.T) {
+	evtmgr := New()
+	var during RunStats
+	handler := func(evtmgr *EventManager, context any, data any) any {
+		if data.(int) == 50 {
+			during = evtmgr.RunStats()
+		}
+		return nil
+	}
+	for i := 1; i <= 100; i++ {
+		evtmgr.Schedule(nil, i, handler, vrtime.CreateTime(int64(i), 0))
+	}
+	evtmgr.Run(1e-6)
+
+	if !during.Running || during.RunEvents != 50 || during.VirtualTime != vrtime.TicksToSeconds(50) || during.LimitTime != 1e-6 {
+		t.Errorf("mid-run stats %+v, want running at event 50, tick 50, limit 1e-06", during)
+	}
+	if during.ETA < 0 {
+		t.Errorf("mid-run ETA %v, want an estimate", during.ETA)
+	}
+	after := evtmgr.RunStats()
+	if after.Running || after.Events != 100 || after.RunEvents != 100 || after.ETA != 0 || after.AverageRate <= 0 {
+		t.Errorf("final stats %+v, want 100 events, not running, ETA 0", after)
+	}
+
+	for i := 1; i <= 10; i++ {
+		evtmgr.Schedule(nil, i, handler, vrtime.CreateTime(int64(i), 0))
+	}
+	evtmgr.Run(1e-5)
+	if again := evtmgr.RunStats(); again.Events != 110 || again.RunEvents != 10 {
+		t.Errorf("second run counted %d events of %d, want 10 of 110", again.RunEvents, again.Events)
+	}
+}
@@ -0,0 +1,55 @@
+package evtm
+
+import (
+	"runtime"
+)
+
+// ThreadOptions select how the goroutine running the dispatch loop is bound to an
+// operating system thread, to cut the jitter of hardware-in-the-loop experiments run in
+// wallclock mode.
+type ThreadOptions struct {
+	// Lock runs each call to Run or RunFor on an OS thread locked to the goroutine that
+	// made it (see [runtime.LockOSThread]), so that the dispatch loop is not migrated
+	// between threads by the Go scheduler.
+	Lock bool
+
+	// Nice, when not zero and Lock is set, is the scheduling priority (nice value) requested
+	// for the locked thread for the duration of the run, where the operating system supports
+	// it.  Negative values raise the priority, and usually need privileges.  A request that
+	// is refused is reported at TraceInfo and the run goes on at the usual priority.
+	Nice int
+}
+
+// SetThreadOptions selects how the dispatch loop is bound to an OS thread from the next run on
+func (evtmgr *EventManager) SetThreadOptions(opts ThreadOptions) {
+	evtmgr.mu.Lock()
+	evtmgr.threadOpts = opts
+	evtmgr.mu.Unlock()
+}
+
+// bindThread applies the ThreadOptions at the start of a run, and returns a function
+// undoing them at its end
+func (evtmgr *EventManager) bindThread(opts ThreadOptions) (unbind func()) {
+	runtime.LockOSThread()
+	if opts.Nice == 0 {
+		return runtime.UnlockOSThread
+	}
+	restore, err := setThreadNice(opts.Nice)
+	if err != nil {
+		if evtmgr.tracing(TraceInfo) {
+			evtmgr.tracef("cannot set thread priority %d: %v\n", opts.Nice, err)
+		}
+		return runtime.UnlockOSThread
+	}
+	return func() {
+		if err := restore(); err != nil {
+			// the thread keeps a priority the goroutines next scheduled on it should not inherit
+			// so it is left locked, and the runtime ends it when the goroutine exits
+			if evtmgr.tracing(TraceInfo) {
+				evtmgr.tracef("cannot restore thread priority: %v\n", err)
+			}
+			return
+		}
+		runtime.UnlockOSThread()
+	}
+}
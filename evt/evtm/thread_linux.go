@@ -0,0 +1,26 @@
+//go:build linux
+
+package evtm
+
+import (
+	"syscall"
+)
+
+// setThreadNice sets the nice value of the calling thread, which must be locked to its
+// goroutine, and returns a function that restores the previous value
+func setThreadNice(nice int) (restore func() error, err error) {
+	tid := syscall.Gettid()
+
+	// the raw system call reports 20 minus the nice value
+	raw, err := syscall.Getpriority(syscall.PRIO_PROCESS, tid)
+	if err != nil {
+		return nil, err
+	}
+	previous := 20 - raw
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, previous)
+	}, nil
+}
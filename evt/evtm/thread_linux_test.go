@@ -0,0 +1,35 @@
+package evtm
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestThreadOptions checks that with Lock set every handler of a run executes on the same
+// thread, at the nice value requested
+func TestThreadOptions(t *testing.T) {
+	evtmgr := New()
+	evtmgr.SetThreadOptions(ThreadOptions{Lock: true, Nice: 5})
+	threads := make(map[int]bool)
+	nice := make(map[int]bool)
+	var step func(*EventManager, any, any) any
+	step = func(evtmgr *EventManager, context any, data any) any {
+		tid := syscall.Gettid()
+		threads[tid] = true
+		if raw, err := syscall.Getpriority(syscall.PRIO_PROCESS, tid); err == nil {
+			nice[20-raw] = true
+		}
+		if evtmgr.CurrentTicks() < 1000 {
+			evtmgr.Schedule(nil, nil, step, vrtime.CreateTime(1, 0))
+		}
+		return nil
+	}
+	evtmgr.Schedule(nil, nil, step, vrtime.CreateTime(0, 0))
+	evtmgr.Run(1)
+
+	if len(threads) != 1 || len(nice) != 1 || !nice[5] {
+		t.Errorf("handlers ran on %d threads at nice values %v, want one thread at 5", len(threads), nice)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package evtm
+
+import (
+	"errors"
+)
+
+// setThreadNice reports that the priority of a single thread cannot be set on this system
+func setThreadNice(nice int) (restore func() error, err error) {
+	return nil, errors.New("per-thread priority is not supported on this system")
+}
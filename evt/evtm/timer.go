@@ -0,0 +1,167 @@
+package evtm
+
+import (
+	"sync"
+
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// Timer is a timeout in virtual time that can be started, stopped, and restarted
+// any number of times.  Its semantics follow those of [time.Timer]: when it expires the
+// handler given when it was created is called, once, with the context and data given there.
+// Each time it is armed the Timer is mapped onto a single event managed by the
+// EventManager, so there is never more than one pending expiration, and a stopped
+// or reset Timer never delivers a stale one.
+type Timer struct {
+	evtmgr  *EventManager
+	context any
+	data    any
+	handler EventHandlerFunction
+	eventID int         // identifier of the pending expiration, evtq.InvalidEventID when not running
+	due     vrtime.Time // time of the pending expiration
+	expired bool        // true when the timer has expired since it was last armed
+	mu      sync.Mutex
+}
+
+// NewTimer creates a Timer, running, that expires offset after the current time and then
+// calls handler with the Timer as context and nil data
+func (evtmgr *EventManager) NewTimer(offset vrtime.Time, handler func(*EventManager, any, any) any) *Timer {
+	t := evtmgr.NewStoppedTimer(nil, nil, handler)
+	t.context = t
+	t.Start(offset)
+	return t
+}
+
+// NewStoppedTimer creates a Timer that calls handler with context and data when it expires.
+// The Timer is not running until Start or Reset is called.
+func (evtmgr *EventManager) NewStoppedTimer(context any, data any,
+	handler func(*EventManager, any, any) any) *Timer {
+	return &Timer{evtmgr: evtmgr, context: context, data: data,
+		handler: handler, eventID: evtq.InvalidEventID}
+}
+
+// StartTimer creates a Timer, as NewStoppedTimer does, and starts it to expire offset after
+// the current time
+func (evtmgr *EventManager) StartTimer(context any, data any,
+	handler func(*EventManager, any, any) any, offset vrtime.Time) *Timer {
+	t := evtmgr.NewStoppedTimer(context, data, handler)
+	t.Start(offset)
+	return t
+}
+
+// Start arms a Timer that is not running to expire offset after the current time.
+// It returns false, and leaves the Timer unchanged, if the Timer is already running.
+func (t *Timer) Start(offset vrtime.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.eventID != evtq.InvalidEventID {
+		return false
+	}
+	t.arm(offset)
+	return true
+}
+
+// Reset changes the Timer to expire offset after the current time, whether or not
+// it is running.  It returns true if the Timer had been running, and false if it
+// had expired or been stopped.  An expiration already taken from the event list for
+// dispatch, e.g., when Reset is called by another goroutine, is superseded, and does not
+// call the handler.
+func (t *Timer) Reset(offset vrtime.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := t.disarm()
+	t.arm(offset)
+	return active
+}
+
+// Stop prevents the Timer from expiring.  It returns true if the call stops the
+// Timer, and false if the Timer had already expired or been stopped, or if its expiration
+// has already been taken from the event list for dispatch, in which case the handler is
+// still called.
+func (t *Timer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.disarm()
+}
+
+// StopTimer is Stop
+func (t *Timer) StopTimer() bool {
+	return t.Stop()
+}
+
+// Expired returns true if the Timer has expired since it was last started or reset.
+func (t *Timer) Expired() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.expired
+}
+
+// Active returns true if the Timer is running, i.e., it has been armed
+// and has neither expired nor been stopped.
+func (t *Timer) Active() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.eventID != evtq.InvalidEventID
+}
+
+// Remaining returns the virtual time left before the Timer expires, and false, with a zero
+// Time, if it is not running.
+func (t *Timer) Remaining() (vrtime.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.eventID == evtq.InvalidEventID {
+		return vrtime.ZeroTime(), false
+	}
+	return vrtime.CreateTime(t.due.Ticks()-t.evtmgr.CurrentTicks(), 0), true
+}
+
+// arm schedules the expiration of the Timer.  Called with t.mu held.
+func (t *Timer) arm(offset vrtime.Time) {
+	t.expired = false
+	t.eventID, t.due = t.evtmgr.Schedule(t.context, t.data, t.fire, offset)
+}
+
+// disarm removes the pending expiration of the Timer, if there is one, and
+// reports whether there was.  An expiration already taken for dispatch cannot be
+// removed, and is left to fire.  Called with t.mu held.
+func (t *Timer) disarm() bool {
+	if t.eventID == evtq.InvalidEventID {
+		return false
+	}
+	if !t.evtmgr.RemoveEvent(t.eventID) {
+		return false
+	}
+	t.eventID = evtq.InvalidEventID
+	return true
+}
+
+// fire is the event handler of the expiration.  It does nothing if the expiration
+// dispatched is not the one the Timer is armed with, having been superseded by Reset.
+func (t *Timer) fire(evtmgr *EventManager, context any, data any) any {
+	t.mu.Lock()
+	if evtmgr.CurrentEventID() != t.eventID {
+		t.mu.Unlock()
+		return nil
+	}
+	t.eventID = evtq.InvalidEventID
+	t.expired = true
+	t.mu.Unlock()
+
+	// the handler is free to restart the Timer
+	return t.handler(evtmgr, context, data)
+}
+
+// AfterFunc schedules fn to be called delay after the current virtual time, in the manner
+// of [time.AfterFunc].  It returns a function that cancels the call; that function returns
+// true if it prevented fn from being called, and false if fn had already been called or cancelled.
+func (evtmgr *EventManager) AfterFunc(delay vrtime.Time, fn func()) (cancel func() bool) {
+	eventID, _ := evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any {
+		fn()
+		return nil
+	}, delay)
+
+	return func() bool {
+		return evtmgr.RemoveEvent(eventID)
+	}
+}
@@ -0,0 +1,129 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestNewTimer checks that a Timer made by NewTimer runs at once, expires when Remaining
+// said it would, and calls its handler with itself as context
+func TestNewTimer(t *testing.T) {
+	evtmgr := New()
+	var fired []int64
+	var timer *Timer
+	timer = evtmgr.NewTimer(vrtime.CreateTime(40, 0), func(evtmgr *EventManager, context any, data any) any {
+		if context != timer {
+			t.Errorf("handler called with context %v, not the Timer", context)
+		}
+		fired = append(fired, evtmgr.CurrentTicks())
+		return nil
+	})
+	if !timer.Active() {
+		t.Fatal("Timer from NewTimer is not running")
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+	left, running := timer.Remaining()
+	if !running || left.Ticks() != 30 {
+		t.Fatalf("Remaining is %d, %v at 10, want 30, true", left.Ticks(), running)
+	}
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(fired) != 1 || fired[0] != 40 {
+		t.Fatalf("Timer expired at %v, want once at 40", fired)
+	}
+	if _, running := timer.Remaining(); running || !timer.Expired() {
+		t.Error("Timer still running after it expired")
+	}
+}
+
+// TestStopTimer checks that a stopped Timer does not expire, and that StopTimer reports
+// whether it stopped one running
+func TestStopTimer(t *testing.T) {
+	evtmgr := New()
+	fired := 0
+	timer := evtmgr.NewTimer(vrtime.CreateTime(40, 0), func(*EventManager, any, any) any {
+		fired += 1
+		return nil
+	})
+	if !timer.StopTimer() {
+		t.Error("StopTimer of a running Timer returned false")
+	}
+	if timer.StopTimer() {
+		t.Error("StopTimer of a stopped Timer returned true")
+	}
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if fired != 0 {
+		t.Errorf("stopped Timer expired %d times", fired)
+	}
+
+	timer.Reset(vrtime.CreateTime(5, 0))
+	evtmgr.AdvanceTo(vrtime.CreateTime(200, 0))
+	if fired != 1 {
+		t.Errorf("Timer reset after StopTimer expired %d times, want 1", fired)
+	}
+}
+
+// TestTimerStopAfterPop checks a Stop and a Reset that land after the expiration is taken
+// from the event list, as they may from another goroutine: Stop reports that it was too late,
+// and Reset supersedes the expiration, so the handler is called once, when the reset one fires
+func TestTimerStopAfterPop(t *testing.T) {
+	for _, reset := range []bool{false, true} {
+		evtmgr := New()
+		var fired []int64
+		timer := evtmgr.StartTimer(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+			fired = append(fired, evtmgr.CurrentTicks())
+			return nil
+		}, vrtime.CreateTime(10, 0))
+
+		// a filter is consulted after the event is popped, just before it is dispatched
+		var late bool
+		remove := evtmgr.AddFilter(func(*EventManager, *Event) (FilterVerdict, vrtime.Time) {
+			if reset {
+				late = timer.Reset(vrtime.CreateTime(5, 0))
+			} else {
+				late = timer.Stop()
+			}
+			return FilterDispatch, vrtime.Time{}
+		})
+		evtmgr.AdvanceTo(vrtime.CreateTime(10, 0))
+		remove()
+		if late {
+			t.Errorf("reset %v: call after the expiration was popped returned true", reset)
+		}
+		if !reset {
+			if len(fired) != 1 || fired[0] != 10 || timer.Active() {
+				t.Errorf("stopped too late, Timer fired at %v and is active %v", fired, timer.Active())
+			}
+			continue
+		}
+
+		if len(fired) != 0 || !timer.Active() {
+			t.Fatalf("reset Timer fired at %v and is active %v, want pending", fired, timer.Active())
+		}
+		evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+		if len(fired) != 1 || fired[0] != 15 || timer.Active() {
+			t.Errorf("reset Timer fired at %v and is active %v, want once at 15", fired, timer.Active())
+		}
+	}
+}
+
+// TestAfterFunc checks that AfterFunc calls its function after the delay unless cancelled,
+// and that cancel reports whether it prevented the call
+func TestAfterFunc(t *testing.T) {
+	evtmgr := New()
+	var called []int64
+	cancelKept := evtmgr.AfterFunc(vrtime.CreateTime(10, 0), func() { called = append(called, evtmgr.CurrentTicks()) })
+	cancelDropped := evtmgr.AfterFunc(vrtime.CreateTime(20, 0), func() { called = append(called, -1) })
+	if !cancelDropped() || cancelDropped() {
+		t.Error("cancel did not report the call prevented once, and only once")
+	}
+
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+	if len(called) != 1 || called[0] != 10 {
+		t.Errorf("functions called at %v, want only the first, at 10", called)
+	}
+	if cancelKept() {
+		t.Error("cancel after the call reported it prevented")
+	}
+}
@@ -0,0 +1,53 @@
+package evtm
+
+import (
+	"fmt"
+	"log"
+)
+
+// TraceLevel selects how much an EventManager reports about its own operation.
+// Each level includes everything reported at the levels below it.
+type TraceLevel int32
+
+const (
+	// TraceOff reports nothing.  This is the default.
+	TraceOff TraceLevel = iota
+
+	// TraceInfo reports changes in the state of the EventManager,
+	// e.g., the dispatch thread suspending and resuming.
+	TraceInfo
+
+	// TraceEvents additionally reports the scheduling and dispatch of each event.
+	TraceEvents
+
+	// TraceDebug additionally reports the entry to and exit from each
+	// scheduling call, and the state examined by the dispatch loop.
+	TraceDebug
+)
+
+// SetTraceLevel sets the amount of information the EventManager reports.
+func (evtmgr *EventManager) SetTraceLevel(level TraceLevel) {
+	evtmgr.traceLevel.Store(int32(level))
+}
+
+// SetTraceLogger directs the information the EventManager reports to logger.
+// By default it goes to the standard logger of package [log].
+func (evtmgr *EventManager) SetTraceLogger(logger *log.Logger) {
+	evtmgr.traceLogger.Store(logger)
+}
+
+// tracing returns true if the EventManager reports information at the given level.
+// Trace statements are guarded by a call to tracing, so that when tracing is off
+// their arguments are neither evaluated nor formatted and the cost is a single atomic load.
+func (evtmgr *EventManager) tracing(level TraceLevel) bool {
+	return TraceLevel(evtmgr.traceLevel.Load()) >= level
+}
+
+// tracef reports formatted information
+func (evtmgr *EventManager) tracef(format string, args ...any) {
+	logger := evtmgr.traceLogger.Load()
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Output(2, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,38 @@
+package evtm
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestTraceLevels checks that each trace level reports what it should to the logger set for
+// the EventManager, and that a level below it reports nothing of it
+func TestTraceLevels(t *testing.T) {
+	for _, level := range []TraceLevel{TraceOff, TraceInfo, TraceEvents, TraceDebug} {
+		var out bytes.Buffer
+		evtmgr := New()
+		evtmgr.SetTraceLogger(log.New(&out, "", 0))
+		evtmgr.SetTraceLevel(level)
+		evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any { return nil }, vrtime.CreateTime(5, 0))
+		evtmgr.Run(1)
+		evtmgr.Abort(nil)
+
+		report := out.String()
+		for _, want := range []struct {
+			level TraceLevel
+			text  string
+		}{
+			{TraceInfo, "Abort"},
+			{TraceEvents, "dispatch event"},
+			{TraceDebug, "enter Schedule entry 1"},
+		} {
+			if reported := strings.Contains(report, want.text); reported != (level >= want.level) {
+				t.Errorf("level %d reported %q: %v", level, want.text, reported)
+			}
+		}
+	}
+}
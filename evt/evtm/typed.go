@@ -0,0 +1,81 @@
+package evtm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// The handlers of events take their context and data as values of type any, which the
+// code that schedules an event and the handler must agree on without help from the compiler.
+// ScheduleT and TypedHandler let them agree through type parameters instead.
+
+// TypeMismatchError reports an event whose context or data is not of the type its typed
+// handler takes, as when an EventInterceptor has replaced it
+type TypeMismatchError struct {
+	EventID int    // identifier of the event
+	Field   string // "context" or "data"
+	Want    string // type the handler takes
+	Got     string // type of the value the event carries
+}
+
+// Error describes the mismatch
+func (tme *TypeMismatchError) Error() string {
+	return fmt.Sprintf("evtm: event %d carries %s of type %s, handler takes %s",
+		tme.EventID, tme.Field, tme.Got, tme.Want)
+}
+
+// TypedHandler adapts a handler taking a context of type C and data of type D to an
+// EventHandlerFunction.  The error the handler returns is the value of the event, as seen by
+// OnResult.  An event whose context or data is not of the type the handler takes aborts the
+// run (see Abort) with a *TypeMismatchError, the handler not being called.  Since every
+// adapted handler is the same function, HandlerName does not tell them apart.
+func TypedHandler[C any, D any](handler func(*EventManager, C, D) error) EventHandlerFunction {
+	return func(evtmgr *EventManager, context any, data any) any {
+		ctx, ok := typed[C](context)
+		if !ok {
+			evtmgr.Abort(mismatch[C](evtmgr, "context", context))
+			return nil
+		}
+		dat, ok := typed[D](data)
+		if !ok {
+			evtmgr.Abort(mismatch[D](evtmgr, "data", data))
+			return nil
+		}
+		return handler(evtmgr, ctx, dat)
+	}
+}
+
+// ScheduleT is Schedule for a handler that takes a context of type C and data of type D,
+// adapted by TypedHandler, so that the compiler checks the handler against what is scheduled
+func ScheduleT[C any, D any](evtmgr *EventManager, context C, data D,
+	handler func(*EventManager, C, D) error, offset vrtime.Time) (int, vrtime.Time) {
+
+	return evtmgr.Schedule(context, data, TypedHandler(handler), offset)
+}
+
+// typed converts value to T, a nil value converting to the zero value of a T that may be nil
+func typed[T any](value any) (T, bool) {
+	if value == nil {
+		var zero T
+		switch typeOf[T]().Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return zero, true
+		}
+		return zero, false
+	}
+	converted, ok := value.(T)
+	return converted, ok
+}
+
+// typeOf returns the type T
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// mismatch describes value not being of type T
+func mismatch[T any](evtmgr *EventManager, field string, value any) *TypeMismatchError {
+	return &TypeMismatchError{EventID: evtmgr.CurrentEventID(), Field: field,
+		Want: typeOf[T]().String(), Got: fmt.Sprintf("%T", value)}
+}
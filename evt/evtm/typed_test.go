@@ -0,0 +1,36 @@
+package evtm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+type typedEntity struct{ name string }
+
+// TestScheduleT checks that a typed handler gets its context and data as scheduled, and that
+// an event whose data an interceptor has replaced with the wrong type aborts the run
+func TestScheduleT(t *testing.T) {
+	evtmgr := New()
+	var got string
+	handler := func(evtmgr *EventManager, entity *typedEntity, count int) error {
+		for idx := 0; idx < count; idx++ {
+			got += entity.name
+		}
+		return nil
+	}
+	ScheduleT(evtmgr, &typedEntity{name: "ab"}, 2, handler, vrtime.CreateTime(1, 0))
+	ScheduleT(evtmgr, nil, 0, handler, vrtime.CreateTime(2, 0))
+	if err := evtmgr.RunE(1); err != nil || got != "abab" {
+		t.Fatalf("run returned %v with %q from the handler, want nil and abab", err, got)
+	}
+
+	eventID, _ := ScheduleT(evtmgr, &typedEntity{}, 1, handler, vrtime.CreateTime(1, 0))
+	evtmgr.AddInterceptor(func(evtmgr *EventManager, event *Event) { event.Data = "one" })
+	err := evtmgr.RunE(2)
+	var tme *TypeMismatchError
+	if !errors.As(err, &tme) || tme.EventID != eventID || tme.Field != "data" || tme.Want != "int" || tme.Got != "string" {
+		t.Errorf("run returned %v, want a mismatch of the data of event %d", err, eventID)
+	}
+}
@@ -0,0 +1,158 @@
+package evtm
+
+import (
+	"sync"
+
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// WaitPolicy says how the condition of WaitUntil is checked.  It is always checked when
+// WaitUntil is called and whenever Signal is called; Poll adds periodic checks, for
+// conditions that change without the model being able to signal it.
+type WaitPolicy struct {
+	// Poll is the interval of virtual time at which the condition is checked; zero checks
+	// it only on Signal
+	Poll vrtime.Time
+
+	// MaxPolls is the number of periodic checks after which the wait gives up without calling
+	// the handler; zero means no limit
+	MaxPolls int
+}
+
+// Waiter is a handle on a wait begun by WaitUntil
+type Waiter struct {
+	evtmgr    *EventManager
+	context   any
+	data      any
+	predicate func() bool
+	handler   EventHandlerFunction
+	policy    WaitPolicy
+	pollID    int  // identifier of the pending poll, evtq.InvalidEventID if none
+	polls     int  // periodic checks made
+	fired     bool // the handler has been called or scheduled
+	done      bool // the wait has ended
+	mu        sync.Mutex
+}
+
+// WaitUntil calls handler with context and data as soon as predicate holds, e.g., to start a
+// transmission once a channel is idle.  If predicate holds now the handler is scheduled at
+// the current time; otherwise it is scheduled at the current time of the first call to Signal
+// at which predicate holds, or called by the first periodic check at which it holds, as the
+// policy allows.  predicate is called by the goroutine calling WaitUntil or Signal, or by the
+// thread running the EventManager, without the EventManager's lock held.
+func (evtmgr *EventManager) WaitUntil(context any, data any, predicate func() bool,
+	handler EventHandlerFunction, policy WaitPolicy) *Waiter {
+
+	wt := &Waiter{evtmgr: evtmgr, context: context, data: data, predicate: predicate,
+		handler: handler, policy: policy, pollID: evtq.InvalidEventID}
+	if predicate() {
+		wt.fired, wt.done = true, true
+		evtmgr.Schedule(context, data, handler, vrtime.CreateTime(0, 0))
+		return wt
+	}
+
+	evtmgr.mu.Lock()
+	evtmgr.waiters = append(evtmgr.waiters, wt)
+	evtmgr.mu.Unlock()
+	if policy.Poll.Ticks() > 0 {
+		// a Signal from another goroutine may already have ended the wait
+		wt.mu.Lock()
+		if !wt.done {
+			wt.pollID, _ = evtmgr.Schedule(wt, nil, wt.poll, policy.Poll)
+		}
+		wt.mu.Unlock()
+	}
+	return wt
+}
+
+// Signal checks the conditions of the waits begun by WaitUntil, in the order the waits began,
+// and schedules at the current time the handlers of those whose conditions hold.  A handler
+// that changes the state on which conditions depend calls it to let the waits see the change.
+func (evtmgr *EventManager) Signal() {
+	evtmgr.mu.Lock()
+	waiters := append([]*Waiter(nil), evtmgr.waiters...)
+	evtmgr.mu.Unlock()
+
+	for _, wt := range waiters {
+		if wt.predicate() && wt.end(true) {
+			evtmgr.Schedule(wt.context, wt.data, wt.handler, vrtime.CreateTime(0, 0))
+		}
+	}
+}
+
+// poll is the handler of the periodic checks of a wait
+func (wt *Waiter) poll(evtmgr *EventManager, context any, data any) any {
+	wt.mu.Lock()
+	wt.pollID = evtq.InvalidEventID
+	wt.polls += 1
+	exhausted := wt.policy.MaxPolls > 0 && wt.polls >= wt.policy.MaxPolls
+	wt.mu.Unlock()
+
+	if wt.predicate() {
+		if wt.end(true) {
+			return wt.handler(evtmgr, wt.context, wt.data)
+		}
+		return nil
+	}
+	if exhausted {
+		wt.end(false)
+		return nil
+	}
+
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	if !wt.done {
+		wt.pollID, _ = evtmgr.Schedule(wt, nil, wt.poll, wt.policy.Poll)
+	}
+	return nil
+}
+
+// end ends the wait, recording whether its handler is called, and removes its pending poll.
+// It returns false if the wait had already ended.
+func (wt *Waiter) end(fired bool) bool {
+	wt.mu.Lock()
+	if wt.done {
+		wt.mu.Unlock()
+		return false
+	}
+	wt.done, wt.fired = true, fired
+	pollID := wt.pollID
+	wt.pollID = evtq.InvalidEventID
+	wt.mu.Unlock()
+
+	if pollID != evtq.InvalidEventID {
+		wt.evtmgr.RemoveEvent(pollID)
+	}
+	evtmgr := wt.evtmgr
+	evtmgr.mu.Lock()
+	for idx, waiter := range evtmgr.waiters {
+		if waiter == wt {
+			evtmgr.waiters = append(evtmgr.waiters[:idx], evtmgr.waiters[idx+1:]...)
+			break
+		}
+	}
+	evtmgr.mu.Unlock()
+	return true
+}
+
+// Cancel ends the wait without calling its handler.  It returns false if the wait had
+// already ended.
+func (wt *Waiter) Cancel() bool {
+	return wt.end(false)
+}
+
+// Active returns true if the wait has not ended
+func (wt *Waiter) Active() bool {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	return !wt.done
+}
+
+// Fired returns true if the wait ended because its condition held, its handler having been
+// called or scheduled
+func (wt *Waiter) Fired() bool {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	return wt.fired
+}
@@ -0,0 +1,57 @@
+package evtm
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestWaitUntil checks that a wait fires at once if its condition holds, on the Signal that
+// finds it holding, or on the periodic check that does, gives up after its last poll, and
+// that a cancelled wait never fires
+func TestWaitUntil(t *testing.T) {
+	evtmgr := New()
+	fired := make(map[string]int64)
+	handler := func(evtmgr *EventManager, context any, data any) any {
+		fired[data.(string)] = evtmgr.CurrentTicks()
+		return nil
+	}
+	level := 0
+	now := evtmgr.WaitUntil(nil, "now", func() bool { return true }, handler, WaitPolicy{})
+	signalled := evtmgr.WaitUntil(nil, "signal", func() bool { return level >= 1 }, handler, WaitPolicy{})
+	polled := evtmgr.WaitUntil(nil, "poll", func() bool { return level >= 2 }, handler,
+		WaitPolicy{Poll: vrtime.CreateTime(10, 0)})
+	exhausted := evtmgr.WaitUntil(nil, "never", func() bool { return false }, handler,
+		WaitPolicy{Poll: vrtime.CreateTime(10, 0), MaxPolls: 3})
+	cancelled := evtmgr.WaitUntil(nil, "cancelled", func() bool { return level >= 1 }, handler, WaitPolicy{})
+	if !cancelled.Cancel() || cancelled.Cancel() {
+		t.Error("wait not cancelled exactly once")
+	}
+
+	evtmgr.Schedule(nil, nil, func(evtmgr *EventManager, context any, data any) any {
+		level = 1
+		evtmgr.Signal()
+		return nil
+	}, vrtime.CreateTime(5, 0))
+	evtmgr.Schedule(nil, nil, func(*EventManager, any, any) any {
+		level = 2 // not signalled, so seen by the next poll
+		return nil
+	}, vrtime.CreateTime(15, 0))
+	evtmgr.AdvanceTo(vrtime.CreateTime(100, 0))
+
+	want := map[string]int64{"now": 0, "signal": 5, "poll": 20}
+	if len(fired) != len(want) || fired["now"] != 0 || fired["signal"] != 5 || fired["poll"] != 20 {
+		t.Errorf("got waits fired at %v, want %v", fired, want)
+	}
+	for _, wt := range []*Waiter{now, signalled, polled} {
+		if wt.Active() || !wt.Fired() {
+			t.Errorf("wait for %v still active %v, fired %v", wt.data, wt.Active(), wt.Fired())
+		}
+	}
+	if exhausted.Active() || exhausted.Fired() || cancelled.Fired() {
+		t.Error("wait given up or cancelled reported as fired")
+	}
+	if n := evtmgr.EventList.Len(); n != 0 {
+		t.Errorf("%d events left pending, want no polls", n)
+	}
+}
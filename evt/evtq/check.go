@@ -0,0 +1,116 @@
+package evtq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetCheckInvariants selects a debugging mode in which the queue verifies its own
+// consistency after every mutation: that the heap of every lane is ordered, that every item
+// records its true position and lane, that the front list is in increasing time order, and that
+// the lookup map holds exactly the items in the queue.  On a violation it panics with a description of
+// what is wrong and a dump of the queue, so that corruption (e.g., by code that wraps or
+// extends the queue) is caught at the operation that caused it.  The checks cost time
+// proportional to the length of the queue, so the mode is off by default.
+func (p *EventQueue) SetCheckInvariants(on bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checks = on
+	if on {
+		p.checkInvariants("SetCheckInvariants")
+	}
+}
+
+// checkInvariants panics if the queue is inconsistent.  op names the operation just
+// completed, for the panic message.  Called with the queue lock held.
+func (p *EventQueue) checkInvariants(op string) {
+	var violations []string
+	report := func(format string, args ...any) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	held := len(p.front)
+	for lane := 0; lane <= len(p.lanes); lane++ {
+		ih := *p.heapOf(lane)
+		held += len(ih)
+		for idx, it := range ih {
+			if it.index != idx {
+				report("lane %d heap[%d] (id %d) records index %d", lane, idx, it.itemID, it.index)
+			}
+			if it.lane != lane {
+				report("lane %d heap[%d] (id %d) records lane %d", lane, idx, it.itemID, it.lane)
+			}
+			if idx > 0 {
+				parent := (idx - 1) / 2
+				if it.Time.LT(ih[parent].Time) {
+					report("lane %d heap[%d] (id %d) at %s precedes its parent heap[%d] (id %d) at %s",
+						lane, idx, it.itemID, it.Time.TimeStr(), parent, ih[parent].itemID, ih[parent].Time.TimeStr())
+				}
+			}
+			if p.lookup[it.itemID] != it {
+				report("lane %d heap[%d] (id %d) is not in the lookup map under its id", lane, idx, it.itemID)
+			}
+		}
+	}
+
+	for idx, it := range p.front {
+		if it.index != -1 || it.lane != 0 {
+			report("front[%d] (id %d) records index %d lane %d", idx, it.itemID, it.index, it.lane)
+		}
+		if idx > 0 && it.Time.LT(p.front[idx-1].Time) {
+			report("front[%d] (id %d) at %s precedes front[%d] at %s",
+				idx, it.itemID, it.Time.TimeStr(), idx-1, p.front[idx-1].Time.TimeStr())
+		}
+		if p.lookup[it.itemID] != it {
+			report("front[%d] (id %d) is not in the lookup map under its id", idx, it.itemID)
+		}
+	}
+
+	if len(p.lookup) != held {
+		report("lookup map holds %d items, heaps and front list %d", len(p.lookup), held)
+	}
+	for id, it := range p.lookup {
+		if it.itemID != id {
+			report("lookup map holds id %d under id %d", it.itemID, id)
+		}
+	}
+	spilled := 0
+	if p.spill != nil {
+		spilled = len(p.spill.where)
+		for id := range p.spill.where {
+			if _, present := p.lookup[id]; present {
+				report("id %d is both on disk and in memory", id)
+			}
+		}
+	}
+	if int(p.size.Load()) != held+spilled {
+		report("length is recorded as %d, heaps and front list hold %d, disk %d", p.size.Load(), held, spilled)
+	}
+	// the least element may lie on disk, which a check must not disturb by reading
+	if cached := p.minTime.Load(); cached != nil && spilled == 0 {
+		if least := p.peekMemory(); least == nil || least.Time.NEQ(*cached) {
+			report("cached minimum time %s is stale", cached.TimeStr())
+		}
+	}
+
+	if len(violations) > 0 {
+		panic(fmt.Sprintf("evtq: inconsistent after %s:\n  %s\n%s",
+			op, strings.Join(violations, "\n  "), p.dump()))
+	}
+}
+
+// dump describes the contents of the queue.  Called with the queue lock held.
+func (p *EventQueue) dump() string {
+	var sb strings.Builder
+	for lane := 0; lane <= len(p.lanes); lane++ {
+		fmt.Fprintf(&sb, "lane %d heap (%d items):\n", lane, p.heapOf(lane).Len())
+		for idx, it := range *p.heapOf(lane) {
+			fmt.Fprintf(&sb, "  [%d] id %d index %d time %s\n", idx, it.itemID, it.index, it.Time.TimeStr())
+		}
+	}
+	fmt.Fprintf(&sb, "front (%d items):\n", len(p.front))
+	for idx, it := range p.front {
+		fmt.Fprintf(&sb, "  [%d] id %d index %d time %s\n", idx, it.itemID, it.index, it.Time.TimeStr())
+	}
+	return sb.String()
+}
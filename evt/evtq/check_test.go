@@ -0,0 +1,53 @@
+package evtq
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestCheckInvariants checks that a consistent queue passes the checks through every kind of
+// mutation, and that a corrupted one panics at the next mutation, naming it
+func TestCheckInvariants(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	eq := New()
+	if err := eq.SetLaneOrder(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	eq.SetCheckInvariants(true)
+	var ids []int
+	for step := 0; step < 2000; step++ {
+		at := vrtime.CreateTime(rng.Int63n(1000), 0)
+		switch rng.Intn(6) {
+		case 0:
+			ids = append(ids, eq.Insert(step, at))
+		case 1:
+			ids = append(ids, eq.InsertFront(step, eq.MinTime()))
+		case 2:
+			ids = append(ids, eq.InsertInLane(step, at, 1))
+		case 3:
+			if len(ids) > 0 {
+				eq.UpdateTime(ids[rng.Intn(len(ids))], at)
+			}
+		case 4:
+			if len(ids) > 0 {
+				eq.Remove(ids[rng.Intn(len(ids))])
+			}
+		case 5:
+			eq.PopUpTo(rng.Int63n(1000))
+		}
+	}
+
+	ids = append(ids, eq.Insert(nil, vrtime.CreateTime(5, 0)))
+	eq.GetItem(ids[len(ids)-1]).(*item).index = 1 << 20
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "inconsistent after Insert") || !strings.Contains(msg, "records index 1048576") {
+			t.Errorf("corruption reported as %q", msg)
+		}
+	}()
+	eq.Insert(nil, vrtime.CreateTime(7, 0))
+	t.Error("corrupted queue passed the checks")
+}
@@ -0,0 +1,95 @@
+package evtq
+
+import (
+	"github.com/iti/evt/internal/slab"
+)
+
+// Clone returns an independent copy of the queue, holding copies of its items with the same
+// identifiers, times, and lanes, so that the copy and the original can go their separate
+// ways.  Each element is passed through copyValue, which returns the value to place in the
+// copy; a nil copyValue places the same value in both.  The copy has the same lane order,
+// slab size, and invariant checking as the original, and continues its numbering of
+// identifiers, in the same generation, so that identifiers held remain valid in both.
+// The elements of a spilling queue (see SetSpill) are first read back into memory, and the
+// copy does not spill.
+func (p *EventQueue) Clone(copyValue func(any) any) *EventQueue {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unspill()
+
+	q := &EventQueue{
+		evtID:   p.evtID,
+		gen:     p.gen,
+		lookup:  make(map[int]*item, len(p.lookup)),
+		maxTime: p.maxTime,
+		checks:  p.checks}
+	if p.laneRank != nil {
+		q.laneRank = append([]int(nil), p.laneRank...)
+	}
+	if p.slabs != nil {
+		q.slabs = slab.New[item](p.slabs.Size())
+	}
+
+	dup := func(it *item) *item {
+		copied := new(item)
+		*copied = *it
+		if copyValue != nil {
+			copied.Value = copyValue(it.Value)
+		}
+		q.lookup[copied.itemID] = copied
+		return copied
+	}
+	dupHeap := func(ih *itemHeapType) *itemHeapType {
+		copied := make(itemHeapType, len(*ih), cap(*ih))
+		for idx, it := range *ih {
+			copied[idx] = dup(it)
+		}
+		return &copied
+	}
+
+	q.itemHeap = dupHeap(p.itemHeap)
+	for _, ih := range p.lanes {
+		q.lanes = append(q.lanes, dupHeap(ih))
+	}
+	for _, it := range p.front {
+		q.front = append(q.front, dup(it))
+	}
+	q.size.Store(p.size.Load())
+	if cached := p.minTime.Load(); cached != nil {
+		least := *cached
+		q.minTime.Store(&least)
+	}
+	return q
+}
+
+// Restore replaces the contents of the queue with those of snapshot, a queue made by Clone,
+// e.g., to roll the queue back to an earlier state while keeping its identity.  snapshot
+// must not be used afterwards.
+func (p *EventQueue) Restore(snapshot *EventQueue) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	snapshot.mu.Lock()
+	defer snapshot.mu.Unlock()
+
+	p.evtID = snapshot.evtID
+	p.gen = snapshot.gen
+	p.itemHeap = snapshot.itemHeap
+	p.lanes = snapshot.lanes
+	p.laneRank = snapshot.laneRank
+	p.lookup = snapshot.lookup
+	p.front = snapshot.front
+	p.maxTime = snapshot.maxTime
+	p.size.Store(snapshot.size.Load())
+	p.minTime.Store(snapshot.minTime.Load())
+	if sp := p.spill; sp != nil {
+		// the elements on disk belong to the state being replaced
+		sp.discard()
+		sp.loaded = 1
+		if least := p.peekMemory(); least != nil {
+			sp.loaded = sp.bucketOf(least.Time.Ticks()) + 1
+		}
+	}
+	if p.checks {
+		p.checkInvariants("Restore")
+	}
+}
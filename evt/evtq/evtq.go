@@ -5,8 +5,11 @@ package evtq
 
 import (
 	"container/heap"
+	"errors"
 	"sync"
+	"sync/atomic"
 
+	"github.com/iti/evt/internal/slab"
 	"github.com/iti/evt/vrtime"
 )
 
@@ -16,46 +19,161 @@ const InvalidEventID = 0
 
 // EventQueue represents the queue
 type EventQueue struct {
-	evtID    int           // monotonically increasing counter used for default secondary time in event Time
-	itemHeap *itemHeapType // data structure holding items, see struct definition for item and itemHeapType
-	lookup   map[int]*item // event identifier to event, used for marking events to be ignored
-	MaxTime  vrtime.Time   // Largest vrtime.Time value pushed onto to the heap as yet
-	mu       sync.Mutex    // used to support thread safety
+	evtID    int                         // monotonically increasing counter used for default secondary time in event Time
+	gen      int                         // generation of the queue, carried by every identifier it returns; zero unless stamped
+	itemHeap *itemHeapType               // data structure holding items, see struct definition for item and itemHeapType
+	lanes    []*itemHeapType             // heaps of the lanes other than lane 0, which is itemHeap, indexed by lane-1
+	laneRank []int                       // position of each lane in the order lanes are drained at a tick, nil with one lane
+	lookup   map[int]*item               // event identifier to event, used for marking events to be ignored
+	front    []*item                     // items placed by InsertFront ahead of the heap, in increasing time order
+	size     atomic.Int64                // number of items in the queue, readable without the lock
+	minTime  atomic.Pointer[vrtime.Time] // time of the least item, readable without the lock; nil when not known
+	maxTime  vrtime.Time                 // Largest vrtime.Time value pushed onto to the heap as yet, see Bounds
+	mu       sync.Mutex                  // used to support thread safety
+	slabs    *slab.Slab[item]            // source of items when slab allocation is selected, otherwise nil
+	checks   bool                        // verify consistency after every mutation
+	spill    *spiller                    // spilling of far-future elements to disk, nil unless selected by SetSpill
 }
 
-// New is a constructor. Initializes an empty slice of events
-func New() *EventQueue {
+// New is a constructor. Initializes an empty slice of events.
+// An optional size hint gives the number of events the queue is expected to hold,
+// so that the slice and lookup map are allocated once at that size rather than
+// grown repeatedly as a model front-loads its events.
+func New(sizeHint ...int) *EventQueue {
+	capacity := 0
+	if len(sizeHint) > 0 && sizeHint[0] > 0 {
+		capacity = sizeHint[0]
+	}
+	itemHeap := make(itemHeapType, 0, capacity)
 	return &EventQueue{
-		evtID:    InvalidEventID,      // has to have an event id, so include an invalid one at initialization
-		itemHeap: &itemHeapType{},     // event list is initialized to be empty of events
-		lookup:   make(map[int]*item)} // map to support deletion of events is initially empty
+		evtID:    InvalidEventID,                // has to have an event id, so include an invalid one at initialization
+		itemHeap: &itemHeap,                     // event list is initialized to be empty of events
+		lookup:   make(map[int]*item, capacity)} // map to support deletion of events is initially empty
 }
 
 // Len returns the number of elements in the queue.
+// The count is maintained atomically, so Len does not need the queue's lock.
 func (p *EventQueue) Len() int {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	rtn := p.itemHeap.Len()
-	return rtn
+	return int(p.size.Load())
 }
 
 // MinTime returns the Time associated with the next event.
+// The result is cached, so the queue's lock is needed only for the first
+// call after a Pop, Remove, or UpdateTime has invalidated the cache.
+// MinTime panics if the queue is empty; TryMinTime does not.
 func (p *EventQueue) MinTime() vrtime.Time {
+	least, found := p.TryMinTime()
+	if !found {
+		panic("evtq: MinTime of an empty queue")
+	}
+	return least
+}
+
+// TryMinTime returns the Time associated with the next event, and false if the queue is
+// empty.  Unlike testing Len before calling MinTime, it is safe when other goroutines may
+// empty the queue in between.
+func (p *EventQueue) TryMinTime() (vrtime.Time, bool) {
+	if cached := p.minTime.Load(); cached != nil {
+		return *cached, true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	least := p.peekMin()
+	if least == nil {
+		return vrtime.Time{}, false
+	}
+	rtn := least.Time
+	p.minTime.Store(&rtn)
+	return rtn, true
+}
+
+// Bounds returns, as one consistent view, the least time of the elements in the queue, the
+// largest time of any element inserted as yet, and the number of elements.  The least time
+// is the zero Time if the queue is empty.
+func (p *EventQueue) Bounds() (min, max vrtime.Time, n int) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	rtn := (*p.itemHeap)[0].Time
-	return rtn
+	if least := p.peekMin(); least != nil {
+		min = least.Time
+	}
+	return min, p.maxTime, int(p.size.Load())
 }
 
 // Insert inserts a new element into the queue. No action is performed on duplicate elements.
 func (p *EventQueue) Insert(v any, time vrtime.Time) int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.checks {
+		defer p.checkInvariants("Insert")
+	}
+	if itemID, spilled := p.spillNew(v, time, 0); spilled {
+		return itemID
+	}
+	newItem := p.newItem(v, time)
+	heap.Push(p.itemHeap, newItem)
+	return newItem.itemID
+}
+
+// InsertFront inserts a new element the caller expects to be the next one popped.
+// When its time precedes every element in the heap (and follows every element
+// previously placed this way) it is appended to a short list that is consulted before
+// the heap, avoiding the cost of a heap insertion.  Otherwise it is inserted exactly as
+// Insert would.
+func (p *EventQueue) InsertFront(v any, time vrtime.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.checks {
+		defer p.checkInvariants("InsertFront")
+	}
+	if itemID, spilled := p.spillNew(v, time, 0); spilled {
+		return itemID
+	}
+	newItem := p.newItem(v, time)
+
+	fits := p.itemHeap.Len() == 0 || newItem.Time.LT((*p.itemHeap)[0].Time)
+	if fits && len(p.front) > 0 {
+		fits = p.front[len(p.front)-1].Time.LE(newItem.Time)
+	}
+	if !fits {
+		heap.Push(p.itemHeap, newItem)
+		return newItem.itemID
+	}
+	newItem.index = -1
+	p.front = append(p.front, newItem)
+	return newItem.itemID
+}
+
+// newItem creates the item for a value being inserted and enters it into
+// the lookup table.  Called with the queue lock held.
+func (p *EventQueue) newItem(v any, time vrtime.Time) *item {
+	itemID, time := p.stamp(time)
+
+	// create an item for insertion
+	var newItem *item
+	if p.slabs != nil {
+		newItem = p.slabs.Get()
+	} else {
+		newItem = new(item)
+	}
+	newItem.itemID = itemID // identifier for this event
+	newItem.Value = v       // notice that v can be anything, what matters for ordering is time value
+	newItem.Time = time
+
+	p.lookup[itemID] = newItem
+	p.size.Add(1)
+	p.noteInserted(time)
+	return newItem
+}
+
+// stamp draws the identifier of an element being inserted and settles its time.  The
+// default priority is the count of insertions alone, without the generation.
+// Called with the queue lock held.
+func (p *EventQueue) stamp(time vrtime.Time) (int, vrtime.Time) {
 	p.evtID++
 
 	// update maximum time of inserted event
-	if p.MaxTime.LT(time) {
-		p.MaxTime = time
+	if p.maxTime.LT(time) {
+		p.maxTime = time
 	}
 
 	// if the priority in the time stamp is -1
@@ -66,51 +184,287 @@ func (p *EventQueue) Insert(v any, time vrtime.Time) int {
 	if time.Pri() == -1 {
 		time.SetPri(int64(p.evtID))
 	}
+	return p.gen<<seqBits | p.evtID, time
+}
 
-	// create an item for insertion
-	newItem := &item{
-		itemID: p.evtID, // identifier for this event
-		Value:  v,       // notice that v can be anything, what matters for ordering is time value
-		Time:   time}
+// noteInserted keeps the cached least time up to date with the insertion of an element at
+// time.  Called with the queue lock held.
+func (p *EventQueue) noteInserted(time vrtime.Time) {
+	// the cached least time remains valid unless the new item precedes it.  With more than one
+	// lane the lane of the cached item is not known, so a new item at the same tick invalidates it
+	if cached := p.minTime.Load(); cached != nil {
+		if p.laneRank == nil && time.LT(*cached) || time.Ticks() < cached.Ticks() {
+			least := time
+			p.minTime.Store(&least)
+		} else if p.laneRank != nil && time.Ticks() == cached.Ticks() {
+			p.minTime.Store(nil)
+		}
+	}
+}
 
-	heap.Push(p.itemHeap, newItem)
-	p.lookup[p.evtID] = newItem
-	rtn := p.evtID
-	return rtn
+// SetLaneOrder divides the queue into len(order) lanes, numbered from 0, each held in its own
+// heap.  Elements inserted with Insert and InsertFront go to lane 0; InsertInLane places an
+// element in any lane.  The heaps are merged when the least element is sought: elements
+// are ordered first by tick count, then at equal tick counts by lane, the lanes being drained
+// in the sequence given by order, and only then by priority.  This isolates the ordering
+// of, say, control events from the priorities chosen for model events.  order must be a
+// permutation of the lane numbers.  An error is returned, and the queue unchanged, if it is
+// not, or if it would drop a lane that holds elements.
+func (p *EventQueue) SetLaneOrder(order ...int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(order) == 0 {
+		return errors.New("evtq: lane order is empty")
+	}
+	rank := make([]int, len(order))
+	seen := make([]bool, len(order))
+	for idx, lane := range order {
+		if lane < 0 || lane >= len(order) || seen[lane] {
+			return errors.New("evtq: lane order is not a permutation of the lane numbers")
+		}
+		seen[lane] = true
+		rank[lane] = idx
+	}
+	for idx := len(order) - 1; idx < len(p.lanes); idx++ {
+		if p.lanes[idx].Len() > 0 {
+			return errors.New("evtq: lane order drops a lane that holds elements")
+		}
+	}
+
+	for len(p.lanes) < len(order)-1 {
+		laneHeap := make(itemHeapType, 0)
+		p.lanes = append(p.lanes, &laneHeap)
+	}
+	p.lanes = p.lanes[:len(order)-1]
+	if len(order) == 1 {
+		p.laneRank = nil
+	} else {
+		p.laneRank = rank
+	}
+	p.minTime.Store(nil)
+	return nil
+}
+
+// Lanes returns the number of lanes the queue is divided into.
+func (p *EventQueue) Lanes() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.lanes) + 1
+}
+
+// InsertInLane inserts a new element into the given lane of the queue (see SetLaneOrder),
+// returning its identifier.  Identifiers are drawn from a single sequence across all lanes.
+// InvalidEventID is returned, and nothing inserted, if there is no such lane.
+func (p *EventQueue) InsertInLane(v any, time vrtime.Time, lane int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if lane < 0 || lane > len(p.lanes) {
+		return InvalidEventID
+	}
+	if p.checks {
+		defer p.checkInvariants("InsertInLane")
+	}
+	if itemID, spilled := p.spillNew(v, time, lane); spilled {
+		return itemID
+	}
+	newItem := p.newItem(v, time)
+	newItem.lane = lane
+	heap.Push(p.heapOf(lane), newItem)
+	return newItem.itemID
+}
+
+// heapOf returns the heap holding the given lane.  Called with the queue lock held.
+func (p *EventQueue) heapOf(lane int) *itemHeapType {
+	if lane == 0 {
+		return p.itemHeap
+	}
+	return p.lanes[lane-1]
+}
+
+// precedes reports whether item a is ordered ahead of item b, which may lie in different
+// lanes.  Called with the queue lock held.
+func (p *EventQueue) precedes(a, b *item) bool {
+	if p.laneRank == nil || a.lane == b.lane || a.Time.Ticks() != b.Time.Ticks() {
+		return a.Time.LT(b.Time)
+	}
+	return p.laneRank[a.lane] < p.laneRank[b.lane]
+}
+
+// SetSlabSize selects how the queue allocates the items that hold its elements.
+// When size is positive items are carved, size at a time, out of preallocated slabs,
+// which cuts the work of the garbage collector in simulations that push a high volume
+// of short-lived events through the queue.  A size of zero (the default) allocates
+// each item individually.
+func (p *EventQueue) SetSlabSize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if size <= 0 {
+		p.slabs = nil
+		return
+	}
+	p.slabs = slab.New[item](size)
+}
+
+// ReleaseSlabs frees the slabs items have been carved from, wholesale, e.g., at the end of
+// a run or of a window of simulation time.  If the queue is empty the slabs are kept and
+// carved again from the start; a value returned earlier by GetItem must not be used after
+// that.  Otherwise the slabs are dropped, their memory being reclaimed by the collector
+// once the items still pending in them have been removed.
+func (p *EventQueue) ReleaseSlabs() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.slabs != nil {
+		p.slabs.Release(p.size.Load() == 0)
+	}
+}
+
+// frontFirst reports whether the least element of the queue is at the head of
+// the front list rather than at the top of the heap.  Called with the queue lock held.
+func (p *EventQueue) frontFirst() bool {
+	if len(p.front) == 0 {
+		return false
+	}
+	return p.itemHeap.Len() == 0 || p.front[0].Time.LT((*p.itemHeap)[0].Time)
+}
+
+// peekMin returns the element with the least time, or nil if the queue
+// is empty.  Called with the queue lock held.
+func (p *EventQueue) peekMin() *item {
+	p.refill()
+	return p.peekMemory()
+}
+
+// peekMemory returns the element held in memory with the least time, or nil if there is
+// none.  Called with the queue lock held.
+func (p *EventQueue) peekMemory() *item {
+	var least *item
+	if p.frontFirst() {
+		least = p.front[0]
+	} else if p.itemHeap.Len() > 0 {
+		least = (*p.itemHeap)[0]
+	}
+	for _, laneHeap := range p.lanes {
+		if laneHeap.Len() > 0 && (least == nil || p.precedes((*laneHeap)[0], least)) {
+			least = (*laneHeap)[0]
+		}
+	}
+	return least
+}
+
+// popMin removes the element with the least time from a non-empty queue
+// and returns it.  Called with the queue lock held.
+func (p *EventQueue) popMin() *item {
+	popped := p.peekMin()
+	if popped.index < 0 {
+		p.front[0] = nil
+		p.front = p.front[1:]
+	} else {
+		heap.Pop(p.heapOf(popped.lane))
+	}
+	delete(p.lookup, popped.itemID)
+	p.size.Add(-1)
+	p.minTime.Store(nil)
+	return popped
+}
+
+// removeFront takes the given item out of the front list.  Called with the queue lock held.
+func (p *EventQueue) removeFront(it *item) {
+	for idx, fit := range p.front {
+		if fit == it {
+			p.front = append(p.front[:idx], p.front[idx+1:]...)
+			return
+		}
+	}
 }
 
 // Pop removes the element with the least time from the queue and returns it.
-// In case of an empty queue, an error is returned.
+// Pop panics if the queue is empty; TryPop does not.
 func (p *EventQueue) Pop() any {
+	value, _, found := p.TryPop()
+	if !found {
+		panic("evtq: Pop of an empty queue")
+	}
+	return value
+}
+
+// TryPop removes the element with the least time from the queue and returns it along with
+// its time.  The last return value is false, and the queue unchanged, if the queue is empty.
+func (p *EventQueue) TryPop() (any, vrtime.Time, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.checks {
+		defer p.checkInvariants("TryPop")
+	}
+	if p.peekMin() == nil {
+		return nil, vrtime.Time{}, false
+	}
+	popped := p.popMin()
+	return popped.Value, popped.Time, true
+}
 
-	popped := heap.Pop(p.itemHeap).(*item)
-	delete(p.lookup, popped.itemID)
-	rtn := popped.Value
-	return rtn
+// PopUpTo removes the element with the least time from the queue and returns it
+// along with its time, provided the tick count of that time does not exceed limit.
+// The last return value is false, and the queue unchanged, if the queue is empty
+// or its least element lies beyond limit.  The test and the removal are made under
+// a single acquisition of the queue's lock.
+func (p *EventQueue) PopUpTo(limit int64) (any, vrtime.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.checks {
+		defer p.checkInvariants("PopUpTo")
+	}
+
+	least := p.peekMin()
+	if least == nil || least.Time.Ticks() > limit {
+		return nil, vrtime.Time{}, false
+	}
+	popped := p.popMin()
+	return popped.Value, popped.Time, true
 }
 
 // UpdateTime changes the priority of a given item.
-// If the specified item is not present in the queue, no action is performed.
+// If the specified item is not present in the queue, or the identifier is stale (see Owns),
+// no action is performed.
 func (p *EventQueue) UpdateTime(evtID int, newTime vrtime.Time) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if !p.owns(evtID) {
+		return
+	}
+	if p.checks {
+		defer p.checkInvariants("UpdateTime")
+	}
 	item, present := p.lookup[evtID]
-
 	if !present {
+		item = p.fetch(evtID)
+	}
+	if item == nil {
 		return
 	}
 
 	item.Time = newTime
-	heap.Fix(p.itemHeap, item.index)
+	p.minTime.Store(nil)
+
+	// an item moved off the front list is placed in the heap, whatever its new time
+	if item.index < 0 {
+		p.removeFront(item)
+		heap.Push(p.itemHeap, item)
+		return
+	}
+	heap.Fix(p.heapOf(item.lane), item.index)
 }
 
 func (p *EventQueue) GetItem(evtID int) any {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if !p.owns(evtID) {
+		return nil
+	}
 	_, present := p.lookup[evtID]
 	if !present {
+		if fetched := p.fetch(evtID); fetched != nil {
+			return fetched
+		}
 		return nil
 	}
 	return p.lookup[evtID]
@@ -119,29 +473,47 @@ func (p *EventQueue) GetItem(evtID int) any {
 func (p *EventQueue) GetValue(evtID int) any {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if !p.owns(evtID) {
+		return nil
+	}
 	_, present := p.lookup[evtID]
 	if !present {
+		if fetched := p.fetch(evtID); fetched != nil {
+			return fetched.Value
+		}
 		return nil
 	}
 	return p.lookup[evtID].Value
 }
 
-// Remove an element. Returns true on success.
+// Remove an element. Returns true on success, and false if it is not present or the
+// identifier is stale (see Owns).
 func (p *EventQueue) Remove(evtID int) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if !p.owns(evtID) {
+		return false
+	}
+	if p.checks {
+		defer p.checkInvariants("Remove")
+	}
 	element, present := p.lookup[evtID]
 	if !present {
-		return false
+		return p.dropSpilled(evtID)
 	}
 
-	// we're going to push the element to be rid of to the top
-	element.Time = vrtime.ZeroTime()
-	heap.Fix(p.itemHeap, element.index)
+	p.size.Add(-1)
+	p.minTime.Store(nil)
+	if element.index < 0 {
+		p.removeFront(element)
+		delete(p.lookup, evtID)
+		return true
+	}
 
-	// pop it off
-	popped := heap.Pop(p.itemHeap).(*item)
-	delete(p.lookup, popped.itemID)
+	// take the element out of the heap from wherever it sits.  (Moving it to the top by
+	// giving it ZeroTime and popping is not safe, as priorities may be negative.)
+	heap.Remove(p.heapOf(element.lane), element.index)
+	delete(p.lookup, evtID)
 	return true
 }
 
@@ -153,7 +525,8 @@ type item struct {
 	itemID int         // unique identifier with every event inserted into the queue
 	Value  any         // completely general payload for the item
 	Time   vrtime.Time // the field used to order the elements
-	index  int         // the position of the item in the (heap-organized) slice of events
+	index  int         // the position of the item in the (heap-organized) slice of events, -1 on the front list
+	lane   int         // the lane holding the item, see SetLaneOrder
 	Cancel bool        // has been marked for removal
 }
 
@@ -0,0 +1,271 @@
+package evtq
+
+import (
+	"container/heap"
+	"math/rand"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// baselineHeap is the binary heap of the original EventQueue, ordering its elements by Time
+// alone, against which the order of the queue is checked
+type baselineHeap []*baselineItem
+
+type baselineItem struct {
+	id    int
+	time  vrtime.Time
+	index int
+}
+
+func (bh baselineHeap) Len() int           { return len(bh) }
+func (bh baselineHeap) Less(i, j int) bool { return bh[i].time.LT(bh[j].time) }
+func (bh baselineHeap) Swap(i, j int) {
+	bh[i], bh[j] = bh[j], bh[i]
+	bh[i].index, bh[j].index = i, j
+}
+func (bh *baselineHeap) Push(x any) {
+	it := x.(*baselineItem)
+	it.index = len(*bh)
+	*bh = append(*bh, it)
+}
+func (bh *baselineHeap) Pop() any {
+	old := *bh
+	it := old[len(old)-1]
+	*bh = old[:len(old)-1]
+	return it
+}
+
+// TestOrderMatchesBaseline drives the queue and the baseline heap through the same random
+// mix of insertions, insertions at the front, time updates, removals, and bounded pops, and
+// checks that the queue gives up its elements in the same order
+func TestOrderMatchesBaseline(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		q := New()
+		q.SetCheckInvariants(true)
+		var base baselineHeap
+		live := make(map[int]*baselineItem)
+		var ids []int
+		var now int64
+
+		// times are unique, by an explicit priority or that of the insertion, so that the
+		// order of the baseline is fully defined
+		used := make(map[vrtime.Time]bool)
+		draw := func(least int64) vrtime.Time {
+			for {
+				tm := vrtime.CreateTime(least+rng.Int63n(50), -1)
+				if rng.Intn(2) == 0 {
+					tm = vrtime.CreateTime(tm.Ticks(), rng.Int63n(1000)-500)
+				}
+				if tm.Pri() == -1 || !used[tm] {
+					used[tm] = true
+					return tm
+				}
+			}
+		}
+		insert := func(front bool) {
+			tm := draw(now)
+			var evtID int
+			if front {
+				evtID = q.InsertFront(nil, tm)
+			} else {
+				evtID = q.Insert(nil, tm)
+			}
+			if tm.Pri() == -1 {
+				tm = q.GetItem(evtID).(*item).Time
+			}
+			it := &baselineItem{id: evtID, time: tm}
+			heap.Push(&base, it)
+			live[evtID] = it
+			ids = append(ids, evtID)
+		}
+
+		for op := 0; op < 5000; op++ {
+			switch r := rng.Intn(10); {
+			case r < 4:
+				insert(r == 0)
+			case r < 5 && len(ids) > 0:
+				evtID := ids[rng.Intn(len(ids))]
+				it, present := live[evtID]
+				tm := draw(now)
+				if tm.Pri() == -1 {
+					tm = vrtime.CreateTime(tm.Ticks(), int64(1000+op))
+				}
+				q.UpdateTime(evtID, tm)
+				if present {
+					it.time = tm
+					heap.Fix(&base, it.index)
+				}
+			case r < 6 && len(ids) > 0:
+				evtID := ids[rng.Intn(len(ids))]
+				it, present := live[evtID]
+				if q.Remove(evtID) != present {
+					t.Fatalf("seed %d: Remove(%d) disagrees with the baseline", seed, evtID)
+				}
+				if present {
+					heap.Remove(&base, it.index)
+					delete(live, evtID)
+				}
+			default:
+				limit := now + rng.Int63n(20)
+				value, tm, found := q.PopUpTo(limit)
+				want := base.Len() > 0 && base[0].time.Ticks() <= limit
+				if found != want {
+					t.Fatalf("seed %d op %d: PopUpTo(%d) found %v, baseline %v", seed, op, limit, found, want)
+				}
+				if !found {
+					continue
+				}
+				it := heap.Pop(&base).(*baselineItem)
+				delete(live, it.id)
+				if value != nil || !tm.EQ(it.time) {
+					t.Fatalf("seed %d op %d: popped time %v, baseline %v", seed, op, tm, it.time)
+				}
+				now = tm.Ticks()
+			}
+			if q.Len() != base.Len() {
+				t.Fatalf("seed %d op %d: length %d, baseline %d", seed, op, q.Len(), base.Len())
+			}
+		}
+	}
+}
+
+// holdQueue fills a queue for the hold model with pending elements
+func holdQueue(pending int) (*EventQueue, *rand.Rand) {
+	rng := rand.New(rand.NewSource(1))
+	q := New(pending)
+	for idx := 0; idx < pending; idx++ {
+		q.Insert(nil, vrtime.CreateTime(rng.Int63n(1000), -1))
+	}
+	return q, rng
+}
+
+// BenchmarkHoldPopUpTo runs the hold model on the queue the way the dispatch loop now takes
+// an element: PopUpTo tests the limit and removes the least element under one acquisition of
+// the queue lock
+func BenchmarkHoldPopUpTo(b *testing.B) {
+	q, rng := holdQueue(1000)
+	limit := vrtime.InfinityTime().Ticks()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, tm, _ := q.PopUpTo(limit)
+		q.Insert(nil, vrtime.CreateTime(tm.Ticks()+rng.Int63n(1000), -1))
+	}
+}
+
+// BenchmarkHoldLenMinTimePop runs the hold model on the queue the way the dispatch loop took
+// an element before: Len and MinTime test whether one is due, and Pop then removes it, each
+// acquiring the queue lock
+func BenchmarkHoldLenMinTimePop(b *testing.B) {
+	q, rng := holdQueue(1000)
+	limit := vrtime.InfinityTime().Ticks()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if q.Len() == 0 || q.MinTime().Ticks() > limit {
+			b.Fatal("hold model ran out of events")
+		}
+		tm := q.MinTime()
+		q.Pop()
+		q.Insert(nil, vrtime.CreateTime(tm.Ticks()+rng.Int63n(1000), -1))
+	}
+}
+
+// TestCachedMinTime checks the cached least time against the one found under the lock after
+// every kind of change to the queue, with one lane and with several
+func TestCachedMinTime(t *testing.T) {
+	for _, lanes := range [][]int{nil, {2, 0, 1}} {
+		rng := rand.New(rand.NewSource(3))
+		q := New()
+		if lanes != nil {
+			if err := q.SetLaneOrder(lanes...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var ids []int
+		for op := 0; op < 3000; op++ {
+			tm := vrtime.CreateTime(rng.Int63n(100), rng.Int63n(10))
+			switch r := rng.Intn(6); {
+			case r < 2:
+				ids = append(ids, q.InsertInLane(op, tm, rng.Intn(len(lanes)+1)))
+			case r < 3:
+				ids = append(ids, q.InsertFront(op, tm))
+			case r < 4 && len(ids) > 0:
+				q.UpdateTime(ids[rng.Intn(len(ids))], tm)
+			case r < 5 && len(ids) > 0:
+				q.Remove(ids[rng.Intn(len(ids))])
+			default:
+				q.TryPop()
+			}
+
+			least, found := q.TryMinTime()
+			want, _, n := q.Bounds()
+			if found != (n > 0) || q.Len() != n || (found && !least.EQ(want)) {
+				t.Fatalf("lanes %v op %d: cached least time %v, %v and length %d; under the lock %v and %d",
+					lanes, op, least, found, q.Len(), want, n)
+			}
+		}
+	}
+}
+
+// TestSizeHint checks that a queue given a size hint takes that many elements without
+// growing its heap, and that one given none, or a negative one, still works
+func TestSizeHint(t *testing.T) {
+	q := New(1000)
+	backing := cap(*q.itemHeap)
+	if backing < 1000 {
+		t.Fatalf("heap of a queue hinted 1000 elements has capacity %d", backing)
+	}
+	for idx := 0; idx < 1000; idx++ {
+		q.Insert(idx, vrtime.CreateTime(int64(1000-idx), 0))
+	}
+	if cap(*q.itemHeap) != backing {
+		t.Errorf("heap grew from %d to %d while filled to its hint", backing, cap(*q.itemHeap))
+	}
+
+	for _, q := range []*EventQueue{New(), New(-5)} {
+		q.Insert("a", vrtime.CreateTime(2, 0))
+		q.Insert("b", vrtime.CreateTime(1, 0))
+		if value, _, _ := q.TryPop(); value != "b" {
+			t.Errorf("queue without a hint popped %v first", value)
+		}
+	}
+}
+
+// TestTryPop checks that TryPop and TryMinTime report an empty queue rather than panic, and
+// otherwise return the least element and its time
+func TestTryPop(t *testing.T) {
+	q := New()
+	if _, _, found := q.TryPop(); found {
+		t.Error("TryPop found an element in an empty queue")
+	}
+	if _, found := q.TryMinTime(); found {
+		t.Error("TryMinTime found an element in an empty queue")
+	}
+	q.Insert("b", vrtime.CreateTime(7, 0))
+	q.Insert("a", vrtime.CreateTime(3, 0))
+	if least, found := q.TryMinTime(); !found || least.Ticks() != 3 {
+		t.Errorf("got least time %d, %v, want 3, true", least.Ticks(), found)
+	}
+	value, tm, found := q.TryPop()
+	if !found || value != "a" || tm.Ticks() != 3 || q.Len() != 1 {
+		t.Errorf("got %v at %d, %v with %d left, want a at 3 with 1 left", value, tm.Ticks(), found, q.Len())
+	}
+}
+
+// TestBounds checks that Bounds reports the least time pending and the largest time ever
+// inserted, which removing the element that held it does not lower
+func TestBounds(t *testing.T) {
+	q := New()
+	if min, max, n := q.Bounds(); !min.EQ(vrtime.Time{}) || !max.EQ(vrtime.Time{}) || n != 0 {
+		t.Errorf("got bounds %v, %v, %d of an empty queue, want zero", min, max, n)
+	}
+	q.Insert(nil, vrtime.CreateTime(5, 0))
+	latest := q.Insert(nil, vrtime.CreateTime(9, 0))
+	q.Insert(nil, vrtime.CreateTime(2, 0))
+	q.Remove(latest)
+	min, max, n := q.Bounds()
+	if min.Ticks() != 2 || max.Ticks() != 9 || n != 2 {
+		t.Errorf("got bounds %d, %d, %d, want 2, 9, 2", min.Ticks(), max.Ticks(), n)
+	}
+}
@@ -0,0 +1,73 @@
+package evtq
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// A queue may stamp the identifiers returned by Insert with a generation, drawn afresh for
+// the queue, combined with the count of elements inserted into it so far.  An identifier
+// kept from a previous run, or obtained from another stamped queue, then never aliases an
+// element of this one: UpdateTime, GetItem, GetValue, and Remove reject it as stale.
+// Stamping is selected with SetGenerationStamp.  By default a queue is of generation zero and
+// numbers its elements 1, 2, 3, ..., as the Python port does.
+
+// seqBits is the number of low-order bits of an identifier holding the count of insertions:
+// 40 where int has 64 bits, and 20 where it has 32
+const seqBits = (32 << (^uint(0) >> 63)) * 5 / 8
+
+// maxGeneration is the largest generation; generations wrap around to 1 beyond it
+const maxGeneration = 1<<((32<<(^uint(0)>>63))-1-seqBits) - 1
+
+// generations counts the generations drawn
+var generations atomic.Int64
+
+// nextGeneration draws the generation of a queue that stamps its identifiers, never zero
+func nextGeneration() int {
+	return int((generations.Add(1)-1)%maxGeneration) + 1
+}
+
+// SetGenerationStamp selects whether the identifiers the queue returns carry a generation
+// of their own, so that stale identifiers are detected, or generation zero.  It must be called
+// before the first insertion into the queue, and returns an error, changing nothing,
+// otherwise.
+func (p *EventQueue) SetGenerationStamp(stamp bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.evtID != InvalidEventID {
+		return errors.New("evtq: generation stamp selected after the first insertion")
+	}
+	switch {
+	case !stamp:
+		p.gen = 0
+	case p.gen == 0:
+		p.gen = nextGeneration()
+	}
+	return nil
+}
+
+// Generation returns the generation of the queue, which every identifier it returns carries
+func (p *EventQueue) Generation() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.gen
+}
+
+// GenerationOf returns the generation of the queue that returned the identifier evtID
+func GenerationOf(evtID int) int {
+	return evtID >> seqBits
+}
+
+// Owns reports whether evtID was returned by this queue, or by a copy sharing its numbering
+// (see Clone), rather than being stale.  The element it identifies may since have been
+// removed.  A queue of generation zero cannot tell its identifiers from those of another.
+func (p *EventQueue) Owns(evtID int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.owns(evtID)
+}
+
+// owns is Owns, called with the queue lock held
+func (p *EventQueue) owns(evtID int) bool {
+	return evtID>>seqBits == p.gen && evtID&(1<<seqBits-1) != 0
+}
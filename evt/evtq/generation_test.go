@@ -0,0 +1,67 @@
+package evtq
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// stampedQueue returns a new queue stamping its identifiers with a generation
+func stampedQueue(t *testing.T) *EventQueue {
+	t.Helper()
+	q := New()
+	if err := q.SetGenerationStamp(true); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// TestStaleIDRejected checks that an identifier kept from a discarded queue does not alias
+// the element a new queue numbers the same way
+func TestStaleIDRejected(t *testing.T) {
+	old := stampedQueue(t)
+	stale := old.Insert("old", vrtime.CreateTime(5, 0))
+
+	reused := stampedQueue(t)
+	current := reused.Insert("new", vrtime.CreateTime(5, 0))
+	if count := current - reused.Generation()<<seqBits; count != stale-old.Generation()<<seqBits {
+		t.Fatalf("queues count insertions differently: %d and %d", stale, current)
+	}
+	if stale == current || reused.Owns(stale) {
+		t.Fatalf("identifier %d of a discarded queue is taken as one of the new queue", stale)
+	}
+
+	if reused.GetItem(stale) != nil || reused.GetValue(stale) != nil {
+		t.Error("GetItem or GetValue found an element by a stale identifier")
+	}
+	reused.UpdateTime(stale, vrtime.CreateTime(1, 0))
+	if ticks := reused.MinTime().Ticks(); ticks != 5 {
+		t.Errorf("UpdateTime by a stale identifier moved the element to %d", ticks)
+	}
+	if reused.Remove(stale) || reused.Len() != 1 {
+		t.Error("Remove took an element by a stale identifier")
+	}
+	if !reused.Remove(current) || reused.Len() != 0 {
+		t.Error("Remove did not take the element by its own identifier")
+	}
+}
+
+// TestDefaultGenerationPlain checks that every queue not stamped numbers its elements by the
+// count of insertions alone, as the Python port does, and that stamping cannot be selected
+// once identifiers have been returned
+func TestDefaultGenerationPlain(t *testing.T) {
+	for queue := 0; queue < 2; queue++ {
+		q := New()
+		for want := 1; want <= 3; want++ {
+			if evtID := q.Insert(want, vrtime.CreateTime(int64(want), 0)); evtID != want {
+				t.Fatalf("insertion %d into queue %d has identifier %d", want, queue, evtID)
+			}
+		}
+		if q.SetGenerationStamp(true) == nil || q.Generation() != 0 {
+			t.Errorf("queue %d stamped after its first insertion", queue)
+		}
+	}
+	if stampedQueue(t).Generation() == 0 {
+		t.Error("stamped queue has generation zero")
+	}
+}
@@ -0,0 +1,30 @@
+package evtq
+
+// Histogram counts the elements of the queue by bucket of time, bucket b holding those whose
+// tick count lies in [b*width, (b+1)*width).  An element spilled to disk (see SetSpill) is
+// counted at the start of its spill bucket, which is exact when width is a multiple of the
+// spill width.
+func (p *EventQueue) Histogram(width int64) map[int64]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[int64]int)
+	if width <= 0 {
+		return counts
+	}
+	bucketOf := func(ticks int64) int64 {
+		bucket := ticks / width
+		if ticks < 0 && ticks%width != 0 {
+			bucket -= 1
+		}
+		return bucket
+	}
+	for _, it := range p.lookup {
+		counts[bucketOf(it.Time.Ticks())] += 1
+	}
+	if sp := p.spill; sp != nil {
+		for _, bucket := range sp.where {
+			counts[bucketOf(bucket*sp.width)] += 1
+		}
+	}
+	return counts
+}
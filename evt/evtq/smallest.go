@@ -0,0 +1,97 @@
+package evtq
+
+import (
+	"container/heap"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// Pending describes an element of the queue without removing it
+type Pending struct {
+	ItemID int         // identifier returned when the element was inserted
+	Time   vrtime.Time // time of the element
+	Value  any         // the element
+}
+
+// Smallest returns the k earliest elements of the queue, in the order they would be popped,
+// leaving the queue unchanged.  Fewer are returned if the queue holds fewer.  Only the parts
+// of the heaps holding the elements returned are visited, so the cost grows with k log k
+// rather than with the size of the queue.
+func (p *EventQueue) Smallest(k int) []Pending {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k <= 0 {
+		return nil
+	}
+	for {
+		found := p.smallestInMemory(k)
+		sp := p.spill
+		if sp == nil || len(sp.where) == 0 {
+			return found
+		}
+		// elements on disk may precede the last found; read the next bucket and look again
+		if len(found) == k && sp.bucketOf(found[k-1].Time.Ticks()) < sp.loaded {
+			return found
+		}
+		p.load()
+	}
+}
+
+// candidate is an element that may be the next smallest: the first of the front list not yet
+// taken, or one whose parent in its heap has been taken
+type candidate struct {
+	it   *item
+	heap *itemHeapType // heap holding it, nil for the front list
+	pos  int           // its position in the heap or front list
+}
+
+// candidates orders the candidates as the queue orders its elements
+type candidates struct {
+	queue *EventQueue
+	cands []candidate
+}
+
+func (cs *candidates) Len() int { return len(cs.cands) }
+func (cs *candidates) Less(i, j int) bool {
+	return cs.queue.precedes(cs.cands[i].it, cs.cands[j].it)
+}
+func (cs *candidates) Swap(i, j int) { cs.cands[i], cs.cands[j] = cs.cands[j], cs.cands[i] }
+func (cs *candidates) Push(x any)    { cs.cands = append(cs.cands, x.(candidate)) }
+func (cs *candidates) Pop() any {
+	last := cs.cands[len(cs.cands)-1]
+	cs.cands = cs.cands[:len(cs.cands)-1]
+	return last
+}
+
+// smallestInMemory returns the k earliest elements held in memory, by a best-first walk of
+// the heaps and front list.  Called with the queue lock held.
+func (p *EventQueue) smallestInMemory(k int) []Pending {
+	cs := &candidates{queue: p}
+	if len(p.front) > 0 {
+		cs.cands = append(cs.cands, candidate{it: p.front[0], pos: 0})
+	}
+	for _, ih := range append([]*itemHeapType{p.itemHeap}, p.lanes...) {
+		if ih.Len() > 0 {
+			cs.cands = append(cs.cands, candidate{it: (*ih)[0], heap: ih, pos: 0})
+		}
+	}
+	heap.Init(cs)
+
+	var found []Pending
+	for len(found) < k && cs.Len() > 0 {
+		next := heap.Pop(cs).(candidate)
+		found = append(found, Pending{ItemID: next.it.itemID, Time: next.it.Time, Value: next.it.Value})
+		if next.heap == nil {
+			if next.pos+1 < len(p.front) {
+				heap.Push(cs, candidate{it: p.front[next.pos+1], pos: next.pos + 1})
+			}
+			continue
+		}
+		for _, child := range []int{2*next.pos + 1, 2*next.pos + 2} {
+			if child < next.heap.Len() {
+				heap.Push(cs, candidate{it: (*next.heap)[child], heap: next.heap, pos: child})
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,48 @@
+package evtq
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestSmallest checks that Smallest returns the elements a copy of the queue pops first, in
+// the same order, across lanes and the front list, and leaves the queue unchanged
+func TestSmallest(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	q := New()
+	if err := q.SetLaneOrder(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	for idx := 0; idx < 500; idx++ {
+		tm := vrtime.CreateTime(rng.Int63n(50), int64(idx)) // distinct, so the order is unique
+		if rng.Intn(10) == 0 {
+			q.InsertFront(idx, tm)
+		} else {
+			q.InsertInLane(idx, tm, rng.Intn(2))
+		}
+	}
+
+	for _, k := range []int{0, 1, 37, 500, 600} {
+		want := k
+		if want > 500 {
+			want = 500
+		}
+		found := q.Smallest(k)
+		if len(found) != want {
+			t.Fatalf("Smallest(%d) returned %d elements, want %d", k, len(found), want)
+		}
+		popped := q.Clone(nil)
+		for idx, pending := range found {
+			value, tm, _ := popped.TryPop()
+			if value != pending.Value || !tm.EQ(pending.Time) {
+				t.Fatalf("Smallest(%d) element %d is %v at %v, popped %v at %v",
+					k, idx, pending.Value, pending.Time, value, tm)
+			}
+		}
+	}
+	if q.Len() != 500 {
+		t.Errorf("got %d elements after Smallest, want 500", q.Len())
+	}
+}
@@ -0,0 +1,459 @@
+package evtq
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// A model may hold more pending events than fit in memory, most of them far in the future.
+// A spilling queue keeps in memory only the elements of the near future.  Time is divided
+// into buckets of a fixed width, and an element inserted into a bucket that has not yet been
+// reached is encoded and appended to that bucket's file on disk instead of entering a heap.
+// When nothing in memory precedes the earliest bucket still on disk, that bucket is read back
+// whole into the heaps.  An element on disk that is asked for by identifier, through GetItem,
+// GetValue, or UpdateTime, is fetched back into memory on its own; one that is removed is
+// noted, and skipped when its bucket is read.
+
+// Codec encodes the elements of a spilling queue for storage on disk, and decodes them.
+// Decode is given the identifier the element was inserted under, which the element may carry
+// but cannot have been given before Encode was called.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(itemID int, data []byte) (any, error)
+}
+
+// maxSpillWriters is the number of bucket files kept open for writing at once
+const maxSpillWriters = 32
+
+// spillBuffer is the number of bytes of records held for a bucket before they are written
+const spillBuffer = 64 << 10
+
+// spiller holds the state of a spilling queue
+type spiller struct {
+	dir     string
+	width   int64                // ticks per bucket
+	codec   Codec                //
+	loaded  int64                // buckets below this one have been read back, or were never spilled to
+	where   map[int]int64        // bucket of each element on disk, by identifier
+	counts  map[int64]int        // number of elements on disk, by bucket
+	skip    map[int]int64        // bucket of each element removed or fetched from disk, to skip when reading
+	writers map[int64]*spillFile // files open for writing, by bucket
+	err     error                // first error writing to disk, after which nothing more is spilled
+}
+
+// spillFile is a bucket file open for writing.  Records are held in memory until enough have
+// gathered to write, and only whole records are ever counted as written, so a failed write
+// leaves nothing in the file that a reader would take for a record.
+type spillFile struct {
+	file    *os.File
+	written int64  // bytes of whole records in the file
+	pending []byte // whole records not yet written
+	failed  bool   // a failed write could not be undone, so the file is written no more
+}
+
+// SetSpill selects spilling of far-future elements to files in dir, in buckets width ticks
+// wide, encoded by codec.  The bucket holding the least element, and those before it, stay in
+// memory.  A width of zero or a nil codec switches spilling off, reading every element on disk
+// back into memory.  An element that codec cannot encode is kept in memory.
+func (p *EventQueue) SetSpill(dir string, width int64, codec Codec) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spill != nil {
+		p.unspill()
+		p.spill.discard()
+		p.spill = nil
+	}
+	if width <= 0 || codec == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	sp := &spiller{dir: dir, width: width, codec: codec, where: make(map[int]int64),
+		counts: make(map[int64]int), skip: make(map[int]int64), writers: make(map[int64]*spillFile)}
+	if least := p.peekMin(); least != nil {
+		sp.loaded = sp.bucketOf(least.Time.Ticks()) + 1
+	} else {
+		sp.loaded = 1
+	}
+	p.spill = sp
+	return nil
+}
+
+// Spilled returns the number of elements held on disk
+func (p *EventQueue) Spilled() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spill == nil {
+		return 0
+	}
+	return len(p.spill.where)
+}
+
+// SpillErr returns the first error met writing elements to disk, after which the queue has
+// kept every element in memory
+func (p *EventQueue) SpillErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spill == nil {
+		return nil
+	}
+	return p.spill.err
+}
+
+// bucketOf returns the bucket holding the tick count ticks
+func (sp *spiller) bucketOf(ticks int64) int64 {
+	bucket := ticks / sp.width
+	if ticks < 0 && ticks%sp.width != 0 {
+		bucket -= 1
+	}
+	return bucket
+}
+
+// path returns the name of the file of a bucket
+func (sp *spiller) path(bucket int64) string {
+	return filepath.Join(sp.dir, fmt.Sprintf("bucket-%020d.spill", bucket))
+}
+
+// spillNew inserts an element on disk, if it falls in a bucket not yet reached, returning its
+// identifier and true.  Otherwise, or if the element cannot be written, it returns false and
+// the queue is unchanged.  Called with the queue lock held.
+func (p *EventQueue) spillNew(v any, time vrtime.Time, lane int) (int, bool) {
+	sp := p.spill
+	if sp == nil || sp.err != nil {
+		return InvalidEventID, false
+	}
+	bucket := sp.bucketOf(time.Ticks())
+	if bucket < sp.loaded {
+		return InvalidEventID, false
+	}
+	payload, err := sp.codec.Encode(v)
+	if err != nil {
+		return InvalidEventID, false
+	}
+
+	itemID, time := p.stamp(time)
+	rec := binary.AppendUvarint(nil, uint64(itemID))
+	rec = binary.AppendVarint(rec, time.Ticks())
+	rec = binary.AppendVarint(rec, time.Pri())
+	rec = binary.AppendUvarint(rec, uint64(lane))
+	rec = binary.AppendUvarint(rec, uint64(len(payload)))
+	rec = append(rec, payload...)
+	if sp.err = sp.write(bucket, rec); sp.err != nil {
+		// the element is kept in memory under the identifier already drawn
+		p.evtID -= 1
+		return InvalidEventID, false
+	}
+	sp.where[itemID] = bucket
+	sp.counts[bucket] += 1
+	p.size.Add(1)
+	p.noteInserted(time)
+	return itemID, true
+}
+
+// write appends a record to the file of a bucket.  If it returns an error the record is
+// neither in the file nor held for it, and the records appended before are held if they could
+// not be written.
+func (sp *spiller) write(bucket int64, rec []byte) error {
+	sf, open := sp.writers[bucket]
+	if !open {
+		if len(sp.writers) >= maxSpillWriters {
+			if err := sp.closeWriters(); err != nil {
+				return err
+			}
+		}
+		file, err := os.OpenFile(sp.path(bucket), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		sf = &spillFile{file: file, written: info.Size()}
+		sp.writers[bucket] = sf
+	}
+	sf.pending = append(sf.pending, rec...)
+	if len(sf.pending) < spillBuffer {
+		return nil
+	}
+	if err := sf.flush(); err != nil {
+		sf.pending = sf.pending[:len(sf.pending)-len(rec)]
+		return err
+	}
+	return nil
+}
+
+// flush writes the records held for the file.  If the write fails they stay held, and the
+// part of them written, if any, is cut from the file.
+func (sf *spillFile) flush() error {
+	if len(sf.pending) == 0 {
+		return nil
+	}
+	if sf.failed {
+		return fmt.Errorf("earlier write to %s failed", sf.file.Name())
+	}
+	n, err := sf.file.Write(sf.pending)
+	if err != nil {
+		if n > 0 && sf.file.Truncate(sf.written) != nil {
+			sf.failed = true
+		}
+		return err
+	}
+	sf.written += int64(n)
+	sf.pending = sf.pending[:0]
+	return nil
+}
+
+// closeWriter flushes and closes the file of a bucket, if it is open for writing.  If the
+// records held for it cannot be written it stays open, holding them.
+func (sp *spiller) closeWriter(bucket int64) error {
+	sf, open := sp.writers[bucket]
+	if !open {
+		return nil
+	}
+	if err := sf.flush(); err != nil {
+		return err
+	}
+	delete(sp.writers, bucket)
+	return sf.file.Close()
+}
+
+// forget closes the file of a bucket, if it is open for writing, dropping the records held
+// for it, as when the file is to be removed
+func (sp *spiller) forget(bucket int64) {
+	if sf, open := sp.writers[bucket]; open {
+		sf.file.Close()
+		delete(sp.writers, bucket)
+	}
+}
+
+// closeWriters flushes and closes every file open for writing
+func (sp *spiller) closeWriters() error {
+	var err error
+	for bucket := range sp.writers {
+		if cerr := sp.closeWriter(bucket); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// discard closes and removes every file
+func (sp *spiller) discard() {
+	for bucket := range sp.writers {
+		sp.forget(bucket)
+	}
+	for bucket := range sp.counts {
+		os.Remove(sp.path(bucket))
+	}
+	sp.where, sp.counts, sp.skip = make(map[int]int64), make(map[int64]int), make(map[int]int64)
+}
+
+// spilledRecord is an element read back from disk
+type spilledRecord struct {
+	itemID int
+	time   vrtime.Time
+	lane   int
+	value  any
+}
+
+// read calls fn with each element of a bucket that has not been removed or fetched, until fn
+// returns false.  The elements are read from the whole records in its file, then from those
+// held for the file and not yet written.  The queue cannot go on without its elements, so a
+// file that cannot be read is fatal.
+func (sp *spiller) read(bucket int64, fn func(rec spilledRecord) bool) {
+	fail := func(err error) {
+		panic(fmt.Sprintf("evtq: reading spilled elements from %s: %v", sp.path(bucket), err))
+	}
+	file, err := os.Open(sp.path(bucket))
+	if err != nil {
+		fail(err)
+	}
+	defer file.Close()
+
+	var src io.Reader = file
+	if sf, open := sp.writers[bucket]; open {
+		src = io.MultiReader(io.LimitReader(file, sf.written), bytes.NewReader(sf.pending))
+	}
+	rd := bufio.NewReader(src)
+	for {
+		id, err := binary.ReadUvarint(rd)
+		if err == io.EOF {
+			return
+		}
+		var ticks, pri int64
+		var lane, size uint64
+		if err == nil {
+			ticks, err = binary.ReadVarint(rd)
+		}
+		if err == nil {
+			pri, err = binary.ReadVarint(rd)
+		}
+		if err == nil {
+			lane, err = binary.ReadUvarint(rd)
+		}
+		if err == nil {
+			size, err = binary.ReadUvarint(rd)
+		}
+		payload := make([]byte, size)
+		if err == nil {
+			_, err = io.ReadFull(rd, payload)
+		}
+		if err != nil {
+			fail(err)
+		}
+		if _, skipped := sp.skip[int(id)]; skipped {
+			continue
+		}
+		value, err := sp.codec.Decode(int(id), payload)
+		if err != nil {
+			fail(err)
+		}
+		if !fn(spilledRecord{itemID: int(id), time: vrtime.CreateTime(ticks, pri), lane: int(lane), value: value}) {
+			return
+		}
+	}
+}
+
+// admit places an element read back from disk into the heaps.  Called with the queue lock held.
+func (p *EventQueue) admit(rec spilledRecord) *item {
+	var it *item
+	if p.slabs != nil {
+		it = p.slabs.Get()
+	} else {
+		it = new(item)
+	}
+	it.itemID, it.Value, it.Time = rec.itemID, rec.value, rec.time
+	if rec.lane <= len(p.lanes) {
+		it.lane = rec.lane
+	}
+	p.lookup[it.itemID] = it
+	heap.Push(p.heapOf(it.lane), it)
+	delete(p.spill.where, it.itemID)
+	return it
+}
+
+// load reads the earliest bucket on disk back into memory.  Called with the queue lock held.
+func (p *EventQueue) load() {
+	sp := p.spill
+	first, found := int64(0), false
+	for bucket := range sp.counts {
+		if !found || bucket < first {
+			first, found = bucket, true
+		}
+	}
+	if !found {
+		return
+	}
+	admitted := 0
+	sp.read(first, func(rec spilledRecord) bool {
+		p.admit(rec)
+		admitted += 1
+		return true
+	})
+	if admitted != sp.counts[first] {
+		panic(fmt.Sprintf("evtq: %s holds %d elements, not %d", sp.path(first), admitted, sp.counts[first]))
+	}
+	sp.forget(first)
+	os.Remove(sp.path(first))
+	delete(sp.counts, first)
+	for id, bucket := range sp.skip {
+		if bucket == first {
+			delete(sp.skip, id)
+		}
+	}
+	sp.loaded = first + 1
+}
+
+// refill reads buckets back from disk while none of the elements in memory is certain to
+// precede every element on disk.  Called with the queue lock held.
+func (p *EventQueue) refill() {
+	sp := p.spill
+	for sp != nil && len(sp.where) > 0 {
+		least := p.peekMemory()
+		if least != nil && sp.bucketOf(least.Time.Ticks()) < sp.loaded {
+			return
+		}
+		p.load()
+	}
+}
+
+// unspill reads every element on disk back into memory.  Called with the queue lock held.
+func (p *EventQueue) unspill() {
+	for p.spill != nil && len(p.spill.where) > 0 {
+		p.load()
+	}
+}
+
+// fetch brings an element on disk back into memory on its own, returning it, or nil if no
+// element with the identifier is on disk.  Called with the queue lock held.
+func (p *EventQueue) fetch(evtID int) *item {
+	sp := p.spill
+	if sp == nil {
+		return nil
+	}
+	bucket, spilled := sp.where[evtID]
+	if !spilled {
+		return nil
+	}
+	var fetched *item
+	sp.read(bucket, func(rec spilledRecord) bool {
+		if rec.itemID != evtID {
+			return true
+		}
+		fetched = p.admit(rec)
+		return false
+	})
+	if fetched == nil {
+		panic(fmt.Sprintf("evtq: element %d is missing from %s", evtID, sp.path(bucket)))
+	}
+	sp.skip[evtID] = bucket
+	sp.drop(bucket)
+	return fetched
+}
+
+// dropSpilled removes an element on disk, returning false if no element with the
+// identifier is on disk.  Called with the queue lock held.
+func (p *EventQueue) dropSpilled(evtID int) bool {
+	sp := p.spill
+	if sp == nil {
+		return false
+	}
+	bucket, spilled := sp.where[evtID]
+	if !spilled {
+		return false
+	}
+	delete(sp.where, evtID)
+	sp.skip[evtID] = bucket
+	sp.drop(bucket)
+	p.size.Add(-1)
+	p.minTime.Store(nil)
+	return true
+}
+
+// drop counts an element of a bucket removed or fetched from disk, removing the bucket's
+// file once it holds no other element
+func (sp *spiller) drop(bucket int64) {
+	sp.counts[bucket] -= 1
+	if sp.counts[bucket] > 0 {
+		return
+	}
+	sp.forget(bucket)
+	os.Remove(sp.path(bucket))
+	delete(sp.counts, bucket)
+	for id, skipped := range sp.skip {
+		if skipped == bucket {
+			delete(sp.skip, id)
+		}
+	}
+}
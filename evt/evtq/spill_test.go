@@ -0,0 +1,152 @@
+package evtq
+
+import (
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// stringCodec spills elements that are strings, padded to pad bytes on disk
+type stringCodec struct {
+	pad int
+}
+
+func (sc stringCodec) Encode(v any) ([]byte, error) {
+	text := v.(string)
+	if len(text) < sc.pad {
+		text += strings.Repeat(" ", sc.pad-len(text))
+	}
+	return []byte(text), nil
+}
+
+func (sc stringCodec) Decode(itemID int, data []byte) (any, error) {
+	return strings.TrimRight(string(data), " "), nil
+}
+
+// spilledElement is an element inserted into a spilling queue, to check it is popped in order
+type spilledElement struct {
+	value string
+	time  vrtime.Time
+}
+
+// drainInOrder pops every element of q, checking they come out in the order of want
+func drainInOrder(t *testing.T, q *EventQueue, want []spilledElement) {
+	t.Helper()
+	sort.Slice(want, func(i, j int) bool { return want[i].time.LT(want[j].time) })
+	for idx, elem := range want {
+		value, tm, found := q.TryPop()
+		if !found {
+			t.Fatalf("queue empty after %d of %d elements", idx, len(want))
+		}
+		if value.(string) != elem.value || !tm.EQ(elem.time) {
+			t.Fatalf("element %d is %v at %v, want %v at %v", idx, value, tm, elem.value, elem.time)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("%d elements left after popping all those inserted", q.Len())
+	}
+}
+
+// TestSpillRoundTrip spills elements to several buckets, fetches and removes some of them
+// while they are on disk, and checks the rest come back in order
+func TestSpillRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	q := New()
+	if err := q.SetSpill(dir, 100, stringCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	var want []spilledElement
+	var ids []int
+	for idx := 0; idx < 500; idx++ {
+		ticks := rng.Int63n(2000)
+		value := strings.Repeat("x", idx%7) + string(rune('a'+idx%26))
+		evtID := q.Insert(value, vrtime.CreateTime(ticks, -1))
+		ids = append(ids, evtID)
+
+		// the priority given in place of -1 is the count of insertions
+		want = append(want, spilledElement{value: value, time: vrtime.CreateTime(ticks, int64(idx+1))})
+	}
+	spilled := q.Spilled()
+	if spilled < 400 {
+		t.Fatalf("%d of 500 elements spilled, want all those beyond the first bucket", spilled)
+	}
+
+	removed := 0
+	for idx := 0; idx < 500; idx += 5 {
+		if q.spill.where[ids[idx]] == 0 {
+			continue
+		}
+		switch idx % 10 {
+		case 0:
+			if !q.Remove(ids[idx]) {
+				t.Fatalf("Remove of element %d on disk failed", idx)
+			}
+			want[idx].value = ""
+			removed += 1
+		default:
+			moved := vrtime.CreateTime(want[idx].time.Ticks()+7, want[idx].time.Pri())
+			q.UpdateTime(ids[idx], moved)
+			want[idx].time = moved
+		}
+	}
+	if removed == 0 || q.Spilled() >= spilled-removed {
+		t.Fatalf("removed %d and fetched none of %d elements on disk", removed, spilled)
+	}
+	kept := want[:0]
+	for _, elem := range want {
+		if elem.value != "" {
+			kept = append(kept, elem)
+		}
+	}
+
+	drainInOrder(t, q, kept)
+	if err := q.SpillErr(); err != nil {
+		t.Error(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d bucket files left once the queue is empty", len(files))
+	}
+}
+
+// TestSpillWriteFailure checks that a failed write to a bucket file loses no element, whether
+// held for the file or being spilled, and leaves no part of a record for the reader
+func TestSpillWriteFailure(t *testing.T) {
+	q := New()
+	if err := q.SetSpill(t.TempDir(), 100, stringCodec{pad: 10000}); err != nil {
+		t.Fatal(err)
+	}
+	var want []spilledElement
+	insert := func(idx int) {
+		value := string(rune('a' + idx%26))
+		q.Insert(value, vrtime.CreateTime(int64(500+idx), 0))
+		want = append(want, spilledElement{value: value, time: vrtime.CreateTime(int64(500+idx), 0)})
+	}
+
+	// enough records to be written to the file, and more held for it
+	for idx := 0; idx < 10; idx++ {
+		insert(idx)
+	}
+	sf := q.spill.writers[q.spill.bucketOf(500)]
+	if sf == nil || sf.written == 0 || len(sf.pending) == 0 {
+		t.Fatal("records were not both written and held")
+	}
+
+	// the next write fails, and every element from then on stays in memory
+	sf.file.Close()
+	for idx := 10; idx < 20; idx++ {
+		insert(idx)
+	}
+	if q.SpillErr() == nil {
+		t.Fatal("failed write not reported")
+	}
+	if q.Spilled()+q.itemHeap.Len() != len(want) {
+		t.Fatalf("%d elements on disk and %d in memory, want %d", q.Spilled(), q.itemHeap.Len(), len(want))
+	}
+
+	drainInOrder(t, q, want)
+}
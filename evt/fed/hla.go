@@ -0,0 +1,153 @@
+package fed
+
+import (
+	"fmt"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// RTI is the part of an HLA RTI ambassador used by a Federate, to be implemented over the
+// binding of whichever RTI the federation runs.  Times are given in the ticks of package vrtime;
+// the implementation converts them to the federation's logical time representation.
+type RTI interface {
+	// TimeAdvanceRequest asks for a grant to advance to t, as for a time-stepped federate
+	TimeAdvanceRequest(t vrtime.Time) error
+
+	// NextMessageRequest asks for a grant to advance to the earlier of t and the time of the
+	// next timestamp-ordered message for the federate, as for an event-driven federate
+	NextMessageRequest(t vrtime.Time) error
+
+	// SendInteraction sends a timestamp-ordered interaction of the given class
+	SendInteraction(class string, parameters map[string][]byte, t vrtime.Time) error
+}
+
+// Interaction is an HLA interaction received by a Federate, given as data to its handler
+type Interaction struct {
+	Class      string
+	Parameters map[string][]byte
+	Time       vrtime.Time
+}
+
+// Federate adapts an EventManager to an HLA federation.  Run maps the advance of the
+// EventManager to the RTI's time management: it requests an advance to the next event time
+// (or by a fixed step, see SetTimeStep), and, once the grant arrives, delivers the interactions
+// received up to the grant as events and has the EventManager advance to it.  The binding calls
+// the methods ReceiveInteraction and TimeAdvanceGrant of the Federate from its federate
+// ambassador callbacks, on any goroutine.
+type Federate struct {
+	mgr      *evtm.EventManager
+	rti      RTI
+	inbox    *Mailbox
+	handlers map[string]evtm.EventHandlerFunction
+	granted  chan vrtime.Time
+	step     int64 // ticks of each time advance request, 0 to request next messages
+	grant    int64 // time of the grant being executed
+}
+
+// NewFederate creates a Federate advancing mgr through rti.  mgr must not be run other than by
+// the Federate.
+func NewFederate(mgr *evtm.EventManager, rti RTI) *Federate {
+	fd := &Federate{mgr: mgr, rti: rti, handlers: make(map[string]evtm.EventHandlerFunction),
+		granted: make(chan vrtime.Time, 1)}
+	fd.inbox = NewMailbox(mgr, fd.dispatch)
+	return fd
+}
+
+// SetTimeStep has the Federate advance by time advance requests of the given step, as a
+// time-stepped federate does, rather than by next message requests for the time of its next
+// event.  A step of zero restores next message requests.  Call it before Run.
+func (fd *Federate) SetTimeStep(step vrtime.Time) {
+	fd.step = step.Ticks()
+	if fd.step < 0 {
+		fd.step = 0
+	}
+}
+
+// HandleInteraction has the interactions of the given class dispatched to handler, with the
+// Federate as context and the *Interaction as data.  Interactions of a class without a
+// handler are dropped.  Call it before Run.
+func (fd *Federate) HandleInteraction(class string, handler evtm.EventHandlerFunction) {
+	fd.handlers[class] = handler
+}
+
+// SendInteraction sends an interaction to the federation, timestamped offset after the current
+// time of the EventManager.  It is called by a handler, and returns an *evtm.LookaheadError,
+// sending nothing, if the timestamp precedes the grant being executed plus the lookahead of the
+// EventManager, which should be that declared to the RTI.  The federation holds a federate to
+// be at its grant while it executes the events up to it, so in time-stepped mode (see
+// SetTimeStep) an event early in the step may send only so far ahead.
+func (fd *Federate) SendInteraction(class string, parameters map[string][]byte, offset vrtime.Time) error {
+	now := fd.mgr.CurrentTime()
+	lookahead := fd.mgr.Lookahead()
+	if least := fd.grant + lookahead.Ticks() - now.Ticks(); offset.Ticks() < least {
+		return &evtm.LookaheadError{Offset: offset, Lookahead: vrtime.CreateTime(least, 0), Time: now}
+	}
+	return fd.rti.SendInteraction(class, parameters, vrtime.CreateTime(now.Ticks()+offset.Ticks(), offset.Pri()))
+}
+
+// ReceiveInteraction is called by the federate ambassador with an interaction received from
+// the federation.  It is held until the grant of an advance past its time.
+func (fd *Federate) ReceiveInteraction(class string, parameters map[string][]byte, t vrtime.Time) {
+	fd.inbox.Post(t, &Interaction{Class: class, Parameters: parameters, Time: t})
+}
+
+// TimeAdvanceGrant is called by the federate ambassador when the RTI grants an advance to t
+func (fd *Federate) TimeAdvanceGrant(t vrtime.Time) {
+	fd.granted <- t
+}
+
+// dispatch is the handler of the interactions delivered from the inbox
+func (fd *Federate) dispatch(evtmgr *evtm.EventManager, context any, data any) any {
+	interaction := data.(*Interaction)
+	handler := fd.handlers[interaction.Class]
+	if handler == nil {
+		return nil
+	}
+	return handler(evtmgr, fd, interaction)
+}
+
+// Run advances the EventManager up to the time limit, grant by grant, leaving its clock at
+// limit.  Every event at or before a grant, the limit included, is executed before the next
+// request is made or Run returns.  It returns an error if the RTI refuses a request, if an interaction arrives in the
+// past of the EventManager, or if a handler stops or aborts the EventManager.
+func (fd *Federate) Run(limit vrtime.Time) error {
+	for {
+		now := fd.mgr.CurrentTicks()
+		var err error
+		if fd.step > 0 {
+			target := now + fd.step
+			if target > limit.Ticks() {
+				target = limit.Ticks()
+			}
+			err = fd.rti.TimeAdvanceRequest(vrtime.CreateTime(target, 0))
+		} else {
+			target := fd.mgr.ProposeNextEventTime().Ticks()
+			if target > limit.Ticks() {
+				target = limit.Ticks()
+			}
+			err = fd.rti.NextMessageRequest(vrtime.CreateTime(target, 0))
+		}
+		if err != nil {
+			return fmt.Errorf("time advance request at %g: %w", vrtime.TicksToSeconds(now), err)
+		}
+
+		grant := <-fd.granted
+		if grant.Ticks() > limit.Ticks() {
+			grant = limit
+		}
+		if _, err := fd.inbox.Deliver(grant); err != nil {
+			return err
+		}
+		fd.grant = grant.Ticks()
+		switch fd.mgr.AdvanceTo(grant) {
+		case evtm.StopAborted:
+			return fmt.Errorf("federate: %w", fd.mgr.Err())
+		case evtm.StopStopped:
+			return fmt.Errorf("federate stopped at %g", fd.mgr.CurrentSeconds())
+		}
+		if grant.Ticks() == limit.Ticks() {
+			return nil
+		}
+	}
+}
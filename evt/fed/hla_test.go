@@ -0,0 +1,55 @@
+package fed
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// grantingRTI grants every request at once, there being no other federates
+type grantingRTI struct {
+	fd       *Federate
+	requests []int64
+}
+
+func (rti *grantingRTI) TimeAdvanceRequest(t vrtime.Time) error {
+	rti.requests = append(rti.requests, t.Ticks())
+	rti.fd.TimeAdvanceGrant(t)
+	return nil
+}
+
+func (rti *grantingRTI) NextMessageRequest(t vrtime.Time) error {
+	return rti.TimeAdvanceRequest(t)
+}
+
+func (rti *grantingRTI) SendInteraction(class string, parameters map[string][]byte, t vrtime.Time) error {
+	return nil
+}
+
+// TestFederateDrainsGrant checks that a Federate executes every event at a grant, the limit
+// included, before requesting the next
+func TestFederateDrainsGrant(t *testing.T) {
+	mgr := evtm.New()
+	rti := &grantingRTI{}
+	rti.fd = NewFederate(mgr, rti)
+
+	var dispatched []int64
+	record := func(evtmgr *evtm.EventManager, context any, data any) any {
+		dispatched = append(dispatched, evtmgr.CurrentTicks())
+		return nil
+	}
+	for _, ticks := range []int64{5, 5, 5, 7, 10, 10} {
+		mgr.Schedule(nil, nil, record, vrtime.CreateTime(ticks, 0))
+	}
+
+	if err := rti.fd.Run(vrtime.CreateTime(10, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(dispatched) != 6 {
+		t.Errorf("dispatched events at %v, want all 6", dispatched)
+	}
+	if len(rti.requests) != 3 {
+		t.Errorf("requested advances to %v, want one to each of 5, 7, and 10", rti.requests)
+	}
+}
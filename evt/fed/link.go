@@ -0,0 +1,198 @@
+package fed
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Link couples an EventManager to one in another OS process, possibly on another machine,
+// over a stream connection such as a TCP connection.  A handler on either side sends an event
+// to the other with Send, naming the kind of the event rather than its handler, since functions
+// do not cross process boundaries; the receiving side maps each kind to a handler with Handle.
+// Events received are held in a Mailbox (see Inbox) until a synchronization algorithm, e.g.,
+// one computing its windows from the MinTimestamp of every inbox, delivers them.
+//
+// Events are encoded with encoding/gob, so the concrete types of their data must be registered
+// with gob.Register by both processes.  NewLink begins with a handshake that checks both
+// processes measure virtual time in the same ticks.
+type Link struct {
+	mgr      *evtm.EventManager
+	conn     io.ReadWriteCloser
+	enc      *gob.Encoder
+	dec      *gob.Decoder
+	inbox    *Mailbox
+	handlers map[string]evtm.EventHandlerFunction
+	peer     uint32 // manager identifier of the EventManager at the other end
+	sent     int64
+	received int64
+	err      error
+	closed   bool // closed by this end
+	done     chan struct{}
+	mu       sync.Mutex
+	writing  sync.Mutex // serializes the encoding of events sent
+}
+
+// linkHello is exchanged by the two ends of a Link when it is created
+type linkHello struct {
+	TicksPerSecond int64
+	ManagerID      uint32
+}
+
+// linkEvent is an event sent over a Link
+type linkEvent struct {
+	Ticks   int64
+	Pri     int64
+	Kind    string
+	Payload any
+}
+
+// NewLink creates the end for mgr of a Link over conn, the other end being created by the
+// process at the other end of conn.  The ends exchange their time bases, and an error is
+// returned, closing conn, if they differ.  The time base of this process is then frozen (see
+// [vrtime.FreezeTimeBase]), as a later change would reinterpret the times of events in transit.
+func NewLink(conn io.ReadWriteCloser, mgr *evtm.EventManager) (*Link, error) {
+	lk := &Link{mgr: mgr, conn: conn, enc: gob.NewEncoder(conn), dec: gob.NewDecoder(conn),
+		handlers: make(map[string]evtm.EventHandlerFunction), done: make(chan struct{})}
+	lk.inbox = NewMailbox(mgr, lk.dispatch)
+
+	// both ends write before reading, which must not wait on an unbuffered connection
+	vrtime.FreezeTimeBase()
+	ours := linkHello{TicksPerSecond: vrtime.TicksPerSecond, ManagerID: mgr.ManagerID()}
+	wrote := make(chan error, 1)
+	go func() { wrote <- lk.enc.Encode(ours) }()
+	var theirs linkHello
+	err := lk.dec.Decode(&theirs)
+	if werr := <-wrote; err == nil {
+		err = werr
+	}
+	if err == nil && theirs.TicksPerSecond != ours.TicksPerSecond {
+		err = fmt.Errorf("peer measures time in %d ticks per second, not %d",
+			theirs.TicksPerSecond, ours.TicksPerSecond)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("link handshake: %w", err)
+	}
+	lk.peer = theirs.ManagerID
+	go lk.receive()
+	return lk, nil
+}
+
+// Handle has events of the given kind received over the link dispatched to handler, with the
+// Link as context and the data sent as data.  Events of a kind without a handler are dropped
+// when delivered, and reported by Err.
+func (lk *Link) Handle(kind string, handler evtm.EventHandlerFunction) {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	lk.handlers[kind] = handler
+}
+
+// Send sends an event of the given kind to the other end of the link, to execute offset after
+// the current time of this end's EventManager.  It is called by a handler of that EventManager,
+// and returns an *evtm.LookaheadError, sending nothing, if offset is less than its lookahead,
+// or the error of the connection if the event could not be written.
+func (lk *Link) Send(kind string, data any, offset vrtime.Time) error {
+	now := lk.mgr.CurrentTime()
+	lookahead := lk.mgr.Lookahead()
+	if offset.Ticks() < lookahead.Ticks() {
+		return &evtm.LookaheadError{Offset: offset, Lookahead: lookahead, Time: now}
+	}
+	ev := linkEvent{Ticks: now.Ticks() + offset.Ticks(), Pri: offset.Pri(), Kind: kind, Payload: data}
+	lk.writing.Lock()
+	err := lk.enc.Encode(&ev)
+	lk.writing.Unlock()
+	if err != nil {
+		return fmt.Errorf("link send of %s: %w", kind, err)
+	}
+	lk.mu.Lock()
+	lk.sent += 1
+	lk.mu.Unlock()
+	return nil
+}
+
+// receive posts the events read from the connection to the inbox, until the connection fails
+func (lk *Link) receive() {
+	defer close(lk.done)
+	for {
+		var ev linkEvent
+		if err := lk.dec.Decode(&ev); err != nil {
+			lk.mu.Lock()
+			closed := lk.closed
+			lk.mu.Unlock()
+			if err != io.EOF && !closed {
+				lk.fail(fmt.Errorf("link receive: %w", err))
+			}
+			return
+		}
+		lk.mu.Lock()
+		lk.received += 1
+		lk.mu.Unlock()
+		lk.inbox.Post(vrtime.CreateTime(ev.Ticks, ev.Pri), ev)
+	}
+}
+
+// dispatch is the handler of the events delivered from the inbox
+func (lk *Link) dispatch(evtmgr *evtm.EventManager, context any, data any) any {
+	ev := data.(linkEvent)
+	lk.mu.Lock()
+	handler := lk.handlers[ev.Kind]
+	lk.mu.Unlock()
+	if handler == nil {
+		lk.fail(fmt.Errorf("link event of kind %q at %g has no handler",
+			ev.Kind, vrtime.TicksToSeconds(ev.Ticks)))
+		return nil
+	}
+	return handler(evtmgr, lk, ev.Payload)
+}
+
+// fail records the first error met by the link
+func (lk *Link) fail(err error) {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	if lk.err == nil {
+		lk.err = err
+	}
+}
+
+// Inbox returns the Mailbox holding the events received and not yet delivered
+func (lk *Link) Inbox() *Mailbox {
+	return lk.inbox
+}
+
+// Peer returns the manager identifier of the EventManager at the other end of the link
+func (lk *Link) Peer() uint32 {
+	return lk.peer
+}
+
+// Counts returns the numbers of events sent and received over the link
+func (lk *Link) Counts() (sent, received int64) {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	return lk.sent, lk.received
+}
+
+// Err returns the first error met receiving or dispatching events, nil if none
+func (lk *Link) Err() error {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	return lk.err
+}
+
+// Done returns a channel closed once the other end has closed the link or the connection
+// has failed, after which no more events are received
+func (lk *Link) Done() <-chan struct{} {
+	return lk.done
+}
+
+// Close closes the connection of the link
+func (lk *Link) Close() error {
+	lk.mu.Lock()
+	lk.closed = true
+	lk.mu.Unlock()
+	return lk.conn.Close()
+}
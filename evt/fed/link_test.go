@@ -0,0 +1,82 @@
+package fed
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestLink checks that an event sent over a Link is held in the receiver's inbox, then
+// dispatched to the handler of its kind at the sender's time plus the offset, and that
+// closing one end is seen at the other
+func TestLink(t *testing.T) {
+	near, far := net.Pipe()
+	sender, receiver := evtm.New(), evtm.New()
+	sender.SetManagerID(1)
+	receiver.SetManagerID(2)
+	type made struct {
+		lk  *Link
+		err error
+	}
+	other := make(chan made, 1)
+	go func() {
+		lk, err := NewLink(far, receiver)
+		other <- made{lk, err}
+	}()
+	out, err := NewLink(near, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-other
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	in := res.lk
+	if out.Peer() != 2 || in.Peer() != 1 {
+		t.Errorf("got peers %d and %d, want 2 and 1", out.Peer(), in.Peer())
+	}
+
+	var got string
+	var at int64
+	in.Handle("greet", func(evtmgr *evtm.EventManager, context any, data any) any {
+		if context != in {
+			t.Error("handler not given the Link as its context")
+		}
+		got, at = data.(string), evtmgr.CurrentTicks()
+		return nil
+	})
+	sender.Schedule(nil, nil, func(*evtm.EventManager, any, any) any {
+		if err := out.Send("greet", "hello", vrtime.CreateTime(10, 0)); err != nil {
+			t.Error(err)
+		}
+		return nil
+	}, vrtime.CreateTime(5, 0))
+	sender.AdvanceTo(vrtime.CreateTime(5, 0))
+
+	for in.Inbox().Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if n, err := in.Inbox().Deliver(vrtime.CreateTime(100, 0)); n != 1 || err != nil {
+		t.Fatalf("delivered %d events with error %v, want 1", n, err)
+	}
+	receiver.AdvanceTo(vrtime.CreateTime(100, 0))
+	if got != "hello" || at != 15 || in.Err() != nil {
+		t.Errorf("received %q at %d with error %v, want hello at 15", got, at, in.Err())
+	}
+	if sent, _ := out.Counts(); sent != 1 {
+		t.Errorf("got %d events sent, want 1", sent)
+	}
+
+	out.Close()
+	select {
+	case <-in.Done():
+	case <-time.After(time.Second):
+		t.Fatal("far end not done a second after the near end closed")
+	}
+	if _, received := in.Counts(); received != 1 || in.Err() != nil {
+		t.Errorf("got %d events received and error %v, want 1 and nil", received, in.Err())
+	}
+}
@@ -0,0 +1,125 @@
+package fed
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Mailbox buffers timestamped messages sent by producers, possibly running other
+// EventManagers on other goroutines, to a consumer EventManager.  Messages are held until
+// Deliver schedules them on the consumer as events, in timestamp order, each dispatched to
+// the handler given to NewMailbox with the Mailbox as context and the message as data.
+// MinTimestamp gives a synchronization algorithm the earliest time at which an undelivered
+// message would act on the consumer.
+type Mailbox struct {
+	consumer *evtm.EventManager
+	handler  evtm.EventHandlerFunction
+	held     letters
+	seq      int64
+	mu       sync.Mutex
+}
+
+// letter is a message held in a Mailbox
+type letter struct {
+	at    vrtime.Time
+	seq   int64 // order of posting, to break ties
+	msg   any
+	clock *evtm.LogicalClock // logical clocks of the sender, if known
+}
+
+// letters orders held messages by timestamp, then order of posting
+type letters []letter
+
+func (lt letters) Len() int { return len(lt) }
+func (lt letters) Less(i, j int) bool {
+	if lt[i].at.Ticks() != lt[j].at.Ticks() {
+		return lt[i].at.Ticks() < lt[j].at.Ticks()
+	}
+	if lt[i].at.Pri() != lt[j].at.Pri() {
+		return lt[i].at.Pri() < lt[j].at.Pri()
+	}
+	return lt[i].seq < lt[j].seq
+}
+func (lt letters) Swap(i, j int) { lt[i], lt[j] = lt[j], lt[i] }
+func (lt *letters) Push(x any)   { *lt = append(*lt, x.(letter)) }
+func (lt *letters) Pop() any {
+	old := *lt
+	last := old[len(old)-1]
+	*lt = old[:len(old)-1]
+	return last
+}
+
+// NewMailbox creates an empty Mailbox whose messages are delivered to handler on consumer
+func NewMailbox(consumer *evtm.EventManager, handler evtm.EventHandlerFunction) *Mailbox {
+	return &Mailbox{consumer: consumer, handler: handler}
+}
+
+// Post leaves a message to act on the consumer at the virtual time at.  A priority given
+// with at orders the event among others at the same tick, as for Schedule; otherwise
+// messages with the same timestamp are delivered in the order they were posted.
+func (mb *Mailbox) Post(at vrtime.Time, msg any) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.seq += 1
+	heap.Push(&mb.held, letter{at: at, seq: mb.seq, msg: msg})
+}
+
+// PostFrom leaves a message as Post does, sent by a handler of sender, whose logical clocks,
+// if on (see [evtm.EventManager.SetLogicalClocks]), the message carries to the consumer
+func (mb *Mailbox) PostFrom(sender *evtm.EventManager, at vrtime.Time, msg any) {
+	lt := letter{at: at, msg: msg}
+	if clock, on := sender.LogicalClock(); on {
+		lt.clock = &clock
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.seq += 1
+	lt.seq = mb.seq
+	heap.Push(&mb.held, lt)
+}
+
+// Len returns the number of messages not yet delivered
+func (mb *Mailbox) Len() int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return len(mb.held)
+}
+
+// MinTimestamp returns the earliest timestamp of the messages not yet delivered, or
+// InfinityTime if there are none
+func (mb *Mailbox) MinTimestamp() vrtime.Time {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if len(mb.held) == 0 {
+		return vrtime.InfinityTime()
+	}
+	return mb.held[0].at
+}
+
+// Deliver schedules on the consumer, in timestamp order, every message with a timestamp at or
+// before upTo, and returns the number delivered.  A message whose timestamp has already
+// passed at the consumer is left undelivered, with any after it, and reported as an error.
+func (mb *Mailbox) Deliver(upTo vrtime.Time) (int, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	delivered := 0
+	for len(mb.held) > 0 && mb.held[0].at.Ticks() <= upTo.Ticks() {
+		next := mb.held[0]
+		now := mb.consumer.CurrentTicks()
+		if next.at.Ticks() < now {
+			return delivered, fmt.Errorf("message at %g is in the past of the consumer, at %g",
+				next.at.Seconds(), vrtime.TicksToSeconds(now))
+		}
+		heap.Pop(&mb.held)
+		eventID, _ := mb.consumer.Schedule(mb, next.msg, mb.handler, vrtime.CreateTime(next.at.Ticks()-now, next.at.Pri()))
+		if next.clock != nil {
+			mb.consumer.StampEvent(eventID, *next.clock)
+		}
+		delivered += 1
+	}
+	return delivered, nil
+}
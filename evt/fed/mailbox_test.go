@@ -0,0 +1,39 @@
+package fed
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestMailbox checks that messages posted out of order are delivered in timestamp order,
+// ties in the order posted, only up to the time asked for, and that a message in the past of
+// the consumer is refused
+func TestMailbox(t *testing.T) {
+	consumer := evtm.New()
+	var got []string
+	mb := NewMailbox(consumer, func(evtmgr *evtm.EventManager, context any, data any) any {
+		got = append(got, fmt.Sprintf("%s@%d", data, evtmgr.CurrentTicks()))
+		return nil
+	})
+	mb.Post(vrtime.CreateTime(30, 0), "c")
+	mb.Post(vrtime.CreateTime(10, 0), "a")
+	mb.Post(vrtime.CreateTime(10, 0), "b")
+	mb.Post(vrtime.CreateTime(50, 0), "d")
+	if min := mb.MinTimestamp(); min.Ticks() != 10 {
+		t.Errorf("least timestamp %d, want 10", min.Ticks())
+	}
+
+	if n, err := mb.Deliver(vrtime.CreateTime(30, 0)); n != 3 || err != nil || mb.Len() != 1 {
+		t.Fatalf("delivered %d up to 30 with error %v, %d left; want 3 and 1 left", n, err, mb.Len())
+	}
+	consumer.AdvanceTo(vrtime.CreateTime(60, 0))
+	if fmt.Sprint(got) != "[a@10 b@10 c@30]" {
+		t.Errorf("consumer received %v, want [a@10 b@10 c@30]", got)
+	}
+	if n, err := mb.Deliver(vrtime.CreateTime(100, 0)); n != 0 || err == nil || mb.Len() != 1 {
+		t.Errorf("message in the past delivered %d times with error %v", n, err)
+	}
+}
@@ -0,0 +1,110 @@
+// Package fed coordinates federations of EventManagers, each typically run by its own
+// goroutine, that exchange events as messages.
+package fed
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iti/evt/evtm"
+)
+
+// A federation has terminated when no member has work to do and no message is in flight
+// between members.  No member can tell this from its own event list, which may be empty
+// only until a message from another member arrives.  Detector establishes it with
+// Mattern's four-counter method: each member counts the messages it sends and receives,
+// and a wave visits the members, collecting their counts and whether they are idle.
+// If two successive waves find every member idle, and find the same totals of messages
+// sent and received, equal to each other, then no member was activated between the waves
+// and nothing remains in flight, so the federation has terminated.
+
+// Member is the participation of one member in a Detector
+type Member struct {
+	name     string
+	idle     func() bool
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// Name returns the name the member joined with
+func (mbr *Member) Name() string {
+	return mbr.name
+}
+
+// Sent records that the member has sent a message to another member.
+// It must be called before the message is handed over for delivery.
+func (mbr *Member) Sent() {
+	mbr.sent.Add(1)
+}
+
+// Received records that the member has received a message from another member.
+// It must be called before the member acts upon the message, e.g., before the
+// message is scheduled as an event on the member's EventManager.
+func (mbr *Member) Received() {
+	mbr.received.Add(1)
+}
+
+// Detector detects the termination of a federation
+type Detector struct {
+	mu      sync.Mutex
+	members []*Member
+}
+
+// NewDetector creates a Detector for a federation with no members
+func NewDetector() *Detector {
+	return &Detector{}
+}
+
+// Join adds a member to the federation.  idle reports whether the member has no work to
+// do, so that it can become active again only by receiving a message.
+func (det *Detector) Join(name string, idle func() bool) *Member {
+	mbr := &Member{name: name, idle: idle}
+	det.mu.Lock()
+	det.members = append(det.members, mbr)
+	det.mu.Unlock()
+	return mbr
+}
+
+// JoinManager adds a member whose work is done by mgr, which is idle when its event list
+// is empty and it is not executing an event handler (see [evtm.EventManager.Idle]).
+func (det *Detector) JoinManager(name string, mgr *evtm.EventManager) *Member {
+	return det.Join(name, mgr.Idle)
+}
+
+// wave visits every member, returning whether all were idle and the totals of
+// messages sent and received.
+func (det *Detector) wave() (idle bool, sent, received int64) {
+	det.mu.Lock()
+	members := det.members
+	det.mu.Unlock()
+
+	idle = true
+	for _, mbr := range members {
+		// read the counts before asking whether the member is idle, so that a message
+		// received since the previous wave shows in the counts of any member found idle
+		received += mbr.received.Load()
+		sent += mbr.sent.Load()
+		if !mbr.idle() {
+			idle = false
+		}
+	}
+	return idle, sent, received
+}
+
+// Terminated returns true if the federation has terminated.  It visits the members twice.
+func (det *Detector) Terminated() bool {
+	idle1, sent1, received1 := det.wave()
+	if !idle1 || sent1 != received1 {
+		return false
+	}
+	idle2, sent2, received2 := det.wave()
+	return idle2 && sent2 == sent1 && received2 == received1
+}
+
+// Wait blocks until the federation has terminated, testing for termination every poll.
+func (det *Detector) Wait(poll time.Duration) {
+	for !det.Terminated() {
+		time.Sleep(poll)
+	}
+}
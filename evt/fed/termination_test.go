@@ -0,0 +1,46 @@
+package fed
+
+import "testing"
+
+// TestTerminated checks that a federation is found terminated only when every member is idle
+// and every message sent has been received, and not when a message was exchanged between
+// the two waves
+func TestTerminated(t *testing.T) {
+	det := NewDetector()
+	busy := false
+	var exchange func()
+	first := det.Join("first", func() bool { return !busy })
+	second := det.Join("second", func() bool {
+		if exchange != nil {
+			exchange()
+		}
+		return true
+	})
+
+	if !det.Terminated() {
+		t.Error("idle federation with nothing sent not terminated")
+	}
+	first.Sent()
+	if det.Terminated() {
+		t.Error("terminated with a message in flight")
+	}
+	second.Received()
+	if !det.Terminated() {
+		t.Error("not terminated once the message was received")
+	}
+	busy = true
+	if det.Terminated() {
+		t.Error("terminated with a member busy")
+	}
+	busy = false
+
+	// the second member sends a message that the first receives and finishes with, all
+	// after the first member's counts were read, so only the second wave can see it
+	exchange = func() { second.Sent(); first.Received(); exchange = nil }
+	if det.Terminated() {
+		t.Error("terminated although a message was exchanged between the waves")
+	}
+	if !det.Terminated() {
+		t.Error("not terminated once the exchange was done")
+	}
+}
@@ -0,0 +1,447 @@
+package fed
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TimeWarp runs a federation of EventManagers optimistically, after Jefferson's Time Warp.
+// Each member executes its events as they come, on its own goroutine, without waiting to
+// learn whether another member will send it an earlier message.  Before each time step a
+// member checkpoints its event list (see [evtm.EventManager.Speculate]) and the model state
+// given to SetState.  A message that arrives in the past of its receiver, a straggler, rolls
+// the receiver back to the checkpoint preceding the message's time, and every message the
+// receiver sent in the steps undone is cancelled by an anti-message, which may roll back its
+// own receiver in turn.  Members meet at a barrier after every batch of steps, where the
+// global virtual time (GVT), the earliest time any member may yet be rolled back to, is
+// computed, and the checkpoints and message logs preceding it are discarded.
+//
+// Unlike a Coordinator, a TimeWarp needs no lookahead, but checkpointing copies the pending
+// events of a member at every step, so it suits members with short event lists whose
+// handlers do enough work to pay for it.
+type TimeWarp struct {
+	mu        sync.Mutex
+	processes []*WarpProcess
+	batch     int
+	stats     WarpStats
+}
+
+// WarpStats counts the work of a TimeWarp
+type WarpStats struct {
+	Rounds       int         // batches run between barriers
+	Steps        int         // time steps executed, including those later undone
+	Rollbacks    int         // rollbacks caused by stragglers and anti-messages
+	Undone       int         // time steps undone by rollbacks
+	AntiMessages int         // anti-messages sent
+	GVT          vrtime.Time // global virtual time at the last barrier
+}
+
+// WarpProcess is the membership of one EventManager in a TimeWarp
+type WarpProcess struct {
+	tw      *TimeWarp
+	index   int
+	name    string
+	mgr     *evtm.EventManager
+	copier  evtm.EventCopier
+	save    func() any
+	restore func(any)
+
+	inbox []warpMessage // messages posted and not yet taken by the process
+	mu    sync.Mutex
+
+	// the fields below are used only by the goroutine running the process, or at the barrier
+	checkpoints []warpCheckpoint
+	seq         int   // number of checkpoints taken
+	last        int64 // time of the last step executed, -1 before the first
+	sends       int   // number of messages sent
+	sent        []warpSent
+	inputs      map[warpKey]*warpInput
+	stats       WarpStats
+}
+
+// warpKey identifies a message by its sender and order of sending
+type warpKey struct {
+	from int
+	seq  int
+}
+
+// warpMessage is an event sent by one process to another, or the anti-message cancelling it
+type warpMessage struct {
+	key     warpKey
+	anti    bool
+	at      vrtime.Time
+	context any
+	data    any
+	handler evtm.EventHandlerFunction
+}
+
+// warpInput is a message scheduled on its receiver
+type warpInput struct {
+	msg      warpMessage
+	eventID  int
+	after    int  // number of checkpoints taken when it was scheduled
+	annulled bool // cancelled by its anti-message, and to be removed again if a rollback restores it
+}
+
+// warpSent is a message logged by its sender, to be cancelled if the step that sent it is undone
+type warpSent struct {
+	to   *WarpProcess
+	msg  warpMessage
+	step int64 // time of the step that sent it
+}
+
+// warpCheckpoint is the state of a process before one of its time steps
+type warpCheckpoint struct {
+	seq   int
+	step  int64 // time of the step taken after the checkpoint
+	last  int64 // time of the step preceding it
+	spec  *evtm.Speculation
+	state any
+}
+
+// NewTimeWarp creates a TimeWarp with no members, running batches of 64 time steps
+func NewTimeWarp() *TimeWarp {
+	return &TimeWarp{batch: 64}
+}
+
+// SetBatch sets the number of time steps each member executes between barriers.  Smaller
+// batches reclaim checkpoints sooner and let no member run far ahead of the others.
+func (tw *TimeWarp) SetBatch(steps int) {
+	if steps < 1 {
+		steps = 1
+	}
+	tw.mu.Lock()
+	tw.batch = steps
+	tw.mu.Unlock()
+}
+
+// Join adds the EventManager mgr to the federation under name.  mgr must not be run other
+// than by the TimeWarp.  An mgr without a manager identifier (see
+// [evtm.EventManager.SetManagerID]) is given its position in the order of joining, from 1.
+func (tw *TimeWarp) Join(name string, mgr *evtm.EventManager) *WarpProcess {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	wp := &WarpProcess{tw: tw, index: len(tw.processes), name: name, mgr: mgr, last: -1,
+		inputs: make(map[warpKey]*warpInput)}
+	tw.processes = append(tw.processes, wp)
+	if mgr.ManagerID() == 0 {
+		mgr.SetManagerID(uint32(len(tw.processes)))
+	}
+	return wp
+}
+
+// Name returns the name the process joined with
+func (wp *WarpProcess) Name() string {
+	return wp.name
+}
+
+// Manager returns the EventManager of the process
+func (wp *WarpProcess) Manager() *evtm.EventManager {
+	return wp.mgr
+}
+
+// SetState registers the functions checkpointing the model state of the process.  save is
+// called before every time step, and restore is given what save returned when the process
+// rolls back to that step.  A model whose state lives only in its pending events needs neither.
+func (wp *WarpProcess) SetState(save func() any, restore func(any)) {
+	wp.save, wp.restore = save, restore
+}
+
+// SetCopier sets the function giving the context and data of the events copied into each
+// checkpoint, as for [evtm.EventManager.Clone].  By default they are shared, which is enough
+// only when they are immutable.
+func (wp *WarpProcess) SetCopier(copier evtm.EventCopier) {
+	wp.copier = copier
+}
+
+// Send sends an event to the process to, to execute offset after the current time of the
+// sender.  It is called by a handler of the sender's EventManager, and returns an error,
+// sending nothing, if offset is not positive, as a message to the sender's own present
+// would roll it back to the step sending it.
+func (wp *WarpProcess) Send(to *WarpProcess, context any, data any,
+	handler evtm.EventHandlerFunction, offset vrtime.Time) error {
+
+	now := wp.mgr.CurrentTicks()
+	if offset.Ticks() <= 0 {
+		return fmt.Errorf("process %s: message offset %g at %g is not positive",
+			wp.name, offset.Seconds(), vrtime.TicksToSeconds(now))
+	}
+	msg := warpMessage{key: warpKey{from: wp.index, seq: wp.sends},
+		at: vrtime.CreateTime(now+offset.Ticks(), offset.Pri()), context: context, data: data, handler: handler}
+	wp.sends += 1
+	wp.sent = append(wp.sent, warpSent{to: to, msg: msg, step: now})
+	to.post(msg)
+	return nil
+}
+
+// post adds a message to the inbox of the process
+func (wp *WarpProcess) post(msg warpMessage) {
+	wp.mu.Lock()
+	wp.inbox = append(wp.inbox, msg)
+	wp.mu.Unlock()
+}
+
+// earliestPosted returns the earliest time of a message in the inbox, InfinityTime if none
+func (wp *WarpProcess) earliestPosted() int64 {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	earliest := vrtime.InfinityTime().Ticks()
+	for _, msg := range wp.inbox {
+		if msg.at.Ticks() < earliest {
+			earliest = msg.at.Ticks()
+		}
+	}
+	return earliest
+}
+
+// drain takes the messages in the inbox, rolling back for stragglers and their anti-messages
+func (wp *WarpProcess) drain() {
+	wp.mu.Lock()
+	msgs := wp.inbox
+	wp.inbox = nil
+	wp.mu.Unlock()
+
+	for _, msg := range msgs {
+		if msg.anti {
+			wp.annul(msg)
+		} else {
+			wp.accept(msg)
+		}
+	}
+}
+
+// accept schedules a message, first rolling back if it arrived in the past
+func (wp *WarpProcess) accept(msg warpMessage) {
+	if msg.at.Ticks() <= wp.last {
+		wp.rollback(msg.at.Ticks())
+	}
+	wp.inputs[msg.key] = &warpInput{msg: msg, eventID: wp.schedule(msg), after: wp.seq}
+}
+
+// annul cancels a message, first rolling back if it has been executed.  An anti-message
+// never overtakes its message, as both pass through the receiver's inbox in order of posting.
+func (wp *WarpProcess) annul(anti warpMessage) {
+	rec, present := wp.inputs[anti.key]
+	if !present || rec.annulled {
+		return
+	}
+	if rec.msg.at.Ticks() <= wp.last {
+		wp.rollback(rec.msg.at.Ticks())
+	}
+	wp.mgr.RemoveEvent(rec.eventID)
+	rec.annulled = true
+}
+
+// schedule schedules a message on the process at its time of arrival
+func (wp *WarpProcess) schedule(msg warpMessage) int {
+	offset := vrtime.CreateTime(msg.at.Ticks()-wp.mgr.CurrentTicks(), msg.at.Pri())
+	eventID, _ := wp.mgr.Schedule(msg.context, msg.data, msg.handler, offset)
+	return eventID
+}
+
+// rollback returns the process to the checkpoint taken before its first step at or after
+// ticks, sends anti-messages for the messages sent by the steps undone, reschedules the
+// messages received since the checkpoint, and removes again those annulled since
+func (wp *WarpProcess) rollback(ticks int64) {
+	idx := sort.Search(len(wp.checkpoints), func(i int) bool { return wp.checkpoints[i].step >= ticks })
+	if idx == len(wp.checkpoints) {
+		return
+	}
+	cp := wp.checkpoints[idx]
+	cp.spec.Rollback()
+	if wp.restore != nil {
+		wp.restore(cp.state)
+	}
+	wp.stats.Rollbacks += 1
+	wp.stats.Undone += len(wp.checkpoints) - idx
+	wp.checkpoints = wp.checkpoints[:idx]
+	wp.last = cp.last
+
+	kept := wp.sent[:0]
+	for _, sent := range wp.sent {
+		if sent.step < cp.step {
+			kept = append(kept, sent)
+			continue
+		}
+		anti := sent.msg
+		anti.anti, anti.context, anti.data, anti.handler = true, nil, nil, nil
+		sent.to.post(anti)
+		wp.stats.AntiMessages += 1
+	}
+	wp.sent = kept
+
+	// the restored event list predates these messages; reschedule them in a deterministic order
+	var redo []*warpInput
+	for key, rec := range wp.inputs {
+		switch {
+		case rec.after < cp.seq && rec.annulled:
+			wp.mgr.RemoveEvent(rec.eventID)
+		case rec.after < cp.seq:
+		case rec.annulled:
+			// no checkpoint left holds it
+			delete(wp.inputs, key)
+		default:
+			redo = append(redo, rec)
+		}
+	}
+	sort.Slice(redo, func(i, j int) bool {
+		if redo[i].msg.at.Ticks() != redo[j].msg.at.Ticks() {
+			return redo[i].msg.at.Ticks() < redo[j].msg.at.Ticks()
+		}
+		if redo[i].msg.key.from != redo[j].msg.key.from {
+			return redo[i].msg.key.from < redo[j].msg.key.from
+		}
+		return redo[i].msg.key.seq < redo[j].msg.key.seq
+	})
+	for _, rec := range redo {
+		rec.eventID = wp.schedule(rec.msg)
+		rec.after = wp.seq
+	}
+}
+
+// step takes the messages posted, then checkpoints the process and executes its next time
+// step, every event at the time of its next event, if it is no later than limit.  It returns
+// false if there was no step to execute.
+func (wp *WarpProcess) step(limit int64) (bool, error) {
+	wp.drain()
+	next := wp.mgr.ProposeNextEventTime().Ticks()
+	if next > limit {
+		return false, nil
+	}
+	wp.seq += 1
+	cp := warpCheckpoint{seq: wp.seq, step: next, last: wp.last, spec: wp.mgr.Speculate(wp.copier)}
+	if wp.save != nil {
+		cp.state = wp.save()
+	}
+	wp.checkpoints = append(wp.checkpoints, cp)
+
+	switch wp.mgr.AdvanceTo(vrtime.CreateTime(next, 0)) {
+	case evtm.StopAborted:
+		return false, fmt.Errorf("process %s: %w", wp.name, wp.mgr.Err())
+	case evtm.StopStopped:
+		return false, fmt.Errorf("process %s stopped at %g", wp.name, wp.mgr.CurrentSeconds())
+	}
+	wp.last = next
+	wp.stats.Steps += 1
+	return true, nil
+}
+
+// fossilCollect commits the checkpoints, and forgets the messages, that precede gvt
+func (wp *WarpProcess) fossilCollect(gvt int64) {
+	idx := sort.Search(len(wp.checkpoints), func(i int) bool { return wp.checkpoints[i].step >= gvt })
+	for _, cp := range wp.checkpoints[:idx] {
+		cp.spec.Commit()
+	}
+	wp.checkpoints = append(wp.checkpoints[:0], wp.checkpoints[idx:]...)
+
+	kept := wp.sent[:0]
+	for _, sent := range wp.sent {
+		if sent.step >= gvt {
+			kept = append(kept, sent)
+		}
+	}
+	wp.sent = kept
+	for key, rec := range wp.inputs {
+		if rec.msg.at.Ticks() < gvt {
+			delete(wp.inputs, key)
+		}
+	}
+}
+
+// Stats returns the counts of the work done by the TimeWarp
+func (tw *TimeWarp) Stats() WarpStats {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.stats
+}
+
+// gvt returns the global virtual time: the earliest time of an event pending on, or a message
+// posted to, any process.  Called at the barrier.
+func (tw *TimeWarp) gvt(processes []*WarpProcess) int64 {
+	gvt := vrtime.InfinityTime().Ticks()
+	for _, wp := range processes {
+		if ticks := wp.mgr.ProposeNextEventTime().Ticks(); ticks < gvt {
+			gvt = ticks
+		}
+		if ticks := wp.earliestPosted(); ticks < gvt {
+			gvt = ticks
+		}
+	}
+	return gvt
+}
+
+// Run runs the federation up to the time limit, leaving the clock of every process at limit.
+// It returns an error if a handler aborts its EventManager (see [evtm.EventManager.Abort]) or
+// stops it, in which case the run ends at the barrier following, with the work of the
+// other processes since the last barrier possibly yet to be rolled back.
+func (tw *TimeWarp) Run(limit vrtime.Time) error {
+	tw.mu.Lock()
+	processes := append([]*WarpProcess(nil), tw.processes...)
+	batch := tw.batch
+	tw.mu.Unlock()
+
+	for {
+		gvt := tw.gvt(processes)
+		for _, wp := range processes {
+			wp.fossilCollect(gvt)
+		}
+		tw.mu.Lock()
+		tw.stats.GVT = vrtime.CreateTime(gvt, 0)
+		tw.mu.Unlock()
+		if gvt > limit.Ticks() {
+			break
+		}
+
+		errs := make([]error, len(processes))
+		var wg sync.WaitGroup
+		for i, wp := range processes {
+			wg.Add(1)
+			go func(i int, wp *WarpProcess) {
+				defer wg.Done()
+				for n := 0; n < batch; n++ {
+					more, err := wp.step(limit.Ticks())
+					if err != nil || !more {
+						errs[i] = err
+						return
+					}
+				}
+			}(i, wp)
+		}
+		wg.Wait()
+
+		tw.mu.Lock()
+		tw.stats.Rounds += 1
+		tw.tally(processes)
+		tw.mu.Unlock()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// every message left arrives after limit, so none rolls its receiver back
+	for _, wp := range processes {
+		wp.drain()
+		wp.mgr.AdvanceTo(limit)
+	}
+	tw.mu.Lock()
+	tw.tally(processes)
+	tw.mu.Unlock()
+	return nil
+}
+
+// tally adds the counts of the processes to those of the TimeWarp.  Called with tw.mu held.
+func (tw *TimeWarp) tally(processes []*WarpProcess) {
+	for _, wp := range processes {
+		tw.stats.Steps += wp.stats.Steps
+		tw.stats.Rollbacks += wp.stats.Rollbacks
+		tw.stats.Undone += wp.stats.Undone
+		tw.stats.AntiMessages += wp.stats.AntiMessages
+		wp.stats = WarpStats{}
+	}
+}
@@ -0,0 +1,36 @@
+package fed
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestTimeWarpStepsByTimestamp checks that a time step executes every event at its time,
+// with one checkpoint for all of them
+func TestTimeWarpStepsByTimestamp(t *testing.T) {
+	tw := NewTimeWarp()
+	first, second := evtm.New(), evtm.New()
+	tw.Join("first", first)
+	tw.Join("second", second)
+
+	var dispatched []int64
+	record := func(evtmgr *evtm.EventManager, context any, data any) any {
+		dispatched = append(dispatched, evtmgr.CurrentTicks())
+		return nil
+	}
+	for _, ticks := range []int64{5, 5, 5, 8, 8} {
+		first.Schedule(nil, nil, record, vrtime.CreateTime(ticks, 0))
+	}
+
+	if err := tw.Run(vrtime.CreateTime(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(dispatched) != 5 {
+		t.Fatalf("dispatched events at %v, want 5", dispatched)
+	}
+	if steps := tw.Stats().Steps; steps != 2 {
+		t.Errorf("executed %d time steps, want one at each of 5 and 8", steps)
+	}
+}
@@ -0,0 +1,229 @@
+package fed
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Coordinator runs a federation of EventManagers conservatively, in the bounded windows of
+// YAWNS.  Each member declares a lookahead (see [evtm.EventManager.SetLookahead]), the minimum
+// offset of the messages it sends.  No message sent at or after a member's next event time
+// can arrive before that time plus the member's lookahead, so every event before the least of
+// these sums over the members is safe to execute.  The Coordinator repeatedly computes this
+// window, runs every member to its bound, each on its own goroutine, and, at the barrier that
+// follows, delivers the messages sent within the window, in a deterministic order.
+//
+// Members that declare no lookahead may instead be run in windows of a fixed width (see
+// SetWindow), every message then being sent at least a window ahead.
+type Coordinator struct {
+	mu           sync.Mutex
+	participants []*Participant
+	windows      int
+	width        int64 // fixed width of a window in ticks, 0 if windows follow lookahead
+}
+
+// Participant is the membership of one EventManager in a Coordinator
+type Participant struct {
+	co     *Coordinator
+	name   string
+	mgr    *evtm.EventManager
+	outbox []message
+	mu     sync.Mutex
+}
+
+// message is an event sent by one participant to another, held until the end of the window
+type message struct {
+	to      *Participant
+	context any
+	data    any
+	handler evtm.EventHandlerFunction
+	at      vrtime.Time
+	clock   *evtm.LogicalClock // logical clocks of the sender, if on
+}
+
+// NewCoordinator creates a Coordinator with no participants
+func NewCoordinator() *Coordinator {
+	return &Coordinator{}
+}
+
+// Join adds the EventManager mgr to the federation under name.  mgr must not be run other
+// than by the Coordinator.  An mgr without a manager identifier (see
+// [evtm.EventManager.SetManagerID]) is given its position in the order of joining, from 1.
+func (co *Coordinator) Join(name string, mgr *evtm.EventManager) *Participant {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	pt := &Participant{co: co, name: name, mgr: mgr}
+	co.participants = append(co.participants, pt)
+	if mgr.ManagerID() == 0 {
+		mgr.SetManagerID(uint32(len(co.participants)))
+	}
+	return pt
+}
+
+// Name returns the name the participant joined with
+func (pt *Participant) Name() string {
+	return pt.name
+}
+
+// Manager returns the EventManager of the participant
+func (pt *Participant) Manager() *evtm.EventManager {
+	return pt.mgr
+}
+
+// Send sends an event to the participant to, to execute offset after the current time of the
+// sender.  It is called by a handler of the sender's EventManager, and returns an
+// *evtm.LookaheadError, sending nothing, if offset is less than the sender's lookahead, or
+// than the width of a window when the Coordinator runs windows of a fixed width.
+// The event is scheduled on the receiver at the end of the current window.
+func (pt *Participant) Send(to *Participant, context any, data any,
+	handler evtm.EventHandlerFunction, offset vrtime.Time) error {
+
+	now := pt.mgr.CurrentTime()
+	lookahead := pt.mgr.Lookahead()
+	if width := pt.co.Window(); width.Ticks() > lookahead.Ticks() {
+		lookahead = width
+	}
+	if offset.Ticks() < lookahead.Ticks() {
+		return &evtm.LookaheadError{Offset: offset, Lookahead: lookahead, Time: now}
+	}
+	at := vrtime.CreateTime(now.Ticks()+offset.Ticks(), offset.Pri())
+	msg := message{to: to, context: context, data: data, handler: handler, at: at}
+	if clock, on := pt.mgr.LogicalClock(); on {
+		msg.clock = &clock
+	}
+	pt.mu.Lock()
+	pt.outbox = append(pt.outbox, msg)
+	pt.mu.Unlock()
+	return nil
+}
+
+// SetWindow has the Coordinator run its members in windows of a fixed width, each window
+// starting at the earliest time of an event pending on any member, rather than in windows
+// bounded by their lookaheads.  Every member then runs to the end of the window, and the
+// messages sent within it are exchanged before the next begins, so each must be sent at
+// least width ahead.  A width of zero restores windows bounded by lookahead.
+func (co *Coordinator) SetWindow(width vrtime.Time) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.width = width.Ticks()
+	if co.width < 0 {
+		co.width = 0
+	}
+}
+
+// Window returns the fixed width of a window, zero if windows are bounded by lookahead
+func (co *Coordinator) Window() vrtime.Time {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return vrtime.CreateTime(co.width, 0)
+}
+
+// Windows returns the number of windows the Coordinator has run
+func (co *Coordinator) Windows() int {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return co.windows
+}
+
+// bound returns the time up to which, inclusive, every participant may safely advance, and
+// whether any event is pending before the limit
+func (co *Coordinator) bound(participants []*Participant, limit int64, width int64) (int64, bool, error) {
+	next, safe := vrtime.InfinityTime().Ticks(), limit
+	for _, pt := range participants {
+		ticks := pt.mgr.ProposeNextEventTime().Ticks()
+		if ticks == vrtime.InfinityTime().Ticks() {
+			continue
+		}
+		if ticks < next {
+			next = ticks
+		}
+		if len(participants) > 1 && width == 0 {
+			if horizon := ticks + pt.mgr.Lookahead().Ticks() - 1; horizon < safe {
+				safe = horizon
+			}
+		}
+	}
+	if next > limit {
+		return limit, false, nil
+	}
+	if width > 0 && next+width-1 < safe {
+		safe = next + width - 1
+	}
+	if safe < next {
+		return 0, false, fmt.Errorf("window at %g is empty; lookahead must be positive",
+			vrtime.TicksToSeconds(next))
+	}
+	return safe, true, nil
+}
+
+// Run runs the federation up to the time limit, leaving the clock of every participant at
+// limit.  It returns an error if a window cannot advance, as when a participant with pending
+// events has no lookahead, or if a handler aborts its EventManager (see [evtm.EventManager.Abort]),
+// in which case the run ends at the end of the window in which it did.
+func (co *Coordinator) Run(limit vrtime.Time) error {
+	co.mu.Lock()
+	participants := append([]*Participant(nil), co.participants...)
+	width := co.width
+	co.mu.Unlock()
+
+	for {
+		bound, pending, err := co.bound(participants, limit.Ticks(), width)
+		if err != nil {
+			return err
+		}
+
+		granted := vrtime.CreateTime(bound, 0)
+		reasons := make([]evtm.StopReason, len(participants))
+		var wg sync.WaitGroup
+		for i, pt := range participants {
+			wg.Add(1)
+			go func(i int, pt *Participant) {
+				defer wg.Done()
+				reasons[i] = pt.mgr.AdvanceTo(granted)
+			}(i, pt)
+		}
+		wg.Wait()
+		co.mu.Lock()
+		co.windows += 1
+		co.mu.Unlock()
+
+		co.deliver(participants)
+		for i, pt := range participants {
+			switch reasons[i] {
+			case evtm.StopAborted:
+				return fmt.Errorf("participant %s: %w", pt.name, pt.mgr.Err())
+			case evtm.StopStopped:
+				return fmt.Errorf("participant %s stopped at %g", pt.name, pt.mgr.CurrentSeconds())
+			}
+		}
+		if !pending {
+			return nil
+		}
+	}
+}
+
+// deliver schedules the messages sent in the window just run, ordered by time of arrival,
+// then sender, then order of sending
+func (co *Coordinator) deliver(participants []*Participant) {
+	var msgs []message
+	for _, pt := range participants {
+		pt.mu.Lock()
+		msgs = append(msgs, pt.outbox...)
+		pt.outbox = nil
+		pt.mu.Unlock()
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].at.Ticks() < msgs[j].at.Ticks()
+	})
+	for _, msg := range msgs {
+		offset := vrtime.CreateTime(msg.at.Ticks()-msg.to.mgr.CurrentTicks(), msg.at.Pri())
+		eventID, _ := msg.to.mgr.Schedule(msg.context, msg.data, msg.handler, offset)
+		if msg.clock != nil {
+			msg.to.mgr.StampEvent(eventID, *msg.clock)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package fed
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestWindowDrainsBound checks that a window executes every event at its bound, rather than
+// leaving events at the bound to windows of their own
+func TestWindowDrainsBound(t *testing.T) {
+	co := NewCoordinator()
+	first, second := evtm.New(), evtm.New()
+	for _, mgr := range []*evtm.EventManager{first, second} {
+		mgr.SetLookahead(vrtime.CreateTime(10, 0))
+	}
+	co.Join("first", first)
+	co.Join("second", second)
+
+	var dispatched []int64
+	record := func(evtmgr *evtm.EventManager, context any, data any) any {
+		dispatched = append(dispatched, evtmgr.CurrentTicks())
+		return nil
+	}
+
+	// the first window is bounded by the event at 0 plus the lookahead, less a tick, so
+	// the three events at 9 lie exactly at its bound
+	first.Schedule(nil, nil, record, vrtime.CreateTime(0, 0))
+	for idx := 0; idx < 3; idx++ {
+		first.Schedule(nil, nil, record, vrtime.CreateTime(9, 0))
+	}
+
+	if err := co.Run(vrtime.CreateTime(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(dispatched) != 4 {
+		t.Fatalf("dispatched events at %v, want 4", dispatched)
+	}
+	if windows := co.Windows(); windows != 2 {
+		t.Errorf("ran %d windows, want 2: one to the bound and one to the limit", windows)
+	}
+}
+
+// TestFixedWindow checks that members declaring no lookahead run in windows of the width set,
+// which a message must be sent at least as far ahead as
+func TestFixedWindow(t *testing.T) {
+	co := NewCoordinator()
+	co.SetWindow(vrtime.CreateTime(10, 0))
+	sender := co.Join("sender", evtm.New())
+	receiver := co.Join("receiver", evtm.New())
+
+	var received []int64
+	record := func(evtmgr *evtm.EventManager, context any, data any) any {
+		received = append(received, evtmgr.CurrentTicks())
+		return nil
+	}
+	var short, full error
+	sender.Manager().Schedule(nil, nil, func(evtmgr *evtm.EventManager, context any, data any) any {
+		short = sender.Send(receiver, nil, nil, record, vrtime.CreateTime(5, 0))
+		full = sender.Send(receiver, nil, nil, record, vrtime.CreateTime(10, 0))
+		return nil
+	}, vrtime.CreateTime(3, 0))
+
+	if err := co.Run(vrtime.CreateTime(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	var le *evtm.LookaheadError
+	if !errors.As(short, &le) || le.Lookahead.Ticks() != 10 || full != nil {
+		t.Errorf("sends 5 and 10 ahead returned %v and %v, want a LookaheadError of 10 and nil", short, full)
+	}
+	if len(received) != 1 || received[0] != 13 {
+		t.Errorf("received events at %v, want one at 13", received)
+	}
+	if windows := co.Windows(); windows != 3 {
+		t.Errorf("ran %d windows, want 3: from 3, from 13, and to the limit", windows)
+	}
+}
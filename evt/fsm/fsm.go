@@ -0,0 +1,251 @@
+// Package fsm provides finite-state machines whose states have timed transitions,
+// driven by an [evtm.EventManager].
+//
+// A protocol model typically arms timers on entering a state and must remember to cancel
+// them on every way out of it; forgetting one leaves a stale timer that later fires in
+// the wrong state.  A Machine takes this over: each state declares its timeouts, which
+// are scheduled when the state is entered and removed when it is left, whichever
+// transition leaves it.
+package fsm
+
+import (
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// State names a state of a Machine
+type State string
+
+// Guard decides whether a transition may be taken.  data is the data given with the
+// input that triggers the transition, nil for a timeout.
+type Guard func(mc *Machine, data any) bool
+
+// Action is performed when a transition is taken, after the state left has been
+// exited and before the target state is entered.
+type Action func(mc *Machine, data any)
+
+// StateHooks are called as a Machine enters and leaves a state.  Either may be nil.
+type StateHooks struct {
+	OnEnter func(mc *Machine)
+	OnExit  func(mc *Machine)
+}
+
+// transition is a transition out of a state, triggered by an input or by a timeout
+type transition struct {
+	input   string
+	timeout vrtime.Time
+	target  State
+	guard   Guard
+	action  Action
+}
+
+// stateDef holds the declaration of a state
+type stateDef struct {
+	hooks    StateHooks
+	inputs   []transition // transitions triggered by inputs, in the order declared
+	timeouts []transition // transitions triggered by timeouts, in the order declared
+}
+
+// input is an input to the Machine waiting for the transition in progress to complete
+type input struct {
+	name string
+	data any
+}
+
+// Machine is a finite-state machine with timed transitions.  Its methods are meant to be
+// called by the thread running its EventManager, i.e., from event handlers, and are not
+// safe for concurrent use.
+type Machine struct {
+	// OnChange, if not nil, is called after each transition, e.g., to trace the
+	// behaviour of the Machine.  data is that of the input that triggered the transition.
+	OnChange func(mc *Machine, from State, to State, data any)
+
+	mgr     *evtm.EventManager
+	context any
+	states  map[State]*stateDef
+	current State
+	started bool
+	entered vrtime.Time // time the current state was entered
+	armed   []int       // identifiers of the pending timeouts of the current state
+	gen     int         // number of times a state has been entered, to recognize stale timeouts
+	busy    bool        // true while a transition is in progress
+	pending []input     // inputs received while a transition was in progress
+}
+
+// New creates a Machine with no states, whose timeouts are scheduled on mgr.
+// context is available to the hooks, guards, and actions through Context.
+func New(mgr *evtm.EventManager, context any) *Machine {
+	return &Machine{mgr: mgr, context: context, states: make(map[State]*stateDef)}
+}
+
+// state returns the declaration of a state, creating it on first reference
+func (mc *Machine) state(name State) *stateDef {
+	def, present := mc.states[name]
+	if !present {
+		def = &stateDef{}
+		mc.states[name] = def
+	}
+	return def
+}
+
+// AddState sets the hooks called on entering and leaving a state.  States need not be added
+// before they are referred to by a transition; a state with no hooks need not be added at all.
+func (mc *Machine) AddState(name State, hooks StateHooks) {
+	mc.state(name).hooks = hooks
+}
+
+// AddTransition declares a transition from one state to another, taken when the input is
+// given to Fire while the Machine is in the from state and guard, if not nil, holds.
+// Where several transitions of a state have the same input the first declared whose guard
+// holds is taken.  A transition from a state to itself leaves and re-enters the state,
+// restarting its timeouts.
+func (mc *Machine) AddTransition(from State, inputName string, to State, guard Guard, action Action) {
+	def := mc.state(from)
+	def.inputs = append(def.inputs, transition{input: inputName, target: to, guard: guard, action: action})
+	mc.state(to)
+}
+
+// AddTimeout declares a transition from one state to another, taken when the Machine has
+// been in the from state for the given time and guard, if not nil, holds.  If the guard
+// does not hold when the time comes, the timeout lapses and the Machine stays where it is.
+func (mc *Machine) AddTimeout(from State, after vrtime.Time, to State, guard Guard, action Action) {
+	def := mc.state(from)
+	def.timeouts = append(def.timeouts, transition{timeout: after, target: to, guard: guard, action: action})
+	mc.state(to)
+}
+
+// Start puts the Machine into its initial state, calling the state's OnEnter hook
+// and arming its timeouts.  It returns false if the Machine has already been started.
+func (mc *Machine) Start(initial State) bool {
+	if mc.started {
+		return false
+	}
+	mc.started = true
+	mc.busy = true
+	mc.enter(initial)
+	mc.busy = false
+	mc.drain()
+	return true
+}
+
+// Context returns the context given to New
+func (mc *Machine) Context() any {
+	return mc.context
+}
+
+// Manager returns the EventManager the Machine's timeouts are scheduled on
+func (mc *Machine) Manager() *evtm.EventManager {
+	return mc.mgr
+}
+
+// State returns the current state of the Machine
+func (mc *Machine) State() State {
+	return mc.current
+}
+
+// InState returns the time the Machine has spent in its current state
+func (mc *Machine) InState() vrtime.Time {
+	return vrtime.CreateTime(mc.mgr.CurrentTicks()-mc.entered.Ticks(), 0)
+}
+
+// Fire gives an input, with its data, to the Machine, taking the transition of the current
+// state that the input triggers.  It returns false if the input triggers no transition.
+// An input given while a transition is in progress, e.g., by an Action or a hook, is taken up
+// once that transition is complete; Fire then returns true.
+func (mc *Machine) Fire(inputName string, data any) bool {
+	if !mc.started {
+		return false
+	}
+	if mc.busy {
+		mc.pending = append(mc.pending, input{name: inputName, data: data})
+		return true
+	}
+	taken := mc.fire(inputName, data)
+	mc.drain()
+	return taken
+}
+
+// fire takes the transition triggered by an input, reporting whether there is one
+func (mc *Machine) fire(inputName string, data any) bool {
+	for _, tr := range mc.states[mc.current].inputs {
+		if tr.input == inputName && (tr.guard == nil || tr.guard(mc, data)) {
+			mc.take(tr, data)
+			return true
+		}
+	}
+	return false
+}
+
+// drain takes up the inputs received while transitions were in progress
+func (mc *Machine) drain() {
+	for len(mc.pending) > 0 {
+		next := mc.pending[0]
+		mc.pending = mc.pending[1:]
+		mc.fire(next.name, next.data)
+	}
+}
+
+// take carries out a transition from the current state
+func (mc *Machine) take(tr transition, data any) {
+	mc.busy = true
+	from := mc.current
+	mc.exit()
+	if tr.action != nil {
+		tr.action(mc, data)
+	}
+	mc.enter(tr.target)
+	if mc.OnChange != nil {
+		mc.OnChange(mc, from, tr.target, data)
+	}
+	mc.busy = false
+}
+
+// enter makes a state current, calls its OnEnter hook, and arms its timeouts
+func (mc *Machine) enter(name State) {
+	def := mc.state(name)
+	mc.current = name
+	mc.entered = mc.mgr.CurrentTime()
+	mc.gen += 1
+	for idx, tr := range def.timeouts {
+		eventID, _ := mc.mgr.Schedule(mc, timeoutRef{gen: mc.gen, index: idx}, expire, tr.timeout)
+		mc.armed = append(mc.armed, eventID)
+	}
+	if def.hooks.OnEnter != nil {
+		def.hooks.OnEnter(mc)
+	}
+}
+
+// exit calls the OnExit hook of the current state and removes its pending timeouts
+func (mc *Machine) exit() {
+	for _, eventID := range mc.armed {
+		mc.mgr.RemoveEvent(eventID)
+	}
+	mc.armed = mc.armed[:0]
+	if hooks := mc.states[mc.current].hooks; hooks.OnExit != nil {
+		hooks.OnExit(mc)
+	}
+}
+
+// timeoutRef identifies a timeout of a state, as entered on a particular occasion
+type timeoutRef struct {
+	gen   int
+	index int
+}
+
+// expire is the event handler of a timeout
+func expire(mgr *evtm.EventManager, context any, data any) any {
+	mc := context.(*Machine)
+	ref := data.(timeoutRef)
+
+	// a timeout of a state since left is removed on leaving, and so should never be seen
+	if ref.gen != mc.gen {
+		return nil
+	}
+	tr := mc.states[mc.current].timeouts[ref.index]
+	if tr.guard != nil && !tr.guard(mc, nil) {
+		return nil
+	}
+	mc.take(tr, nil)
+	mc.drain()
+	return nil
+}
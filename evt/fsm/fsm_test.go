@@ -0,0 +1,41 @@
+package fsm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestTimeouts checks that a state's timeout is taken if the state is not left in time, and
+// is removed, not merely ignored, when another transition leaves the state first
+func TestTimeouts(t *testing.T) {
+	mgr := evtm.New()
+	mc := New(mgr, nil)
+	var changes []string
+	mc.OnChange = func(mc *Machine, from State, to State, data any) {
+		changes = append(changes, fmt.Sprintf("%s>%s@%d", from, to, mgr.CurrentTicks()))
+	}
+	mc.AddTransition("idle", "open", "waiting", nil, nil)
+	mc.AddTransition("waiting", "ack", "established", nil, nil)
+	mc.AddTimeout("waiting", vrtime.CreateTime(10, 0), "idle", nil, nil)
+	mc.Start("idle")
+
+	give := func(evtmgr *evtm.EventManager, context any, data any) any {
+		mc.Fire(data.(string), nil)
+		return nil
+	}
+	mgr.Schedule(nil, "open", give, vrtime.CreateTime(0, 0))
+	mgr.Schedule(nil, "open", give, vrtime.CreateTime(20, 0))
+	mgr.Schedule(nil, "ack", give, vrtime.CreateTime(25, 0))
+	mgr.AdvanceTo(vrtime.CreateTime(100, 0))
+
+	want := []string{"idle>waiting@0", "waiting>idle@10", "idle>waiting@20", "waiting>established@25"}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("changes %v, want %v", changes, want)
+	}
+	if mc.State() != "established" || mgr.EventList.Len() != 0 {
+		t.Errorf("ended in %s with %d events pending, want established with none", mc.State(), mgr.EventList.Len())
+	}
+}
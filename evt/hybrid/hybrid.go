@@ -0,0 +1,261 @@
+// Package hybrid interleaves the numerical integration of continuous state with the
+// discrete events of an [evtm.EventManager], for simple cyber-physical models that
+// would otherwise need an external tool.
+//
+// A System holds a vector of continuous variables, advanced by a user-supplied Stepper.
+// Integration proceeds in steps of at most a given length, each step being an event.
+// Before a step is committed the System looks ahead over it for threshold crossings,
+// each described by a Crossing function of the state whose sign change marks the crossing.
+// A crossing found is located, by bisection, to within a tick, and its handler is scheduled
+// as a discrete event at the crossing time.  Discrete event handlers read the state through
+// Sync, which first integrates up to the present, and change it through Update, after
+// which the look-ahead is redone from the changed state.
+package hybrid
+
+import (
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// Derivative computes the time derivative of the state x at time t, in seconds, into dxdt
+type Derivative func(t float64, x []float64, dxdt []float64)
+
+// Stepper advances the state x at time t, in seconds, by a step of h seconds,
+// returning the new state.  It must not modify x.
+type Stepper func(t float64, h float64, x []float64) []float64
+
+// Euler returns a Stepper applying the forward Euler method to the derivative f
+func Euler(f Derivative) Stepper {
+	return func(t float64, h float64, x []float64) []float64 {
+		dxdt := make([]float64, len(x))
+		f(t, x, dxdt)
+		next := make([]float64, len(x))
+		for idx := range x {
+			next[idx] = x[idx] + h*dxdt[idx]
+		}
+		return next
+	}
+}
+
+// RK4 returns a Stepper applying the classical fourth-order Runge-Kutta method to the derivative f
+func RK4(f Derivative) Stepper {
+	return func(t float64, h float64, x []float64) []float64 {
+		n := len(x)
+		k1, k2, k3, k4 := make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+		tmp := make([]float64, n)
+		f(t, x, k1)
+		for idx := range x {
+			tmp[idx] = x[idx] + h/2*k1[idx]
+		}
+		f(t+h/2, tmp, k2)
+		for idx := range x {
+			tmp[idx] = x[idx] + h/2*k2[idx]
+		}
+		f(t+h/2, tmp, k3)
+		for idx := range x {
+			tmp[idx] = x[idx] + h*k3[idx]
+		}
+		f(t+h, tmp, k4)
+		next := make([]float64, n)
+		for idx := range x {
+			next[idx] = x[idx] + h/6*(k1[idx]+2*k2[idx]+2*k3[idx]+k4[idx])
+		}
+		return next
+	}
+}
+
+// Direction selects the sign changes of a Crossing function that count as a crossing
+type Direction int
+
+const (
+	// Either counts a change of sign in either direction
+	Either Direction = iota
+
+	// Rising counts a change from negative to non-negative
+	Rising
+
+	// Falling counts a change from positive to non-positive
+	Falling
+)
+
+// Crossing describes a threshold crossing to be located in the continuous state
+type Crossing struct {
+	Name      string                               // identifies the crossing to its handler
+	Fn        func(t float64, x []float64) float64 // crosses zero at the threshold
+	Direction Direction                            // which sign changes count
+	Handler   func(sys *System, cr *Crossing)      // called as a discrete event at the crossing
+}
+
+// crossed reports whether the function values before and after a step make a crossing
+func (cr *Crossing) crossed(before, after float64) bool {
+	rising := before < 0 && after >= 0
+	falling := before > 0 && after <= 0
+	switch cr.Direction {
+	case Rising:
+		return rising
+	case Falling:
+		return falling
+	}
+	return rising || falling
+}
+
+// System is a vector of continuous variables integrated alongside the discrete events of an
+// EventManager.  Its methods are meant to be called by the thread running the EventManager.
+type System struct {
+	mgr       *evtm.EventManager
+	stepper   Stepper
+	step      vrtime.Time // largest step of integration
+	ticks     int64       // time, in ticks, up to which the state has been integrated
+	x         []float64   // state at that time
+	crossings []*Crossing
+	pending   int       // identifier of the event of the look-ahead, evtq.InvalidEventID if none
+	next      *Crossing // crossing the pending event is for, nil for a plain step
+	running   bool
+}
+
+// New creates a System with the initial state x0 at the current time of mgr,
+// integrated by stepper in steps no longer than step.
+func New(mgr *evtm.EventManager, x0 []float64, stepper Stepper, step vrtime.Time) *System {
+	x := make([]float64, len(x0))
+	copy(x, x0)
+	if step.Ticks() < 1 {
+		step = vrtime.CreateTime(1, 0)
+	}
+	return &System{mgr: mgr, stepper: stepper, step: step, ticks: mgr.CurrentTicks(),
+		x: x, pending: evtq.InvalidEventID}
+}
+
+// AddCrossing adds a threshold crossing to be located.  It takes effect from the next look-ahead.
+func (sys *System) AddCrossing(cr *Crossing) {
+	sys.crossings = append(sys.crossings, cr)
+}
+
+// Start begins integration, scheduling the first step.
+func (sys *System) Start() {
+	sys.running = true
+	sys.plan()
+}
+
+// Stop ends integration, removing the pending step.  The state stays as last integrated.
+func (sys *System) Stop() {
+	sys.running = false
+	sys.cancel()
+}
+
+// Manager returns the EventManager the System is integrated alongside
+func (sys *System) Manager() *evtm.EventManager {
+	return sys.mgr
+}
+
+// Sync integrates the state up to the current time and returns it.
+// The slice returned is the System's own, and must not be modified.
+func (sys *System) Sync() []float64 {
+	sys.advance(sys.mgr.CurrentTicks())
+	return sys.x
+}
+
+// Update integrates the state up to the current time and lets change modify it, e.g.,
+// to model a discrete control action, then looks ahead afresh from the modified state.
+func (sys *System) Update(change func(x []float64)) {
+	sys.advance(sys.mgr.CurrentTicks())
+	change(sys.x)
+	if sys.running {
+		sys.cancel()
+		sys.plan()
+	}
+}
+
+// seconds converts a tick count to seconds
+func seconds(ticks int64) float64 {
+	return vrtime.TicksToSeconds(ticks)
+}
+
+// integrate returns the state x at fromTicks integrated forward to toTicks
+func (sys *System) integrate(fromTicks int64, x []float64, toTicks int64) []float64 {
+	for fromTicks < toTicks {
+		stepTicks := sys.step.Ticks()
+		if toTicks-fromTicks < stepTicks {
+			stepTicks = toTicks - fromTicks
+		}
+		x = sys.stepper(seconds(fromTicks), seconds(stepTicks), x)
+		fromTicks += stepTicks
+	}
+	return x
+}
+
+// advance commits the integration of the state up to the given time
+func (sys *System) advance(toTicks int64) {
+	if toTicks <= sys.ticks {
+		return
+	}
+	sys.x = sys.integrate(sys.ticks, sys.x, toTicks)
+	sys.ticks = toTicks
+}
+
+// cancel removes the pending event of the look-ahead
+func (sys *System) cancel() {
+	if sys.pending != evtq.InvalidEventID {
+		sys.mgr.RemoveEvent(sys.pending)
+		sys.pending = evtq.InvalidEventID
+	}
+}
+
+// plan looks ahead one step from the current state, and schedules either the first crossing
+// found within it, at the first tick at which the crossing has occurred, or the end of the step
+func (sys *System) plan() {
+	start := sys.ticks
+	end := start + sys.step.Ticks()
+	after := sys.integrate(start, sys.x, end)
+
+	var first *Crossing
+	at := end
+	for _, cr := range sys.crossings {
+		before := cr.Fn(seconds(start), sys.x)
+		if !cr.crossed(before, cr.Fn(seconds(end), after)) {
+			continue
+		}
+
+		// bisect for the first tick at which the crossing has occurred; lo precedes it
+		lo, hi := start, end
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			if cr.crossed(before, cr.Fn(seconds(mid), sys.integrate(start, sys.x, mid))) {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		if hi < at || first == nil {
+			first, at = cr, hi
+		}
+	}
+
+	offset := at - sys.mgr.CurrentTicks()
+	if offset < 0 {
+		offset = 0
+	}
+	sys.next = first
+	sys.pending, _ = sys.mgr.Schedule(sys, nil, stepEvent, vrtime.CreateTime(offset, 0))
+}
+
+// stepEvent is the handler of the event of the look-ahead.  It commits the integration
+// up to the present, calls the handler of the crossing found, if any, and looks ahead again.
+func stepEvent(mgr *evtm.EventManager, context any, data any) any {
+	sys := context.(*System)
+	sys.pending = evtq.InvalidEventID
+	if !sys.running {
+		return nil
+	}
+	sys.advance(mgr.CurrentTicks())
+	if cr := sys.next; cr != nil {
+		sys.next = nil
+		if cr.Handler != nil {
+			cr.Handler(sys, cr)
+		}
+	}
+	if sys.running && sys.pending == evtq.InvalidEventID {
+		sys.plan()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package hybrid
+
+import (
+	"math"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestCrossing checks that a threshold crossing falling inside a step is located to within a
+// tick, and that a state changed by its handler is looked ahead from afresh
+func TestCrossing(t *testing.T) {
+	mgr := evtm.New()
+	ramp := Euler(func(t float64, x []float64, dxdt []float64) { dxdt[0] = 1 })
+	sys := New(mgr, []float64{0}, ramp, vrtime.CreateTime(vrtime.SecondsToTicks(0.3), 0))
+
+	var at []int64
+	sys.AddCrossing(&Crossing{
+		Name:      "half",
+		Fn:        func(t float64, x []float64) float64 { return x[0] - 0.5 },
+		Direction: Rising,
+		Handler: func(sys *System, cr *Crossing) {
+			at = append(at, sys.Manager().CurrentTicks())
+			sys.Update(func(x []float64) { x[0] = 0 })
+		},
+	})
+	sys.Start()
+	mgr.RunUntil(1.2)
+
+	half := vrtime.SecondsToTicks(0.5)
+	if len(at) != 2 || math.Abs(float64(at[0]-half)) > 1 || math.Abs(float64(at[1]-2*half)) > 2 {
+		t.Errorf("crossings at ticks %v, want %d and %d", at, half, 2*half)
+	}
+	if x := sys.Sync()[0]; math.Abs(x-0.2) > 1e-6 {
+		t.Errorf("state %g at 1.2s, want 0.2", x)
+	}
+}
@@ -0,0 +1,57 @@
+// Package slab carves values out of preallocated chunks, so that a high volume of
+// short-lived values costs one allocation per chunk rather than one per value.  It
+// serves the slab allocation of the items of an evtq.EventQueue and of the Events of an
+// evtm.EventManager.
+package slab
+
+// Slab carves values of type T out of preallocated chunks
+type Slab[T any] struct {
+	chunks [][]T // chunks allocated so far
+	chunk  int   // index of the chunk values are currently carved from
+	pos    int   // position in that chunk of the next value
+	size   int   // number of values per chunk
+}
+
+// New returns a Slab carving its chunks size values at a time
+func New[T any](size int) *Slab[T] {
+	return &Slab[T]{size: size}
+}
+
+// Size returns the number of values per chunk
+func (s *Slab[T]) Size() int {
+	return s.size
+}
+
+// Get returns a pointer to a zeroed value carved from the slab
+func (s *Slab[T]) Get() *T {
+	if s.chunk < len(s.chunks) && s.pos == s.size {
+		s.chunk += 1
+		s.pos = 0
+	}
+	if s.chunk == len(s.chunks) {
+		s.chunks = append(s.chunks, make([]T, s.size))
+	}
+	v := &s.chunks[s.chunk][s.pos]
+	s.pos += 1
+	return v
+}
+
+// Release frees the slab wholesale.  If reuse is true no value carved from
+// the slab may still be in use, and the chunks are zeroed and kept to be carved again.
+// Otherwise the chunks are dropped; the memory of each is reclaimed by the collector
+// once the last value still in use within it is gone.
+func (s *Slab[T]) Release(reuse bool) {
+	if !reuse {
+		s.chunks = nil
+	} else {
+		var zero T
+		for idx := 0; idx <= s.chunk && idx < len(s.chunks); idx++ {
+			chunk := s.chunks[idx]
+			for pos := range chunk {
+				chunk[pos] = zero
+			}
+		}
+	}
+	s.chunk = 0
+	s.pos = 0
+}
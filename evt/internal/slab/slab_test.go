@@ -0,0 +1,37 @@
+package slab
+
+import "testing"
+
+// TestSlab checks that values are carved chunk by chunk, that Release with reuse zeroes the
+// chunks and carves them again from the start, and that Release without it drops them
+func TestSlab(t *testing.T) {
+	s := New[int](3)
+	var carved []*int
+	for idx := 0; idx < 7; idx++ {
+		v := s.Get()
+		if *v != 0 {
+			t.Fatalf("value %d carved holding %d", idx, *v)
+		}
+		*v = idx + 1
+		carved = append(carved, v)
+	}
+	if len(s.chunks) != 3 {
+		t.Fatalf("7 values carved from %d chunks of 3", len(s.chunks))
+	}
+
+	s.Release(true)
+	if *carved[0] != 0 || *carved[6] != 0 {
+		t.Error("Release with reuse left values unzeroed")
+	}
+	if s.Get() != carved[0] || len(s.chunks) != 3 {
+		t.Error("Release with reuse did not carve the chunks again from the start")
+	}
+
+	s.Release(false)
+	if s.Get() == carved[0] {
+		t.Error("Release without reuse carved from a dropped chunk")
+	}
+	if len(s.chunks) != 1 || s.Size() != 3 {
+		t.Errorf("after Release without reuse, %d chunks of %d", len(s.chunks), s.Size())
+	}
+}
@@ -0,0 +1,237 @@
+// Package loadgen generates synthetic event workloads for performance studies.
+// It populates an [evtm.EventManager] with a self-sustaining population of events
+// whose shape is chosen to stress a particular aspect of the event list, runs it
+// for a given number of dispatches, and reports the sustained rate.  This lets a user
+// size the event list backend and the hardware for a model before building the model.
+//
+// Three workloads are provided:
+//   - HoldModel is the classic hold model, a fixed population of pending events each of
+//     which schedules one successor a random offset into the future.
+//   - SelfSimilar aggregates on/off sources with heavy-tailed (Pareto) period lengths,
+//     producing the bursty, self-similar arrivals seen in network traffic.
+//   - TimerChurn keeps a population of timeouts that are almost always reset before they
+//     expire, as retransmission timers in a protocol model are, so that most of the work
+//     of the event list is removal and re-insertion rather than dispatch.
+package loadgen
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Workload is a population of events that sustains itself as it executes
+type Workload interface {
+	// Name identifies the workload in a Result
+	Name() string
+
+	// Populate schedules the initial events of the workload on mgr.  Every handler
+	// of the workload calls drv.Count, and schedules no further events once it returns false.
+	Populate(mgr *evtm.EventManager, drv *Driver)
+}
+
+// Driver meters the execution of a Workload and supplies its random numbers
+type Driver struct {
+	Rand      *rand.Rand // source of the workload's random numbers
+	remaining int64      // number of events still to be dispatched
+}
+
+// Count records the dispatch of an event of the workload.  Once the number of events
+// to be measured has been reached it stops the EventManager and returns false.
+func (drv *Driver) Count(mgr *evtm.EventManager) bool {
+	drv.remaining -= 1
+	if drv.remaining > 0 {
+		return true
+	}
+	mgr.Stop()
+	return false
+}
+
+// exponential returns an offset drawn from the exponential distribution with the given mean, in ticks.
+// The offset is at least one tick.
+func (drv *Driver) exponential(mean vrtime.Time) vrtime.Time {
+	ticks := int64(math.Round(drv.Rand.ExpFloat64() * float64(mean.Ticks())))
+	if ticks < 1 {
+		ticks = 1
+	}
+	return vrtime.CreateTime(ticks, 0)
+}
+
+// pareto returns an offset drawn from the Pareto distribution with shape alpha
+// and the given minimum, in ticks
+func (drv *Driver) pareto(alpha float64, minimum vrtime.Time) vrtime.Time {
+	ticks := float64(minimum.Ticks()) / math.Pow(1.0-drv.Rand.Float64(), 1.0/alpha)
+	if ticks > math.MaxInt64/4 {
+		ticks = math.MaxInt64 / 4
+	}
+	return vrtime.CreateTime(int64(math.Round(ticks)), 0)
+}
+
+// Result reports the outcome of measuring a Workload
+type Result struct {
+	Workload    string        // name of the workload
+	Events      int64         // number of events dispatched
+	Elapsed     time.Duration // wallclock time the dispatch took
+	VirtualTime float64       // virtual time, in seconds, the workload advanced through
+}
+
+// Rate returns the sustained number of events dispatched per second of wallclock time
+func (res Result) Rate() float64 {
+	if res.Elapsed <= 0 {
+		return 0
+	}
+	return float64(res.Events) / res.Elapsed.Seconds()
+}
+
+// Options modify how Measure builds the EventManager a workload runs on
+type Options struct {
+	Seed     int64 // seed of the workload's random numbers
+	SizeHint int   // expected number of pending events, passed to evtm.New
+	SlabSize int   // slab size for event allocation, 0 to allocate events individually
+}
+
+// Measure runs the workload on a new EventManager until it has dispatched the given number
+// of events, and reports the sustained rate.  Populating the EventManager is not timed.
+func Measure(wl Workload, events int64, opts Options) Result {
+	mgr := evtm.New(opts.SizeHint)
+	mgr.SetSlabSize(opts.SlabSize)
+	drv := &Driver{Rand: rand.New(rand.NewSource(opts.Seed)), remaining: events}
+	wl.Populate(mgr, drv)
+
+	start := time.Now()
+	mgr.Run(vrtime.TicksToSeconds(math.MaxInt64 / 2))
+	elapsed := time.Since(start)
+
+	return Result{Workload: wl.Name(), Events: int64(mgr.EventsDispatched()),
+		Elapsed: elapsed, VirtualTime: mgr.CurrentSeconds()}
+}
+
+// HoldModel is the classic hold model: Pending events are kept in the event list at
+// all times, each scheduling one successor an exponentially distributed offset, with mean
+// Mean, into the future when it executes.
+type HoldModel struct {
+	Pending int
+	Mean    vrtime.Time
+}
+
+// Name identifies the workload
+func (hm HoldModel) Name() string {
+	return "hold"
+}
+
+// Populate schedules the initial population of events
+func (hm HoldModel) Populate(mgr *evtm.EventManager, drv *Driver) {
+	var hold evtm.EventHandlerFunction
+	hold = func(mgr *evtm.EventManager, context any, data any) any {
+		if drv.Count(mgr) {
+			mgr.Schedule(context, data, hold, drv.exponential(hm.Mean))
+		}
+		return nil
+	}
+	for idx := 0; idx < hm.Pending; idx++ {
+		mgr.Schedule(nil, nil, hold, drv.exponential(hm.Mean))
+	}
+}
+
+// SelfSimilar aggregates Sources on/off sources.  The lengths of the on and off periods
+// of each source follow a Pareto distribution with shape Alpha (between 1 and 2 for
+// self-similar aggregate traffic) and minimums MinOn and MinOff.  While on, a source
+// generates an arrival event every Interarrival.
+type SelfSimilar struct {
+	Sources      int
+	Alpha        float64
+	MinOn        vrtime.Time
+	MinOff       vrtime.Time
+	Interarrival vrtime.Time
+}
+
+// Name identifies the workload
+func (ss SelfSimilar) Name() string {
+	return "selfsimilar"
+}
+
+// onOffSource is the state of one source of a SelfSimilar workload
+type onOffSource struct {
+	onUntil int64 // tick at which the current on period ends
+}
+
+// Populate schedules the first on period of each source
+func (ss SelfSimilar) Populate(mgr *evtm.EventManager, drv *Driver) {
+	var arrive, turnOn evtm.EventHandlerFunction
+
+	arrive = func(mgr *evtm.EventManager, context any, data any) any {
+		if !drv.Count(mgr) {
+			return nil
+		}
+		src := context.(*onOffSource)
+		if mgr.CurrentTicks()+ss.Interarrival.Ticks() < src.onUntil {
+			mgr.Schedule(src, nil, arrive, ss.Interarrival)
+		} else {
+			// the on period is over, so wait out an off period
+			mgr.Schedule(src, nil, turnOn, drv.pareto(ss.Alpha, ss.MinOff))
+		}
+		return nil
+	}
+
+	turnOn = func(mgr *evtm.EventManager, context any, data any) any {
+		if !drv.Count(mgr) {
+			return nil
+		}
+		src := context.(*onOffSource)
+		src.onUntil = mgr.CurrentTicks() + drv.pareto(ss.Alpha, ss.MinOn).Ticks()
+		mgr.Schedule(src, nil, arrive, ss.Interarrival)
+		return nil
+	}
+
+	for idx := 0; idx < ss.Sources; idx++ {
+		mgr.Schedule(&onOffSource{}, nil, turnOn, drv.pareto(ss.Alpha, ss.MinOff))
+	}
+}
+
+// TimerChurn keeps Timers timeouts of length Timeout pending.  Traffic for each timer
+// arrives at exponentially distributed intervals with mean Mean, and every arrival resets
+// the timer.  When Mean is well below Timeout nearly every timer is reset, rather than
+// expiring, so most of the work of the event list is the removal and re-insertion of events.
+type TimerChurn struct {
+	Timers  int
+	Timeout vrtime.Time
+	Mean    vrtime.Time
+}
+
+// Name identifies the workload
+func (tc TimerChurn) Name() string {
+	return "timerchurn"
+}
+
+// churnTimer is the context of the events of a TimerChurn workload
+type churnTimer struct {
+	timer *evtm.Timer
+}
+
+// Populate starts the timers and the traffic that resets them
+func (tc TimerChurn) Populate(mgr *evtm.EventManager, drv *Driver) {
+	expire := func(mgr *evtm.EventManager, context any, data any) any {
+		if drv.Count(mgr) {
+			context.(*churnTimer).timer.Start(tc.Timeout)
+		}
+		return nil
+	}
+
+	var traffic evtm.EventHandlerFunction
+	traffic = func(mgr *evtm.EventManager, context any, data any) any {
+		if drv.Count(mgr) {
+			context.(*churnTimer).timer.Reset(tc.Timeout)
+			mgr.Schedule(context, nil, traffic, drv.exponential(tc.Mean))
+		}
+		return nil
+	}
+
+	for idx := 0; idx < tc.Timers; idx++ {
+		ct := &churnTimer{}
+		ct.timer = mgr.StartTimer(ct, nil, expire, tc.Timeout)
+		mgr.Schedule(ct, nil, traffic, drv.exponential(tc.Mean))
+	}
+}
@@ -0,0 +1,30 @@
+package loadgen
+
+import (
+	"testing"
+
+	"github.com/iti/evt/vrtime"
+)
+
+// TestMeasure checks that each workload sustains itself for the number of events asked for,
+// and that the same seed reproduces the same run
+func TestMeasure(t *testing.T) {
+	workloads := []Workload{
+		HoldModel{Pending: 100, Mean: vrtime.CreateTime(1000, 0)},
+		SelfSimilar{Sources: 20, Alpha: 1.5, MinOn: vrtime.CreateTime(500, 0),
+			MinOff: vrtime.CreateTime(500, 0), Interarrival: vrtime.CreateTime(10, 0)},
+		TimerChurn{Timers: 50, Timeout: vrtime.CreateTime(1000, 0), Mean: vrtime.CreateTime(100, 0)},
+	}
+	for _, wl := range workloads {
+		first := Measure(wl, 5000, Options{Seed: 7, SlabSize: 64})
+		if first.Workload != wl.Name() || first.Events != 5000 {
+			t.Errorf("%s dispatched %d events, want 5000", wl.Name(), first.Events)
+		}
+		if first.VirtualTime <= 0 || first.Rate() <= 0 {
+			t.Errorf("%s reached virtual time %g at rate %g", wl.Name(), first.VirtualTime, first.Rate())
+		}
+		if again := Measure(wl, 5000, Options{Seed: 7}); again.VirtualTime != first.VirtualTime {
+			t.Errorf("%s ended at %g and then at %g from the same seed", wl.Name(), first.VirtualTime, again.VirtualTime)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package lp partitions a model across logical processes, each with its own
+// [evtm.EventManager], run in parallel by a conservative [fed.Coordinator].
+//
+// The model declares its entities and assigns each to a logical process (LP).  An event is
+// taken to act on the entity given as its context, so an event a handler schedules with the
+// entity of another LP as context is moved, as it is scheduled, to that LP's EventManager,
+// and the model code that schedules it need not change.  Each LP declares a lookahead, the
+// least offset at which it schedules events on the entities of other LPs; scheduling one
+// sooner aborts the LP's run with an *evtm.LookaheadError.
+package lp
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/fed"
+	"github.com/iti/evt/vrtime"
+)
+
+// Partition is a model divided among logical processes
+type Partition struct {
+	co      *fed.Coordinator
+	lps     []*LP
+	owner   map[any]*LP
+	running bool
+	mu      sync.Mutex
+}
+
+// LP is a logical process of a Partition: the entities assigned to it and the EventManager
+// executing the events acting on them
+type LP struct {
+	name string
+	part *Partition
+	mgr  *evtm.EventManager
+	pt   *fed.Participant
+}
+
+// New creates a Partition with no logical processes
+func New() *Partition {
+	return &Partition{co: fed.NewCoordinator(), owner: make(map[any]*LP)}
+}
+
+// AddLP creates a logical process with its own EventManager, named name, whose events on
+// the entities of other LPs are scheduled at least lookahead ahead
+func (part *Partition) AddLP(name string, lookahead vrtime.Time) *LP {
+	mgr := evtm.New(0)
+	mgr.SetLookahead(lookahead)
+	lp := &LP{name: name, part: part, mgr: mgr}
+	lp.pt = part.co.Join(name, mgr)
+	mgr.AddScheduleObserver(lp.route)
+
+	part.mu.Lock()
+	part.lps = append(part.lps, lp)
+	part.mu.Unlock()
+	return lp
+}
+
+// Assign assigns entity to the logical process lp, to which the events scheduled with entity
+// as context are then routed.  entity must be comparable, typically a pointer.  Entities are
+// assigned before the Partition runs.
+func (part *Partition) Assign(entity any, lp *LP) error {
+	if entity == nil || !reflect.TypeOf(entity).Comparable() {
+		return fmt.Errorf("entity of type %T cannot be assigned to an LP", entity)
+	}
+	part.mu.Lock()
+	defer part.mu.Unlock()
+	part.owner[entity] = lp
+	return nil
+}
+
+// Owner returns the logical process to which entity is assigned, or nil if it is not
+func (part *Partition) Owner(entity any) *LP {
+	if entity == nil || !reflect.TypeOf(entity).Comparable() {
+		return nil
+	}
+	part.mu.Lock()
+	defer part.mu.Unlock()
+	return part.owner[entity]
+}
+
+// LPs returns the logical processes of the Partition, in the order they were added
+func (part *Partition) LPs() []*LP {
+	part.mu.Lock()
+	defer part.mu.Unlock()
+	return append([]*LP(nil), part.lps...)
+}
+
+// Run runs every logical process up to the time limit, as [fed.Coordinator.Run] does
+func (part *Partition) Run(limit vrtime.Time) error {
+	part.mu.Lock()
+	part.running = true
+	part.mu.Unlock()
+	defer func() {
+		part.mu.Lock()
+		part.running = false
+		part.mu.Unlock()
+	}()
+	return part.co.Run(limit)
+}
+
+// Coordinator returns the Coordinator running the logical processes, e.g., to count its windows
+func (part *Partition) Coordinator() *fed.Coordinator {
+	return part.co
+}
+
+// Name returns the name of the logical process
+func (lp *LP) Name() string {
+	return lp.name
+}
+
+// Manager returns the EventManager of the logical process.  Events may be scheduled on it
+// directly, e.g., to start the model, and are routed as those scheduled by handlers are.
+func (lp *LP) Manager() *evtm.EventManager {
+	return lp.mgr
+}
+
+// route is the ScheduleObserver of the LP's EventManager that moves an event acting on an
+// entity of another LP to that LP.  Before the run the event is scheduled on the other LP
+// directly; during it the event is sent, to be delivered at the end of the window.  An event
+// scheduled with ScheduleAfterEvent has no time yet, and stays where it is.
+func (lp *LP) route(evtmgr *evtm.EventManager, op evtm.ScheduleOp, event evtm.Event) {
+	if op != evtm.OpSchedule || event.Time.Ticks() == vrtime.InfinityTime().Ticks() {
+		return
+	}
+	to := lp.part.Owner(event.Context)
+	if to == nil || to == lp {
+		return
+	}
+	lp.part.mu.Lock()
+	running := lp.part.running
+	lp.part.mu.Unlock()
+
+	evtmgr.RemoveEvent(event.EventID)
+	offset := vrtime.CreateTime(event.Time.Ticks()-evtmgr.CurrentTicks(), event.Time.Pri())
+	if !running {
+		to.mgr.Schedule(event.Context, event.Data, event.EventHandler,
+			vrtime.CreateTime(event.Time.Ticks()-to.mgr.CurrentTicks(), event.Time.Pri()))
+		return
+	}
+	if err := lp.pt.Send(to.pt, event.Context, event.Data, event.EventHandler, offset); err != nil {
+		evtmgr.Abort(fmt.Errorf("routing event to LP %s: %w", to.name, err))
+	}
+}
@@ -0,0 +1,58 @@
+package lp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+type entity struct{ name string }
+
+// TestPartition checks that events are executed by the LP owning the entity they act on,
+// whether scheduled before the run on another LP or by a handler of another LP during it
+func TestPartition(t *testing.T) {
+	part := New()
+	left, right := part.AddLP("left", vrtime.CreateTime(10, 0)), part.AddLP("right", vrtime.CreateTime(10, 0))
+	a, b := &entity{"a"}, &entity{"b"}
+	if err := part.Assign(a, left); err != nil {
+		t.Fatal(err)
+	}
+	if err := part.Assign(b, right); err != nil {
+		t.Fatal(err)
+	}
+	if err := part.Assign([]int{1}, left); err == nil {
+		t.Error("entity that is not comparable assigned")
+	}
+
+	var trail []string
+	var bounce func(*evtm.EventManager, any, any) any
+	bounce = func(evtmgr *evtm.EventManager, context any, data any) any {
+		on := left
+		if evtmgr == right.Manager() {
+			on = right
+		}
+		trail = append(trail, fmt.Sprintf("%s@%s:%d", context.(*entity).name, on.Name(), evtmgr.CurrentTicks()))
+		next := a
+		if context == a {
+			next = b
+		}
+		if hops := data.(int); hops > 1 {
+			evtmgr.Schedule(next, hops-1, bounce, vrtime.CreateTime(10, 0))
+		}
+		return nil
+	}
+	// scheduled on the LP that does not own b, and moved before the run
+	left.Manager().Schedule(b, 4, bounce, vrtime.CreateTime(5, 0))
+	if n := left.Manager().EventList.Len(); n != 0 {
+		t.Errorf("%d events left on the LP not owning their entity", n)
+	}
+
+	if err := part.Run(vrtime.CreateTime(100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(trail); got != "[b@right:5 a@left:15 b@right:25 a@left:35]" {
+		t.Errorf("got %s, want events alternating between the LPs ten ticks apart", got)
+	}
+}
@@ -0,0 +1,293 @@
+// Package packet provides building blocks for network models scheduled through an
+// [evtm.EventManager]: packets, the time to transmit them at a link's rate, queues with
+// drop-tail and RED admission, links that serialize and propagate packets, and per-flow
+// statistics.
+package packet
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Packet is a frame or packet travelling through a network model
+type Packet struct {
+	ID      int            // identifier chosen by the model
+	Flow    string         // flow the packet belongs to, for statistics
+	Size    int            // size in bytes
+	Created vrtime.Time    // time the packet entered the network
+	Meta    map[string]any // model-specific information, e.g., headers
+}
+
+// TransmissionTime returns the time taken to put size bytes onto a link
+// with the given rate, in bits per second
+func TransmissionTime(size int, rate float64) vrtime.Time {
+	return vrtime.SecondsToTime(float64(size) * 8 / rate)
+}
+
+// Policy decides whether a packet arriving at a queue is admitted
+type Policy interface {
+	Admit(q *Queue, pkt *Packet) bool
+}
+
+// DropTail admits packets while the queue holds fewer than Packets packets
+// and, if Bytes is positive, the packet fits within Bytes bytes
+type DropTail struct {
+	Packets int
+	Bytes   int
+}
+
+// Admit applies the drop-tail policy
+func (dt DropTail) Admit(q *Queue, pkt *Packet) bool {
+	if dt.Packets > 0 && q.Len() >= dt.Packets {
+		return false
+	}
+	return dt.Bytes <= 0 || q.Bytes()+pkt.Size <= dt.Bytes
+}
+
+// RED is random early detection.  It keeps an exponentially weighted average of the queue
+// length, in packets, with weight Weight.  Below MinThreshold every packet is admitted,
+// at or above MaxThreshold none is, and in between a packet is dropped with a probability
+// rising linearly to MaxP.  Packets are also dropped once the queue holds Limit packets.
+type RED struct {
+	MinThreshold float64
+	MaxThreshold float64
+	MaxP         float64
+	Weight       float64
+	Limit        int
+	Stream       *rand.Rand // source of the drop decisions
+	avg          float64
+}
+
+// NewRED creates a RED policy drawing its decisions from the EventManager's random number stream named "red"
+func NewRED(mgr *evtm.EventManager, minThreshold, maxThreshold, maxP, weight float64, limit int) *RED {
+	return &RED{MinThreshold: minThreshold, MaxThreshold: maxThreshold, MaxP: maxP,
+		Weight: weight, Limit: limit, Stream: mgr.RandStream("red")}
+}
+
+// Admit applies the RED policy
+func (red *RED) Admit(q *Queue, pkt *Packet) bool {
+	red.avg = (1-red.Weight)*red.avg + red.Weight*float64(q.Len())
+	switch {
+	case red.Limit > 0 && q.Len() >= red.Limit:
+		return false
+	case red.avg < red.MinThreshold:
+		return true
+	case red.avg >= red.MaxThreshold:
+		return false
+	}
+	p := red.MaxP * (red.avg - red.MinThreshold) / (red.MaxThreshold - red.MinThreshold)
+	return red.Stream.Float64() >= p
+}
+
+// Average returns the average queue length RED bases its decisions on
+func (red *RED) Average() float64 {
+	return red.avg
+}
+
+// Queue is a first-in first-out queue of packets with an admission policy
+type Queue struct {
+	policy Policy
+	pkts   []*Packet
+	bytes  int
+}
+
+// NewQueue creates an empty queue admitting packets by policy; a nil policy admits every packet
+func NewQueue(policy Policy) *Queue {
+	return &Queue{policy: policy}
+}
+
+// Len returns the number of packets in the queue
+func (q *Queue) Len() int {
+	return len(q.pkts)
+}
+
+// Bytes returns the number of bytes in the queue
+func (q *Queue) Bytes() int {
+	return q.bytes
+}
+
+// Push offers a packet to the queue, returning false if the policy drops it
+func (q *Queue) Push(pkt *Packet) bool {
+	if q.policy != nil && !q.policy.Admit(q, pkt) {
+		return false
+	}
+	q.pkts = append(q.pkts, pkt)
+	q.bytes += pkt.Size
+	return true
+}
+
+// Pop removes and returns the packet at the head of the queue, nil if it is empty
+func (q *Queue) Pop() *Packet {
+	if len(q.pkts) == 0 {
+		return nil
+	}
+	pkt := q.pkts[0]
+	q.pkts[0] = nil
+	q.pkts = q.pkts[1:]
+	q.bytes -= pkt.Size
+	return pkt
+}
+
+// FlowStats accumulates the statistics of one flow
+type FlowStats struct {
+	Sent      int         // packets offered to a link
+	SentBytes int         // bytes offered to a link
+	Delivered int         // packets delivered
+	Bytes     int         // bytes delivered
+	Dropped   int         // packets dropped
+	DelaySum  vrtime.Time // sum of the delays, creation to delivery, of the packets delivered
+	MinDelay  vrtime.Time
+	MaxDelay  vrtime.Time
+	First     vrtime.Time // time of the first delivery
+	Last      vrtime.Time // time of the last delivery
+}
+
+// MeanDelay returns the mean delay of the packets delivered, in seconds
+func (fs *FlowStats) MeanDelay() float64 {
+	if fs.Delivered == 0 {
+		return 0
+	}
+	return fs.DelaySum.Seconds() / float64(fs.Delivered)
+}
+
+// Throughput returns the rate of delivery between the first and last delivery, in bits per second
+func (fs *FlowStats) Throughput() float64 {
+	span := fs.Last.Seconds() - fs.First.Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(fs.Bytes) * 8 / span
+}
+
+// Stats holds the statistics of a set of flows, by name
+type Stats struct {
+	flows map[string]*FlowStats
+}
+
+// NewStats creates an empty set of flow statistics
+func NewStats() *Stats {
+	return &Stats{flows: make(map[string]*FlowStats)}
+}
+
+// Flow returns the statistics of the named flow, creating them on first use
+func (st *Stats) Flow(name string) *FlowStats {
+	fs, present := st.flows[name]
+	if !present {
+		fs = &FlowStats{MinDelay: vrtime.CreateTime(math.MaxInt64, 0)}
+		st.flows[name] = fs
+	}
+	return fs
+}
+
+// Flows returns the statistics of all flows, by name
+func (st *Stats) Flows() map[string]*FlowStats {
+	return st.flows
+}
+
+// sent records a packet offered to a link
+func (st *Stats) sent(pkt *Packet) {
+	fs := st.Flow(pkt.Flow)
+	fs.Sent += 1
+	fs.SentBytes += pkt.Size
+}
+
+// dropped records a packet dropped
+func (st *Stats) dropped(pkt *Packet) {
+	st.Flow(pkt.Flow).Dropped += 1
+}
+
+// delivered records a packet delivered at time now
+func (st *Stats) delivered(pkt *Packet, now vrtime.Time) {
+	fs := st.Flow(pkt.Flow)
+	delay := vrtime.CreateTime(now.Ticks()-pkt.Created.Ticks(), 0)
+	if fs.Delivered == 0 {
+		fs.First = now
+	}
+	fs.Delivered += 1
+	fs.Bytes += pkt.Size
+	fs.DelaySum = vrtime.CreateTime(fs.DelaySum.Ticks()+delay.Ticks(), 0)
+	if delay.Ticks() < fs.MinDelay.Ticks() {
+		fs.MinDelay = delay
+	}
+	if delay.Ticks() > fs.MaxDelay.Ticks() {
+		fs.MaxDelay = delay
+	}
+	fs.Last = now
+}
+
+// Link transmits packets one at a time at its rate, queueing those that arrive while it is
+// busy, and delivers each a propagation delay after its transmission ends.
+type Link struct {
+	Rate    float64     // bits per second
+	Delay   vrtime.Time // propagation delay
+	Queue   *Queue      // packets waiting to be transmitted
+	Stats   *Stats      // statistics of the flows through the link, nil for none
+	Deliver func(mgr *evtm.EventManager, pkt *Packet)
+
+	mgr  *evtm.EventManager
+	busy bool
+}
+
+// NewLink creates an idle link on mgr with the given rate, propagation delay, and
+// queue admission policy, calling deliver as each packet arrives at the far end
+func NewLink(mgr *evtm.EventManager, rate float64, delay vrtime.Time, policy Policy,
+	deliver func(mgr *evtm.EventManager, pkt *Packet)) *Link {
+	return &Link{Rate: rate, Delay: delay, Queue: NewQueue(policy), Deliver: deliver, mgr: mgr}
+}
+
+// Send offers a packet to the link, returning false if the queue drops it
+func (lk *Link) Send(pkt *Packet) bool {
+	if lk.Stats != nil {
+		lk.Stats.sent(pkt)
+	}
+	if !lk.busy {
+		lk.transmit(pkt)
+		return true
+	}
+	if !lk.Queue.Push(pkt) {
+		if lk.Stats != nil {
+			lk.Stats.dropped(pkt)
+		}
+		return false
+	}
+	return true
+}
+
+// Busy returns true while the link is transmitting
+func (lk *Link) Busy() bool {
+	return lk.busy
+}
+
+// transmit starts the transmission of a packet
+func (lk *Link) transmit(pkt *Packet) {
+	lk.busy = true
+	lk.mgr.Schedule(lk, pkt, transmitted, TransmissionTime(pkt.Size, lk.Rate))
+}
+
+// transmitted is the handler of the end of a transmission
+func transmitted(mgr *evtm.EventManager, context any, data any) any {
+	lk := context.(*Link)
+	mgr.Schedule(lk, data, arrived, lk.Delay)
+	if next := lk.Queue.Pop(); next != nil {
+		lk.transmit(next)
+	} else {
+		lk.busy = false
+	}
+	return nil
+}
+
+// arrived is the handler of the arrival of a packet at the far end of the link
+func arrived(mgr *evtm.EventManager, context any, data any) any {
+	lk := context.(*Link)
+	pkt := data.(*Packet)
+	if lk.Stats != nil {
+		lk.Stats.delivered(pkt, mgr.CurrentTime())
+	}
+	if lk.Deliver != nil {
+		lk.Deliver(mgr, pkt)
+	}
+	return nil
+}
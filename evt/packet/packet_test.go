@@ -0,0 +1,43 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestLink checks that a link serializes a burst at its rate, drops what its drop-tail queue
+// cannot hold, delivers the rest a propagation delay later, and counts all of it
+func TestLink(t *testing.T) {
+	mgr := evtm.New()
+	var arrivals []int64
+	lk := NewLink(mgr, 8000, vrtime.SecondsToTime(0.01), DropTail{Packets: 2},
+		func(mgr *evtm.EventManager, pkt *Packet) { arrivals = append(arrivals, mgr.CurrentTicks()) })
+	lk.Stats = NewStats()
+
+	// each 100 byte packet takes 0.1s to transmit at 8000 bits per second
+	admitted := 0
+	for id := 0; id < 5; id++ {
+		if lk.Send(&Packet{ID: id, Flow: "burst", Size: 100}) {
+			admitted++
+		}
+	}
+	mgr.Run(10)
+
+	if admitted != 3 || len(arrivals) != 3 {
+		t.Fatalf("admitted %d packets and delivered %d, want 3 of each", admitted, len(arrivals))
+	}
+	for idx, at := range arrivals {
+		if want := vrtime.SecondsToTicks(0.1*float64(idx+1) + 0.01); at != want {
+			t.Errorf("packet %d delivered at %d, want %d", idx, at, want)
+		}
+	}
+	fs := lk.Stats.Flow("burst")
+	if fs.Sent != 5 || fs.Dropped != 2 || fs.Delivered != 3 || fs.Bytes != 300 {
+		t.Errorf("stats %+v, want 5 sent, 2 dropped, 3 delivered of 300 bytes", fs)
+	}
+	if fs.MaxDelay.Ticks() != arrivals[2] || lk.Busy() || lk.Queue.Len() != 0 {
+		t.Errorf("max delay %d, busy %v, queue %d; want %d, idle and empty", fs.MaxDelay.Ticks(), lk.Busy(), lk.Queue.Len(), arrivals[2])
+	}
+}
@@ -0,0 +1,258 @@
+// Package proc provides process-oriented simulation on top of an [evtm.EventManager], in the
+// style of SimPy.
+//
+// A model process is written as straight-line code that runs on its own goroutine and calls
+// Hold to let virtual time pass, WaitFor to wait until a condition on the model holds, and
+// Interrupt to break another process out of its wait.  The package turns each of these into
+// events: a process runs only while the EventManager dispatches one of its events, and the
+// thread running the EventManager waits until the process parks again, so exactly one of the
+// processes and the event handlers runs at any moment and a run is as deterministic as one
+// written with event handlers.
+package proc
+
+import (
+	"fmt"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// Env holds the processes of a model running on an EventManager.  Its methods, and those of
+// its processes, are called by event handlers and process bodies, not by other goroutines.
+type Env struct {
+	mgr     *evtm.EventManager
+	waiters []*Process // processes in WaitFor, in the order they began waiting
+	procs   []*Process
+}
+
+// phase is the stage of the life of a Process
+type phase int
+
+const (
+	created  phase = iota // started but not yet run
+	running               // its body is executing
+	parked                // in Hold or WaitFor, or woken and due to resume
+	finished              // its body has returned
+)
+
+// Process is a model process, whose body runs on its own goroutine
+type Process struct {
+	env      *Env
+	name     string
+	body     func(*Process)
+	phase    phase
+	resumeID int         // event that resumes the process, evtq.InvalidEventID if none
+	cond     func() bool // condition of WaitFor, nil if not waiting on one
+	wake     chan wakeup
+	parking  chan struct{}
+	err      error // panic of the body, reported by aborting the EventManager
+}
+
+// wakeup is given to a parked process as it resumes
+type wakeup struct {
+	interrupt *Interrupted
+	kill      bool
+}
+
+// Interrupted is returned by Hold or WaitFor when another process or handler interrupts the
+// process waiting (see Interrupt)
+type Interrupted struct {
+	Cause any         // cause given to Interrupt
+	Time  vrtime.Time // time of the interrupt
+}
+
+// Error describes the interrupt
+func (ir *Interrupted) Error() string {
+	return fmt.Sprintf("interrupted at %g: %v", ir.Time.Seconds(), ir.Cause)
+}
+
+// killed is the panic with which Stop unwinds a parked process
+type killed struct{}
+
+// NewEnv creates an Env for the processes of a model running on mgr
+func NewEnv(mgr *evtm.EventManager) *Env {
+	return &Env{mgr: mgr}
+}
+
+// Manager returns the EventManager running the processes
+func (env *Env) Manager() *evtm.EventManager {
+	return env.mgr
+}
+
+// Start creates a process named name whose body begins at the current time, after the events
+// already scheduled for it
+func (env *Env) Start(name string, body func(*Process)) *Process {
+	pr := &Process{env: env, name: name, body: body, wake: make(chan wakeup), parking: make(chan struct{})}
+	env.procs = append(env.procs, pr)
+	pr.resumeID, _ = env.mgr.Schedule(pr, wakeup{}, env.resume, vrtime.CreateTime(0, 0))
+	return pr
+}
+
+// resume is the handler of the events that run a process until it next parks
+func (env *Env) resume(evtmgr *evtm.EventManager, context any, data any) any {
+	pr := context.(*Process)
+	pr.resumeID = evtq.InvalidEventID
+	if pr.phase == created {
+		pr.phase = running
+		go pr.run()
+	} else {
+		pr.phase = running
+		pr.wake <- data.(wakeup)
+	}
+	<-pr.parking
+
+	if pr.phase == finished {
+		env.forget(pr)
+	}
+	if pr.err != nil {
+		evtmgr.Abort(pr.err)
+		return nil
+	}
+	env.Signal()
+	return nil
+}
+
+// forget drops a finished process from the processes Stop must end
+func (env *Env) forget(pr *Process) {
+	for idx, known := range env.procs {
+		if known == pr {
+			env.procs = append(env.procs[:idx], env.procs[idx+1:]...)
+			return
+		}
+	}
+}
+
+// run executes the body of the process on its goroutine
+func (pr *Process) run() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if _, stopped := recovered.(killed); !stopped {
+				pr.err = fmt.Errorf("process %s panicked: %v", pr.name, recovered)
+			}
+		}
+		pr.phase = finished
+		pr.parking <- struct{}{}
+	}()
+	pr.body(pr)
+}
+
+// park hands control back to the EventManager until the process is resumed
+func (pr *Process) park() error {
+	pr.phase = parked
+	pr.parking <- struct{}{}
+	woken := <-pr.wake
+	if woken.kill {
+		panic(killed{})
+	}
+	if woken.interrupt != nil {
+		return woken.interrupt
+	}
+	return nil
+}
+
+// Hold lets the process wait for d of virtual time.  It returns nil once d has passed, or an
+// *Interrupted if the process is interrupted first.
+func (pr *Process) Hold(d vrtime.Time) error {
+	pr.resumeID, _ = pr.env.mgr.Schedule(pr, wakeup{}, pr.env.resume, d)
+	return pr.park()
+}
+
+// WaitFor lets the process wait until cond holds, returning at once if it already does.
+// Conditions are evaluated whenever a process parks or finishes, and when Signal is called,
+// in the order the processes began waiting.  A process woken by its condition checks it again
+// as it resumes, and waits on if a process resumed before it, e.g., one waiting on the same
+// condition, has made it false.  WaitFor returns an *Interrupted if the process is
+// interrupted first.
+func (pr *Process) WaitFor(cond func() bool) error {
+	for !cond() {
+		pr.cond = cond
+		pr.env.waiters = append(pr.env.waiters, pr)
+		if err := pr.park(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Signal evaluates the conditions of the processes in WaitFor, and resumes, at the current
+// time, those whose conditions hold.  An event handler that changes the state of the model
+// calls it to let waiting processes see the change.
+func (env *Env) Signal() {
+	waiting := env.waiters[:0]
+	for _, pr := range env.waiters {
+		if !pr.cond() {
+			waiting = append(waiting, pr)
+			continue
+		}
+		pr.cond = nil
+		pr.resumeID, _ = env.mgr.Schedule(pr, wakeup{}, env.resume, vrtime.CreateTime(0, 0))
+	}
+	env.waiters = waiting
+}
+
+// Interrupt interrupts pr, waiting in Hold or WaitFor, which then returns an *Interrupted with
+// the given cause at the current time.  It returns false if pr is not waiting, as when it is
+// the process calling, has not begun, or has finished.
+func (pr *Process) Interrupt(cause any) bool {
+	if pr.phase != parked {
+		return false
+	}
+	env := pr.env
+	if pr.cond != nil {
+		for idx, waiter := range env.waiters {
+			if waiter == pr {
+				env.waiters = append(env.waiters[:idx], env.waiters[idx+1:]...)
+				break
+			}
+		}
+		pr.cond = nil
+	}
+	if pr.resumeID != evtq.InvalidEventID {
+		env.mgr.RemoveEvent(pr.resumeID)
+	}
+	interrupt := &Interrupted{Cause: cause, Time: env.mgr.CurrentTime()}
+	pr.resumeID, _ = env.mgr.Schedule(pr, wakeup{interrupt: interrupt}, env.resume, vrtime.CreateTime(0, 0))
+	return true
+}
+
+// Name returns the name of the process
+func (pr *Process) Name() string {
+	return pr.name
+}
+
+// Env returns the Env of the process
+func (pr *Process) Env() *Env {
+	return pr.env
+}
+
+// Now returns the current virtual time
+func (pr *Process) Now() vrtime.Time {
+	return pr.env.mgr.CurrentTime()
+}
+
+// Done returns true once the body of the process has returned
+func (pr *Process) Done() bool {
+	return pr.phase == finished
+}
+
+// Stop ends every process that has not finished, unwinding the goroutines of those parked,
+// whose deferred calls run, and removing their pending events.  It is called once the run is
+// over, or by a handler to end the model.
+func (env *Env) Stop() {
+	for _, pr := range env.procs {
+		if pr.resumeID != evtq.InvalidEventID {
+			env.mgr.RemoveEvent(pr.resumeID)
+			pr.resumeID = evtq.InvalidEventID
+		}
+		switch pr.phase {
+		case created:
+			pr.phase = finished
+		case parked:
+			pr.wake <- wakeup{kill: true}
+			<-pr.parking
+		}
+	}
+	env.waiters = nil
+	env.procs = nil
+}
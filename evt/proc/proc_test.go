@@ -0,0 +1,61 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestProcesses checks that Hold lets virtual time pass, that WaitFor resumes a process once
+// its condition holds, that Interrupt breaks a process out of its Hold, and that Stop unwinds
+// a process still waiting
+func TestProcesses(t *testing.T) {
+	mgr := evtm.New()
+	env := NewEnv(mgr)
+	var trail []string
+	note := func(pr *Process, what string) {
+		trail = append(trail, fmt.Sprintf("%s %s@%d", pr.Name(), what, pr.Now().Ticks()))
+	}
+
+	made := 0
+	sleeper := env.Start("sleeper", func(pr *Process) {
+		err := pr.Hold(vrtime.CreateTime(1000, 0))
+		var ir *Interrupted
+		if errors.As(err, &ir) {
+			note(pr, fmt.Sprint(ir.Cause))
+		}
+	})
+	env.Start("producer", func(pr *Process) {
+		for made < 3 {
+			pr.Hold(vrtime.CreateTime(10, 0))
+			made++
+			note(pr, "made")
+		}
+		sleeper.Interrupt("wake")
+	})
+	env.Start("consumer", func(pr *Process) {
+		pr.WaitFor(func() bool { return made >= 2 })
+		note(pr, "saw 2")
+	})
+	unwound := false
+	stuck := env.Start("stuck", func(pr *Process) {
+		defer func() { unwound = true }()
+		pr.WaitFor(func() bool { return false })
+	})
+
+	mgr.Run(1)
+	want := "[producer made@10 producer made@20 consumer saw 2@20 producer made@30 sleeper wake@30]"
+	if got := fmt.Sprint(trail); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if !sleeper.Done() || stuck.Done() {
+		t.Errorf("sleeper done %v, stuck done %v; want true, false", sleeper.Done(), stuck.Done())
+	}
+	env.Stop()
+	if !unwound || stuck.Interrupt("late") {
+		t.Error("process still waiting not unwound by Stop")
+	}
+}
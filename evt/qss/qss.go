@@ -0,0 +1,174 @@
+// Package qss supports quantized-state system (QSS) simulation on an [evtm.EventManager].
+//
+// In QSS each continuous variable is represented by a quantized value that changes in
+// steps of a fixed quantum.  A variable's derivative is computed from the quantized values
+// of the variables it depends on, and so is constant between their changes; the variable
+// schedules its own next event at the time its continuous value will have moved a quantum
+// away from its quantized value.  When that event occurs the variable is requantized, and
+// every variable whose derivative depends on it has its derivative recomputed and its next
+// event rescheduled.  This package keeps that bookkeeping, the first-order method (QSS1),
+// on top of the EventManager, with one pending event per variable.
+package qss
+
+import (
+	"math"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/evtq"
+	"github.com/iti/evt/vrtime"
+)
+
+// Model is a set of variables simulated by QSS.  Its methods are meant to be called
+// by the thread running its EventManager, and are not safe for concurrent use.
+type Model struct {
+	mgr     *evtm.EventManager
+	vars    []*Variable
+	started bool
+}
+
+// Variable is a continuous variable of a Model
+type Variable struct {
+	// OnQuantum, if not nil, is called each time the variable is requantized,
+	// e.g., to record its trajectory.
+	OnQuantum func(v *Variable)
+
+	model      *Model
+	name       string
+	x          float64        // continuous value at time tx
+	tx         int64          // time, in ticks, of the value in x
+	q          float64        // quantized value
+	dq         float64        // quantum
+	dx         float64        // derivative, constant since tx
+	deriv      func() float64 // computes the derivative from quantized values
+	dependents []*Variable    // variables whose derivatives read this variable's quantized value
+	eventID    int            // identifier of the pending requantization, evtq.InvalidEventID if none
+}
+
+// New creates a Model with no variables, whose events are scheduled on mgr
+func New(mgr *evtm.EventManager) *Model {
+	return &Model{mgr: mgr}
+}
+
+// AddVariable adds a variable with initial value x0 and the given quantum.  deriv computes
+// the variable's derivative, in units per second, and should read only the quantized values
+// (see Quantized) of variables, including this one, declared to it by DependsOn.
+func (m *Model) AddVariable(name string, x0 float64, quantum float64, deriv func() float64) *Variable {
+	v := &Variable{model: m, name: name, x: x0, tx: m.mgr.CurrentTicks(), q: x0,
+		dq: math.Abs(quantum), deriv: deriv, eventID: evtq.InvalidEventID}
+	m.vars = append(m.vars, v)
+	if m.started {
+		v.dx = v.deriv()
+		v.schedule()
+	}
+	return v
+}
+
+// DependsOn declares that the derivative of v reads the quantized values of the given
+// variables, so that it is recomputed whenever any of them changes.
+func (v *Variable) DependsOn(inputs ...*Variable) {
+	for _, input := range inputs {
+		if input != v {
+			input.dependents = append(input.dependents, v)
+		}
+	}
+}
+
+// Start computes the derivatives of all variables and schedules their first events.
+// Variables added later are started as they are added.
+func (m *Model) Start() {
+	m.started = true
+	for _, v := range m.vars {
+		v.dx = v.deriv()
+		v.schedule()
+	}
+}
+
+// Name returns the name of the variable
+func (v *Variable) Name() string {
+	return v.name
+}
+
+// Quantized returns the quantized value of the variable
+func (v *Variable) Quantized() float64 {
+	return v.q
+}
+
+// Value returns the continuous value of the variable at the current time
+func (v *Variable) Value() float64 {
+	return v.x + v.dx*vrtime.TicksToSeconds(v.model.mgr.CurrentTicks()-v.tx)
+}
+
+// Derivative returns the current derivative of the variable
+func (v *Variable) Derivative() float64 {
+	return v.dx
+}
+
+// NextEventID returns the identifier of the variable's pending requantization,
+// or evtq.InvalidEventID if its derivative is zero and it has none.
+func (v *Variable) NextEventID() int {
+	return v.eventID
+}
+
+// Set changes the value of the variable from outside the Model, e.g., an input driven by a
+// discrete event.  The variable is requantized at the new value and its dependents updated.
+func (v *Variable) Set(x float64) {
+	v.advance()
+	v.x = x
+	v.requantize()
+}
+
+// advance brings the continuous value of the variable up to the current time
+func (v *Variable) advance() {
+	now := v.model.mgr.CurrentTicks()
+	v.x += v.dx * vrtime.TicksToSeconds(now-v.tx)
+	v.tx = now
+}
+
+// requantize takes the continuous value as the quantized value, recomputes the derivative of
+// the variable and of its dependents, and reschedules their requantizations.
+func (v *Variable) requantize() {
+	v.q = v.x
+	if v.OnQuantum != nil {
+		v.OnQuantum(v)
+	}
+	if !v.model.started {
+		return
+	}
+	v.dx = v.deriv()
+	v.schedule()
+	for _, dep := range v.dependents {
+		dep.advance()
+		dep.dx = dep.deriv()
+		dep.schedule()
+	}
+}
+
+// schedule places the variable's next requantization at the time its continuous value,
+// moving at its present derivative, will be a quantum from its quantized value.
+func (v *Variable) schedule() {
+	if v.eventID != evtq.InvalidEventID {
+		v.model.mgr.RemoveEvent(v.eventID)
+		v.eventID = evtq.InvalidEventID
+	}
+	if v.dx == 0 {
+		return
+	}
+	boundary := v.q + v.dq
+	if v.dx < 0 {
+		boundary = v.q - v.dq
+	}
+	ticks := int64(1)
+	if dt := (boundary - v.x) / v.dx; dt > 0 {
+		ticks = int64(math.Min(math.Ceil(dt/vrtime.TicksToSeconds(1)), math.MaxInt64/4))
+	}
+	v.eventID, _ = v.model.mgr.Schedule(v, nil, requantizeEvent, vrtime.CreateTime(ticks, 0))
+}
+
+// requantizeEvent is the handler of a variable's requantization
+func requantizeEvent(mgr *evtm.EventManager, context any, data any) any {
+	v := context.(*Variable)
+	v.eventID = evtq.InvalidEventID
+	v.advance()
+	v.requantize()
+	return nil
+}
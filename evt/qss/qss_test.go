@@ -0,0 +1,34 @@
+package qss
+
+import (
+	"math"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+)
+
+// TestDecay checks QSS1 on x' = -x, y' = x against the exact solution, and that x is
+// requantized about once per quantum of change
+func TestDecay(t *testing.T) {
+	mgr := evtm.New()
+	model := New(mgr)
+	var x, y *Variable
+	x = model.AddVariable("x", 1, 0.01, func() float64 { return -x.Quantized() })
+	y = model.AddVariable("y", 0, 0.01, func() float64 { return x.Quantized() })
+	x.DependsOn(x)
+	y.DependsOn(x)
+	quanta := 0
+	x.OnQuantum = func(v *Variable) { quanta++ }
+	model.Start()
+	mgr.RunUntil(1)
+
+	if got, want := x.Value(), math.Exp(-1); math.Abs(got-want) > 0.02 {
+		t.Errorf("x(1) = %g, want %g", got, want)
+	}
+	if got, want := y.Value(), 1-math.Exp(-1); math.Abs(got-want) > 0.02 {
+		t.Errorf("y(1) = %g, want %g", got, want)
+	}
+	if quanta < 60 || quanta > 66 {
+		t.Errorf("x requantized %d times, want about 63", quanta)
+	}
+}
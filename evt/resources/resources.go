@@ -0,0 +1,211 @@
+// Package resources provides the shared resources of queueing models, driven by an
+// [evtm.EventManager]: a Resource of identical servers, a Store of items, and a Container
+// of a continuous quantity.
+//
+// A request that cannot be met at once waits in a first-come, first-served queue, and the
+// handler given with a request is scheduled as an event, at the current time, once the request
+// is met.  Each resource keeps time-weighted statistics of its queue and of its occupancy.
+// Like the EventManager's handlers, the methods are called by the thread running the model.
+package resources
+
+import (
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// level tracks the time-weighted average of a quantity that changes at discrete times
+type level struct {
+	value float64
+	max   float64
+	since int64   // tick of the last change
+	start int64   // tick at which tracking began
+	area  float64 // integral of the value over ticks, up to since
+}
+
+// set records that the quantity changes to value at the tick now
+func (lv *level) set(now int64, value float64) {
+	lv.area += lv.value * float64(now-lv.since)
+	lv.since = now
+	lv.value = value
+	if value > lv.max {
+		lv.max = value
+	}
+}
+
+// mean returns the time-weighted average of the quantity up to the tick now
+func (lv *level) mean(now int64) float64 {
+	elapsed := now - lv.start
+	if elapsed <= 0 {
+		return lv.value
+	}
+	return (lv.area + lv.value*float64(now-lv.since)) / float64(elapsed)
+}
+
+// request is a request waiting in the queue of a resource
+type request struct {
+	id      int
+	context any
+	data    any
+	handler evtm.EventHandlerFunction
+	amount  float64 // quantity asked of a Container
+	since   int64   // tick at which the request was made
+}
+
+// queue is a first-come, first-served queue of requests
+type queue struct {
+	reqs   []request
+	nextID int
+	length level
+	waited int64 // ticks waited by the requests taken from the queue
+	served int   // requests taken from the queue, or met at once
+}
+
+// add places a request at the back of the queue, returning its identifier
+func (qu *queue) add(now int64, req request) int {
+	qu.nextID += 1
+	req.id = qu.nextID
+	req.since = now
+	qu.reqs = append(qu.reqs, req)
+	qu.length.set(now, float64(len(qu.reqs)))
+	return req.id
+}
+
+// take removes the request at the front of the queue
+func (qu *queue) take(now int64) request {
+	req := qu.reqs[0]
+	qu.reqs = qu.reqs[1:]
+	qu.length.set(now, float64(len(qu.reqs)))
+	qu.waited += now - req.since
+	qu.served += 1
+	return req
+}
+
+// cancel removes the request with the given identifier, returning false if it is not queued
+func (qu *queue) cancel(now int64, id int) bool {
+	for idx, req := range qu.reqs {
+		if req.id == id {
+			qu.reqs = append(qu.reqs[:idx], qu.reqs[idx+1:]...)
+			qu.length.set(now, float64(len(qu.reqs)))
+			return true
+		}
+	}
+	return false
+}
+
+// meanWait returns the mean wait of the requests served, in seconds
+func (qu *queue) meanWait() float64 {
+	if qu.served == 0 {
+		return 0
+	}
+	return vrtime.TicksToSeconds(qu.waited) / float64(qu.served)
+}
+
+// Stats summarizes the use of a resource since it was created
+type Stats struct {
+	Served    int     // requests met
+	Waiting   int     // requests in the queue
+	MeanQueue float64 // time-weighted mean length of the queue
+	MaxQueue  int     // greatest length of the queue
+	MeanWait  float64 // mean time in seconds a request met waited, including those met at once
+	MeanLevel float64 // time-weighted mean of the units in use, items held, or quantity held
+	MaxLevel  float64 // greatest units in use, items held, or quantity held
+	// Utilization is MeanLevel over the capacity, zero for an unbounded Store
+	Utilization float64
+}
+
+// stats fills in Stats from the queue of a resource and the level of its occupancy
+func stats(now int64, qu *queue, occupancy *level, capacity float64) Stats {
+	st := Stats{Served: qu.served, Waiting: len(qu.reqs), MeanQueue: qu.length.mean(now),
+		MaxQueue: int(qu.length.max), MeanWait: qu.meanWait(),
+		MeanLevel: occupancy.mean(now), MaxLevel: occupancy.max}
+	if capacity > 0 {
+		st.Utilization = st.MeanLevel / capacity
+	}
+	return st
+}
+
+// grant schedules the handler of a request met, at the current time
+func grant(mgr *evtm.EventManager, req request) {
+	mgr.Schedule(req.context, req.data, req.handler, vrtime.CreateTime(0, 0))
+}
+
+// Resource is a pool of identical servers, each held by one request at a time
+type Resource struct {
+	mgr      *evtm.EventManager
+	name     string
+	capacity int
+	inUse    level
+	waiting  queue
+}
+
+// NewResource creates a Resource of capacity servers, all free
+func NewResource(mgr *evtm.EventManager, name string, capacity int) *Resource {
+	now := mgr.CurrentTicks()
+	rs := &Resource{mgr: mgr, name: name, capacity: capacity}
+	rs.inUse = level{since: now, start: now}
+	rs.waiting.length = level{since: now, start: now}
+	return rs
+}
+
+// Name returns the name of the Resource
+func (rs *Resource) Name() string {
+	return rs.name
+}
+
+// Capacity returns the number of servers of the Resource
+func (rs *Resource) Capacity() int {
+	return rs.capacity
+}
+
+// InUse returns the number of servers held
+func (rs *Resource) InUse() int {
+	return int(rs.inUse.value)
+}
+
+// QueueLen returns the number of requests waiting for a server
+func (rs *Resource) QueueLen() int {
+	return len(rs.waiting.reqs)
+}
+
+// Acquire requests a server, on which handler is scheduled with context and data once one is
+// free, at once if one is free now.  The server is held until Release is called.  Acquire
+// returns an identifier of the request, by which CancelRequest withdraws it while it waits,
+// or zero if the request was met at once.
+func (rs *Resource) Acquire(context any, data any, handler evtm.EventHandlerFunction) int {
+	now := rs.mgr.CurrentTicks()
+	req := request{context: context, data: data, handler: handler}
+	if int(rs.inUse.value) < rs.capacity && len(rs.waiting.reqs) == 0 {
+		rs.inUse.set(now, rs.inUse.value+1)
+		rs.waiting.served += 1
+		grant(rs.mgr, req)
+		return 0
+	}
+	return rs.waiting.add(now, req)
+}
+
+// CancelRequest withdraws a request waiting for a server, returning false if it is not waiting,
+// as when it has been granted
+func (rs *Resource) CancelRequest(id int) bool {
+	return rs.waiting.cancel(rs.mgr.CurrentTicks(), id)
+}
+
+// Release frees a server, which passes at once to the request at the front of the queue, if any.
+// It returns false, changing nothing, if no server is held.
+func (rs *Resource) Release() bool {
+	now := rs.mgr.CurrentTicks()
+	if rs.inUse.value < 1 {
+		return false
+	}
+	if len(rs.waiting.reqs) > 0 {
+		grant(rs.mgr, rs.waiting.take(now))
+		return true
+	}
+	rs.inUse.set(now, rs.inUse.value-1)
+	return true
+}
+
+// Stats returns the statistics of the Resource up to the current time, its level being the
+// number of servers in use
+func (rs *Resource) Stats() Stats {
+	return stats(rs.mgr.CurrentTicks(), &rs.waiting, &rs.inUse, float64(rs.capacity))
+}
@@ -0,0 +1,84 @@
+package resources
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// TestResource checks that requests for a single server are met in the order made, each as
+// the one before releases it, that a withdrawn request is never met, and the statistics
+func TestResource(t *testing.T) {
+	mgr := evtm.New()
+	rs := NewResource(mgr, "server", 1)
+	var started []string
+	serve := func(evtmgr *evtm.EventManager, context any, data any) any {
+		started = append(started, fmt.Sprintf("%s@%d", data, evtmgr.CurrentTicks()))
+		evtmgr.Schedule(nil, nil, func(*evtm.EventManager, any, any) any {
+			rs.Release()
+			return nil
+		}, vrtime.CreateTime(10, 0))
+		return nil
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		rs.Acquire(nil, name, serve)
+	}
+	withdrawn := rs.Acquire(nil, "d", serve)
+	if withdrawn == 0 || rs.QueueLen() != 3 || !rs.CancelRequest(withdrawn) || rs.CancelRequest(withdrawn) {
+		t.Fatalf("request %d not withdrawn once from a queue of %d", withdrawn, rs.QueueLen())
+	}
+
+	mgr.AdvanceTo(vrtime.CreateTime(30, 0))
+	if got := fmt.Sprint(started); got != "[a@0 b@10 c@20]" {
+		t.Errorf("got %s, want [a@0 b@10 c@20]", got)
+	}
+	st := rs.Stats()
+	if st.Served != 3 || st.Waiting != 0 || st.MaxQueue != 3 || st.Utilization != 1 || rs.InUse() != 0 {
+		t.Errorf("got %+v with %d in use, want 3 served by a server busy throughout", st, rs.InUse())
+	}
+	if want := vrtime.TicksToSeconds(10); st.MeanWait != want {
+		t.Errorf("got mean wait %g, want %g", st.MeanWait, want)
+	}
+	if rs.Release() {
+		t.Error("server released while none is held")
+	}
+}
+
+// TestStoreAndContainer checks that a get waits for an item and a put for room in a Store,
+// and that a Container meets a get only when it holds enough
+func TestStoreAndContainer(t *testing.T) {
+	mgr := evtm.New()
+	var got []string
+	note := func(evtmgr *evtm.EventManager, context any, data any) any {
+		got = append(got, fmt.Sprintf("%s %v@%d", context, data, evtmgr.CurrentTicks()))
+		return nil
+	}
+	st := NewStore(mgr, "shelf", 1)
+	st.Get("get", note)
+	mgr.Schedule(nil, nil, func(*evtm.EventManager, any, any) any {
+		st.Put("x", "put", note)
+		st.Put("y", "put", note)
+		st.Put("z", "put", note)
+		return nil
+	}, vrtime.CreateTime(5, 0))
+	mgr.Schedule(nil, nil, func(*evtm.EventManager, any, any) any {
+		st.Get("get", note)
+		return nil
+	}, vrtime.CreateTime(10, 0))
+	mgr.AdvanceTo(vrtime.CreateTime(20, 0))
+	want := "[put x@5 get x@5 put y@5 get y@10 put z@10]"
+	if fmt.Sprint(got) != want || st.Len() != 1 {
+		t.Errorf("got %v with %d held, want %s with 1 held", got, st.Len(), want)
+	}
+
+	got = nil
+	ct := NewContainer(mgr, "tank", 10, 4)
+	ct.Get(6, "get", note)
+	ct.Put(5, "put", note)
+	mgr.AdvanceTo(vrtime.CreateTime(30, 0))
+	if fmt.Sprint(got) != "[put 5@20 get 6@20]" || ct.Level() != 3 {
+		t.Errorf("got %v with level %g, want the get met by the put, leaving 3", got, ct.Level())
+	}
+}
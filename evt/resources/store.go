@@ -0,0 +1,252 @@
+package resources
+
+import (
+	"math"
+
+	"github.com/iti/evt/evtm"
+)
+
+// Store holds items, e.g., messages in a buffer or parts in a bin, up to a capacity.  Puts
+// wait while the Store is full and gets while it is empty, each in its own queue, and items
+// are got in the order they were put.
+type Store struct {
+	mgr      *evtm.EventManager
+	name     string
+	capacity int
+	items    []any
+	held     level
+	getters  queue
+	putters  queue
+}
+
+// NewStore creates an empty Store holding up to capacity items, any number if capacity is zero
+func NewStore(mgr *evtm.EventManager, name string, capacity int) *Store {
+	now := mgr.CurrentTicks()
+	st := &Store{mgr: mgr, name: name, capacity: capacity}
+	st.held = level{since: now, start: now}
+	st.getters.length = level{since: now, start: now}
+	st.putters.length = level{since: now, start: now}
+	return st
+}
+
+// Name returns the name of the Store
+func (st *Store) Name() string {
+	return st.name
+}
+
+// Len returns the number of items held
+func (st *Store) Len() int {
+	return len(st.items)
+}
+
+// full returns true if the Store has no room for another item
+func (st *Store) full() bool {
+	return st.capacity > 0 && len(st.items) >= st.capacity
+}
+
+// Put adds item to the Store once there is room, at once if there is room now, and then
+// schedules handler, if not nil, with context and the item as data.  It returns an identifier
+// of the put, by which CancelPut withdraws it while it waits, or zero if it was met at once.
+func (st *Store) Put(item any, context any, handler evtm.EventHandlerFunction) int {
+	now := st.mgr.CurrentTicks()
+	req := request{context: context, data: item, handler: handler}
+	if !st.full() && len(st.putters.reqs) == 0 {
+		st.putters.served += 1
+		st.store(now, req)
+		st.serve(now)
+		return 0
+	}
+	return st.putters.add(now, req)
+}
+
+// Get takes the oldest item from the Store once there is one, at once if there is one now,
+// and schedules handler with context and the item as data.  It returns an identifier of the
+// get, by which CancelGet withdraws it while it waits, or zero if it was met at once.
+func (st *Store) Get(context any, handler evtm.EventHandlerFunction) int {
+	now := st.mgr.CurrentTicks()
+	req := request{context: context, handler: handler}
+	if len(st.items) > 0 && len(st.getters.reqs) == 0 {
+		st.getters.served += 1
+		st.retrieve(now, req)
+		st.serve(now)
+		return 0
+	}
+	return st.getters.add(now, req)
+}
+
+// CancelPut withdraws a waiting put, returning false if it is not waiting
+func (st *Store) CancelPut(id int) bool {
+	return st.putters.cancel(st.mgr.CurrentTicks(), id)
+}
+
+// CancelGet withdraws a waiting get, returning false if it is not waiting
+func (st *Store) CancelGet(id int) bool {
+	return st.getters.cancel(st.mgr.CurrentTicks(), id)
+}
+
+// store adds the item of a put to the Store
+func (st *Store) store(now int64, req request) {
+	st.items = append(st.items, req.data)
+	st.held.set(now, float64(len(st.items)))
+	if req.handler != nil {
+		grant(st.mgr, req)
+	}
+}
+
+// retrieve gives the oldest item to a get
+func (st *Store) retrieve(now int64, req request) {
+	req.data = st.items[0]
+	st.items = st.items[1:]
+	st.held.set(now, float64(len(st.items)))
+	grant(st.mgr, req)
+}
+
+// serve meets waiting gets and puts for as long as the contents of the Store allow
+func (st *Store) serve(now int64) {
+	for {
+		switch {
+		case len(st.getters.reqs) > 0 && len(st.items) > 0:
+			st.retrieve(now, st.getters.take(now))
+		case len(st.putters.reqs) > 0 && !st.full():
+			st.store(now, st.putters.take(now))
+		default:
+			return
+		}
+	}
+}
+
+// Stats returns the statistics of the gets of the Store up to the current time, its level
+// being the number of items held
+func (st *Store) Stats() Stats {
+	return stats(st.mgr.CurrentTicks(), &st.getters, &st.held, float64(st.capacity))
+}
+
+// PutStats returns the statistics of the puts of the Store up to the current time
+func (st *Store) PutStats() Stats {
+	return stats(st.mgr.CurrentTicks(), &st.putters, &st.held, float64(st.capacity))
+}
+
+// Container holds a continuous quantity, e.g., fuel in a tank or charge in a battery, up to a
+// capacity.  Puts wait while they would overfill the Container and gets while they would
+// overdraw it, each in its own queue, served first-come, first-served.
+type Container struct {
+	mgr      *evtm.EventManager
+	name     string
+	capacity float64
+	held     level
+	getters  queue
+	putters  queue
+}
+
+// NewContainer creates a Container holding initial of a quantity, up to capacity, any
+// quantity if capacity is zero
+func NewContainer(mgr *evtm.EventManager, name string, capacity float64, initial float64) *Container {
+	now := mgr.CurrentTicks()
+	ct := &Container{mgr: mgr, name: name, capacity: capacity}
+	ct.held = level{value: initial, max: initial, since: now, start: now}
+	ct.getters.length = level{since: now, start: now}
+	ct.putters.length = level{since: now, start: now}
+	return ct
+}
+
+// Name returns the name of the Container
+func (ct *Container) Name() string {
+	return ct.name
+}
+
+// Level returns the quantity held
+func (ct *Container) Level() float64 {
+	return ct.held.value
+}
+
+// room returns the quantity that may be added to the Container
+func (ct *Container) room() float64 {
+	if ct.capacity <= 0 {
+		return math.Inf(1)
+	}
+	return ct.capacity - ct.held.value
+}
+
+// Put adds amount to the Container once there is room for it, at once if there is room now,
+// and then schedules handler, if not nil, with context and the amount as data.  It returns an
+// identifier of the put, by which CancelPut withdraws it while it waits, or zero if it was
+// met at once.
+func (ct *Container) Put(amount float64, context any, handler evtm.EventHandlerFunction) int {
+	now := ct.mgr.CurrentTicks()
+	req := request{context: context, data: amount, handler: handler, amount: amount}
+	if amount <= ct.room() && len(ct.putters.reqs) == 0 {
+		ct.putters.served += 1
+		ct.fill(now, req)
+		ct.serve(now)
+		return 0
+	}
+	return ct.putters.add(now, req)
+}
+
+// Get draws amount from the Container once it holds that much, at once if it does now, and
+// then schedules handler with context and the amount as data.  It returns an identifier of the
+// get, by which CancelGet withdraws it while it waits, or zero if it was met at once.
+func (ct *Container) Get(amount float64, context any, handler evtm.EventHandlerFunction) int {
+	now := ct.mgr.CurrentTicks()
+	req := request{context: context, data: amount, handler: handler, amount: amount}
+	if amount <= ct.held.value && len(ct.getters.reqs) == 0 {
+		ct.getters.served += 1
+		ct.draw(now, req)
+		ct.serve(now)
+		return 0
+	}
+	return ct.getters.add(now, req)
+}
+
+// CancelPut withdraws a waiting put, returning false if it is not waiting
+func (ct *Container) CancelPut(id int) bool {
+	removed := ct.putters.cancel(ct.mgr.CurrentTicks(), id)
+	ct.serve(ct.mgr.CurrentTicks())
+	return removed
+}
+
+// CancelGet withdraws a waiting get, returning false if it is not waiting
+func (ct *Container) CancelGet(id int) bool {
+	removed := ct.getters.cancel(ct.mgr.CurrentTicks(), id)
+	ct.serve(ct.mgr.CurrentTicks())
+	return removed
+}
+
+// fill adds the amount of a put
+func (ct *Container) fill(now int64, req request) {
+	ct.held.set(now, ct.held.value+req.amount)
+	if req.handler != nil {
+		grant(ct.mgr, req)
+	}
+}
+
+// draw takes the amount of a get
+func (ct *Container) draw(now int64, req request) {
+	ct.held.set(now, ct.held.value-req.amount)
+	grant(ct.mgr, req)
+}
+
+// serve meets waiting gets and puts, each queue in order, for as long as the quantity held allows
+func (ct *Container) serve(now int64) {
+	for {
+		switch {
+		case len(ct.getters.reqs) > 0 && ct.getters.reqs[0].amount <= ct.held.value:
+			ct.draw(now, ct.getters.take(now))
+		case len(ct.putters.reqs) > 0 && ct.putters.reqs[0].amount <= ct.room():
+			ct.fill(now, ct.putters.take(now))
+		default:
+			return
+		}
+	}
+}
+
+// Stats returns the statistics of the gets of the Container up to the current time, its level
+// being the quantity held
+func (ct *Container) Stats() Stats {
+	return stats(ct.mgr.CurrentTicks(), &ct.getters, &ct.held, ct.capacity)
+}
+
+// PutStats returns the statistics of the puts of the Container up to the current time
+func (ct *Container) PutStats() Stats {
+	return stats(ct.mgr.CurrentTicks(), &ct.putters, &ct.held, ct.capacity)
+}
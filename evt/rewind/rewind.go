@@ -0,0 +1,238 @@
+// Package rewind lets a debugger step the execution of a model backwards as well as forwards.
+//
+// A Debugger dispatches the events of a model one at a time.  Stepping back to an earlier
+// event restores the model to an earlier state and replays it forward to that event, which
+// relies on the model being deterministic, i.e., drawing its random numbers from
+// [evtm.EventManager.RandStream] under a fixed seed.  The earlier state is that of a fresh
+// model, built again, unless the model supplies a Checkpointer, in which case the Debugger
+// takes a checkpoint automatically every so many events and replays from the nearest one,
+// so that stepping back late in a long run costs the replay of at most that many events.
+package rewind
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/iti/evt/compression"
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// Build creates a fresh instance of a model: an EventManager with the model's initial
+// events scheduled, ready to run
+type Build func() *evtm.EventManager
+
+// Checkpointer saves and restores the complete state of a model, including the events
+// pending on its EventManager.  Save is called between events, while the EventManager is
+// not running.
+type Checkpointer interface {
+	// Save captures the state of the model running on mgr
+	Save(mgr *evtm.EventManager) (any, error)
+
+	// Load recreates the model in a state captured by Save, returning its EventManager
+	Load(state any) (*evtm.EventManager, error)
+}
+
+// compressed is a Checkpointer holding the states of another compressed
+type compressed struct {
+	cp Checkpointer
+	c  compression.Compressor
+}
+
+// Compressed returns a Checkpointer that holds compressed by c the states cp saves as []byte,
+// e.g., a model's encoding of itself, which over a long run of checkpoints would otherwise
+// dominate the memory a Debugger holds.  States of other types are held as they are.
+func Compressed(cp Checkpointer, c compression.Compressor) Checkpointer {
+	return compressed{cp: cp, c: c}
+}
+
+func (cc compressed) Save(mgr *evtm.EventManager) (any, error) {
+	state, err := cc.cp.Save(mgr)
+	if data, isBytes := state.([]byte); isBytes && err == nil {
+		return compression.Compress(cc.c, data)
+	}
+	return state, err
+}
+
+func (cc compressed) Load(state any) (*evtm.EventManager, error) {
+	if data, isBytes := state.([]byte); isBytes {
+		var err error
+		if state, err = compression.Decompress(data); err != nil {
+			return nil, err
+		}
+	}
+	return cc.cp.Load(state)
+}
+
+// Step describes an event dispatched by a Debugger
+type Step struct {
+	Index   int         // position of the event in the order of dispatch, from 1
+	EventID int         // identifier of the event
+	Time    vrtime.Time // time of the event
+	Handler string      // name of the event's handler
+}
+
+// String describes the step
+func (st Step) String() string {
+	return fmt.Sprintf("#%d event %d at %s handler %s", st.Index, st.EventID, st.Time.TimeStr(), st.Handler)
+}
+
+// checkpoint is a state saved by a Checkpointer, after index events had been dispatched
+type checkpoint struct {
+	index int
+	last  Step
+	state any
+}
+
+// Debugger steps the execution of a model forwards and backwards
+type Debugger struct {
+	build    Build
+	limit    float64
+	cp       Checkpointer
+	interval int
+	saved    []checkpoint // in increasing order of index
+
+	mgr    *evtm.EventManager
+	index  int  // number of events dispatched
+	target int  // number of events to have dispatched when the current run stops
+	last   Step // the most recent event dispatched
+}
+
+// Unlimited is a limit for New under which the model runs until it has no events left
+var Unlimited = vrtime.TicksToSeconds(math.MaxInt64 / 2)
+
+// New creates a Debugger of the model that build creates, which runs the model no further
+// than limit, in seconds of virtual time
+func New(build Build, limit float64) *Debugger {
+	dbg := &Debugger{build: build, limit: limit}
+	dbg.adopt(build(), Step{})
+	return dbg
+}
+
+// SetCheckpoints has the Debugger save the state of the model with cp after every interval
+// events dispatched
+func (dbg *Debugger) SetCheckpoints(cp Checkpointer, interval int) {
+	dbg.cp = cp
+	dbg.interval = interval
+	dbg.saved = nil
+}
+
+// adopt takes mgr as the model's EventManager, the last event it dispatched being last
+func (dbg *Debugger) adopt(mgr *evtm.EventManager, last Step) {
+	dbg.mgr = mgr
+	dbg.index = last.Index
+	dbg.last = last
+	mgr.AddInterceptor(func(mgr *evtm.EventManager, event *evtm.Event) {
+		dbg.index += 1
+		dbg.last = Step{Index: dbg.index, EventID: event.EventID, Time: event.Time,
+			Handler: evtm.HandlerName(event.EventHandler)}
+		if dbg.index >= dbg.target {
+			mgr.Stop()
+		}
+	})
+}
+
+// Manager returns the EventManager of the model in its current state
+func (dbg *Debugger) Manager() *evtm.EventManager {
+	return dbg.mgr
+}
+
+// Index returns the number of events dispatched
+func (dbg *Debugger) Index() int {
+	return dbg.index
+}
+
+// Last describes the most recent event dispatched.  Its Index is 0 if there is none.
+func (dbg *Debugger) Last() Step {
+	return dbg.last
+}
+
+// advance dispatches events until target have been dispatched or the run ends, and
+// returns false if it ends first.  A checkpoint is saved at each multiple of the interval.
+func (dbg *Debugger) advance(target int) (bool, error) {
+	for dbg.index < target {
+		dbg.target = target
+		if dbg.cp != nil && dbg.interval > 0 {
+			next := (dbg.index/dbg.interval + 1) * dbg.interval
+			if next < target {
+				dbg.target = next
+			}
+		}
+		before := dbg.index
+		dbg.mgr.Run(dbg.limit)
+		if err := dbg.mgr.Err(); err != nil {
+			return false, err
+		}
+		if dbg.index == before {
+			return false, nil
+		}
+		if err := dbg.save(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// save saves a checkpoint if one is due and has not already been saved
+func (dbg *Debugger) save() error {
+	if dbg.cp == nil || dbg.interval <= 0 || dbg.index%dbg.interval != 0 {
+		return nil
+	}
+	if len(dbg.saved) > 0 && dbg.saved[len(dbg.saved)-1].index >= dbg.index {
+		return nil
+	}
+	state, err := dbg.cp.Save(dbg.mgr)
+	if err != nil {
+		return fmt.Errorf("rewind: saving checkpoint at event %d: %w", dbg.index, err)
+	}
+	dbg.saved = append(dbg.saved, checkpoint{index: dbg.index, last: dbg.last, state: state})
+	return nil
+}
+
+// restore returns the model to the latest available state at or before index events
+func (dbg *Debugger) restore(index int) error {
+	for idx := len(dbg.saved) - 1; idx >= 0; idx-- {
+		if dbg.saved[idx].index <= index {
+			mgr, err := dbg.cp.Load(dbg.saved[idx].state)
+			if err != nil {
+				return fmt.Errorf("rewind: loading checkpoint at event %d: %w", dbg.saved[idx].index, err)
+			}
+			dbg.adopt(mgr, dbg.saved[idx].last)
+			return nil
+		}
+	}
+	dbg.adopt(dbg.build(), Step{})
+	return nil
+}
+
+// Forward dispatches the next event, and returns false if there is none within the limit
+func (dbg *Debugger) Forward() (bool, error) {
+	return dbg.advance(dbg.index + 1)
+}
+
+// Back returns the model to its state just after the event before the most recent one was
+// dispatched, and returns false if no event has been dispatched
+func (dbg *Debugger) Back() (bool, error) {
+	if dbg.index == 0 {
+		return false, nil
+	}
+	return true, dbg.Goto(dbg.index - 1)
+}
+
+// Goto brings the model to its state just after index events have been dispatched,
+// stepping backwards or forwards as needed.  It is an error for the run to end sooner.
+func (dbg *Debugger) Goto(index int) error {
+	if index < dbg.index {
+		if err := dbg.restore(index); err != nil {
+			return err
+		}
+	}
+	reached, err := dbg.advance(index)
+	if err != nil {
+		return err
+	}
+	if !reached {
+		return fmt.Errorf("rewind: the run ends after event %d, before event %d", dbg.index, index)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package rewind
+
+import (
+	"testing"
+
+	"github.com/iti/evt/evtm"
+	"github.com/iti/evt/vrtime"
+)
+
+// walk is the handler of a random walk, whose position is carried by its events
+func walk(mgr *evtm.EventManager, context any, data any) any {
+	step := mgr.RandStream("walk").Int63n(10) + 1
+	mgr.Schedule(nil, data.(int64)+step, walk, vrtime.CreateTime(step, 0))
+	return nil
+}
+
+// cloner is a Checkpointer that saves a model by cloning its EventManager
+type cloner struct{}
+
+func (cloner) Save(mgr *evtm.EventManager) (any, error) { return mgr.Clone(nil), nil }
+func (cloner) Load(state any) (*evtm.EventManager, error) {
+	return state.(*evtm.EventManager).Clone(nil), nil
+}
+
+// TestBack checks that stepping back through a run revisits the events stepped forward
+// through, whether replaying from the start or from checkpoints, and that checkpoints
+// spare rebuilding the model
+func TestBack(t *testing.T) {
+	for _, interval := range []int{0, 4} {
+		builds := 0
+		build := func() *evtm.EventManager {
+			builds++
+			mgr := evtm.New()
+			mgr.SetSeed(7)
+			mgr.Schedule(nil, int64(0), walk, vrtime.CreateTime(0, 0))
+			return mgr
+		}
+		dbg := New(build, Unlimited)
+		if interval > 0 {
+			dbg.SetCheckpoints(cloner{}, interval)
+		}
+
+		var forward []Step
+		for idx := 0; idx < 20; idx++ {
+			if ok, err := dbg.Forward(); !ok || err != nil {
+				t.Fatalf("interval %d: step %d forward failed: %v", interval, idx+1, err)
+			}
+			forward = append(forward, dbg.Last())
+		}
+		for idx := 18; idx >= 0; idx-- {
+			if ok, err := dbg.Back(); !ok || err != nil {
+				t.Fatalf("interval %d: step back to %d failed: %v", interval, idx+1, err)
+			}
+			if now := dbg.Manager().CurrentTime(); dbg.Last() != forward[idx] || now != forward[idx].Time {
+				t.Errorf("interval %d: stepped back to %s at %s, want %s", interval,
+					dbg.Last(), now.TimeStr(), forward[idx])
+			}
+		}
+		if want := map[int]int{0: 20, 4: 4}[interval]; builds != want {
+			t.Errorf("interval %d: model built %d times, want %d", interval, builds, want)
+		}
+	}
+}
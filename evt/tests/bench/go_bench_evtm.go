@@ -0,0 +1,42 @@
+// go_bench_evtm.go
+// Measures the dispatch throughput of an EventManager under the synthetic
+// workloads of package loadgen.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iti/evt/loadgen"
+	"github.com/iti/evt/vrtime"
+)
+
+func main() {
+	workload := flag.String("workload", "hold", "workload to run: hold, selfsimilar, or timerchurn")
+	population := flag.Int("pending", 1000, "number of pending events, sources, or timers")
+	events := flag.Int64("events", 2000000, "number of events to dispatch")
+	reps := flag.Int("reps", 5, "number of repetitions")
+	slabSize := flag.Int("slab", 0, "slab size for event allocation, 0 to allocate events individually")
+	flag.Parse()
+
+	var wl loadgen.Workload
+	switch *workload {
+	case "hold":
+		wl = loadgen.HoldModel{Pending: *population, Mean: vrtime.CreateTime(500, 0)}
+	case "selfsimilar":
+		wl = loadgen.SelfSimilar{Sources: *population, Alpha: 1.4, MinOn: vrtime.CreateTime(1000, 0),
+			MinOff: vrtime.CreateTime(1000, 0), Interarrival: vrtime.CreateTime(10, 0)}
+	case "timerchurn":
+		wl = loadgen.TimerChurn{Timers: *population, Timeout: vrtime.CreateTime(10000, 0),
+			Mean: vrtime.CreateTime(500, 0)}
+	default:
+		fmt.Println("unknown workload")
+		os.Exit(1)
+	}
+
+	for rep := 0; rep < *reps; rep++ {
+		res := loadgen.Measure(wl, *events, loadgen.Options{Seed: int64(rep), SizeHint: *population, SlabSize: *slabSize})
+		fmt.Printf("%s, population %d, events %d: %.0f events/sec\n", res.Workload, *population, res.Events, res.Rate())
+	}
+}
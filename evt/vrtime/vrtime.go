@@ -5,8 +5,10 @@
 package vrtime
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 )
 
 // Time is represented by a pair of int64s.  The primary one is
@@ -51,13 +53,50 @@ type Time struct {
 // (the frequency of the ticker) and the associated values
 // [FloatTicksPersecond] and [TickValue].
 // the frequency of the ticker. The default value is 1e7.
+// It returns false, changing nothing, if tps is not positive, and panics
+// if the time base has been frozen (see [FreezeTimeBase]).
 func SetTicksPerSecond(tps int64) bool {
+	err := ConfigureTicksPerSecond(tps)
+	if errors.Is(err, ErrTimeBaseFrozen) {
+		panic(err)
+	}
+	return err == nil
+}
+
+// ErrTimeBaseFrozen is reported by an attempt to change the time base once it is frozen
+var ErrTimeBaseFrozen = errors.New("vrtime: time base is frozen")
+
+// frozen is true once the time base may no longer change
+var frozen atomic.Bool
+
+// ConfigureTicksPerSecond is SetTicksPerSecond, returning an error rather than false if tps is
+// not positive, as every conversion would then divide by zero or invert the order of times,
+// and an error wrapping ErrTimeBaseFrozen rather than panicking if the time base is frozen
+func ConfigureTicksPerSecond(tps int64) error {
+	if tps <= 0 {
+		return fmt.Errorf("vrtime: ticks per second must be positive, not %d", tps)
+	}
+	if frozen.Load() {
+		return fmt.Errorf("setting %d ticks per second: %w", tps, ErrTimeBaseFrozen)
+	}
 	TicksPerSecond = tps
 	FloatTicksPerSecond = float64(TicksPerSecond)
 	SecondPerTick = 1.0 / FloatTicksPerSecond
 	NanoSecPerTick = int64((float64(1e9)) * SecondPerTick)
 	TickValue = 1.0 / FloatTicksPerSecond
-	return true
+	return nil
+}
+
+// FreezeTimeBase fixes the time base, so that later attempts to change it fail.  An
+// EventManager calls it when it schedules its first event, since times already computed
+// would be reinterpreted by a change.
+func FreezeTimeBase() {
+	frozen.Store(true)
+}
+
+// TimeBaseFrozen returns true once the time base has been frozen
+func TimeBaseFrozen() bool {
+	return frozen.Load()
 }
 
 // Ticks returns the primary key of a Time data structure, usually